@@ -35,7 +35,8 @@ func main() {
 	//diodeWriter := diode.NewWriter(consoleWriter, 4096, 10*time.Millisecond, func(missed int) {
 	//	fmt.Printf("Logger Dropped %d messages", missed)
 	//})
-	log.Logger = zerolog.New(zerolog.SyncWriter(consoleWriter)).With().Timestamp().Caller().Logger()
+	// status.Current.LogWriter() allows streaming log lines of running commands via API /backup/actions/{id}/stream
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(zerolog.SyncWriter(consoleWriter), status.Current.LogWriter())).With().Timestamp().Caller().Logger()
 	//zerolog.SetGlobalLevel(zerolog.Disabled)
 	//log.Logger = zerolog.New(os.Stdout).With().Timestamp().Caller().Logger()
 	stdlog.SetOutput(log.Logger)
@@ -62,6 +63,12 @@ func main() {
 			Usage:    "override any environment variable via CLI parameter",
 			Required: false,
 		},
+		cli.StringFlag{
+			Name:     "storage",
+			Usage:    "Use named remote storage from 'storages' config section instead of general->remote_storage, create_remote and watch allow comma separated list to upload one backup to several storages",
+			EnvVar:   "CLICKHOUSE_BACKUP_STORAGE",
+			Required: false,
+		},
 		cli.IntFlag{
 			Name:     "command-id",
 			Hidden:   true,
@@ -111,11 +118,11 @@ func main() {
 		{
 			Name:        "create",
 			Usage:       "Create new backup",
-			UsageText:   "clickhouse-backup create [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from-remote=<backup-name>] [-s, --schema] [--rbac] [--configs] [--skip-check-parts-columns] [--resume] <backup_name>",
+			UsageText:   "clickhouse-backup create [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from-remote=<backup-name>] [--since=<backup_name|timestamp>] [-s, --schema] [--rbac] [--configs] [--keeper] [--named-collections] [--skip-check-parts-columns] [--resume] <backup_name>",
 			Description: "Create new backup",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.CreateBackup(c.Args().First(), c.String("diff-from-remote"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("skip-check-parts-columns"), c.Bool("resume"), version, c.Int("command-id"))
+				return b.CreateBackup(c.Args().First(), c.String("diff-from-remote"), c.String("since"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("keeper"), c.Bool("named-collections"), c.Bool("skip-check-parts-columns"), c.Bool("resume"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -128,6 +135,11 @@ func main() {
 					Hidden: false,
 					Usage:  "Create incremental embedded backup or upload incremental object disk data based on other remote backup name",
 				},
+				cli.StringFlag{
+					Name:   "since",
+					Hidden: false,
+					Usage:  "Freeze and backup only parts which modification_time or max block number exceed watermark of local or remote backup name (other parts will mark as required from it and hardlink from local base backup, upload requires base backup on remote storage), or exceed timestamp like 2006-01-02T15:04:05 (other parts will skip, backup will tag as partial)",
+				},
 				cli.StringSliceFlag{
 					Name:   "partitions",
 					Hidden: false,
@@ -154,6 +166,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Backup 'clickhouse-server' configuration files",
 				},
+				cli.BoolFlag{
+					Name:   "keeper, backup-keeper",
+					Hidden: false,
+					Usage:  "Backup Keeper/ZooKeeper subtrees listed in general.keeper_paths",
+				},
+				cli.BoolFlag{
+					Name:   "named-collections, backup-named-collections",
+					Hidden: false,
+					Usage:  "Backup SQL defined named collections, secret values require display_secrets_in_show_and_select",
+				},
 				cli.BoolFlag{
 					Name:   "rbac-only",
 					Hidden: false,
@@ -179,11 +201,11 @@ func main() {
 		{
 			Name:        "create_remote",
 			Usage:       "Create and upload new backup",
-			UsageText:   "clickhouse-backup create_remote [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from=<local_backup_name>] [--diff-from-remote=<local_backup_name>] [--schema] [--rbac] [--configs] [--resumable] [--skip-check-parts-columns] <backup_name>",
+			UsageText:   "clickhouse-backup create_remote [-t, --tables=<db>.<table>] [--partitions=<partition_names>] [--diff-from=<local_backup_name>] [--diff-from-remote=<local_backup_name>] [--since=<backup_name|timestamp>] [--schema] [--rbac] [--configs] [--keeper] [--named-collections] [--resumable] [--skip-check-parts-columns] [--storage=<storage_name>[,<storage_name>]] <backup_name>",
 			Description: "Create and upload",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.CreateToRemote(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), c.Bool("skip-check-parts-columns"), version, c.Int("command-id"))
+				return b.CreateToRemote(c.Args().First(), c.Bool("delete-source"), c.String("diff-from"), c.String("diff-from-remote"), c.String("since"), c.String("t"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("keeper"), c.Bool("named-collections"), c.Bool("resume"), c.Bool("skip-check-parts-columns"), config.GetStorageNamesFromCli(c), version, c.Int("command-id"), nil)
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -212,6 +234,11 @@ func main() {
 					Hidden: false,
 					Usage:  "Remote backup name which used to upload current backup as incremental",
 				},
+				cli.StringFlag{
+					Name:   "since",
+					Hidden: false,
+					Usage:  "Freeze and backup only parts which modification_time or max block number exceed watermark of local or remote backup name (other parts will mark as required from it and hardlink from local base backup, upload requires base backup on remote storage), or exceed timestamp like 2006-01-02T15:04:05 (other parts will skip, backup will tag as partial)",
+				},
 				cli.BoolFlag{
 					Name:   "schema, s",
 					Hidden: false,
@@ -227,6 +254,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Backup and upload 'clickhouse-server' configuration files",
 				},
+				cli.BoolFlag{
+					Name:   "keeper, backup-keeper",
+					Hidden: false,
+					Usage:  "Backup Keeper/ZooKeeper subtrees listed in general.keeper_paths",
+				},
+				cli.BoolFlag{
+					Name:   "named-collections, backup-named-collections",
+					Hidden: false,
+					Usage:  "Backup SQL defined named collections, secret values require display_secrets_in_show_and_select",
+				},
 				cli.BoolFlag{
 					Name:   "rbac-only",
 					Hidden: false,
@@ -317,6 +354,114 @@ func main() {
 			},
 			Flags: cliapp.Flags,
 		},
+		{
+			Name:      "diff",
+			Usage:     "Compare two backups without restore, show changed metadata, RBAC and config files, tables, partitions and sizes",
+			UsageText: "clickhouse-backup diff [--remote] [--remote-a] [--remote-b] [-t, --tables=<db>.<table>] [--format=text|json] <backup_name_a> <backup_name_b>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.Diff(c.Args().Get(0), c.Args().Get(1), c.Bool("remote") || c.Bool("remote-a"), c.Bool("remote") || c.Bool("remote-b"), c.String("t"), c.String("format"), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.BoolFlag{
+					Name:   "remote",
+					Hidden: false,
+					Usage:  "Read both backups from remote storage",
+				},
+				cli.BoolFlag{
+					Name:   "remote-a",
+					Hidden: false,
+					Usage:  "Read first backup from remote storage",
+				},
+				cli.BoolFlag{
+					Name:   "remote-b",
+					Hidden: false,
+					Usage:  "Read second backup from remote storage",
+				},
+				cli.StringFlag{
+					Name:   "table, tables, t",
+					Hidden: false,
+					Usage:  "Compare only tables matched with table name patterns, separated by comma, allow ? and * as wildcard",
+				},
+				cli.StringFlag{
+					Name:   "format, f",
+					Hidden: false,
+					Value:  "text",
+					Usage:  "Output format, text or json",
+				},
+			),
+		},
+		{
+			Name:      "show",
+			Usage:     "Print backup metadata and details of each table from local or remote backup",
+			UsageText: "clickhouse-backup show [--remote] [-t, --tables=<db>.<table>] [--parts] [--format=text|json] <backup_name>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.Show(c.Args().First(), c.Bool("remote"), c.String("t"), c.String("format"), c.Bool("parts"), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.BoolFlag{
+					Name:   "remote",
+					Hidden: false,
+					Usage:  "Read backup from remote storage",
+				},
+				cli.StringFlag{
+					Name:   "table, tables, t",
+					Hidden: false,
+					Usage:  "Show only tables matched with table name patterns, separated by comma, allow ? and * as wildcard",
+				},
+				cli.BoolFlag{
+					Name:   "parts",
+					Hidden: false,
+					Usage:  "Print each data part name, parts which required from incremental base backup are marked",
+				},
+				cli.StringFlag{
+					Name:   "format, f",
+					Hidden: false,
+					Value:  "text",
+					Usage:  "Output format, text or json",
+				},
+			),
+		},
+		{
+			Name:      "export",
+			Usage:     "Pack local or remote backup with all required parts from incremental chain into single portable tar archive",
+			UsageText: "clickhouse-backup export [--remote] [-o, --output=<file.tar>] <backup_name>",
+			Description: "Local backup created with --diff-from-remote or --since=<remote_backup> doesn't contain required parts, export fails for it, upload it, delete local copy and export with --remote, " +
+				"download places required parts into backup",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.Export(c.Args().First(), c.String("output"), c.Bool("remote"), version, c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.BoolFlag{
+					Name:   "remote",
+					Hidden: false,
+					Usage:  "Export backup from remote storage, backup will download to local storage temporarily if it doesn't exist locally",
+				},
+				cli.StringFlag{
+					Name:   "output, o",
+					Hidden: false,
+					Usage:  "Archive file name, <backup_name>.tar by default, .gz and .tgz extensions enable gzip compression",
+				},
+			),
+		},
+		{
+			Name:      "import",
+			Usage:     "Create local backup from archive created by export command",
+			UsageText: "clickhouse-backup import [--name=<backup_name>] <file.tar>",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.Import(c.Args().First(), c.String("name"), c.Int("command-id"))
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "name",
+					Hidden: false,
+					Usage:  "Local backup name, backup name stored inside archive by default",
+				},
+			),
+		},
 		{
 			Name:      "download",
 			Usage:     "Download backup from remote storage",
@@ -357,10 +502,10 @@ func main() {
 		{
 			Name:      "restore",
 			Usage:     "Create schema and restore data from backup",
-			UsageText: "clickhouse-backup restore  [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [-s, --schema] [-d, --data] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--resume] <backup_name>",
+			UsageText: "clickhouse-backup restore  [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [-s, --schema] [-d, --data] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--keeper] [--named-collections] [--resume] <backup_name>",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.Restore(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("data"), c.Bool("drop"), c.Bool("ignore-dependencies"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), version, c.Int("command-id"))
+				return b.Restore(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("data"), c.Bool("drop"), c.Bool("ignore-dependencies"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("keeper"), c.Bool("named-collections"), c.Bool("resume"), version, c.Int("command-id"))
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -419,6 +564,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Restore 'clickhouse-server' CONFIG related files",
 				},
+				cli.BoolFlag{
+					Name:   "keeper, restore-keeper",
+					Hidden: false,
+					Usage:  "Restore Keeper/ZooKeeper subtrees from backup, paths could be remapped with general.restore_keeper_path_mapping",
+				},
+				cli.BoolFlag{
+					Name:   "named-collections, restore-named-collections",
+					Hidden: false,
+					Usage:  "Restore named collections from backup before tables, existing named collections with the same name will replace",
+				},
 				cli.BoolFlag{
 					Name:   "rbac-only",
 					Hidden: false,
@@ -432,17 +587,17 @@ func main() {
 				cli.BoolFlag{
 					Name:   "resume, resumable",
 					Hidden: false,
-					Usage:  "Will resume download for object disk data",
+					Usage:  "Save intermediate restore state and skip already restored tables, attached parts and downloaded object disk data",
 				},
 			),
 		},
 		{
 			Name:      "restore_remote",
 			Usage:     "Download and restore",
-			UsageText: "clickhouse-backup restore_remote [--schema] [--data] [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--skip-rbac] [--skip-configs] [--resumable] <backup_name>",
+			UsageText: "clickhouse-backup restore_remote [--schema] [--data] [-t, --tables=<db>.<table>] [-m, --restore-database-mapping=<originDB>:<targetDB>[,<...>]] [--tm, --restore-table-mapping=<originTable>:<targetTable>[,<...>]] [--partitions=<partitions_names>] [--rm, --drop] [-i, --ignore-dependencies] [--rbac] [--configs] [--keeper] [--named-collections] [--skip-rbac] [--skip-configs] [--resumable] <backup_name>",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.RestoreFromRemote(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("d"), c.Bool("rm"), c.Bool("i"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("resume"), version, c.Int("command-id"))
+				return b.RestoreFromRemote(c.Args().First(), c.String("t"), c.StringSlice("restore-database-mapping"), c.StringSlice("restore-table-mapping"), c.StringSlice("partitions"), c.Bool("s"), c.Bool("d"), c.Bool("rm"), c.Bool("i"), c.Bool("rbac"), c.Bool("rbac-only"), c.Bool("configs"), c.Bool("configs-only"), c.Bool("keeper"), c.Bool("named-collections"), c.Bool("resume"), version, c.Int("command-id"), nil)
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -501,6 +656,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Download and Restore 'clickhouse-server' CONFIG related files",
 				},
+				cli.BoolFlag{
+					Name:   "keeper, restore-keeper",
+					Hidden: false,
+					Usage:  "Restore Keeper/ZooKeeper subtrees from backup, paths could be remapped with general.restore_keeper_path_mapping",
+				},
+				cli.BoolFlag{
+					Name:   "named-collections, restore-named-collections",
+					Hidden: false,
+					Usage:  "Restore named collections from backup before tables, existing named collections with the same name will replace",
+				},
 				cli.BoolFlag{
 					Name:   "rbac-only",
 					Hidden: false,
@@ -536,6 +701,37 @@ func main() {
 			},
 			Flags: cliapp.Flags,
 		},
+		{
+			Name:      "state",
+			Usage:     "Print or delete resumable state of upload, download and restore for specific backup",
+			UsageText: "clickhouse-backup state [--format=text|json] <show|reset> <backup_name> [upload|download|restore]",
+			Description: "Upload to named storage keeps own state, so upload to several storages could resume each storage separately, " +
+				"--storage selects upload state of one storage, without --storage upload states of all storages are shown or reset",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				if c.Args().Get(1) == "" {
+					log.Err(fmt.Errorf("backup name must be defined")).Send()
+					cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				}
+				switch c.Args().Get(0) {
+				case "show":
+					return b.ShowResumableState(c.Args().Get(1), c.Args().Get(2), c.String("format"), c.Int("command-id"))
+				case "reset":
+					return b.ResetResumableState(c.Args().Get(1), c.Args().Get(2), c.Int("command-id"))
+				}
+				log.Err(fmt.Errorf("Unknown command '%s'\n", c.Args().Get(0))).Send()
+				cli.ShowCommandHelpAndExit(c, c.Command.Name, 1)
+				return nil
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "format, f",
+					Hidden: false,
+					Value:  "text",
+					Usage:  "Output format for show, text or json",
+				},
+			),
+		},
 		{
 			Name:  "default-config",
 			Usage: "Print default config",
@@ -570,15 +766,51 @@ func main() {
 			},
 			Flags: cliapp.Flags,
 		},
+		{
+			Name:      "clean_remote_stale_uploads",
+			Usage:     "Abort S3 multipart uploads and discard Azure uncommitted blocks left after killed upload",
+			UsageText: "clickhouse-backup clean_remote_stale_uploads [--older-than=24h]",
+			Description: "Looks through whole `path` and `object_disk_path`, so uploads of all backups started earlier than --older-than are aborted, " +
+				"choose --older-than longer than the longest upload",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.CleanRemoteStaleUploads(c.String("older-than"), status.NotFromAPI)
+			},
+			Flags: append(cliapp.Flags,
+				cli.StringFlag{
+					Name:   "older-than",
+					Hidden: false,
+					Usage:  "Abort only uploads started earlier than this duration ago, general->abort_stale_uploads_after by default, required when it is empty",
+				},
+			),
+		},
+		{
+			Name:      "clean_remote_orphans",
+			Usage:     "Find remote objects which are not referenced by any remote backup and delete them with --apply",
+			UsageText: "clickhouse-backup clean_remote_orphans [--apply]",
+			Description: "Before delete with --apply backup list is read again, orphans which belong to backups started during scan or modified after scan start are skipped. " +
+				"object_disk_path orphans are detected by local and remote backup names, so object disk data of `create` which runs on another host and not uploaded yet is reported as orphan, don't use --apply while such create in progress",
+			Action: func(c *cli.Context) error {
+				b := backup.NewBackuper(config.GetConfigFromCli(c))
+				return b.CleanRemoteOrphans(c.Bool("apply"), status.NotFromAPI)
+			},
+			Flags: append(cliapp.Flags,
+				cli.BoolFlag{
+					Name:   "apply",
+					Hidden: false,
+					Usage:  "Delete found orphans, by default only print report with path and size of each orphan",
+				},
+			),
+		},
 
 		{
 			Name:        "watch",
 			Usage:       "Run infinite loop which create full + incremental backup sequence to allow efficient backup sequences",
-			UsageText:   "clickhouse-backup watch [--watch-interval=1h] [--full-interval=24h] [--watch-backup-name-template=shard{shard}-{type}-{time:20060102150405}] [-t, --tables=<db>.<table>] [--partitions=<partitions_names>] [--schema] [--rbac] [--configs] [--skip-check-parts-columns]",
+			UsageText:   "clickhouse-backup watch [--watch-interval=1h] [--full-interval=24h] [--watch-backup-name-template=shard{shard}-{type}-{time:20060102150405}] [-t, --tables=<db>.<table>] [--partitions=<partitions_names>] [--schema] [--rbac] [--configs] [--keeper] [--named-collections] [--skip-check-parts-columns] [--storage=<storage_name>[,<storage_name>]]",
 			Description: "Execute create_remote + delete local, create full backup every `--full-interval`, create and upload incremental backup every `--watch-interval` use previous backup as base with `--diff-from-remote` option, use `backups_to_keep_remote` config option for properly deletion remote backups, will delete old backups which not have references from other backups",
 			Action: func(c *cli.Context) error {
 				b := backup.NewBackuper(config.GetConfigFromCli(c))
-				return b.Watch(c.String("watch-interval"), c.String("full-interval"), c.String("watch-backup-name-template"), c.String("tables"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("rbac"), c.Bool("configs"), c.Bool("skip-check-parts-columns"), version, c.Int("command-id"), nil, c)
+				return b.Watch(c.String("watch-interval"), c.String("full-interval"), c.String("watch-backup-name-template"), c.String("tables"), c.StringSlice("partitions"), c.Bool("schema"), c.Bool("rbac"), c.Bool("configs"), c.Bool("keeper"), c.Bool("named-collections"), c.Bool("skip-check-parts-columns"), config.GetStorageNamesFromCli(c), version, c.Int("command-id"), nil, c)
 			},
 			Flags: append(cliapp.Flags,
 				cli.StringFlag{
@@ -627,6 +859,16 @@ func main() {
 					Hidden: false,
 					Usage:  "Backup `clickhouse-server' configuration files only",
 				},
+				cli.BoolFlag{
+					Name:   "keeper, backup-keeper",
+					Hidden: false,
+					Usage:  "Backup Keeper/ZooKeeper subtrees listed in general.keeper_paths",
+				},
+				cli.BoolFlag{
+					Name:   "named-collections, backup-named-collections",
+					Hidden: false,
+					Usage:  "Backup SQL defined named collections, secret values require display_secrets_in_show_and_select",
+				},
 				cli.BoolFlag{
 					Name:   "skip-check-parts-columns",
 					Hidden: false,
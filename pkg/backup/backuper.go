@@ -40,11 +40,14 @@ type Backuper struct {
 	isEmbedded             bool
 	resume                 bool
 	resumableState         *resumable.State
+	// queueGate - wait for API operation queue slot before each watch iteration, returned function release slot
+	queueGate func(ctx context.Context, operation, command string) (func(), error)
 }
 
 func NewBackuper(cfg *config.Config, opts ...BackuperOpt) *Backuper {
 	ch := &clickhouse.ClickHouse{
-		Config: &cfg.ClickHouse,
+		Config:        &cfg.ClickHouse,
+		TablePolicies: cfg.Tables,
 	}
 	b := &Backuper{
 		cfg:  cfg,
@@ -70,6 +73,13 @@ func WithBackupSharder(s backupSharder) BackuperOpt {
 	}
 }
 
+// WithQueueGate - API server use it to apply operation queue limits to each watch iteration, watch doesn't occupy queue between iterations
+func WithQueueGate(gate func(ctx context.Context, operation, command string) (func(), error)) BackuperOpt {
+	return func(b *Backuper) {
+		b.queueGate = gate
+	}
+}
+
 func (b *Backuper) initDisksPathsAndBackupDestination(ctx context.Context, disks []clickhouse.Disk, backupName string) error {
 	var err error
 	if disks == nil {
@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-shellwords"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// consistentSnapshot - result of freeze barrier, tables from shadowBackupUUIDs already frozen and shall not be frozen again
+type consistentSnapshot struct {
+	shadowBackupUUIDs map[metadata.TableTitle]string
+	snapshotTime      time.Time
+	freezeWindow      time.Duration
+}
+
+// getShadowBackupUUID - FREEZE name for table, isFrozen=true when table frozen inside freeze window
+func (s *consistentSnapshot) getShadowBackupUUID(table clickhouse.Table) (shadowBackupUUID string, isFrozen bool) {
+	if s != nil {
+		if shadowBackupUUID, isFrozen = s.shadowBackupUUIDs[metadata.TableTitle{Database: table.Database, Table: table.Name}]; isFrozen {
+			return shadowBackupUUID, isFrozen
+		}
+	}
+	return strings.ReplaceAll(uuid.New().String(), "-", ""), false
+}
+
+func isFreezableEngine(engine string) bool {
+	return strings.HasSuffix(engine, "MergeTree") || engine == "MaterializedMySQL" || engine == "MaterializedPostgreSQL"
+}
+
+// getFreezableTables - tables which data backup via FREEZE
+func getFreezableTables(tables []clickhouse.Table) []clickhouse.Table {
+	freezableTables := make([]clickhouse.Table, 0)
+	for _, table := range tables {
+		if !table.Skip && table.BackupType == clickhouse.ShardBackupFull && isFreezableEngine(table.Engine) {
+			freezableTables = append(freezableTables, table)
+		}
+	}
+	return freezableTables
+}
+
+// freezeTablesConsistently - stop merges and fetches, pause inserts via clickhouse->consistent_snapshot_pause_command, freeze all tables as a group
+// and resume activity, so tables related by materialized views or joins contain data for the same point in time
+func (b *Backuper) freezeTablesConsistently(ctx context.Context, tables []clickhouse.Table, sinceTablesParts map[metadata.TableTitle]*sinceTableParts) (snapshot *consistentSnapshot, err error) {
+	snapshotTables := getFreezableTables(tables)
+	snapshot = &consistentSnapshot{shadowBackupUUIDs: make(map[metadata.TableTitle]string, len(snapshotTables))}
+	// replica sync and parts check could take a long time, so it happens before freeze window
+	for i := range snapshotTables {
+		if b.cfg.ClickHouse.CheckPartsColumns {
+			if err = b.ch.CheckSystemPartsColumns(ctx, &snapshotTables[i]); err != nil {
+				return nil, err
+			}
+		}
+		b.ch.SyncReplica(ctx, &snapshotTables[i])
+	}
+
+	windowStart := time.Now()
+	stoppedTables := make([]clickhouse.Table, 0, len(snapshotTables))
+	isPaused := false
+	defer func() {
+		// activity shall resume even when backup was canceled
+		b.resumeTablesActivity(context.WithoutCancel(ctx), stoppedTables, isPaused)
+		snapshot.freezeWindow = time.Since(windowStart)
+		log.Info().Int("tables", len(snapshotTables)).Str("freeze_window", utils.HumanizeDuration(snapshot.freezeWindow)).Msg("consistent snapshot done")
+	}()
+	for _, table := range snapshotTables {
+		if err = b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM STOP MERGES `%s`.`%s`", table.Database, table.Name)); err != nil {
+			return snapshot, fmt.Errorf("can't stop merges for `%s`.`%s`: %v", table.Database, table.Name, err)
+		}
+		stoppedTables = append(stoppedTables, table)
+		// other replicas still merge, merged parts shall not replace frozen ones
+		if strings.HasPrefix(table.Engine, "Replicated") {
+			if err = b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM STOP FETCHES `%s`.`%s`", table.Database, table.Name)); err != nil {
+				return snapshot, fmt.Errorf("can't stop fetches for `%s`.`%s`: %v", table.Database, table.Name, err)
+			}
+		}
+	}
+	if b.cfg.ClickHouse.ConsistentSnapshotPauseCommand != "" {
+		isPaused = true
+		if err = b.runConsistentSnapshotCommand(ctx, b.cfg.ClickHouse.ConsistentSnapshotPauseCommand); err != nil {
+			return snapshot, err
+		}
+	}
+	snapshot.snapshotTime = time.Now().UTC()
+
+	var frozenMutex sync.Mutex
+	freezeWorkingGroup, freezeCtx := errgroup.WithContext(ctx)
+	freezeWorkingGroup.SetLimit(max(b.cfg.ClickHouse.MaxConnections, 1))
+	for i := range snapshotTables {
+		table := snapshotTables[i]
+		freezeWorkingGroup.Go(func() error {
+			shadowBackupUUID := strings.ReplaceAll(uuid.New().String(), "-", "")
+			title := metadata.TableTitle{Database: table.Database, Table: table.Name}
+			if freezeErr := b.freezeTable(freezeCtx, &table, shadowBackupUUID, sinceTablesParts[title]); freezeErr != nil {
+				return freezeErr
+			}
+			frozenMutex.Lock()
+			snapshot.shadowBackupUUIDs[title] = shadowBackupUUID
+			frozenMutex.Unlock()
+			log.Debug().Str("database", table.Database).Str("table", table.Name).Msg("frozen")
+			return nil
+		})
+	}
+	if err = freezeWorkingGroup.Wait(); err != nil {
+		b.unfreezeConsistentSnapshot(context.WithoutCancel(ctx), snapshot)
+		return snapshot, fmt.Errorf("one of freezeTablesConsistently go-routine return error: %v", err)
+	}
+	return snapshot, nil
+}
+
+// resumeTablesActivity - errors are logged only, backup is already consistent or already failed
+func (b *Backuper) resumeTablesActivity(ctx context.Context, stoppedTables []clickhouse.Table, isPaused bool) {
+	if isPaused && b.cfg.ClickHouse.ConsistentSnapshotResumeCommand != "" {
+		if err := b.runConsistentSnapshotCommand(ctx, b.cfg.ClickHouse.ConsistentSnapshotResumeCommand); err != nil {
+			log.Error().Msgf("can't resume inserts: %v", err)
+		}
+	}
+	for _, table := range stoppedTables {
+		if strings.HasPrefix(table.Engine, "Replicated") {
+			if err := b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM START FETCHES `%s`.`%s`", table.Database, table.Name)); err != nil {
+				log.Error().Msgf("can't start fetches for `%s`.`%s`: %v", table.Database, table.Name, err)
+			}
+		}
+		if err := b.ch.QueryContext(ctx, fmt.Sprintf("SYSTEM START MERGES `%s`.`%s`", table.Database, table.Name)); err != nil {
+			log.Error().Msgf("can't start merges for `%s`.`%s`: %v", table.Database, table.Name, err)
+		}
+	}
+}
+
+// unfreezeConsistentSnapshot - remove shadow of tables which was frozen before freeze error
+func (b *Backuper) unfreezeConsistentSnapshot(ctx context.Context, snapshot *consistentSnapshot) {
+	for table, shadowBackupUUID := range snapshot.shadowBackupUUIDs {
+		if err := b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` UNFREEZE WITH NAME '%s'", table.Database, table.Table, shadowBackupUUID)); err != nil {
+			log.Warn().Msgf("can't unfreeze `%s`.`%s`: %v", table.Database, table.Table, err)
+		}
+	}
+}
+
+// runConsistentSnapshotCommand - `;` separated list of `sql:` and `exec:` commands, the same format as clickhouse->restart_command,
+// unlike restart_command any error breaks backup, cause snapshot can't be consistent without paused inserts
+func (b *Backuper) runConsistentSnapshotCommand(ctx context.Context, commands string) error {
+	for _, cmd := range strings.Split(commands, ";") {
+		cmd = strings.Trim(cmd, " \t\r\n")
+		switch {
+		case cmd == "":
+			continue
+		case strings.HasPrefix(cmd, "sql:"):
+			if err := b.ch.QueryContext(ctx, strings.TrimPrefix(cmd, "sql:")); err != nil {
+				return fmt.Errorf("consistent snapshot sql: %s, error: %v", cmd, err)
+			}
+		case strings.HasPrefix(cmd, "exec:"):
+			shellCmd, err := shellwords.Parse(strings.TrimPrefix(cmd, "exec:"))
+			if err != nil {
+				return err
+			}
+			if len(shellCmd) == 0 {
+				return fmt.Errorf("consistent snapshot exec: %s, empty command", cmd)
+			}
+			shellCtx, shellCancel := context.WithTimeout(ctx, 180*time.Second)
+			log.Info().Msgf("run %s", cmd)
+			out, err := exec.CommandContext(shellCtx, shellCmd[0], shellCmd[1:]...).CombinedOutput()
+			shellCancel()
+			log.Debug().Msg(string(out))
+			if err != nil {
+				return fmt.Errorf("consistent snapshot exec: %s, error: %v, output: %s", cmd, err, strings.TrimSpace(string(out)))
+			}
+		default:
+			return fmt.Errorf("consistent snapshot command: %s, shall start with `sql:` or `exec:`", cmd)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestGetConsistentSnapshotTables(t *testing.T) {
+	r := require.New(t)
+	tables := []clickhouse.Table{
+		{Database: "db", Name: "events", Engine: "ReplicatedMergeTree", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: ".inner_id.5f2c", Engine: "AggregatingMergeTree", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "events_mv", Engine: "MaterializedView", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "skipped", Engine: "MergeTree", BackupType: clickhouse.ShardBackupFull, Skip: true},
+		{Database: "db", Name: "other_shard", Engine: "MergeTree", BackupType: clickhouse.ShardBackupSchema},
+		{Database: "db", Name: "mysql", Engine: "MaterializedMySQL", BackupType: clickhouse.ShardBackupFull},
+	}
+	snapshotTables := getFreezableTables(tables)
+	names := make([]string, 0, len(snapshotTables))
+	for _, table := range snapshotTables {
+		names = append(names, table.Name)
+	}
+	r.Equal([]string{"events", ".inner_id.5f2c", "mysql"}, names)
+}
+
+func TestConsistentSnapshotGetShadowBackupUUID(t *testing.T) {
+	r := require.New(t)
+	table := clickhouse.Table{Database: "db", Name: "events"}
+	var snapshot *consistentSnapshot
+	shadowBackupUUID, isFrozen := snapshot.getShadowBackupUUID(table)
+	r.False(isFrozen)
+	r.Len(shadowBackupUUID, 32)
+
+	snapshot = &consistentSnapshot{shadowBackupUUIDs: map[metadata.TableTitle]string{{Database: "db", Table: "events"}: "frozen_uuid"}}
+	shadowBackupUUID, isFrozen = snapshot.getShadowBackupUUID(table)
+	r.True(isFrozen)
+	r.Equal("frozen_uuid", shadowBackupUUID)
+
+	shadowBackupUUID, isFrozen = snapshot.getShadowBackupUUID(clickhouse.Table{Database: "db", Name: "new_table"})
+	r.False(isFrozen)
+	r.NotEqual("frozen_uuid", shadowBackupUUID)
+}
+
+func TestRunConsistentSnapshotCommand(t *testing.T) {
+	r := require.New(t)
+	b := &Backuper{cfg: config.DefaultConfig()}
+	ctx := context.Background()
+	r.NoError(b.runConsistentSnapshotCommand(ctx, "exec:true; exec:true ;"))
+	err := b.runConsistentSnapshotCommand(ctx, "exec:true; exec:sh -c 'echo producers busy && exit 1'")
+	r.Error(err)
+	r.Contains(err.Error(), "producers busy")
+	err = b.runConsistentSnapshotCommand(ctx, "systemctl stop producer")
+	r.Error(err)
+	r.Contains(err.Error(), "shall start with `sql:` or `exec:`")
+}
@@ -0,0 +1,258 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/eapache/go-resiliency/retrier"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/kafka"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/keeper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+var kafkaEngineArgsRE = regexp.MustCompile(`ENGINE = Kafka\(\s*'([^']*)'\s*,\s*'([^']*)'\s*,\s*'([^']*)'`)
+var queueEngineRE = regexp.MustCompile(`ENGINE = (S3Queue|AzureQueue)\(`)
+
+// s3QueueStateNodes - first level children of S3Queue keeper_path which contain processed files state,
+// `processing` contains ephemeral nodes, `metadata` and `registry` are re-created by restored table
+var s3QueueStateNodes = []string{"processed", "failed", "buckets"}
+
+// getQuerySetting - value of `name = 'value'` from SETTINGS clause of CREATE query
+func getQuerySetting(query, name string) string {
+	settingRE := regexp.MustCompile(`[\s,(]` + regexp.QuoteMeta(name) + `\s*=\s*'((?:[^'\\]|\\.)*)'`)
+	if matches := settingRE.FindStringSubmatch(query); len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// parseKafkaEngineSettings - broker list and consumer group from SETTINGS or from ENGINE = Kafka('broker_list', 'topic_list', 'group_name', 'format') arguments
+func parseKafkaEngineSettings(query string) (brokers, group string) {
+	brokers, group = getQuerySetting(query, "kafka_broker_list"), getQuerySetting(query, "kafka_group_name")
+	if matches := kafkaEngineArgsRE.FindStringSubmatch(query); len(matches) > 3 {
+		if brokers == "" {
+			brokers = matches[1]
+		}
+		if group == "" {
+			group = matches[3]
+		}
+	}
+	return brokers, group
+}
+
+// parseQueueKeeperPath - S3Queue and AzureQueue tables store processed files state in keeper_path
+func parseQueueKeeperPath(query string) string {
+	if keeperPath := getQuerySetting(query, "keeper_path"); keeperPath != "" {
+		return keeperPath
+	}
+	return getQuerySetting(query, "s3queue_keeper_path")
+}
+
+func isS3QueueStateNode(nodePath string) bool {
+	for _, stateNode := range s3QueueStateNodes {
+		if nodePath == stateNode || strings.HasPrefix(nodePath, stateNode+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getConsumerStates - Kafka consumer group offsets and S3Queue processed files state for tables, when clickhouse->backup_consumer_state enabled
+// state capture is best effort, errors are logged and table backup continues without state
+func (b *Backuper) getConsumerStates(ctx context.Context, tables []clickhouse.Table) map[metadata.TableTitle]*metadata.ConsumerState {
+	if !b.cfg.ClickHouse.BackupConsumerState {
+		return nil
+	}
+	states := map[metadata.TableTitle]*metadata.ConsumerState{}
+	var k *keeper.Keeper
+	defer func() {
+		if k != nil {
+			k.Close()
+		}
+	}()
+	for _, table := range tables {
+		if table.Skip {
+			continue
+		}
+		var state *metadata.ConsumerState
+		var err error
+		switch {
+		case table.Engine == "Kafka":
+			state, err = b.getKafkaConsumerState(ctx, table)
+		case table.Engine == "S3Queue" || table.Engine == "AzureQueue":
+			if k == nil {
+				k = &keeper.Keeper{}
+				if err = k.Connect(ctx, b.ch); err != nil {
+					k = nil
+					log.Warn().Msgf("can't connect to keeper for backup `%s`.`%s` consumer state: %v", table.Database, table.Name, err)
+					continue
+				}
+			}
+			state, err = b.getQueueConsumerState(ctx, k, table)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Warn().Msgf("can't backup `%s`.`%s` consumer state: %v", table.Database, table.Name, err)
+			continue
+		}
+		if state != nil {
+			states[metadata.TableTitle{Database: table.Database, Table: table.Name}] = state
+		}
+	}
+	return states
+}
+
+// getKafkaConsumerState - positions from system.kafka_consumers, or committed offsets from broker when table doesn't consume now
+func (b *Backuper) getKafkaConsumerState(ctx context.Context, table clickhouse.Table) (*metadata.ConsumerState, error) {
+	brokers, group := parseKafkaEngineSettings(table.CreateTableQuery)
+	if brokers == "" || group == "" {
+		return nil, fmt.Errorf("kafka_broker_list and kafka_group_name not found in CREATE query, named collections are not supported")
+	}
+	offsets, err := b.ch.GetKafkaConsumerOffsets(ctx, table.Database, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
+		k := &kafka.Kafka{}
+		if err = k.Connect(brokers, &b.cfg.ClickHouse); err != nil {
+			return nil, err
+		}
+		defer k.Close()
+		if offsets, err = k.FetchOffsets(ctx, group); err != nil {
+			return nil, err
+		}
+	}
+	if len(offsets) == 0 {
+		log.Warn().Msgf("`%s`.`%s` consumer group %s doesn't have committed offsets", table.Database, table.Name, group)
+		return nil, nil
+	}
+	log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Str("group", group).Int("partitions", len(offsets)).Msg("kafka offsets captured")
+	return &metadata.ConsumerState{KafkaBrokers: brokers, KafkaGroup: group, KafkaOffsets: offsets}, nil
+}
+
+// getQueueConsumerState - processed files nodes from keeper_path
+func (b *Backuper) getQueueConsumerState(ctx context.Context, k *keeper.Keeper, table clickhouse.Table) (*metadata.ConsumerState, error) {
+	keeperPath := parseQueueKeeperPath(table.CreateTableQuery)
+	if keeperPath == "" {
+		return nil, fmt.Errorf("keeper_path not found in CREATE query")
+	}
+	resolvedKeeperPath, err := b.ch.ApplyMacros(ctx, keeperPath)
+	if err != nil {
+		return nil, err
+	}
+	state := &metadata.ConsumerState{KeeperPath: keeperPath}
+	for _, stateNode := range s3QueueStateNodes {
+		nodes, err := k.DumpNodes(path.Join(resolvedKeeperPath, stateNode))
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			state.KeeperNodes = append(state.KeeperNodes, metadata.KeeperNode{Path: path.Join(stateNode, node.Path), Value: node.Value})
+		}
+	}
+	log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Str("keeper_path", resolvedKeeperPath).Int("nodes", len(state.KeeperNodes)).Msg("queue processed files state captured")
+	return state, nil
+}
+
+// restoreConsumerStates - re-seed Kafka consumer group offsets and S3Queue processed files state, when clickhouse->restore_consumer_state enabled,
+// table is detached during re-seed, so consumers leave group and don't overwrite restored state
+func (b *Backuper) restoreConsumerStates(ctx context.Context, tablesForRestore ListOfTables) error {
+	var k *keeper.Keeper
+	defer func() {
+		if k != nil {
+			k.Close()
+		}
+	}()
+	for _, table := range tablesForRestore {
+		if table.ConsumerState == nil {
+			continue
+		}
+		isQueue := queueEngineRE.MatchString(table.Query)
+		if isQueue && k == nil {
+			k = &keeper.Keeper{}
+			if err := k.Connect(ctx, b.ch); err != nil {
+				k = nil
+				return fmt.Errorf("can't connect to keeper for restore `%s`.`%s` consumer state: %v", table.Database, table.Table, err)
+			}
+		}
+		if err := b.ch.QueryContext(ctx, fmt.Sprintf("DETACH TABLE `%s`.`%s`", table.Database, table.Table)); err != nil {
+			return fmt.Errorf("can't detach `%s`.`%s` before restore consumer state: %v", table.Database, table.Table, err)
+		}
+		var restoreErr error
+		if isQueue {
+			restoreErr = b.restoreQueueConsumerState(ctx, k, table)
+		} else {
+			restoreErr = b.restoreKafkaConsumerState(ctx, table)
+		}
+		if err := b.ch.QueryContext(ctx, fmt.Sprintf("ATTACH TABLE `%s`.`%s`", table.Database, table.Table)); err != nil {
+			return fmt.Errorf("can't attach `%s`.`%s` after restore consumer state: %v", table.Database, table.Table, err)
+		}
+		if restoreErr != nil {
+			return fmt.Errorf("can't restore `%s`.`%s` consumer state: %v", table.Database, table.Table, restoreErr)
+		}
+	}
+	return nil
+}
+
+// restoreKafkaConsumerState - broker list and group from restored CREATE query, consumer group could be renamed during restore
+func (b *Backuper) restoreKafkaConsumerState(ctx context.Context, table metadata.TableMetadata) error {
+	state := table.ConsumerState
+	brokers, group := parseKafkaEngineSettings(table.Query)
+	if brokers == "" {
+		brokers = state.KafkaBrokers
+	}
+	if group == "" {
+		group = state.KafkaGroup
+	}
+	k := &kafka.Kafka{}
+	if err := k.Connect(brokers, &b.cfg.ClickHouse); err != nil {
+		return err
+	}
+	defer k.Close()
+	// broker needs some time to remove members of detached table from consumer group
+	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	if err := retry.RunCtx(ctx, func(ctx context.Context) error {
+		return k.CommitOffsets(ctx, group, state.KafkaOffsets)
+	}); err != nil {
+		return err
+	}
+	log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Table)).Str("group", group).Int("partitions", len(state.KafkaOffsets)).Msg("kafka offsets restored")
+	return nil
+}
+
+// restoreQueueConsumerState - replace processed files state, files processed after backup will process again
+func (b *Backuper) restoreQueueConsumerState(ctx context.Context, k *keeper.Keeper, table metadata.TableMetadata) error {
+	state := table.ConsumerState
+	keeperPath := parseQueueKeeperPath(table.Query)
+	if keeperPath == "" {
+		keeperPath = state.KeeperPath
+	}
+	resolvedKeeperPath, err := b.ch.ApplyMacros(ctx, keeperPath)
+	if err != nil {
+		return err
+	}
+	for _, stateNode := range s3QueueStateNodes {
+		if err = k.DeleteTree(path.Join(resolvedKeeperPath, stateNode)); err != nil {
+			return err
+		}
+	}
+	nodes := make([]keeper.DumpNode, 0, len(state.KeeperNodes))
+	for _, node := range state.KeeperNodes {
+		if isS3QueueStateNode(node.Path) {
+			nodes = append(nodes, keeper.DumpNode{Path: node.Path, Value: node.Value})
+		}
+	}
+	report, err := k.RestoreNodes(nodes, resolvedKeeperPath, keeper.RestoreModeOverwrite)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Table)).Str("keeper_path", resolvedKeeperPath).Int("created", report.Created).Msg("queue processed files state restored")
+	return nil
+}
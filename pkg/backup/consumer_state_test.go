@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/kafka"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestParseKafkaEngineSettings(t *testing.T) {
+	r := require.New(t)
+	brokers, group := parseKafkaEngineSettings("CREATE TABLE db.queue (`message` String) ENGINE = Kafka SETTINGS kafka_broker_list = 'kafka1:9092,kafka2:9092', kafka_topic_list = 'events', kafka_group_name = 'clickhouse', kafka_format = 'JSONEachRow'")
+	r.Equal("kafka1:9092,kafka2:9092", brokers)
+	r.Equal("clickhouse", group)
+
+	brokers, group = parseKafkaEngineSettings("CREATE TABLE db.queue (`message` String) ENGINE = Kafka('kafka:9092', 'events', 'group1', 'JSONEachRow')")
+	r.Equal("kafka:9092", brokers)
+	r.Equal("group1", group)
+
+	brokers, group = parseKafkaEngineSettings("CREATE TABLE db.queue (`message` String) ENGINE = Kafka(kafka_nc)")
+	r.Empty(brokers)
+	r.Empty(group)
+}
+
+func TestParseQueueKeeperPath(t *testing.T) {
+	r := require.New(t)
+	r.Equal("/clickhouse/s3queue/{shard}/events", parseQueueKeeperPath("CREATE TABLE db.s3_queue (`message` String) ENGINE = S3Queue('http://minio:9000/bucket/*.json', 'JSONEachRow') SETTINGS mode = 'unordered', keeper_path = '/clickhouse/s3queue/{shard}/events'"))
+	r.Equal("/s3queue/events", parseQueueKeeperPath("CREATE TABLE db.s3_queue (`message` String) ENGINE = S3Queue('http://minio:9000/bucket/*.json', 'JSONEachRow') SETTINGS s3queue_keeper_path = '/s3queue/events'"))
+	r.Empty(parseQueueKeeperPath("CREATE TABLE db.s3_queue (`message` String) ENGINE = S3Queue('http://minio:9000/bucket/*.json', 'JSONEachRow')"))
+
+	r.True(isS3QueueStateNode("processed"))
+	r.True(isS3QueueStateNode("buckets/0/processed"))
+	r.False(isS3QueueStateNode("processing/file"))
+	r.False(isS3QueueStateNode("registry"))
+}
+
+func TestRestoreKafkaConsumerState(t *testing.T) {
+	r := require.New(t)
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "events"))
+	r.NoError(err)
+	defer cluster.Close()
+	brokers := strings.Join(cluster.ListenAddrs(), ",")
+
+	b := &Backuper{cfg: config.DefaultConfig()}
+	offsets := []metadata.KafkaOffset{{Topic: "events", Partition: 0, Offset: 10}, {Topic: "events", Partition: 1, Offset: 20}}
+	table := metadata.TableMetadata{
+		Database: "db",
+		Table:    "queue",
+		// consumer group renamed during restore, offsets shall be committed for restored group
+		Query:         fmt.Sprintf("CREATE TABLE db.queue (`message` String) ENGINE = Kafka SETTINGS kafka_broker_list = '%s', kafka_topic_list = 'events', kafka_group_name = 'restored_group', kafka_format = 'JSONEachRow'", brokers),
+		ConsumerState: &metadata.ConsumerState{KafkaBrokers: brokers, KafkaGroup: "backup_group", KafkaOffsets: offsets},
+	}
+	ctx := context.Background()
+	r.NoError(b.restoreKafkaConsumerState(ctx, table))
+
+	k := &kafka.Kafka{}
+	r.NoError(k.Connect(brokers, &b.cfg.ClickHouse))
+	defer k.Close()
+	restoredOffsets, err := k.FetchOffsets(ctx, "restored_group")
+	r.NoError(err)
+	r.Equal(offsets, restoredOffsets)
+	backupGroupOffsets, err := k.FetchOffsets(ctx, "backup_group")
+	r.NoError(err)
+	r.Empty(backupGroupOffsets)
+}
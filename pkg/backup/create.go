@@ -16,7 +16,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
 	recursiveCopy "github.com/otiai10/copy"
 	"golang.org/x/sync/errgroup"
 
@@ -57,7 +56,7 @@ func NewBackupName() string {
 
 // CreateBackup - create new backup of all tables matched by tablePattern
 // If backupName is empty string will use default backup name
-func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string, partitions []string, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, skipCheckPartsColumns, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) CreateBackup(backupName, diffFromRemote, since, tablePattern string, partitions []string, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, createKeeper, createNamedCollections, skipCheckPartsColumns, resume bool, backupVersion string, commandId int) error {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -82,6 +81,9 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 		createRBAC = true
 	}
 	b.adjustResumeFlag(resume)
+	if since != "" && (diffFromRemote != "" || b.cfg.ClickHouse.UseEmbeddedBackupRestore || b.cfg.General.DataFormat != "") {
+		return fmt.Errorf("--since is compatible only with FREEZE based backup without --diff-from-remote")
+	}
 
 	allDatabases, err := b.ch.GetDatabases(ctx, b.cfg, tablePattern)
 	if err != nil {
@@ -91,6 +93,7 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 	if err != nil {
 		return fmt.Errorf("can't get tables from clickhouse: %v", err)
 	}
+	applyTablePolicies(b.cfg.Tables, tables)
 
 	if b.CalculateNonSkipTables(tables) == 0 && !b.cfg.General.AllowEmptyBackups {
 		return fmt.Errorf("no tables for backup")
@@ -100,6 +103,16 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 	if err != nil {
 		return fmt.Errorf("GetUserDefinedFunctions return error: %v", err)
 	}
+	allWorkloads, err := b.ch.GetWorkloads(ctx)
+	if err != nil {
+		return fmt.Errorf("GetWorkloads return error: %v", err)
+	}
+	var allNamedCollections []clickhouse.NamedCollection
+	if createNamedCollections {
+		if allNamedCollections, err = b.ch.GetNamedCollections(ctx); err != nil {
+			return fmt.Errorf("GetNamedCollections return error: %v", err)
+		}
+	}
 
 	disks, err := b.ch.GetDisks(ctx, false)
 	if err != nil {
@@ -120,16 +133,23 @@ func (b *Backuper) CreateBackup(backupName, diffFromRemote, tablePattern string,
 		diskMap[disk.Name] = disk.Path
 		diskTypes[disk.Name] = disk.Type
 	}
+	if len(partitions) == 0 {
+		partitions = getTablePolicyPartitions(b.cfg.Tables, tables)
+	}
 	partitionsIdMap, partitionsNameList := partition.ConvertPartitionsToIdsMapAndNamesList(ctx, b.ch, tables, nil, partitions)
 	doBackupData := !schemaOnly && !rbacOnly && !configsOnly
 	backupRBACSize, backupConfigSize, rbacAndConfigsErr := b.createRBACAndConfigsIfNecessary(ctx, backupName, createRBAC, rbacOnly, createConfigs, configsOnly, disks, diskMap)
 	if rbacAndConfigsErr != nil {
 		return rbacAndConfigsErr
 	}
+	backupKeeperSize, keeperErr := b.createKeeperIfNecessary(ctx, backupName, createKeeper, disks, diskMap)
+	if keeperErr != nil {
+		return keeperErr
+	}
 	if b.cfg.ClickHouse.UseEmbeddedBackupRestore {
-		err = b.createBackupEmbedded(ctx, backupName, diffFromRemote, doBackupData, schemaOnly, backupVersion, tablePattern, partitionsNameList, partitionsIdMap, tables, allDatabases, allFunctions, disks, diskMap, diskTypes, backupRBACSize, backupConfigSize, startBackup, version)
+		err = b.createBackupEmbedded(ctx, backupName, diffFromRemote, doBackupData, schemaOnly, backupVersion, tablePattern, partitionsNameList, partitionsIdMap, tables, allDatabases, allFunctions, allNamedCollections, allWorkloads, disks, diskMap, diskTypes, backupRBACSize, backupConfigSize, backupKeeperSize, startBackup, version)
 	} else {
-		err = b.createBackupLocal(ctx, backupName, diffFromRemote, doBackupData, schemaOnly, rbacOnly, configsOnly, backupVersion, partitions, partitionsIdMap, tables, tablePattern, disks, diskMap, diskTypes, allDatabases, allFunctions, backupRBACSize, backupConfigSize, startBackup, version)
+		err = b.createBackupLocal(ctx, backupName, diffFromRemote, since, doBackupData, schemaOnly, rbacOnly, configsOnly, backupVersion, partitions, partitionsIdMap, tables, tablePattern, disks, diskMap, diskTypes, allDatabases, allFunctions, allNamedCollections, allWorkloads, backupRBACSize, backupConfigSize, backupKeeperSize, startBackup, version)
 	}
 	if err != nil {
 		log.Error().Msgf("backup failed error: %v", err)
@@ -193,7 +213,67 @@ func (b *Backuper) createRBACAndConfigsIfNecessary(ctx context.Context, backupNa
 	return backupRBACSize, backupConfigSize, nil
 }
 
-func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRemote string, doBackupData, schemaOnly, rbacOnly, configsOnly bool, backupVersion string, partitions []string, partitionsIdMap map[metadata.TableTitle]common.EmptyMap, tables []clickhouse.Table, tablePattern string, disks []clickhouse.Disk, diskMap, diskTypes map[string]string, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, backupRBACSize, backupConfigSize uint64, startBackup time.Time, version int) error {
+// createKeeperIfNecessary - dump general.keeper_paths subtrees into <backup>/keeper/<encoded_path>.jsonl
+func (b *Backuper) createKeeperIfNecessary(ctx context.Context, backupName string, createKeeper bool, disks []clickhouse.Disk, diskMap map[string]string) (uint64, error) {
+	if !createKeeper {
+		return 0, nil
+	}
+	if len(b.cfg.General.KeeperPaths) == 0 {
+		return 0, fmt.Errorf("--keeper requires non empty general.keeper_paths")
+	}
+	backupPath := path.Join(b.DefaultDataPath, "backup")
+	if b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		backupPath = diskMap[b.cfg.ClickHouse.EmbeddedBackupDisk]
+	}
+	backupPath = path.Join(backupPath, backupName)
+	backupKeeperSize, err := b.createBackupKeeper(ctx, path.Join(backupPath, "keeper"))
+	if err != nil {
+		return 0, fmt.Errorf("error during do KEEPER backup: %v", err)
+	}
+	log.Info().Str("size", utils.FormatBytes(backupKeeperSize)).Msg("done createBackupKeeper")
+	if backupKeeperSize > 0 {
+		if chownErr := filesystemhelper.Chown(backupPath, b.ch, disks, true); chownErr != nil {
+			return backupKeeperSize, chownErr
+		}
+	}
+	return backupKeeperSize, nil
+}
+
+func (b *Backuper) createBackupKeeper(ctx context.Context, keeperBackup string) (uint64, error) {
+	k := keeper.Keeper{}
+	if err := k.Connect(ctx, b.ch); err != nil {
+		return 0, err
+	}
+	defer k.Close()
+	if err := os.MkdirAll(keeperBackup, 0755); err != nil {
+		return 0, err
+	}
+	keeperDataSize := uint64(0)
+	for _, keeperPath := range b.cfg.General.KeeperPaths {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		default:
+			keeperPath = path.Clean("/" + keeperPath)
+			dumpFile := path.Join(keeperBackup, common.TablePathEncode(keeperPath)+".jsonl")
+			if b.cfg.General.KeeperDumpCompression == "gzip" {
+				dumpFile += ".gz"
+			}
+			log.Info().Str("logger", "createBackupKeeper").Msgf("keeper.Dump %s -> %s", keeperPath, dumpFile)
+			if _, err := k.Dump(keeperPath, dumpFile); err != nil {
+				return 0, fmt.Errorf("keeper.Dump(%s) error: %v", keeperPath, err)
+			}
+			dumpInfo, err := os.Stat(dumpFile)
+			if err != nil {
+				return 0, err
+			}
+			keeperDataSize += uint64(dumpInfo.Size())
+		}
+	}
+	return keeperDataSize, nil
+}
+
+func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRemote, since string, doBackupData, schemaOnly, rbacOnly, configsOnly bool, backupVersion string, partitions []string, partitionsIdMap map[metadata.TableTitle]common.EmptyMap, tables []clickhouse.Table, tablePattern string, disks []clickhouse.Disk, diskMap, diskTypes map[string]string, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, allNamedCollections []clickhouse.NamedCollection, allWorkloads []clickhouse.Workload, backupRBACSize, backupConfigSize, backupKeeperSize uint64, startBackup time.Time, version int) error {
 	// Create backup dir on all clickhouse disks
 	for _, disk := range disks {
 		if err := filesystemhelper.Mkdir(path.Join(disk.Path, "backup"), b.ch, disks); err != nil {
@@ -255,9 +335,11 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 		if b.resume {
 			b.resumableState = resumable.NewState(b.GetStateDir(), backupName, "create", map[string]interface{}{
 				"diffFromRemote": diffFromRemote,
+				"since":          since,
 				"tablePattern":   tablePattern,
 				"partitions":     partitions,
 				"schemaOnly":     schemaOnly,
+				"storage":        b.cfg.StorageName,
 			})
 			defer b.resumableState.Close()
 		}
@@ -272,6 +354,39 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 		}
 	}
 
+	requiredBackup := diffFromRemote
+	tags := "regular"
+	var watermark *metadata.Watermark
+	var sinceTablesParts map[metadata.TableTitle]*sinceTableParts
+	if doBackupData {
+		if watermark, err = b.getBackupWatermark(ctx, tables); err != nil {
+			return fmt.Errorf("b.getBackupWatermark return error: %v", err)
+		}
+		if since != "" {
+			sinceWm, sinceErr := b.getSinceWatermark(ctx, since, tablePattern)
+			if sinceErr != nil {
+				return fmt.Errorf("b.getSinceWatermark return error: %v", sinceErr)
+			}
+			if sinceTablesParts, err = b.getSinceTablesParts(ctx, sinceWm, tables, partitionsIdMap); err != nil {
+				return fmt.Errorf("b.getSinceTablesParts return error: %v", err)
+			}
+			if sinceWm.baseBackup != "" {
+				requiredBackup = sinceWm.baseBackup
+			} else {
+				// older parts are skipped without required backup, restore of such backup returns only data since timestamp
+				tags = "regular,partial"
+			}
+		}
+	}
+
+	// consumer positions shall be captured before freeze, data after this point could be consumed again after restore
+	consumerStates := b.getConsumerStates(ctx, tables)
+	var snapshot *consistentSnapshot
+	if b.cfg.ClickHouse.ConsistentSnapshot && doBackupData {
+		if snapshot, err = b.freezeTablesConsistently(ctx, tables, sinceTablesParts); err != nil {
+			return fmt.Errorf("b.freezeTablesConsistently return error: %v", err)
+		}
+	}
 	var backupDataSize, backupObjectDiskSize, backupMetadataSize uint64
 	var metaMutex sync.Mutex
 	createBackupWorkingGroup, createCtx := errgroup.WithContext(ctx)
@@ -286,14 +401,18 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 		}
 		idx := tableIdx
 		createBackupWorkingGroup.Go(func() error {
-			logger := log.With().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Logger()
+			logger := log.Ctx(ctx).With().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Logger()
 			var realSize, objectDiskSize map[string]int64
 			var disksToPartsMap map[string][]metadata.Part
 			if doBackupData && table.BackupType == clickhouse.ShardBackupFull {
 				logger.Debug().Msg("create data")
-				shadowBackupUUID := strings.ReplaceAll(uuid.New().String(), "-", "")
 				var addTableToBackupErr error
-				disksToPartsMap, realSize, objectDiskSize, addTableToBackupErr = b.AddTableToLocalBackup(createCtx, backupName, tablesDiffFromRemote, shadowBackupUUID, disks, &table, partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Name}], version)
+				if b.cfg.General.DataFormat != "" {
+					disksToPartsMap, realSize, addTableToBackupErr = b.AddTableToLocalBackupLogical(createCtx, backupName, disks, &table, partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Name}])
+				} else {
+					shadowBackupUUID, isFrozen := snapshot.getShadowBackupUUID(table)
+					disksToPartsMap, realSize, objectDiskSize, addTableToBackupErr = b.AddTableToLocalBackup(createCtx, backupName, tablesDiffFromRemote, shadowBackupUUID, isFrozen, sinceTablesParts[metadata.TableTitle{Database: table.Database, Table: table.Name}], disks, &table, partitionsIdMap[metadata.TableTitle{Database: table.Database, Table: table.Name}], version)
+				}
 				if addTableToBackupErr != nil {
 					logger.Error().Msgf("b.AddTableToLocalBackup error: %v", addTableToBackupErr)
 					return addTableToBackupErr
@@ -318,16 +437,22 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 				}
 			}
 			logger.Debug().Msg("create metadata")
+			dataFormat := ""
+			if len(disksToPartsMap) > 0 {
+				dataFormat = b.cfg.General.DataFormat
+			}
 			if schemaOnly || doBackupData {
 				metadataSize, createTableMetadataErr := b.createTableMetadata(path.Join(backupPath, "metadata"), metadata.TableMetadata{
-					Table:        table.Name,
-					Database:     table.Database,
-					Query:        table.CreateTableQuery,
-					TotalBytes:   table.TotalBytes,
-					Size:         realSize,
-					Parts:        disksToPartsMap,
-					Mutations:    inProgressMutations,
-					MetadataOnly: schemaOnly || table.BackupType == clickhouse.ShardBackupSchema,
+					Table:         table.Name,
+					Database:      table.Database,
+					Query:         table.CreateTableQuery,
+					TotalBytes:    table.TotalBytes,
+					Size:          realSize,
+					Parts:         disksToPartsMap,
+					Mutations:     inProgressMutations,
+					DataFormat:    dataFormat,
+					MetadataOnly:  schemaOnly || table.BackupType == clickhouse.ShardBackupSchema,
+					ConsumerState: consumerStates[metadata.TableTitle{Database: table.Database, Table: table.Name}],
 				}, disks)
 				if createTableMetadataErr != nil {
 					logger.Error().Msgf("b.createTableMetadata error: %v", createTableMetadataErr)
@@ -350,14 +475,14 @@ func (b *Backuper) createBackupLocal(ctx context.Context, backupName, diffFromRe
 	}
 
 	backupMetaFile := path.Join(b.DefaultDataPath, "backup", backupName, "metadata.json")
-	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, diffFromRemote, backupVersion, "regular", diskMap, diskTypes, disks, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize, tableMetas, allDatabases, allFunctions); err != nil {
+	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, requiredBackup, backupVersion, tags, diskMap, diskTypes, disks, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize, backupKeeperSize, tableMetas, allDatabases, allFunctions, allNamedCollections, allWorkloads, snapshot, since, watermark); err != nil {
 		return fmt.Errorf("createBackupMetadata return error: %v", err)
 	}
 	log.Info().Str("version", backupVersion).Str("operation", "createBackupLocal").Str("duration", utils.HumanizeDuration(time.Since(startBackup))).Msg("done")
 	return nil
 }
 
-func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBackup string, doBackupData, schemaOnly bool, backupVersion, tablePattern string, partitionsNameList map[metadata.TableTitle][]string, partitionsIdMap map[metadata.TableTitle]common.EmptyMap, tables []clickhouse.Table, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, disks []clickhouse.Disk, diskMap, diskTypes map[string]string, backupRBACSize, backupConfigSize uint64, startBackup time.Time, version int) error {
+func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBackup string, doBackupData, schemaOnly bool, backupVersion, tablePattern string, partitionsNameList map[metadata.TableTitle][]string, partitionsIdMap map[metadata.TableTitle]common.EmptyMap, tables []clickhouse.Table, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, allNamedCollections []clickhouse.NamedCollection, allWorkloads []clickhouse.Workload, disks []clickhouse.Disk, diskMap, diskTypes map[string]string, backupRBACSize, backupConfigSize, backupKeeperSize uint64, startBackup time.Time, version int) error {
 	// TODO: Implement sharded backup operations for embedded backups
 	if doesShard(b.cfg.General.ShardedOperationMode) {
 		return fmt.Errorf("cannot perform embedded backup: %w", errShardOperationUnsupported)
@@ -373,6 +498,7 @@ func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBac
 	if !schemaOnly && !doBackupData {
 		backupDataSize = append(backupDataSize, clickhouse.BackupDataSize{Size: 0})
 	}
+	consumerStates := b.getConsumerStates(ctx, tables)
 	var tablesTitle []metadata.TableTitle
 
 	if schemaOnly || doBackupData {
@@ -464,13 +590,14 @@ func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBac
 				}
 				if schemaOnly || doBackupData {
 					metadataSize, err := b.createTableMetadata(path.Join(backupPath, "metadata"), metadata.TableMetadata{
-						Table:        table.Name,
-						Database:     table.Database,
-						Query:        table.CreateTableQuery,
-						TotalBytes:   table.TotalBytes,
-						Size:         map[string]int64{b.cfg.ClickHouse.EmbeddedBackupDisk: 0},
-						Parts:        disksToPartsMap,
-						MetadataOnly: schemaOnly,
+						Table:         table.Name,
+						Database:      table.Database,
+						Query:         table.CreateTableQuery,
+						TotalBytes:    table.TotalBytes,
+						Size:          map[string]int64{b.cfg.ClickHouse.EmbeddedBackupDisk: 0},
+						Parts:         disksToPartsMap,
+						MetadataOnly:  schemaOnly,
+						ConsumerState: consumerStates[metadata.TableTitle{Database: table.Database, Table: table.Name}],
 					}, disks)
 					if err != nil {
 						return err
@@ -481,7 +608,7 @@ func (b *Backuper) createBackupEmbedded(ctx context.Context, backupName, baseBac
 		}
 	}
 	backupMetaFile := path.Join(backupPath, "metadata.json")
-	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, baseBackup, backupVersion, "embedded", diskMap, diskTypes, disks, backupDataSize[0].Size, 0, backupMetadataSize, backupRBACSize, backupConfigSize, tablesTitle, allDatabases, allFunctions); err != nil {
+	if err := b.createBackupMetadata(ctx, backupMetaFile, backupName, baseBackup, backupVersion, "embedded", diskMap, diskTypes, disks, backupDataSize[0].Size, 0, backupMetadataSize, backupRBACSize, backupConfigSize, backupKeeperSize, tablesTitle, allDatabases, allFunctions, allNamedCollections, allWorkloads, nil, "", nil); err != nil {
 		return err
 	}
 
@@ -741,7 +868,7 @@ func (b *Backuper) createBackupRBACReplicated(ctx context.Context, rbacBackup st
 	return rbacDataSize, nil
 }
 
-func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string, tablesDiffFromRemote map[metadata.TableTitle]metadata.TableMetadata, shadowBackupUUID string, diskList []clickhouse.Disk, table *clickhouse.Table, partitionsIdsMap common.EmptyMap, version int) (map[string][]metadata.Part, map[string]int64, map[string]int64, error) {
+func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string, tablesDiffFromRemote map[metadata.TableTitle]metadata.TableMetadata, shadowBackupUUID string, isFrozen bool, sinceParts *sinceTableParts, diskList []clickhouse.Disk, table *clickhouse.Table, partitionsIdsMap common.EmptyMap, version int) (map[string][]metadata.Part, map[string]int64, map[string]int64, error) {
 	logger := log.With().Fields(map[string]interface{}{
 		"backup":    backupName,
 		"operation": "create",
@@ -751,25 +878,38 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 		return nil, nil, nil, fmt.Errorf("backupName is not defined")
 	}
 
-	if !strings.HasSuffix(table.Engine, "MergeTree") && table.Engine != "MaterializedMySQL" && table.Engine != "MaterializedPostgreSQL" {
+	if !isFreezableEngine(table.Engine) {
 		if table.Engine != "MaterializedView" {
 			logger.Warn().Str("engine", table.Engine).Msg("supports only schema backup")
 		}
 		return nil, nil, nil, nil
 	}
-	if b.cfg.ClickHouse.CheckPartsColumns {
-		if err := b.ch.CheckSystemPartsColumns(ctx, table); err != nil {
+	// table could be already checked and frozen inside consistent snapshot freeze window
+	if !isFrozen {
+		if b.cfg.ClickHouse.CheckPartsColumns {
+			if err := b.ch.CheckSystemPartsColumns(ctx, table); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		// backup data, `create --since` and `tables` policy could freeze only part of partitions
+		b.ch.SyncReplica(ctx, table)
+		if err := b.freezeTable(ctx, table, shadowBackupUUID, sinceParts); err != nil {
 			return nil, nil, nil, err
 		}
+		log.Debug().Str("database", table.Database).Str("table", table.Name).Msg("frozen")
 	}
-	// backup data
-	if err := b.ch.FreezeTable(ctx, table, shadowBackupUUID); err != nil {
-		return nil, nil, nil, err
-	}
-	log.Debug().Str("database", table.Database).Str("table", table.Name).Msg("frozen")
 	realSize := map[string]int64{}
 	objectDiskSize := map[string]int64{}
 	disksToPartsMap := map[string][]metadata.Part{}
+	var sincePartsMap common.EmptyMap
+	var sinceRequiredParts map[string][]metadata.Part
+	if sinceParts != nil {
+		shadowParts, err := getShadowParts(diskList, shadowBackupUUID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sincePartsMap, sinceRequiredParts = sinceParts.selectShadowParts(shadowParts)
+	}
 
 	for _, disk := range diskList {
 		select {
@@ -795,7 +935,7 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 				return nil, nil, nil, err
 			}
 			// If partitionsIdsMap is not empty, only parts in this partition will back up.
-			parts, size, err := filesystemhelper.MoveShadowToBackup(shadowPath, backupShadowPath, partitionsIdsMap, tablesDiffFromRemote[metadata.TableTitle{Database: table.Database, Table: table.Name}], disk, version)
+			parts, size, err := filesystemhelper.MoveShadowToBackup(shadowPath, backupShadowPath, partitionsIdsMap, sincePartsMap, tablesDiffFromRemote[metadata.TableTitle{Database: table.Database, Table: table.Name}], disk, version)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -821,6 +961,17 @@ func (b *Backuper) AddTableToLocalBackup(ctx context.Context, backupName string,
 			}
 		}
 	}
+	if sinceParts != nil {
+		if sinceParts.localBaseBackup != "" {
+			if err := b.hardlinkRequiredParts(backupName, sinceParts.localBaseBackup, table, diskList, sinceRequiredParts); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		for diskName, requiredParts := range sinceRequiredParts {
+			disksToPartsMap[diskName] = append(disksToPartsMap[diskName], requiredParts...)
+			metadata.SortPartsByMinBlock(disksToPartsMap[diskName])
+		}
+	}
 	// Unfreeze to unlock data on S3 disks, https://github.com/Altinity/clickhouse-backup/issues/423
 	if version > 21004000 {
 		if err := b.ch.QueryContext(ctx, fmt.Sprintf("ALTER TABLE `%s`.`%s` UNFREEZE WITH NAME '%s'", table.Database, table.Name, shadowBackupUUID)); err != nil {
@@ -952,7 +1103,7 @@ func (b *Backuper) uploadObjectDiskParts(ctx context.Context, backupName string,
 	return size, nil
 }
 
-func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, backupName, requiredBackup, version, tags string, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize uint64, tableMetas []metadata.TableTitle, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function) error {
+func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, backupName, requiredBackup, version, tags string, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, backupDataSize, backupObjectDiskSize, backupMetadataSize, backupRBACSize, backupConfigSize, backupKeeperSize uint64, tableMetas []metadata.TableTitle, allDatabases []clickhouse.Database, allFunctions []clickhouse.Function, allNamedCollections []clickhouse.NamedCollection, allWorkloads []clickhouse.Workload, snapshot *consistentSnapshot, since string, watermark *metadata.Watermark) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -960,6 +1111,8 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 		backupMetadata := metadata.BackupMetadata{
 			BackupName:              backupName,
 			RequiredBackup:          requiredBackup,
+			Since:                   since,
+			Watermark:               watermark,
 			Disks:                   diskMap,
 			DiskTypes:               diskTypes,
 			ClickhouseBackupVersion: version,
@@ -971,6 +1124,7 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 			MetadataSize:            backupMetadataSize,
 			RBACSize:                backupRBACSize,
 			ConfigSize:              backupConfigSize,
+			KeeperSize:              backupKeeperSize,
 			Tables:                  tableMetas,
 			Databases:               []metadata.DatabasesMeta{},
 			Functions:               []metadata.FunctionsMeta{},
@@ -981,6 +1135,16 @@ func (b *Backuper) createBackupMetadata(ctx context.Context, backupMetaFile, bac
 		for _, function := range allFunctions {
 			backupMetadata.Functions = append(backupMetadata.Functions, metadata.FunctionsMeta(function))
 		}
+		for _, namedCollection := range allNamedCollections {
+			backupMetadata.NamedCollections = append(backupMetadata.NamedCollections, metadata.NamedCollectionsMeta(namedCollection))
+		}
+		for _, workload := range allWorkloads {
+			backupMetadata.Workloads = append(backupMetadata.Workloads, metadata.WorkloadsMeta(workload))
+		}
+		if snapshot != nil {
+			backupMetadata.SnapshotTime = &snapshot.snapshotTime
+			backupMetadata.FreezeWindow = utils.HumanizeDuration(snapshot.freezeWindow)
+		}
 		content, err := json.MarshalIndent(&backupMetadata, "", "\t")
 		if err != nil {
 			return fmt.Errorf("can't marshal backup metafile json: %v", err)
@@ -2,10 +2,26 @@ package backup
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
 	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
 )
 
-func (b *Backuper) CreateToRemote(backupName string, deleteSource bool, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, backupRBAC, rbacOnly, backupConfigs, configsOnly, skipCheckPartsColumns, resume bool, version string, commandId int) error {
+// StepFunc - wrap each sub-step of create_remote and restore_remote, API server use it to measure metrics of each sub-step
+type StepFunc func(subCommand string, stepFunc func() error) error
+
+// runStep - save current sub-step into command status and run it via step when it passed
+func runStep(step StepFunc, commandId int, subCommand string, stepFunc func() error) error {
+	status.Current.SetStep(commandId, subCommand)
+	if step == nil {
+		return stepFunc()
+	}
+	return step(subCommand, stepFunc)
+}
+
+func (b *Backuper) CreateToRemote(backupName string, deleteSource bool, diffFrom, diffFromRemote, since, tablePattern string, partitions []string, schemaOnly, backupRBAC, rbacOnly, backupConfigs, configsOnly, backupKeeper, backupNamedCollections, skipCheckPartsColumns, resume bool, storages []string, version string, commandId int, step StepFunc) error {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -15,12 +31,38 @@ func (b *Backuper) CreateToRemote(backupName string, deleteSource bool, diffFrom
 	if backupName == "" {
 		backupName = NewBackupName()
 	}
-	if err := b.CreateBackup(backupName, diffFromRemote, tablePattern, partitions, schemaOnly, backupRBAC, rbacOnly, backupConfigs, configsOnly, skipCheckPartsColumns, resume, version, commandId); err != nil {
-		return err
+	if since != "" && diffFrom != "" {
+		return fmt.Errorf("--since is not compatible with --diff-from")
 	}
-	if err := b.Upload(backupName, deleteSource, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, version, commandId); err != nil {
+	if err := runStep(step, commandId, "create", func() error {
+		return b.CreateBackup(backupName, diffFromRemote, since, tablePattern, partitions, schemaOnly, backupRBAC, rbacOnly, backupConfigs, configsOnly, backupKeeper, backupNamedCollections, skipCheckPartsColumns, resume, version, commandId)
+	}); err != nil {
 		return err
 	}
+	return runStep(step, commandId, "upload", func() error {
+		return b.UploadToStorages(backupName, deleteSource, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, storages, version, commandId)
+	})
+}
 
+// UploadToStorages - upload one local backup to several named storages from `storages` config section,
+// local backup shall be deleted only after upload to the last storage
+func (b *Backuper) UploadToStorages(backupName string, deleteSource bool, diffFrom, diffFromRemote, tablePattern string, partitions []string, schemaOnly, resume bool, storages []string, version string, commandId int) error {
+	if len(storages) <= 1 {
+		return b.Upload(backupName, deleteSource, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, version, commandId)
+	}
+	for i, storageName := range storages {
+		storageCfg, err := b.cfg.WithStorage(storageName)
+		if err != nil {
+			return err
+		}
+		isLastStorage := i == len(storages)-1
+		if !isLastStorage {
+			storageCfg.General.BackupsToKeepLocal = 0
+		}
+		log.Info().Str("storage", storageName).Str("progress", fmt.Sprintf("%d/%d", i+1, len(storages))).Msg("start upload")
+		if err = NewBackuper(storageCfg).Upload(backupName, deleteSource && isLastStorage, diffFrom, diffFromRemote, tablePattern, partitions, schemaOnly, resume, version, commandId); err != nil {
+			return fmt.Errorf("upload to `%s` storage return error: %v", storageName, err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,29 @@
+package backup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+func TestRunStep(t *testing.T) {
+	r := require.New(t)
+	steps := make([]string, 0)
+	step := func(subCommand string, stepFunc func() error) error {
+		steps = append(steps, subCommand)
+		return stepFunc()
+	}
+	r.NoError(runStep(step, status.NotFromAPI, "create", func() error { return nil }))
+	r.EqualError(runStep(step, status.NotFromAPI, "upload", func() error { return fmt.Errorf("upload error") }), "upload error")
+	r.Equal([]string{"create", "upload"}, steps)
+	r.NoError(runStep(nil, status.NotFromAPI, "download", func() error { return nil }))
+
+	// parameters validation shall be the same for CLI and API, before any step started
+	b := &Backuper{cfg: config.DefaultConfig()}
+	r.Error(b.CreateToRemote("backup", false, "base", "", "2024-01-01T00:00:00Z", "", nil, false, false, false, false, false, false, false, false, false, nil, "test", status.NotFromAPI, step))
+	r.Equal([]string{"create", "upload"}, steps)
+}
@@ -303,11 +303,18 @@ func (b *Backuper) RemoveBackupRemote(ctx context.Context, backupName string) er
 			if err != nil {
 				return err
 			}
+			sharedPartsHashes, err := b.getRemoteBackupSharedPartsHashes(ctx, backup)
+			if err != nil {
+				return err
+			}
 
 			if err = bd.RemoveBackupRemote(ctx, backup, b.cfg); err != nil {
 				log.Warn().Msgf("bd.RemoveBackup return error: %v", err)
 				return err
 			}
+			if err = b.cleanSharedParts(ctx, sharedPartsHashes); err != nil {
+				return err
+			}
 			log.Info().Fields(map[string]interface{}{
 				"backup":    backupName,
 				"location":  "remote",
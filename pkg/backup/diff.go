@@ -0,0 +1,570 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eapache/go-resiliency/retrier"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// BackupFieldDiff - changed field of metadata.BackupMetadata
+type BackupFieldDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// PartitionDiff - parts which present only in one of backups for the same partition
+type PartitionDiff struct {
+	Partition    string   `json:"partition"`
+	PartsAdded   []string `json:"parts_added,omitempty"`
+	PartsRemoved []string `json:"parts_removed,omitempty"`
+}
+
+// TableDiff - changes of the same table between two backups
+type TableDiff struct {
+	Database         string          `json:"database"`
+	Table            string          `json:"table"`
+	QueryA           string          `json:"query_a,omitempty"`
+	QueryB           string          `json:"query_b,omitempty"`
+	TotalBytesA      uint64          `json:"total_bytes_a"`
+	TotalBytesB      uint64          `json:"total_bytes_b"`
+	SizeDelta        int64           `json:"size_delta"`
+	Partitions       []PartitionDiff `json:"partitions,omitempty"`
+	MutationsAdded   []string        `json:"mutations_added,omitempty"`
+	MutationsRemoved []string        `json:"mutations_removed,omitempty"`
+}
+
+// QueryChanged - table schema differs
+func (td TableDiff) QueryChanged() bool {
+	return td.QueryA != td.QueryB
+}
+
+// BackupDiff - result of `clickhouse-backup diff`, B compared with A
+type BackupDiff struct {
+	BackupA                 string            `json:"backup_a"`
+	BackupB                 string            `json:"backup_b"`
+	Fields                  []BackupFieldDiff `json:"fields,omitempty"`
+	DatabasesAdded          []string          `json:"databases_added,omitempty"`
+	DatabasesRemoved        []string          `json:"databases_removed,omitempty"`
+	DatabasesChanged        []string          `json:"databases_changed,omitempty"`
+	FunctionsAdded          []string          `json:"functions_added,omitempty"`
+	FunctionsRemoved        []string          `json:"functions_removed,omitempty"`
+	FunctionsChanged        []string          `json:"functions_changed,omitempty"`
+	NamedCollectionsAdded   []string          `json:"named_collections_added,omitempty"`
+	NamedCollectionsRemoved []string          `json:"named_collections_removed,omitempty"`
+	NamedCollectionsChanged []string          `json:"named_collections_changed,omitempty"`
+	WorkloadsAdded          []string          `json:"workloads_added,omitempty"`
+	WorkloadsRemoved        []string          `json:"workloads_removed,omitempty"`
+	WorkloadsChanged        []string          `json:"workloads_changed,omitempty"`
+	FilesAdded              []string          `json:"files_added,omitempty"`
+	FilesRemoved            []string          `json:"files_removed,omitempty"`
+	FilesChanged            []string          `json:"files_changed,omitempty"`
+	TablesAdded             []string          `json:"tables_added,omitempty"`
+	TablesRemoved           []string          `json:"tables_removed,omitempty"`
+	TablesChanged           []TableDiff       `json:"tables_changed,omitempty"`
+}
+
+// Diff - compare two local or remote backups without restore them, print result to stdout
+func (b *Backuper) Diff(backupA, backupB string, remoteA, remoteB bool, tablePattern, format string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupA = utils.CleanBackupNameRE.ReplaceAllString(backupA, "")
+	backupB = utils.CleanBackupNameRE.ReplaceAllString(backupB, "")
+	if backupA == "" || backupB == "" {
+		return fmt.Errorf("two backup names required")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	if remoteA || remoteB {
+		if err = b.connectRemoteForRead(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := b.dst.Close(ctx); closeErr != nil {
+				log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+			}
+		}()
+	}
+	metaA, tablesA, err := b.readBackupContents(ctx, backupA, remoteA, tablePattern)
+	if err != nil {
+		return err
+	}
+	filesA, err := b.readBackupRelatedFilesChecksums(ctx, metaA, remoteA)
+	if err != nil {
+		return err
+	}
+	metaB, tablesB, err := b.readBackupContents(ctx, backupB, remoteB, tablePattern)
+	if err != nil {
+		return err
+	}
+	filesB, err := b.readBackupRelatedFilesChecksums(ctx, metaB, remoteB)
+	if err != nil {
+		return err
+	}
+	result := diffBackups(metaA, metaB, tablesA, tablesB, filesA, filesB)
+	return printBackupDiff(os.Stdout, result, format)
+}
+
+// connectRemoteForRead - initialize b.dst for commands which only read remote backup metadata
+func (b *Backuper) connectRemoteForRead(ctx context.Context) error {
+	if b.cfg.General.RemoteStorage == "none" || b.cfg.General.RemoteStorage == "custom" {
+		return fmt.Errorf("remote_storage: %s is not supported, only regular remote storage could be read", b.cfg.General.RemoteStorage)
+	}
+	bd, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, "")
+	if err != nil {
+		return err
+	}
+	if err = bd.Connect(ctx); err != nil {
+		return fmt.Errorf("can't connect to remote storage: %v", err)
+	}
+	b.dst = bd
+	return nil
+}
+
+// readBackupContents - read metadata.json and table metadata of local or remote backup, b.ch shall be connected and b.dst initialized for remote backup
+func (b *Backuper) readBackupContents(ctx context.Context, backupName string, remote bool, tablePattern string) (*metadata.BackupMetadata, ListOfTables, error) {
+	if remote {
+		backupMetadata, err := b.ReadBackupMetadataRemote(ctx, backupName)
+		if err != nil {
+			return nil, nil, err
+		}
+		b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
+		tables, err := getTableListByPatternRemote(ctx, b, backupMetadata, tablePattern, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return backupMetadata, tables, nil
+	}
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.DefaultDataPath, err = b.ch.GetDefaultPath(disks)
+	if err != nil {
+		return nil, nil, ErrUnknownClickhouseDataPath
+	}
+	localBackup, _, err := b.getLocalBackup(ctx, backupName, disks)
+	if err != nil {
+		return nil, nil, err
+	}
+	if localBackup.Broken != "" {
+		return nil, nil, fmt.Errorf("backup '%s' is broken: %s", backupName, localBackup.Broken)
+	}
+	b.isEmbedded = strings.Contains(localBackup.Tags, "embedded")
+	metadataPath := path.Join(b.DefaultDataPath, "backup", backupName, "metadata")
+	if b.isEmbedded {
+		if b.EmbeddedBackupDataPath, err = b.ch.GetEmbeddedBackupPath(disks); err != nil {
+			return nil, nil, err
+		}
+		if b.EmbeddedBackupDataPath == "" {
+			b.EmbeddedBackupDataPath = b.DefaultDataPath
+		}
+		metadataPath = path.Join(b.EmbeddedBackupDataPath, backupName, "metadata")
+	}
+	tables := ListOfTables{}
+	if _, statErr := os.Stat(metadataPath); statErr == nil {
+		if tables, _, err = b.getTableListByPatternLocal(ctx, metadataPath, tablePattern, false, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &localBackup.BackupMetadata, tables, nil
+}
+
+// readBackupRelatedFilesChecksums - sha256 of each RBAC and config file with `access/` or `configs/` prefix, shall be called after readBackupContents for the same backup,
+// remote files download into temporary directory, RBAC and configs are small
+func (b *Backuper) readBackupRelatedFilesChecksums(ctx context.Context, backupMetadata *metadata.BackupMetadata, remote bool) (map[string]string, error) {
+	checksums := map[string]string{}
+	prefixes := make([]string, 0, 2)
+	if backupMetadata.RBACSize > 0 {
+		prefixes = append(prefixes, "access")
+	}
+	if backupMetadata.ConfigSize > 0 {
+		prefixes = append(prefixes, "configs")
+	}
+	if len(prefixes) == 0 {
+		return checksums, nil
+	}
+	localBackupPath := path.Join(b.DefaultDataPath, "backup", backupMetadata.BackupName)
+	if remote {
+		tmpDir, err := os.MkdirTemp("", "clickhouse-backup-diff-")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if removeErr := os.RemoveAll(tmpDir); removeErr != nil {
+				log.Warn().Msgf("can't remove %s: %v", tmpDir, removeErr)
+			}
+		}()
+		localBackupPath = tmpDir
+		for _, prefix := range prefixes {
+			if err = b.downloadBackupRelatedDirTo(ctx, backupMetadata, prefix, path.Join(tmpDir, prefix)); err != nil {
+				return nil, err
+			}
+		}
+	} else if b.isEmbedded {
+		localBackupPath = path.Join(b.EmbeddedBackupDataPath, backupMetadata.BackupName)
+	}
+	for _, prefix := range prefixes {
+		if err := getBackupRelatedFilesChecksums(path.Join(localBackupPath, prefix), prefix, checksums); err != nil {
+			return nil, err
+		}
+	}
+	return checksums, nil
+}
+
+// downloadBackupRelatedDirTo - download `access` or `configs` of remote backup into localDir without resumable state
+func (b *Backuper) downloadBackupRelatedDirTo(ctx context.Context, backupMetadata *metadata.BackupMetadata, prefix, localDir string) error {
+	remoteSource := path.Join(backupMetadata.BackupName, prefix)
+	if backupMetadata.DataFormat == DirectoryFormat {
+		if err := b.dst.DownloadPath(ctx, remoteSource, localDir, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil && !strings.Contains(err.Error(), "not exist") {
+			return err
+		}
+		return nil
+	}
+	remoteSource = fmt.Sprintf("%s.%s", remoteSource, config.ArchiveExtensions[backupMetadata.DataFormat])
+	if _, err := b.dst.StatFile(ctx, remoteSource); err != nil {
+		log.Debug().Msgf("%s not exists on remote storage, skip download", remoteSource)
+		return nil
+	}
+	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	return retry.RunCtx(ctx, func(ctx context.Context) error {
+		return b.dst.DownloadCompressedStreamWithFormat(ctx, remoteSource, localDir, b.cfg.General.DownloadMaxBytesPerSecond, backupMetadata.DataFormat)
+	})
+}
+
+// getBackupRelatedFilesChecksums - add sha256 of each file from dir into checksums with `<prefix>/<relative_path>` key, absent dir is empty
+func getBackupRelatedFilesChecksums(dir, prefix string, checksums map[string]string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(dir, func(fPath string, fInfo fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fInfo.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(dir, fPath)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(fPath)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		if closeErr := f.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", fPath, closeErr)
+		}
+		if err != nil {
+			return err
+		}
+		checksums[path.Join(prefix, filepath.ToSlash(relativePath))] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+}
+
+// diffBackups - compare backup B with backup A, all lists in result are sorted, filesA and filesB contain RBAC and config files checksums
+func diffBackups(a, b *metadata.BackupMetadata, tablesA, tablesB ListOfTables, filesA, filesB map[string]string) BackupDiff {
+	result := BackupDiff{
+		BackupA: a.BackupName,
+		BackupB: b.BackupName,
+	}
+	addField := func(field, valueA, valueB string) {
+		if valueA != valueB {
+			result.Fields = append(result.Fields, BackupFieldDiff{Field: field, A: valueA, B: valueB})
+		}
+	}
+	addSizeField := func(field string, valueA, valueB uint64) {
+		if valueA != valueB {
+			result.Fields = append(result.Fields, BackupFieldDiff{Field: field, A: utils.FormatBytes(valueA), B: utils.FormatBytes(valueB)})
+		}
+	}
+	addField("version", a.ClickhouseBackupVersion, b.ClickhouseBackupVersion)
+	addField("clickhouse_version", a.ClickHouseVersion, b.ClickHouseVersion)
+	addField("tags", a.Tags, b.Tags)
+	addField("data_format", a.DataFormat, b.DataFormat)
+	addField("required_backup", a.RequiredBackup, b.RequiredBackup)
+	addField("disks", formatStringMap(a.Disks), formatStringMap(b.Disks))
+	addField("disk_types", formatStringMap(a.DiskTypes), formatStringMap(b.DiskTypes))
+	addSizeField("data_size", a.DataSize, b.DataSize)
+	addSizeField("object_disk_size", a.ObjectDiskSize, b.ObjectDiskSize)
+	addSizeField("metadata_size", a.MetadataSize, b.MetadataSize)
+	addSizeField("rbac_size", a.RBACSize, b.RBACSize)
+	addSizeField("config_size", a.ConfigSize, b.ConfigSize)
+	addSizeField("keeper_size", a.KeeperSize, b.KeeperSize)
+	addSizeField("compressed_size", a.CompressedSize, b.CompressedSize)
+
+	databasesA := map[string]string{}
+	for _, db := range a.Databases {
+		databasesA[db.Name] = db.Query
+	}
+	databasesB := map[string]string{}
+	for _, db := range b.Databases {
+		databasesB[db.Name] = db.Query
+	}
+	result.DatabasesAdded, result.DatabasesRemoved, result.DatabasesChanged = diffStringMaps(databasesA, databasesB)
+
+	functionsA := map[string]string{}
+	for _, f := range a.Functions {
+		functionsA[f.Name] = f.CreateQuery
+	}
+	functionsB := map[string]string{}
+	for _, f := range b.Functions {
+		functionsB[f.Name] = f.CreateQuery
+	}
+	result.FunctionsAdded, result.FunctionsRemoved, result.FunctionsChanged = diffStringMaps(functionsA, functionsB)
+
+	namedCollectionsA := map[string]string{}
+	for _, nc := range a.NamedCollections {
+		namedCollectionsA[nc.Name] = nc.CreateQuery
+	}
+	namedCollectionsB := map[string]string{}
+	for _, nc := range b.NamedCollections {
+		namedCollectionsB[nc.Name] = nc.CreateQuery
+	}
+	result.NamedCollectionsAdded, result.NamedCollectionsRemoved, result.NamedCollectionsChanged = diffStringMaps(namedCollectionsA, namedCollectionsB)
+
+	workloadsA := map[string]string{}
+	for _, w := range a.Workloads {
+		workloadsA[strings.ToLower(w.Kind)+" "+w.Name] = w.CreateQuery
+	}
+	workloadsB := map[string]string{}
+	for _, w := range b.Workloads {
+		workloadsB[strings.ToLower(w.Kind)+" "+w.Name] = w.CreateQuery
+	}
+	result.WorkloadsAdded, result.WorkloadsRemoved, result.WorkloadsChanged = diffStringMaps(workloadsA, workloadsB)
+	result.FilesAdded, result.FilesRemoved, result.FilesChanged = diffStringMaps(filesA, filesB)
+
+	tableMapA := map[metadata.TableTitle]metadata.TableMetadata{}
+	for _, t := range tablesA {
+		tableMapA[metadata.TableTitle{Database: t.Database, Table: t.Table}] = t
+	}
+	tableMapB := map[metadata.TableTitle]metadata.TableMetadata{}
+	for _, t := range tablesB {
+		tableMapB[metadata.TableTitle{Database: t.Database, Table: t.Table}] = t
+	}
+	for title, tableB := range tableMapB {
+		tableA, exists := tableMapA[title]
+		if !exists {
+			result.TablesAdded = append(result.TablesAdded, fmt.Sprintf("%s.%s", title.Database, title.Table))
+			continue
+		}
+		if tableDiff, changed := diffTables(tableA, tableB); changed {
+			result.TablesChanged = append(result.TablesChanged, tableDiff)
+		}
+	}
+	for title := range tableMapA {
+		if _, exists := tableMapB[title]; !exists {
+			result.TablesRemoved = append(result.TablesRemoved, fmt.Sprintf("%s.%s", title.Database, title.Table))
+		}
+	}
+	sort.Strings(result.TablesAdded)
+	sort.Strings(result.TablesRemoved)
+	sort.Slice(result.TablesChanged, func(i, j int) bool {
+		if result.TablesChanged[i].Database != result.TablesChanged[j].Database {
+			return result.TablesChanged[i].Database < result.TablesChanged[j].Database
+		}
+		return result.TablesChanged[i].Table < result.TablesChanged[j].Table
+	})
+	return result
+}
+
+// diffTables - compare query, parts, sizes and mutations, return false when table not changed
+func diffTables(a, b metadata.TableMetadata) (TableDiff, bool) {
+	result := TableDiff{
+		Database:    b.Database,
+		Table:       b.Table,
+		TotalBytesA: a.TotalBytes,
+		TotalBytesB: b.TotalBytes,
+		SizeDelta:   int64(b.TotalBytes) - int64(a.TotalBytes),
+	}
+	if a.Query != b.Query {
+		result.QueryA = a.Query
+		result.QueryB = b.Query
+	}
+	partsA := partsByPartition(a.Parts)
+	partsB := partsByPartition(b.Parts)
+	partitions := map[string]struct{}{}
+	for partition := range partsA {
+		partitions[partition] = struct{}{}
+	}
+	for partition := range partsB {
+		partitions[partition] = struct{}{}
+	}
+	for partition := range partitions {
+		partitionDiff := PartitionDiff{Partition: partition}
+		for partName := range partsB[partition] {
+			if _, exists := partsA[partition][partName]; !exists {
+				partitionDiff.PartsAdded = append(partitionDiff.PartsAdded, partName)
+			}
+		}
+		for partName := range partsA[partition] {
+			if _, exists := partsB[partition][partName]; !exists {
+				partitionDiff.PartsRemoved = append(partitionDiff.PartsRemoved, partName)
+			}
+		}
+		if len(partitionDiff.PartsAdded) > 0 || len(partitionDiff.PartsRemoved) > 0 {
+			sort.Strings(partitionDiff.PartsAdded)
+			sort.Strings(partitionDiff.PartsRemoved)
+			result.Partitions = append(result.Partitions, partitionDiff)
+		}
+	}
+	sort.Slice(result.Partitions, func(i, j int) bool {
+		return result.Partitions[i].Partition < result.Partitions[j].Partition
+	})
+	mutationsA := map[string]string{}
+	for _, m := range a.Mutations {
+		mutationsA[m.MutationId] = m.Command
+	}
+	mutationsB := map[string]string{}
+	for _, m := range b.Mutations {
+		mutationsB[m.MutationId] = m.Command
+	}
+	result.MutationsAdded, result.MutationsRemoved, _ = diffStringMaps(mutationsA, mutationsB)
+	changed := result.QueryChanged() || result.SizeDelta != 0 || len(result.Partitions) > 0 || len(result.MutationsAdded) > 0 || len(result.MutationsRemoved) > 0
+	return result, changed
+}
+
+// partsByPartition - partition id is the first part name component, see metadata.SortPartsByMinBlock
+func partsByPartition(parts map[string][]metadata.Part) map[string]map[string]struct{} {
+	result := map[string]map[string]struct{}{}
+	for _, diskParts := range parts {
+		for _, part := range diskParts {
+			partition := strings.Split(part.Name, "_")[0]
+			if _, exists := result[partition]; !exists {
+				result[partition] = map[string]struct{}{}
+			}
+			result[partition][part.Name] = struct{}{}
+		}
+	}
+	return result
+}
+
+// diffStringMaps - return sorted keys which present only in b, only in a and present in both with different values
+func diffStringMaps(a, b map[string]string) ([]string, []string, []string) {
+	var added, removed, changed []string
+	for key, valueB := range b {
+		if valueA, exists := a[key]; !exists {
+			added = append(added, key)
+		} else if valueA != valueB {
+			changed = append(changed, key)
+		}
+	}
+	for key := range a {
+		if _, exists := b[key]; !exists {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+func formatStringMap(m map[string]string) string {
+	items := make([]string, 0, len(m))
+	for k, v := range m {
+		items = append(items, k+"="+v)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + utils.FormatBytes(uint64(-delta))
+	}
+	return "+" + utils.FormatBytes(uint64(delta))
+}
+
+func printBackupDiff(out io.Writer, result BackupDiff, format string) error {
+	if format == "json" {
+		body, err := json.MarshalIndent(result, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(body))
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	printRow := func(format string, args ...interface{}) {
+		if bytes, err := fmt.Fprintf(w, format, args...); err != nil {
+			log.Error().Msgf("fmt.Fprintf write %d bytes return error: %v", bytes, err)
+		}
+	}
+	printRow("--- %s\n+++ %s\n", result.BackupA, result.BackupB)
+	for _, field := range result.Fields {
+		printRow("~ %s\t%s\t->\t%s\n", field.Field, field.A, field.B)
+	}
+	printList := func(prefix, kind string, names []string) {
+		for _, name := range names {
+			printRow("%s %s\t%s\n", prefix, kind, name)
+		}
+	}
+	printList("+", "database", result.DatabasesAdded)
+	printList("-", "database", result.DatabasesRemoved)
+	printList("~", "database", result.DatabasesChanged)
+	printList("+", "function", result.FunctionsAdded)
+	printList("-", "function", result.FunctionsRemoved)
+	printList("~", "function", result.FunctionsChanged)
+	printList("+", "named_collection", result.NamedCollectionsAdded)
+	printList("-", "named_collection", result.NamedCollectionsRemoved)
+	printList("~", "named_collection", result.NamedCollectionsChanged)
+	printList("+", "workload", result.WorkloadsAdded)
+	printList("-", "workload", result.WorkloadsRemoved)
+	printList("~", "workload", result.WorkloadsChanged)
+	printList("+", "file", result.FilesAdded)
+	printList("-", "file", result.FilesRemoved)
+	printList("~", "file", result.FilesChanged)
+	printList("+", "table", result.TablesAdded)
+	printList("-", "table", result.TablesRemoved)
+	for _, table := range result.TablesChanged {
+		printRow("~ table\t%s.%s\t%s\t->\t%s\t(%s)\n", table.Database, table.Table, utils.FormatBytes(table.TotalBytesA), utils.FormatBytes(table.TotalBytesB), formatSizeDelta(table.SizeDelta))
+		if table.QueryChanged() {
+			printRow("  - query\t%s\n  + query\t%s\n", table.QueryA, table.QueryB)
+		}
+		for _, partition := range table.Partitions {
+			printRow("  ~ partition\t%s\t+%d parts\t-%d parts\n", partition.Partition, len(partition.PartsAdded), len(partition.PartsRemoved))
+			for _, part := range partition.PartsAdded {
+				printRow("    + part\t%s\n", part)
+			}
+			for _, part := range partition.PartsRemoved {
+				printRow("    - part\t%s\n", part)
+			}
+		}
+		for _, mutation := range table.MutationsAdded {
+			printRow("  + mutation\t%s\n", mutation)
+		}
+		for _, mutation := range table.MutationsRemoved {
+			printRow("  - mutation\t%s\n", mutation)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestDiffBackups(t *testing.T) {
+	a := &metadata.BackupMetadata{
+		BackupName: "nightly1",
+		DataSize:   1000,
+		RBACSize:   10,
+		Databases:  []metadata.DatabasesMeta{{Name: "db", Query: "CREATE DATABASE db"}, {Name: "old_db", Query: "CREATE DATABASE old_db"}},
+	}
+	b := &metadata.BackupMetadata{
+		BackupName: "nightly2",
+		DataSize:   1500,
+		RBACSize:   20,
+		Databases:  []metadata.DatabasesMeta{{Name: "db", Query: "CREATE DATABASE db ENGINE=Atomic"}},
+	}
+	tablesA := ListOfTables{
+		{
+			Database:   "db",
+			Table:      "events",
+			Query:      "CREATE TABLE db.events (id UInt64) ENGINE=MergeTree PARTITION BY id ORDER BY id",
+			TotalBytes: 800,
+			Parts: map[string][]metadata.Part{
+				"default": {{Name: "202401_1_1_0"}, {Name: "202402_2_2_0"}},
+			},
+		},
+		{Database: "db", Table: "removed", Query: "CREATE TABLE db.removed (id UInt64) ENGINE=Memory"},
+		{Database: "db", Table: "same", Query: "CREATE TABLE db.same (id UInt64) ENGINE=Memory"},
+	}
+	tablesB := ListOfTables{
+		{
+			Database:   "db",
+			Table:      "events",
+			Query:      "CREATE TABLE db.events (id UInt64, name String) ENGINE=MergeTree PARTITION BY id ORDER BY id",
+			TotalBytes: 600,
+			Parts: map[string][]metadata.Part{
+				"default": {{Name: "202401_1_1_0"}},
+				"hdd":     {{Name: "202402_2_3_1"}, {Name: "202403_4_4_0", Required: true}},
+			},
+			Mutations: []metadata.MutationMetadata{{MutationId: "0000000001", Command: "DELETE WHERE id=1"}},
+		},
+		{Database: "db", Table: "added", Query: "CREATE TABLE db.added (id UInt64) ENGINE=Memory"},
+		{Database: "db", Table: "same", Query: "CREATE TABLE db.same (id UInt64) ENGINE=Memory"},
+	}
+
+	filesA := map[string]string{"access/users.jsonl": "aaa", "access/roles.jsonl": "bbb", "configs/config.xml": "ccc"}
+	// the same size, but different content
+	filesB := map[string]string{"access/users.jsonl": "abc", "configs/config.xml": "ccc", "configs/users.d/default.xml": "ddd"}
+	result := diffBackups(a, b, tablesA, tablesB, filesA, filesB)
+	assert.Equal(t, []string{"db.added"}, result.TablesAdded)
+	assert.Equal(t, []string{"db.removed"}, result.TablesRemoved)
+	assert.Equal(t, []string{"old_db"}, result.DatabasesRemoved)
+	assert.Equal(t, []string{"db"}, result.DatabasesChanged)
+	fields := map[string]BackupFieldDiff{}
+	for _, field := range result.Fields {
+		fields[field.Field] = field
+	}
+	assert.Contains(t, fields, "data_size")
+	assert.Contains(t, fields, "rbac_size")
+	assert.NotContains(t, fields, "config_size")
+	assert.Equal(t, []string{"configs/users.d/default.xml"}, result.FilesAdded)
+	assert.Equal(t, []string{"access/roles.jsonl"}, result.FilesRemoved)
+	assert.Equal(t, []string{"access/users.jsonl"}, result.FilesChanged)
+
+	assert.Len(t, result.TablesChanged, 1)
+	events := result.TablesChanged[0]
+	assert.True(t, events.QueryChanged())
+	assert.Equal(t, int64(-200), events.SizeDelta)
+	assert.Equal(t, []string{"0000000001"}, events.MutationsAdded)
+	assert.Equal(t, []PartitionDiff{
+		{Partition: "202402", PartsAdded: []string{"202402_2_3_1"}, PartsRemoved: []string{"202402_2_2_0"}},
+		{Partition: "202403", PartsAdded: []string{"202403_4_4_0"}},
+	}, events.Partitions)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printBackupDiff(out, result, "text"))
+	assert.True(t, strings.HasPrefix(out.String(), "--- nightly1\n+++ nightly2\n"))
+	assert.Contains(t, out.String(), "db.added")
+	assert.Contains(t, out.String(), "~ partition")
+
+	out.Reset()
+	assert.NoError(t, printBackupDiff(out, result, "json"))
+	assert.Contains(t, out.String(), `"parts_added": [`)
+}
+
+func TestGetBackupRelatedFilesChecksums(t *testing.T) {
+	backupPath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(backupPath, "configs", "users.d"), 0750))
+	assert.NoError(t, os.WriteFile(path.Join(backupPath, "configs", "config.xml"), []byte("<clickhouse/>"), 0640))
+	assert.NoError(t, os.WriteFile(path.Join(backupPath, "configs", "users.d", "default.xml"), []byte("<clickhouse/>"), 0640))
+	checksums := map[string]string{}
+	assert.NoError(t, getBackupRelatedFilesChecksums(path.Join(backupPath, "access"), "access", checksums))
+	assert.Empty(t, checksums)
+	assert.NoError(t, getBackupRelatedFilesChecksums(path.Join(backupPath, "configs"), "configs", checksums))
+	assert.Len(t, checksums, 2)
+	assert.Equal(t, checksums["configs/config.xml"], checksums["configs/users.d/default.xml"])
+
+	assert.NoError(t, os.WriteFile(path.Join(backupPath, "configs", "config.xml"), []byte("<yandex/>"), 0640))
+	changed := map[string]string{}
+	assert.NoError(t, getBackupRelatedFilesChecksums(path.Join(backupPath, "configs"), "configs", changed))
+	assert.NotEqual(t, checksums["configs/config.xml"], changed["configs/config.xml"])
+}
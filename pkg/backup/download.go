@@ -147,6 +147,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 			"tablePattern": tablePattern,
 			"partitions":   partitions,
 			"schemaOnly":   schemaOnly,
+			"storage":      b.cfg.StorageName,
 		})
 	}
 
@@ -197,7 +198,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 				if err := b.downloadTableData(dataCtx, remoteBackup.BackupMetadata, *tableMetadataAfterDownload[idx]); err != nil {
 					return err
 				}
-				log.Info().Fields(map[string]interface{}{
+				log.Ctx(ctx).Info().Fields(map[string]interface{}{
 					"backup_name": backupName,
 					"operation":   "download_data",
 					"table":       fmt.Sprintf("%s.%s", tableMetadataAfterDownload[idx].Database, tableMetadataAfterDownload[idx].Table),
@@ -213,7 +214,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 			return fmt.Errorf("one of Download go-routine return error: %v", err)
 		}
 	}
-	var rbacSize, configSize uint64
+	var rbacSize, configSize, keeperSize uint64
 	rbacSize, err = b.downloadRBACData(ctx, remoteBackup)
 	if err != nil {
 		return fmt.Errorf("download RBAC error: %v", err)
@@ -224,6 +225,11 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 		return fmt.Errorf("download CONFIGS error: %v", err)
 	}
 
+	keeperSize, err = b.downloadKeeperData(ctx, remoteBackup)
+	if err != nil {
+		return fmt.Errorf("download KEEPER error: %v", err)
+	}
+
 	backupMetadata := remoteBackup.BackupMetadata
 	backupMetadata.Tables = tablesForDownload
 
@@ -243,6 +249,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 	backupMetadata.MetadataSize = metadataSize
 	backupMetadata.ConfigSize = configSize
 	backupMetadata.RBACSize = rbacSize
+	backupMetadata.KeeperSize = keeperSize
 	backupMetadata.ClickhouseBackupVersion = backupVersion
 	backupMetafileLocalPath := path.Join(b.DefaultDataPath, "backup", backupName, "metadata.json")
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
@@ -274,7 +281,7 @@ func (b *Backuper) Download(backupName string, tablePattern string, partitions [
 		"backup":           backupName,
 		"operation":        "download",
 		"duration":         utils.HumanizeDuration(time.Since(startDownload)),
-		"download_size":    utils.FormatBytes(dataSize + metadataSize + rbacSize + configSize),
+		"download_size":    utils.FormatBytes(dataSize + metadataSize + rbacSize + configSize + keeperSize),
 		"object_disk_size": utils.FormatBytes(backupMetadata.ObjectDiskSize),
 		"version":          backupVersion,
 	}).Msg("done")
@@ -347,8 +354,8 @@ func (b *Backuper) reBalanceTablesMetadataIfDiskNotExists(tableMetadataAfterDown
 					if !isObjectDisk {
 						updateDiskFreeSize(downloadDisk, diskType, storagePolicy, newFreeSpace)
 					}
-					//re-balance file depend on part
-					if t.Files != nil && len(t.Files) > 0 {
+					//re-balance file depend on part, shared parts are not stored in files
+					if t.Files != nil && len(t.Files) > 0 && t.Parts[disk][j].Hash == "" {
 						if len(t.Files[disk]) == 0 {
 							return fmt.Errorf("table: `%s`.`%s` part.Name: %s, part.RebalancedDisk: %s, non empty `files` can't find disk: %s", t.Table, t.Database, t.Parts[disk][j].Name, t.Parts[disk][j].RebalancedDisk, disk)
 						}
@@ -544,6 +551,10 @@ func (b *Backuper) downloadConfigData(ctx context.Context, remoteBackup storage.
 	return b.downloadBackupRelatedDir(ctx, remoteBackup, "configs")
 }
 
+func (b *Backuper) downloadKeeperData(ctx context.Context, remoteBackup storage.Backup) (uint64, error) {
+	return b.downloadBackupRelatedDir(ctx, remoteBackup, "keeper")
+}
+
 func (b *Backuper) downloadBackupRelatedDir(ctx context.Context, remoteBackup storage.Backup, prefix string) (uint64, error) {
 	localDir := path.Join(b.DefaultDataPath, "backup", remoteBackup.BackupName, prefix)
 
@@ -634,18 +645,22 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 				tableRemoteFile := path.Join(remoteBackup.BackupName, "shadow", common.TablePathEncode(table.Database), common.TablePathEncode(table.Table), archiveFile)
 				dataGroup.Go(func() error {
 					log.Debug().Msgf("start download %s", tableRemoteFile)
-					if b.resume && b.resumableState.IsAlreadyProcessedBool(tableRemoteFile) {
+					if b.resume && b.isResumableDownloadProcessed(tableRemoteFile, tableLocalDir, false) {
 						return nil
 					}
 					retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
 					err := retry.RunCtx(dataCtx, func(dataCtx context.Context) error {
-						return b.dst.DownloadCompressedStream(dataCtx, tableRemoteFile, tableLocalDir, b.cfg.General.DownloadMaxBytesPerSecond)
+						return b.dst.DownloadCompressedStreamWithFormat(dataCtx, tableRemoteFile, tableLocalDir, b.cfg.General.DownloadMaxBytesPerSecond, getTableArchiveFormat(remoteBackup.DataFormat, table))
 					})
 					if err != nil {
 						return err
 					}
 					if b.resume {
-						b.resumableState.AppendToState(tableRemoteFile, 0)
+						remoteFileInfo, err := b.dst.StatFile(dataCtx, tableRemoteFile)
+						if err != nil {
+							return fmt.Errorf("can't stat downloaded %s: %v", tableRemoteFile, err)
+						}
+						b.resumableState.AppendToState(tableRemoteFile, remoteFileInfo.Size())
 					}
 					log.Debug().Msgf("finish download %s", tableRemoteFile)
 					return nil
@@ -667,7 +682,7 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 				tableLocalPath = path.Join(diskPath, remoteBackup.BackupName, "data", dbAndTableDir)
 			}
 			for _, part := range parts {
-				if part.Required {
+				if part.Required || part.Hash != "" {
 					continue
 				}
 				if !diskExists {
@@ -680,14 +695,18 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 				partLocalPath := path.Join(tableLocalPath, part.Name)
 				dataGroup.Go(func() error {
 					log.Debug().Msgf("start %s -> %s", partRemotePath, partLocalPath)
-					if b.resume && b.resumableState.IsAlreadyProcessedBool(partRemotePath) {
+					if b.resume && b.isResumableDownloadProcessed(partRemotePath, partLocalPath, true) {
 						return nil
 					}
 					if err := b.dst.DownloadPath(dataCtx, partRemotePath, partLocalPath, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
 						return err
 					}
 					if b.resume {
-						b.resumableState.AppendToState(partRemotePath, 0)
+						partSize, err := getLocalPathSize(partLocalPath)
+						if err != nil {
+							return fmt.Errorf("can't calculate size of downloaded %s: %v", partLocalPath, err)
+						}
+						b.resumableState.AppendToState(partRemotePath, partSize)
 					}
 					log.Debug().Msgf("finish %s -> %s", partRemotePath, partLocalPath)
 					return nil
@@ -695,6 +714,26 @@ func (b *Backuper) downloadTableData(ctx context.Context, remoteBackup metadata.
 			}
 		}
 	}
+	for disk, parts := range table.Parts {
+		for _, part := range parts {
+			if part.Hash == "" {
+				continue
+			}
+			diskName := disk
+			if _, diskExists := b.DiskToPathMap[disk]; !diskExists {
+				diskName = part.RebalancedDisk
+				if _, diskExists = b.DiskToPathMap[diskName]; !diskExists {
+					return fmt.Errorf("downloadTableData: table: `%s`.`%s`, disk: %s, part.Name: %s, part.RebalancedDisk: %s not rebalanced", table.Table, table.Database, disk, part.Name, part.RebalancedDisk)
+				}
+			}
+			partLocalPath := path.Join(b.getLocalBackupDataPathForTable(remoteBackup.BackupName, diskName, dbAndTableDir), part.Name)
+			partHash := part.Hash
+			dataGroup.Go(func() error {
+				log.Debug().Msgf("start download shared part %s -> %s", partHash, partLocalPath)
+				return b.downloadSharedPart(dataCtx, remoteBackup.DataFormat, partHash, partLocalPath)
+			})
+		}
+	}
 	if err := dataGroup.Wait(); err != nil {
 		return fmt.Errorf("one of downloadTableData go-routine return error: %v", err)
 	}
@@ -865,6 +904,40 @@ func (b *Backuper) downloadDiffRemoteFile(ctx context.Context, diffRemoteFilesLo
 	return nil
 }
 
+// isResumableDownloadProcessed - local backup data could be removed or partially written after crash, so resumable state record is trusted only when localPath still exists,
+// when checkSize is true localPath shall contain the same amount of bytes which was recorded after download
+func (b *Backuper) isResumableDownloadProcessed(remotePath, localPath string, checkSize bool) bool {
+	isProcessed, processedSize := b.resumableState.IsAlreadyProcessed(remotePath)
+	if !isProcessed {
+		return false
+	}
+	localSize, err := getLocalPathSize(localPath)
+	if err != nil {
+		log.Warn().Msgf("%s already processed, but %s is not available: %v, will download again", remotePath, localPath, err)
+		return false
+	}
+	if checkSize && processedSize > 0 && localSize != processedSize {
+		log.Warn().Msgf("%s already processed with size %d, but %s contains %d bytes, will download again", remotePath, processedSize, localPath, localSize)
+		return false
+	}
+	return true
+}
+
+// getLocalPathSize - total size of regular files inside localPath
+func getLocalPathSize(localPath string) (int64, error) {
+	size := int64(0)
+	err := filepath.Walk(localPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 func (b *Backuper) checkNewPath(newPath string, part metadata.Part) error {
 	info, err := os.Stat(newPath)
 	if err != nil && !os.IsNotExist(err) {
@@ -898,9 +971,25 @@ func (b *Backuper) findDiffBackupFilesRemote(ctx context.Context, backup metadat
 		return tableRemoteFiles, nil
 	}
 
+	// part in RequiredBackup could be stored in shared content addressed area
+	for _, requiredParts := range requiredTable.Parts {
+		for _, requiredPart := range requiredParts {
+			if requiredPart.Name == part.Name && requiredPart.Hash != "" {
+				localDisk := disk
+				if _, diskExists := b.DiskToPathMap[localDisk]; !diskExists {
+					localDisk = part.RebalancedDisk
+				}
+				dbAndTableDir := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+				localPartDir := path.Join(b.DiskToPathMap[localDisk], "backup", requiredBackup.BackupName, "shadow", dbAndTableDir, localDisk, part.Name)
+				return map[string]string{getSharedPartRemotePath(requiredPart.Hash, requiredBackup.DataFormat): localPartDir}, nil
+			}
+		}
+	}
+
 	found = false
 	// try to find part on the same disk
-	tableRemoteFiles, err, found = b.findDiffOnePart(ctx, requiredBackup, table, disk, disk, part)
+	// requiredTable could be compressed by another `tables->compression_format`
+	tableRemoteFiles, err, found = b.findDiffOnePart(ctx, requiredBackup, *requiredTable, disk, disk, part)
 	if found {
 		return tableRemoteFiles, nil
 	}
@@ -908,7 +997,7 @@ func (b *Backuper) findDiffBackupFilesRemote(ctx context.Context, backup metadat
 	// try to find part on other disks
 	for requiredDisk := range requiredBackup.Disks {
 		if requiredDisk != disk {
-			tableRemoteFiles, err, found = b.findDiffOnePart(ctx, requiredBackup, table, disk, requiredDisk, part)
+			tableRemoteFiles, err, found = b.findDiffOnePart(ctx, requiredBackup, *requiredTable, disk, requiredDisk, part)
 			if found {
 				return tableRemoteFiles, nil
 			}
@@ -989,7 +1078,7 @@ func (b *Backuper) findDiffOnePartDirectory(ctx context.Context, requiredBackup
 func (b *Backuper) findDiffOnePartArchive(ctx context.Context, requiredBackup *metadata.BackupMetadata, table metadata.TableMetadata, localDisk, remoteDisk string, part metadata.Part) (string, string, error) {
 	log.Debug().Fields(map[string]interface{}{"database": table.Database, "table": table.Table, "part": part.Name, "logger": "findDiffOnePartArchive"}).Msg("start")
 	dbAndTableDir := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
-	remoteExt := config.ArchiveExtensions[requiredBackup.DataFormat]
+	remoteExt := config.ArchiveExtensions[getTableArchiveFormat(requiredBackup.DataFormat, table)]
 	tableRemotePath := path.Join(requiredBackup.BackupName, "shadow", dbAndTableDir, fmt.Sprintf("%s_%s.%s", remoteDisk, common.TablePathEncode(part.Name), remoteExt))
 	tableRemoteFile := tableRemotePath
 	return b.findDiffFileExist(ctx, requiredBackup, tableRemoteFile, tableRemotePath, localDisk, dbAndTableDir, part)
@@ -4,8 +4,12 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
 	"regexp"
 	"testing"
 	"time"
@@ -284,3 +288,32 @@ func TestReBalanceTablesMetadataIfDiskNotExists_CheckErrors(t *testing.T) {
 	assert.Equal(t, "250B free space, not found in system.disks with `local` type", err.Error())
 
 }
+
+func TestIsResumableDownloadProcessed(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	b := &Backuper{resume: true}
+	b.resumableState = resumable.NewState(stateDir, "test_backup", "download", nil)
+	defer b.resumableState.Close()
+
+	partLocalPath := path.Join(stateDir, "part")
+	require.NoError(t, os.MkdirAll(partLocalPath, 0755))
+	require.NoError(t, os.WriteFile(path.Join(partLocalPath, "checksums.txt"), []byte("checksums"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(partLocalPath, "data.bin"), []byte("data"), 0644))
+	partSize, err := getLocalPathSize(partLocalPath)
+	require.NoError(t, err)
+	require.Equal(t, int64(13), partSize)
+
+	assert.False(t, b.isResumableDownloadProcessed("test_backup/shadow/db/t/default/all_1_1_0", partLocalPath, true))
+	b.resumableState.AppendToState("test_backup/shadow/db/t/default/all_1_1_0", partSize)
+	assert.True(t, b.isResumableDownloadProcessed("test_backup/shadow/db/t/default/all_1_1_0", partLocalPath, true))
+
+	// partially written part after crash
+	require.NoError(t, os.Remove(path.Join(partLocalPath, "data.bin")))
+	assert.False(t, b.isResumableDownloadProcessed("test_backup/shadow/db/t/default/all_1_1_0", partLocalPath, true))
+	assert.True(t, b.isResumableDownloadProcessed("test_backup/shadow/db/t/default/all_1_1_0", partLocalPath, false))
+
+	// local backup removed
+	require.NoError(t, os.RemoveAll(partLocalPath))
+	assert.False(t, b.isResumableDownloadProcessed("test_backup/shadow/db/t/default/all_1_1_0", partLocalPath, false))
+}
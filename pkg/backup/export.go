@@ -0,0 +1,437 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// archive layout: metadata.json always first, then disks/<disk_name>/<path relative to <disk_path>/backup/<backup_name>>
+const (
+	archiveMetadataFile = "metadata.json"
+	archiveDisksPrefix  = "disks"
+)
+
+// Export - pack local or remote backup into single tar archive, all required parts from incremental chain are resolved
+func (b *Backuper) Export(backupName, outputFile string, remote bool, backupVersion string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("backup name is required")
+	}
+	if outputFile == "" {
+		outputFile = backupName + ".tar"
+	}
+	if _, err = os.Stat(outputFile); err == nil {
+		return fmt.Errorf("%s already exists", outputFile)
+	}
+	downloaded := false
+	if remote {
+		// download resolves all required parts from incremental chain via hardlinks
+		if err = b.Download(backupName, "", nil, false, false, backupVersion, commandId); err != nil {
+			if !errors.Is(err, ErrBackupIsAlreadyExists) {
+				return err
+			}
+			log.Warn().Msgf("%s already exists locally, will export local backup", backupName)
+		} else {
+			downloaded = true
+		}
+	}
+	exportErr := b.exportLocal(ctx, backupName, outputFile)
+	if downloaded {
+		if err = b.RemoveBackupLocal(ctx, backupName, nil); err != nil {
+			log.Warn().Msgf("can't remove downloaded %s: %v", backupName, err)
+		}
+	}
+	return exportErr
+}
+
+func (b *Backuper) exportLocal(ctx context.Context, backupName, outputFile string) error {
+	startExport := time.Now()
+	if err := b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return err
+	}
+	if b.DefaultDataPath, err = b.ch.GetDefaultPath(disks); err != nil {
+		return err
+	}
+	localBackup, disks, err := b.getLocalBackup(ctx, backupName, disks)
+	if err != nil {
+		return err
+	}
+	if localBackup.Broken != "" {
+		return fmt.Errorf("backup %s is broken: %s", backupName, localBackup.Broken)
+	}
+	if strings.Contains(localBackup.Tags, "embedded") {
+		return fmt.Errorf("%s is embedded backup, export is not supported", backupName)
+	}
+	if localBackup.ObjectDiskSize > 0 {
+		return fmt.Errorf("%s contains data on object disks which stored outside of backup, export is not supported", backupName)
+	}
+
+	tmpFile := outputFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	var out io.WriteCloser = f
+	if strings.HasSuffix(outputFile, ".gz") || strings.HasSuffix(outputFile, ".tgz") {
+		out = gzip.NewWriter(f)
+	}
+	archiveErr := writeBackupArchive(ctx, out, localBackup.BackupMetadata, getBackupDiskPaths(disks, backupName))
+	if out != f {
+		if err = out.Close(); err != nil && archiveErr == nil {
+			archiveErr = err
+		}
+	}
+	if err = f.Close(); err != nil && archiveErr == nil {
+		archiveErr = err
+	}
+	if archiveErr != nil {
+		if err = os.Remove(tmpFile); err != nil {
+			log.Warn().Msgf("can't remove %s: %v", tmpFile, err)
+		}
+		return fmt.Errorf("export %s error: %v", backupName, archiveErr)
+	}
+	if err = os.Rename(tmpFile, outputFile); err != nil {
+		return err
+	}
+	log.Info().Fields(map[string]interface{}{
+		"backup":    backupName,
+		"operation": "export",
+		"output":    outputFile,
+		"duration":  utils.HumanizeDuration(time.Since(startExport)),
+	}).Msg("done")
+	return nil
+}
+
+// Import - create local backup from archive which was created by Export, backupName overrides name stored inside archive
+func (b *Backuper) Import(inputFile, backupName string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	startImport := time.Now()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if inputFile == "" {
+		return fmt.Errorf("archive file name is required")
+	}
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", inputFile, closeErr)
+		}
+	}()
+	in, err := openBackupArchive(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(in)
+	backupMetadata, err := readArchiveMetadata(tr)
+	if err != nil {
+		return fmt.Errorf("%s is not clickhouse-backup archive: %v", inputFile, err)
+	}
+	if backupName == "" {
+		backupName = backupMetadata.BackupName
+	}
+	backupMetadata.BackupName = backupName
+
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return err
+	}
+	if b.DefaultDataPath, err = b.ch.GetDefaultPath(disks); err != nil {
+		return err
+	}
+	localBackups, disks, err := b.GetLocalBackups(ctx, disks)
+	if err != nil {
+		return err
+	}
+	for _, localBackup := range localBackups {
+		if localBackup.BackupName == backupName {
+			return ErrBackupIsAlreadyExists
+		}
+	}
+	diskPaths := getBackupDiskPaths(disks, backupName)
+	if err = extractBackupArchive(ctx, tr, diskPaths); err == nil {
+		err = backupMetadata.Save(path.Join(b.DefaultDataPath, "backup", backupName, archiveMetadataFile))
+	}
+	if err != nil {
+		for _, backupPath := range diskPaths {
+			if removeErr := os.RemoveAll(backupPath); removeErr != nil {
+				log.Warn().Msgf("can't remove %s: %v", backupPath, removeErr)
+			}
+		}
+		return fmt.Errorf("import %s error: %v", inputFile, err)
+	}
+	for _, backupPath := range diskPaths {
+		if _, statErr := os.Stat(backupPath); statErr != nil {
+			continue
+		}
+		if err = filesystemhelper.Chown(backupPath, b.ch, disks, true); err != nil {
+			return err
+		}
+	}
+	log.Info().Fields(map[string]interface{}{
+		"backup":    backupName,
+		"operation": "import",
+		"input":     inputFile,
+		"duration":  utils.HumanizeDuration(time.Since(startImport)),
+		"size":      utils.FormatBytes(backupMetadata.GetFullSize()),
+	}).Msg("done")
+	return nil
+}
+
+// getBackupDiskPaths - disk name to local backup directory on this disk, backup disks are skipped
+func getBackupDiskPaths(disks []clickhouse.Disk, backupName string) map[string]string {
+	diskPaths := make(map[string]string, len(disks))
+	for _, disk := range disks {
+		if disk.IsBackup {
+			continue
+		}
+		diskPaths[disk.Name] = path.Join(disk.Path, "backup", backupName)
+	}
+	return diskPaths
+}
+
+func openBackupArchive(f io.Reader) (io.Reader, error) {
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(r)
+	}
+	return r, nil
+}
+
+func writeBackupArchive(ctx context.Context, out io.Writer, backupMetadata metadata.BackupMetadata, diskPaths map[string]string) error {
+	tw := tar.NewWriter(out)
+	// required parts are resolved inside archive
+	requiredBackup := backupMetadata.RequiredBackup
+	backupMetadata.RequiredBackup = ""
+	body, err := json.MarshalIndent(&backupMetadata, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = writeArchiveFile(tw, archiveMetadataFile, body, backupMetadata.CreationDate); err != nil {
+		return err
+	}
+	diskNames := make([]string, 0, len(diskPaths))
+	for diskName := range diskPaths {
+		diskNames = append(diskNames, diskName)
+	}
+	sort.Strings(diskNames)
+	for _, diskName := range diskNames {
+		backupPath := diskPaths[diskName]
+		if _, err = os.Stat(backupPath); os.IsNotExist(err) {
+			continue
+		}
+		walkErr := filepath.WalkDir(backupPath, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			relativePath, err := filepath.Rel(backupPath, filePath)
+			if err != nil {
+				return err
+			}
+			if relativePath == "." || relativePath == archiveMetadataFile || strings.HasSuffix(relativePath, ".state2") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			archiveName := path.Join(archiveDisksPrefix, diskName, filepath.ToSlash(relativePath))
+			if d.IsDir() {
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: archiveName + "/", Mode: 0750, ModTime: info.ModTime()})
+			}
+			if !info.Mode().IsRegular() {
+				log.Warn().Msgf("%s is not regular file, skip", filePath)
+				return nil
+			}
+			if strings.HasPrefix(relativePath, "metadata"+string(filepath.Separator)) && strings.HasSuffix(relativePath, ".json") {
+				tableBody, err := resolveRequiredParts(filePath, requiredBackup, diskPaths)
+				if err != nil {
+					return err
+				}
+				return writeArchiveFile(tw, archiveName, tableBody, info.ModTime())
+			}
+			return copyFileToArchive(tw, filePath, archiveName, info)
+		})
+		if walkErr != nil {
+			return walkErr
+		}
+	}
+	return tw.Close()
+}
+
+// resolveRequiredParts - `download` hardlinks required parts into local backup, so required flag shall be dropped for archive,
+// `create --diff-from-remote` and `create --since` don't, archive without such parts can't be restored
+func resolveRequiredParts(tableMetadataFile, requiredBackup string, diskPaths map[string]string) ([]byte, error) {
+	var tm metadata.TableMetadata
+	if _, err := tm.Load(tableMetadataFile); err != nil {
+		return nil, err
+	}
+	dbAndTableDir := path.Join(common.TablePathEncode(tm.Database), common.TablePathEncode(tm.Table))
+	for disk := range tm.Parts {
+		for i, part := range tm.Parts[disk] {
+			if !part.Required {
+				continue
+			}
+			partPath := path.Join(diskPaths[disk], "shadow", dbAndTableDir, disk, part.Name)
+			if info, err := os.Stat(partPath); err != nil || !info.IsDir() {
+				return nil, fmt.Errorf("`%s`.`%s` part %s on disk %s is required from %s and absent in local backup, delete local backup and export it with --remote", tm.Database, tm.Table, part.Name, disk, requiredBackup)
+			}
+			tm.Parts[disk][i].Required = false
+		}
+	}
+	return json.MarshalIndent(&tm, "", "\t")
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, body []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0640, Size: int64(len(body)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(body)
+	return err
+}
+
+func copyFileToArchive(tw *tar.Writer, filePath, name string, info fs.FileInfo) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Msgf("can't close %s: %v", filePath, err)
+		}
+	}()
+	if err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0640, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func readArchiveMetadata(tr *tar.Reader) (*metadata.BackupMetadata, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Name != archiveMetadataFile {
+		return nil, fmt.Errorf("first archive entry shall be %s, got %s", archiveMetadataFile, hdr.Name)
+	}
+	body, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, err
+	}
+	var backupMetadata metadata.BackupMetadata
+	if err = json.Unmarshal(body, &backupMetadata); err != nil {
+		return nil, err
+	}
+	if backupMetadata.BackupName == "" {
+		return nil, fmt.Errorf("%s doesn't contain backup_name", archiveMetadataFile)
+	}
+	return &backupMetadata, nil
+}
+
+func extractBackupArchive(ctx context.Context, tr *tar.Reader, diskPaths map[string]string) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		nameParts := strings.SplitN(strings.TrimSuffix(hdr.Name, "/"), "/", 3)
+		if len(nameParts) < 2 || nameParts[0] != archiveDisksPrefix {
+			return fmt.Errorf("unexpected archive entry %s", hdr.Name)
+		}
+		backupPath, exists := diskPaths[nameParts[1]]
+		if !exists {
+			return fmt.Errorf("disk %s doesn't exist in system.disks", nameParts[1])
+		}
+		localPath := backupPath
+		if len(nameParts) == 3 {
+			if !filepath.IsLocal(nameParts[2]) {
+				return fmt.Errorf("wrong archive entry %s", hdr.Name)
+			}
+			localPath = path.Join(backupPath, nameParts[2])
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(localPath, 0750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = extractArchiveFile(tr, localPath); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected archive entry type %c for %s", hdr.Typeflag, hdr.Name)
+		}
+	}
+}
+
+func extractArchiveFile(tr *tar.Reader, localPath string) error {
+	if err := os.MkdirAll(path.Dir(localPath), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, tr); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
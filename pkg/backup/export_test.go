@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestBackupArchiveRoundTrip(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+	src := t.TempDir()
+	srcPaths := map[string]string{"default": path.Join(src, "default", "backup", "full"), "hdd": path.Join(src, "hdd", "backup", "full")}
+	tm := metadata.TableMetadata{
+		Database: "db",
+		Table:    "t",
+		Parts: map[string][]metadata.Part{
+			"default": {{Name: "all_1_1_0"}},
+			// hardlinked by `download` from required backup
+			"hdd": {{Name: "all_2_2_0", Required: true}},
+		},
+	}
+	_, err := tm.Save(path.Join(srcPaths["default"], "metadata", "db", "t.json"), false)
+	r.NoError(err)
+	r.NoError(os.MkdirAll(path.Join(srcPaths["default"], "shadow", "db", "t", "default", "all_1_1_0"), 0750))
+	r.NoError(os.WriteFile(path.Join(srcPaths["default"], "shadow", "db", "t", "default", "all_1_1_0", "data.bin"), []byte("part1"), 0640))
+	r.NoError(os.MkdirAll(path.Join(srcPaths["hdd"], "shadow", "db", "t", "hdd", "all_2_2_0"), 0750))
+	r.NoError(os.WriteFile(path.Join(srcPaths["hdd"], "shadow", "db", "t", "hdd", "all_2_2_0", "data.bin"), []byte("part2"), 0640))
+	r.NoError(os.WriteFile(path.Join(srcPaths["default"], "metadata.json"), []byte("{}"), 0640))
+	r.NoError(os.WriteFile(path.Join(srcPaths["default"], "download.state2"), []byte("state"), 0640))
+
+	backupMetadata := metadata.BackupMetadata{BackupName: "full", RequiredBackup: "base", CreationDate: time.Now()}
+	archive := &bytes.Buffer{}
+	r.NoError(writeBackupArchive(ctx, archive, backupMetadata, srcPaths))
+
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	restored, err := readArchiveMetadata(tr)
+	r.NoError(err)
+	r.Equal("full", restored.BackupName)
+	r.Empty(restored.RequiredBackup)
+
+	dst := t.TempDir()
+	dstPaths := map[string]string{"default": path.Join(dst, "default", "backup", "imported"), "hdd": path.Join(dst, "hdd", "backup", "imported")}
+	r.NoError(extractBackupArchive(ctx, tr, dstPaths))
+	body, err := os.ReadFile(path.Join(dstPaths["hdd"], "shadow", "db", "t", "hdd", "all_2_2_0", "data.bin"))
+	r.NoError(err)
+	r.Equal("part2", string(body))
+	r.NoFileExists(path.Join(dstPaths["default"], "metadata.json"))
+	r.NoFileExists(path.Join(dstPaths["default"], "download.state2"))
+	var importedTable metadata.TableMetadata
+	_, err = importedTable.Load(path.Join(dstPaths["default"], "metadata", "db", "t.json"))
+	r.NoError(err)
+	r.False(importedTable.Parts["hdd"][0].Required)
+
+	// disk which doesn't exist on destination
+	tr = tar.NewReader(bytes.NewReader(archive.Bytes()))
+	_, err = readArchiveMetadata(tr)
+	r.NoError(err)
+	r.Error(extractBackupArchive(ctx, tr, map[string]string{"default": path.Join(dst, "other")}))
+
+	// `create --diff-from-remote` or `create --since` doesn't hardlink required parts, archive without them can't be restored
+	r.NoError(os.RemoveAll(path.Join(srcPaths["hdd"], "shadow", "db", "t", "hdd", "all_2_2_0")))
+	err = writeBackupArchive(ctx, &bytes.Buffer{}, backupMetadata, srcPaths)
+	r.ErrorContains(err, "all_2_2_0 on disk hdd is required from base")
+}
@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// logical backup stores each partition as pseudo data part <partition_id>/data.<format> on default disk,
+// so upload, download and partitions filtering work the same way as for frozen data parts
+const (
+	logicalBackupDisk         = "default"
+	logicalBackupUserFilesDir = "clickhouse-backup"
+)
+
+var logicalFormats = map[string]string{
+	"native":  "Native",
+	"parquet": "Parquet",
+}
+
+// SELECT from views, dictionaries and Distributed reads data stored in other tables, SELECT from streaming engines consumes messages, Set can't be read
+var logicalBackupSkipEnginesRE = regexp.MustCompile(`^(View|MaterializedView|LiveView|WindowView|Dictionary|Distributed|Merge|Buffer|Null|Set|Kafka|RabbitMQ|NATS|S3Queue|AzureQueue|FileLog)$`)
+
+func isLogicalBackupSupported(engine string) bool {
+	return !logicalBackupSkipEnginesRE.MatchString(engine)
+}
+
+func logicalDataFileName(format string) string {
+	return "data." + format
+}
+
+// AddTableToLocalBackupLogical - export table data via `INSERT INTO FUNCTION file() SELECT`, *MergeTree tables are exported partition by partition
+func (b *Backuper) AddTableToLocalBackupLogical(ctx context.Context, backupName string, diskList []clickhouse.Disk, table *clickhouse.Table, partitionsIdsMap common.EmptyMap) (map[string][]metadata.Part, map[string]int64, error) {
+	logger := log.With().Fields(map[string]interface{}{
+		"backup":    backupName,
+		"operation": "create",
+		"table":     fmt.Sprintf("%s.%s", table.Database, table.Name),
+	}).Logger()
+	format := b.cfg.General.DataFormat
+	if !isLogicalBackupSupported(table.Engine) {
+		if table.Engine != "MaterializedView" && table.Engine != "View" {
+			logger.Warn().Str("engine", table.Engine).Msg("supports only schema backup")
+		}
+		return nil, nil, nil
+	}
+	partitionIds := []string{"all"}
+	isMergeTree := strings.HasSuffix(table.Engine, "MergeTree")
+	if isMergeTree {
+		partitions := make([]struct {
+			PartitionId string `ch:"partition_id"`
+		}, 0)
+		if err := b.ch.SelectContext(ctx, &partitions, "SELECT DISTINCT partition_id FROM system.parts WHERE active AND database=? AND table=? ORDER BY partition_id", table.Database, table.Name); err != nil {
+			return nil, nil, err
+		}
+		partitionIds = make([]string, 0, len(partitions))
+		for _, p := range partitions {
+			if len(partitionsIdsMap) == 0 || filesystemhelper.IsPartInPartition(p.PartitionId, partitionsIdsMap) {
+				partitionIds = append(partitionIds, p.PartitionId)
+			}
+		}
+	}
+	userFilesPath, err := b.ch.GetUserFilesPath(ctx, diskList)
+	if err != nil {
+		return nil, nil, err
+	}
+	encodedTablePath := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Name))
+	userFilesTablePath := path.Join(logicalBackupUserFilesDir, backupName, encodedTablePath)
+	defer func() {
+		if err := os.RemoveAll(path.Join(userFilesPath, userFilesTablePath)); err != nil {
+			logger.Warn().Msgf("can't remove %s: %v", path.Join(userFilesPath, userFilesTablePath), err)
+		}
+	}()
+	if err = filesystemhelper.MkdirAll(path.Join(userFilesPath, userFilesTablePath), b.ch, diskList); err != nil {
+		return nil, nil, err
+	}
+	backupShadowPath := path.Join(b.DefaultDataPath, "backup", backupName, "shadow", encodedTablePath, logicalBackupDisk)
+	parts := make([]metadata.Part, 0, len(partitionIds))
+	realSize := map[string]int64{}
+	for _, partitionId := range partitionIds {
+		start := time.Now()
+		userFile := path.Join(userFilesTablePath, partitionId+"."+format)
+		exportQuery := fmt.Sprintf("INSERT INTO FUNCTION file('%s', '%s') SELECT * FROM `%s`.`%s`", userFile, logicalFormats[format], table.Database, table.Name)
+		if isMergeTree {
+			exportQuery += fmt.Sprintf(" WHERE _partition_id='%s'", strings.ReplaceAll(partitionId, "'", "\\'"))
+		}
+		exportQuery += " SETTINGS engine_file_truncate_on_insert=1"
+		if err = b.ch.QueryContext(ctx, exportQuery); err != nil {
+			return nil, nil, err
+		}
+		exportedFile := path.Join(userFilesPath, userFile)
+		info, err := os.Stat(exportedFile)
+		if os.IsNotExist(err) {
+			logger.Debug().Str("partition_id", partitionId).Msg("empty, skip")
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		partPath := path.Join(backupShadowPath, partitionId)
+		if err = filesystemhelper.MkdirAll(partPath, b.ch, diskList); err != nil {
+			return nil, nil, err
+		}
+		if err = moveFile(exportedFile, path.Join(partPath, logicalDataFileName(format))); err != nil {
+			return nil, nil, err
+		}
+		realSize[logicalBackupDisk] += info.Size()
+		parts = append(parts, metadata.Part{Name: partitionId})
+		logger.Debug().Str("partition_id", partitionId).Str("size", utils.FormatBytes(uint64(info.Size()))).Str("duration", utils.HumanizeDuration(time.Since(start))).Msg("exported")
+	}
+	if len(parts) == 0 {
+		return nil, nil, nil
+	}
+	return map[string][]metadata.Part{logicalBackupDisk: parts}, realSize, nil
+}
+
+// restoreDataLogical - insert each exported partition via `INSERT INTO ... SELECT * FROM file()`, backup file hardlinks into user_files_path
+func (b *Backuper) restoreDataLogical(ctx context.Context, backupName string, table metadata.TableMetadata, diskMap map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, logger zerolog.Logger) error {
+	format, isFormatSupported := logicalFormats[table.DataFormat]
+	if !isFormatSupported {
+		return fmt.Errorf("unknown data_format %s for %s.%s", table.DataFormat, table.Database, table.Table)
+	}
+	userFilesPath, err := b.ch.GetUserFilesPath(ctx, disks)
+	if err != nil {
+		return err
+	}
+	dbAndTableDir := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+	userFilesTablePath := path.Join(logicalBackupUserFilesDir, backupName, dbAndTableDir)
+	if err = filesystemhelper.MkdirAll(path.Join(userFilesPath, userFilesTablePath), b.ch, disks); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(path.Join(userFilesPath, userFilesTablePath)); err != nil {
+			logger.Warn().Msgf("can't remove %s: %v", path.Join(userFilesPath, userFilesTablePath), err)
+		}
+	}()
+	for disk, parts := range table.Parts {
+		for _, part := range parts {
+			start := time.Now()
+			backupFile := path.Join(diskMap[disk], "backup", backupName, "shadow", dbAndTableDir, disk, part.Name, logicalDataFileName(table.DataFormat))
+			userFile := path.Join(userFilesTablePath, part.Name+"."+table.DataFormat)
+			if err = os.Link(backupFile, path.Join(userFilesPath, userFile)); err != nil {
+				logger.Debug().Msgf("can't hardlink %s, will copy: %v", backupFile, err)
+				if err = copyFile(backupFile, path.Join(userFilesPath, userFile)); err != nil {
+					return err
+				}
+			}
+			if err = filesystemhelper.Chown(path.Join(userFilesPath, userFile), b.ch, disks, false); err != nil {
+				return err
+			}
+			importQuery := fmt.Sprintf("INSERT INTO `%s`.`%s` SELECT * FROM file('%s', '%s')", dstTable.Database, dstTable.Name, userFile, format)
+			if err = b.ch.QueryContext(ctx, importQuery); err != nil {
+				return fmt.Errorf("can't insert %s into %s.%s: %v", backupFile, dstTable.Database, dstTable.Name, err)
+			}
+			if err = os.Remove(path.Join(userFilesPath, userFile)); err != nil {
+				return err
+			}
+			logger.Debug().Str("partition_id", part.Name).Str("duration", utils.HumanizeDuration(time.Since(start))).Msg("inserted")
+		}
+	}
+	return nil
+}
+
+// moveFile - user_files_path and backup directory could be placed on different filesystems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := in.Close(); err != nil {
+			log.Warn().Msgf("can't close %s: %v", src, err)
+		}
+	}()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,43 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestIsLogicalBackupSupported(t *testing.T) {
+	r := require.New(t)
+	for _, engine := range []string{"MergeTree", "ReplicatedReplacingMergeTree", "Memory", "Log", "TinyLog", "StripeLog", "Join", "MySQL", "PostgreSQL", "URL", "S3"} {
+		r.True(isLogicalBackupSupported(engine), engine)
+	}
+	for _, engine := range []string{"View", "MaterializedView", "Dictionary", "Distributed", "Kafka", "Set", "Null", "S3Queue"} {
+		r.False(isLogicalBackupSupported(engine), engine)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	r := require.New(t)
+	dir := t.TempDir()
+	src := path.Join(dir, "all.native")
+	r.NoError(os.WriteFile(src, []byte("data"), 0640))
+	dst := path.Join(dir, "all", logicalDataFileName("native"))
+	r.NoError(os.MkdirAll(path.Dir(dst), 0750))
+	r.NoError(moveFile(src, dst))
+	r.NoFileExists(src)
+	body, err := os.ReadFile(dst)
+	r.NoError(err)
+	r.Equal("data", string(body))
+}
+
+func TestMarkDuplicatedPartsSkipLogical(t *testing.T) {
+	b := &Backuper{}
+	existsTable := &metadata.TableMetadata{Parts: map[string][]metadata.Part{"default": {{Name: "202401"}}}, DataFormat: "native"}
+	newTable := &metadata.TableMetadata{Parts: map[string][]metadata.Part{"default": {{Name: "202401"}}}, DataFormat: "native"}
+	b.markDuplicatedParts(&metadata.BackupMetadata{BackupName: "increment", RequiredBackup: "full"}, existsTable, newTable, false)
+	require.False(t, newTable.Parts["default"][0].Required)
+}
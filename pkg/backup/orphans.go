@@ -0,0 +1,381 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+const (
+	orphanKindBackupFile = "backup_file"
+	orphanKindSharedPart = "shared_part"
+	orphanKindObjectDisk = "object_disk"
+)
+
+// RemoteOrphan - remote object or prefix which is not referenced by metadata of any remote backup
+type RemoteOrphan struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Objects int    `json:"objects"`
+	Size    int64  `json:"size"`
+	// LastModified - the newest object of orphan, objects written after scan start could belong to upload which started during scan
+	LastModified time.Time `json:"last_modified"`
+}
+
+// addObject - account walked remote file in orphan
+func (orphan *RemoteOrphan) addObject(f storage.RemoteFile) {
+	orphan.Objects += 1
+	orphan.Size += f.Size()
+	if f.LastModified().After(orphan.LastModified) {
+		orphan.LastModified = f.LastModified()
+	}
+}
+
+// backupReferencedPaths - data paths inside remote backup which are referenced by table metadata, everything outside `shadow` is always referenced
+// ancestors contains parent directories of referenced paths, SFTP and FTP walk returns directories as well as files
+type backupReferencedPaths struct {
+	files     common.EmptyMap
+	dirs      common.EmptyMap
+	ancestors common.EmptyMap
+}
+
+func getBackupReferencedPaths(backup metadata.BackupMetadata, tables []metadata.TableMetadata) backupReferencedPaths {
+	referenced := backupReferencedPaths{files: common.EmptyMap{}, dirs: common.EmptyMap{}, ancestors: common.EmptyMap{}}
+	addAncestors := func(name string) {
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			referenced.ancestors[dir] = struct{}{}
+		}
+	}
+	for _, t := range tables {
+		tableDir := path.Join("shadow", common.TablePathEncode(t.Database), common.TablePathEncode(t.Table))
+		if backup.DataFormat == DirectoryFormat {
+			for disk, parts := range t.Parts {
+				for _, p := range parts {
+					if !p.Required && p.Hash == "" {
+						referenced.dirs[path.Join(tableDir, disk, p.Name)] = struct{}{}
+						addAncestors(path.Join(tableDir, disk, p.Name))
+					}
+				}
+			}
+			continue
+		}
+		for _, files := range t.Files {
+			for _, f := range files {
+				referenced.files[path.Join(tableDir, f)] = struct{}{}
+				addAncestors(path.Join(tableDir, f))
+			}
+		}
+	}
+	return referenced
+}
+
+// isReferenced - name is relative to backup root
+func (referenced backupReferencedPaths) isReferenced(name string) bool {
+	name = strings.Trim(name, "/")
+	if name != "shadow" && !strings.HasPrefix(name, "shadow/") {
+		return true
+	}
+	if _, exists := referenced.files[name]; exists {
+		return true
+	}
+	if _, exists := referenced.ancestors[name]; exists {
+		return true
+	}
+	for dir := path.Dir(name); dir != "shadow" && dir != "."; dir = path.Dir(dir) {
+		if _, exists := referenced.dirs[dir]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// firstPathElement - top level directory of walked remote file name
+func firstPathElement(name string) string {
+	name = strings.Trim(name, "/")
+	if idx := strings.Index(name, "/"); idx >= 0 {
+		return name[:idx]
+	}
+	return name
+}
+
+func isWalkedRemoteFile(f storage.RemoteFile) bool {
+	name := strings.Trim(f.Name(), "/")
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	// azblob returns virtual directories with zero size and zero last modified time
+	return f.Size() > 0 || !f.LastModified().IsZero()
+}
+
+// CleanRemoteOrphans - find remote objects which are not referenced by any remote backup, left after aborted upload, failed delete or killed `create --resume`,
+// report them and delete only when apply is true
+func (b *Backuper) CleanRemoteOrphans(apply bool, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	// objects which modified after scan start are never deleted
+	start := time.Now()
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	if err = b.connectRemoteForRead(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := b.dst.Close(ctx); closeErr != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+		}
+	}()
+	backupList, err := b.dst.BackupList(ctx, true, "")
+	if err != nil {
+		return err
+	}
+	orphans, err := b.findRemoteOrphans(ctx, backupList)
+	if err != nil {
+		return err
+	}
+	if err = printRemoteOrphans(os.Stdout, orphans); err != nil {
+		return err
+	}
+	if !apply {
+		if len(orphans) > 0 {
+			log.Info().Msgf("found %d orphans, use --apply to delete them", len(orphans))
+		}
+		return nil
+	}
+	if orphans, err = b.recheckRemoteOrphans(ctx, orphans, backupList, start); err != nil {
+		return err
+	}
+	deletedSize := int64(0)
+	for _, orphan := range orphans {
+		switch orphan.Kind {
+		case orphanKindBackupFile:
+			err = b.dst.DeleteFile(ctx, orphan.Path)
+		case orphanKindSharedPart:
+			err = b.dst.RemoveRemotePath(ctx, orphan.Path, b.cfg)
+		case orphanKindObjectDisk:
+			_, err = b.cleanBackupObjectDisks(ctx, path.Base(orphan.Path))
+		}
+		if err != nil {
+			return fmt.Errorf("can't delete %s %s: %v", orphan.Kind, orphan.Path, err)
+		}
+		deletedSize += orphan.Size
+	}
+	log.Info().Fields(map[string]interface{}{
+		"operation": "clean_remote_orphans",
+		"orphans":   len(orphans),
+		"size":      utils.FormatBytes(uint64(deletedSize)),
+		"duration":  utils.HumanizeDuration(time.Since(start)),
+	}).Msg("done")
+	return nil
+}
+
+func (b *Backuper) findRemoteOrphans(ctx context.Context, backupList []storage.Backup) ([]RemoteOrphan, error) {
+	orphans := make([]RemoteOrphan, 0)
+	backupNames := common.EmptyMap{}
+	referencedHashes := common.EmptyMap{}
+	hasBrokenBackups := false
+	for _, backup := range backupList {
+		backupNames[backup.BackupName] = struct{}{}
+		// broken backup could be upload in progress, all objects inside it belongs to it
+		if backup.Broken != "" {
+			hasBrokenBackups = true
+			continue
+		}
+		tables, err := b.readRemoteBackupTables(ctx, backup)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range getSharedPartsHashes(tables) {
+			referencedHashes[hash] = struct{}{}
+		}
+		// embedded backups data paths are managed by clickhouse-server
+		if strings.Contains(backup.Tags, "embedded") {
+			continue
+		}
+		referenced := getBackupReferencedPaths(backup.BackupMetadata, tables)
+		// SFTP and FTP walk returns directory before its files, files inside orphan directory are accounted in it
+		orphanDirs := map[string]int{}
+		err = b.dst.Walk(ctx, backup.BackupName+"/", true, func(ctx context.Context, f storage.RemoteFile) error {
+			if !isWalkedRemoteFile(f) || referenced.isReferenced(f.Name()) {
+				return nil
+			}
+			name := strings.Trim(f.Name(), "/")
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				if idx, isOrphanDir := orphanDirs[dir]; isOrphanDir {
+					orphans[idx].addObject(f)
+					return nil
+				}
+			}
+			orphanDirs[name] = len(orphans)
+			orphan := RemoteOrphan{Kind: orphanKindBackupFile, Path: path.Join(backup.BackupName, name)}
+			orphan.addObject(f)
+			orphans = append(orphans, orphan)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hasBrokenBackups {
+		log.Warn().Msgf("remote storage contains broken backups, skip search orphans in %s, use `clean_remote_broken` command before", storage.SharedPartsDir)
+	} else {
+		sharedOrphans := map[string]*RemoteOrphan{}
+		err := b.dst.Walk(ctx, storage.SharedPartsDir+"/", true, func(ctx context.Context, f storage.RemoteFile) error {
+			if !isWalkedRemoteFile(f) {
+				return nil
+			}
+			hash := firstPathElement(f.Name())
+			if _, isReferenced := referencedHashes[hash]; isReferenced {
+				return nil
+			}
+			if _, exists := sharedOrphans[hash]; !exists {
+				sharedOrphans[hash] = &RemoteOrphan{Kind: orphanKindSharedPart, Path: path.Join(storage.SharedPartsDir, hash)}
+			}
+			sharedOrphans[hash].addObject(f)
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		orphans = appendSortedOrphans(orphans, sharedOrphans)
+	}
+
+	objectDiskPath, err := b.getObjectDiskPath()
+	if err != nil || objectDiskPath == "" {
+		log.Debug().Msgf("object_disk_path is not defined, skip search orphans in object disks backup path")
+		return orphans, nil
+	}
+	// object disk data copied during `create`, so local backups which not uploaded yet are not orphans
+	localBackups, _, err := b.GetLocalBackups(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, localBackup := range localBackups {
+		backupNames[localBackup.BackupName] = struct{}{}
+	}
+	objectDiskOrphans := map[string]*RemoteOrphan{}
+	err = b.dst.WalkAbsolute(ctx, objectDiskPath, true, func(ctx context.Context, f storage.RemoteFile) error {
+		if !isWalkedRemoteFile(f) {
+			return nil
+		}
+		backupName := firstPathElement(f.Name())
+		if _, exists := backupNames[backupName]; exists {
+			return nil
+		}
+		if _, exists := objectDiskOrphans[backupName]; !exists {
+			objectDiskOrphans[backupName] = &RemoteOrphan{Kind: orphanKindObjectDisk, Path: path.Join(objectDiskPath, backupName)}
+		}
+		objectDiskOrphans[backupName].addObject(f)
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return appendSortedOrphans(orphans, objectDiskOrphans), nil
+}
+
+// recheckRemoteOrphans - upload which started after first BackupList could write into shared parts or object disk path before its metadata.json,
+// so before delete re-read backup list and skip orphans which belong to new backups or modified after scan start
+func (b *Backuper) recheckRemoteOrphans(ctx context.Context, orphans []RemoteOrphan, backupList []storage.Backup, scanStart time.Time) ([]RemoteOrphan, error) {
+	currentBackupList, err := b.dst.BackupList(ctx, true, "")
+	if err != nil {
+		return nil, err
+	}
+	knownBackups := common.EmptyMap{}
+	for _, backup := range backupList {
+		knownBackups[backup.BackupName] = struct{}{}
+	}
+	newBackups := common.EmptyMap{}
+	newHashes := common.EmptyMap{}
+	hasNewBrokenBackups := false
+	for _, backup := range currentBackupList {
+		if _, isKnown := knownBackups[backup.BackupName]; isKnown {
+			continue
+		}
+		newBackups[backup.BackupName] = struct{}{}
+		if backup.Broken != "" {
+			hasNewBrokenBackups = true
+			continue
+		}
+		tables, err := b.readRemoteBackupTables(ctx, backup)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range getSharedPartsHashes(tables) {
+			newHashes[hash] = struct{}{}
+		}
+	}
+	return filterRecheckedOrphans(ctx, orphans, scanStart, newBackups, newHashes, hasNewBrokenBackups), nil
+}
+
+func filterRecheckedOrphans(ctx context.Context, orphans []RemoteOrphan, scanStart time.Time, newBackups, newHashes common.EmptyMap, hasNewBrokenBackups bool) []RemoteOrphan {
+	confirmed := make([]RemoteOrphan, 0, len(orphans))
+	for _, orphan := range orphans {
+		isSkipped := orphan.LastModified.After(scanStart)
+		switch orphan.Kind {
+		case orphanKindBackupFile:
+			_, isNewBackup := newBackups[firstPathElement(orphan.Path)]
+			isSkipped = isSkipped || isNewBackup
+		case orphanKindSharedPart:
+			_, isNewHash := newHashes[path.Base(orphan.Path)]
+			isSkipped = isSkipped || isNewHash || hasNewBrokenBackups
+		case orphanKindObjectDisk:
+			_, isNewBackup := newBackups[path.Base(orphan.Path)]
+			isSkipped = isSkipped || isNewBackup
+		}
+		if isSkipped {
+			log.Warn().Msgf("skip %s %s, it could belong to backup which started during scan", orphan.Kind, orphan.Path)
+			continue
+		}
+		confirmed = append(confirmed, orphan)
+	}
+	return confirmed
+}
+
+func appendSortedOrphans(orphans []RemoteOrphan, orphansByPath map[string]*RemoteOrphan) []RemoteOrphan {
+	keys := make([]string, 0, len(orphansByPath))
+	for k := range orphansByPath {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		orphans = append(orphans, *orphansByPath[k])
+	}
+	return orphans
+}
+
+func printRemoteOrphans(out io.Writer, orphans []RemoteOrphan) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	totalSize := int64(0)
+	for _, orphan := range orphans {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%d objects\t%s\n", orphan.Kind, orphan.Path, orphan.Objects, utils.FormatBytes(uint64(orphan.Size))); err != nil {
+			return err
+		}
+		totalSize += orphan.Size
+	}
+	if _, err := fmt.Fprintf(w, "total:\t%d orphans\t\t%s\n", len(orphans), utils.FormatBytes(uint64(totalSize))); err != nil {
+		return err
+	}
+	return w.Flush()
+}
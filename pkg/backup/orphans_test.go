@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestBackupReferencedPaths(t *testing.T) {
+	tables := []metadata.TableMetadata{
+		{
+			Database: "db",
+			Table:    "t1",
+			Parts:    map[string][]metadata.Part{"default": {{Name: "all_1_1_0"}, {Name: "all_2_2_0", Required: true}, {Name: "all_3_3_0", Hash: "abc"}}},
+			Files:    map[string][]string{"default": {"default_1.tar.gz"}},
+		},
+	}
+	archive := getBackupReferencedPaths(metadata.BackupMetadata{BackupName: "b1", DataFormat: "gzip"}, tables)
+	assert.True(t, archive.isReferenced("metadata.json"))
+	assert.True(t, archive.isReferenced("/metadata/db/t1.json"))
+	assert.True(t, archive.isReferenced("shadow/db/t1/default_1.tar.gz"))
+	assert.True(t, archive.isReferenced("shadow/db"))
+	assert.False(t, archive.isReferenced("shadow/db/t1/default_2.tar.gz"))
+	assert.False(t, archive.isReferenced("shadow/db/dropped/default_1.tar.gz"))
+
+	directory := getBackupReferencedPaths(metadata.BackupMetadata{BackupName: "b1", DataFormat: DirectoryFormat}, tables)
+	assert.True(t, directory.isReferenced("shadow/db/t1/default/all_1_1_0/checksums.txt"))
+	assert.True(t, directory.isReferenced("shadow/db/t1/default"))
+	assert.False(t, directory.isReferenced("shadow/db/t1/default/all_2_2_0/checksums.txt"))
+	assert.False(t, directory.isReferenced("shadow/db/t1/default/all_3_3_0/checksums.txt"))
+	assert.False(t, directory.isReferenced("shadow/db/t1/default_1.tar.gz"))
+
+	assert.Equal(t, "abc", firstPathElement("/abc/data/checksums.txt"))
+	assert.Equal(t, "backup1", firstPathElement("backup1"))
+}
+
+func TestPrintRemoteOrphans(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.NoError(t, printRemoteOrphans(out, []RemoteOrphan{
+		{Kind: orphanKindBackupFile, Path: "b1/shadow/db/t1/default_2.tar.gz", Objects: 1, Size: 1024},
+		{Kind: orphanKindSharedPart, Path: ".shared_parts/abc", Objects: 3, Size: 2048},
+	}))
+	assert.Contains(t, out.String(), "shared_part")
+	assert.Contains(t, out.String(), "2 orphans")
+	assert.Contains(t, out.String(), "3.00KiB")
+}
+
+func TestFilterRecheckedOrphans(t *testing.T) {
+	scanStart := time.Now()
+	before := scanStart.Add(-time.Hour)
+	orphans := []RemoteOrphan{
+		{Kind: orphanKindBackupFile, Path: "b1/shadow/db/t1/default_2.tar.gz", LastModified: before},
+		{Kind: orphanKindBackupFile, Path: "b1/shadow/db/t1/default_3.tar.gz", LastModified: scanStart.Add(time.Second)},
+		{Kind: orphanKindSharedPart, Path: ".shared_parts/abc", LastModified: before},
+		{Kind: orphanKindSharedPart, Path: ".shared_parts/def", LastModified: before},
+		{Kind: orphanKindObjectDisk, Path: "object_disks/b2", LastModified: before},
+		{Kind: orphanKindObjectDisk, Path: "object_disks/b3", LastModified: before},
+	}
+	ctx := context.Background()
+	confirmed := filterRecheckedOrphans(ctx, orphans, scanStart, common.EmptyMap{"b3": {}}, common.EmptyMap{"def": {}}, false)
+	assert.Equal(t, []string{"b1/shadow/db/t1/default_2.tar.gz", ".shared_parts/abc", "object_disks/b2"}, orphanPaths(confirmed))
+
+	// upload in progress started during scan, its shared parts could be written before metadata
+	confirmed = filterRecheckedOrphans(ctx, orphans, scanStart, common.EmptyMap{"b4": {}}, common.EmptyMap{}, true)
+	assert.Equal(t, []string{"b1/shadow/db/t1/default_2.tar.gz", "object_disks/b2", "object_disks/b3"}, orphanPaths(confirmed))
+}
+
+func orphanPaths(orphans []RemoteOrphan) []string {
+	paths := make([]string, len(orphans))
+	for i, orphan := range orphans {
+		paths[i] = orphan.Path
+	}
+	return paths
+}
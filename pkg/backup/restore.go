@@ -44,7 +44,7 @@ import (
 var CreateDatabaseRE = regexp.MustCompile(`(?m)^CREATE DATABASE (\s*)(\S+)(\s*)`)
 
 // Restore - restore tables matched by tablePattern from backupName
-func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume bool, backupVersion string, commandId int) error {
+func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, restoreKeeper, restoreNamedCollections, resume bool, backupVersion string, commandId int) error {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
@@ -90,6 +90,12 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 			return err
 		}
 	}
+	if b.cfg.General.RestoreReshardCluster != "" {
+		if b.cfg.General.RestoreReshardCluster, err = b.ch.ApplyMacros(ctx, b.cfg.General.RestoreReshardCluster); err != nil {
+			log.Warn().Msgf("%v", err)
+			return err
+		}
+	}
 	b.adjustResumeFlag(resume)
 	backupMetafileLocalPaths := []string{path.Join(b.DefaultDataPath, "backup", backupName, "metadata.json")}
 	var backupMetadataBody []byte
@@ -115,6 +121,9 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		return err
 	}
 	b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
+	if strings.Contains(backupMetadata.Tags, "partial") && doRestoreData {
+		log.Warn().Msgf("%s is partial, created with --since=%s, it contains only parts which changed after this timestamp", backupName, backupMetadata.Since)
+	}
 
 	if schemaOnly || doRestoreData {
 		for _, database := range backupMetadata.Databases {
@@ -128,7 +137,7 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 	}
 	if len(backupMetadata.Tables) == 0 {
 		// corner cases for https://github.com/Altinity/clickhouse-backup/issues/832
-		if !restoreRBAC && !rbacOnly && !restoreConfigs && !configsOnly {
+		if !restoreRBAC && !rbacOnly && !restoreConfigs && !configsOnly && !restoreKeeper && !restoreNamedCollections {
 			if !b.cfg.General.AllowEmptyBackups {
 				err = fmt.Errorf("'%s' doesn't contains tables for restore, if you need it, you can setup `allow_empty_backups: true` in `general` config section", backupName)
 				log.Error().Msgf("%v", err)
@@ -153,6 +162,12 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 		log.Info().Msgf("CONFIGS successfully restored")
 		needRestart = true
 	}
+	if restoreKeeper {
+		if err := b.restoreKeeper(ctx, backupName); err != nil {
+			return err
+		}
+		log.Info().Msgf("KEEPER successfully restored")
+	}
 
 	if needRestart {
 		if err := b.restartClickHouse(ctx, backupName); err != nil {
@@ -162,6 +177,17 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 			return nil
 		}
 	}
+	// tables and dictionaries could use named collections and shall be created after them
+	if restoreNamedCollections {
+		if err = b.restoreNamedCollections(backupMetadata); err != nil {
+			return err
+		}
+	}
+	if schemaOnly || (schemaOnly == dataOnly && !rbacOnly && !configsOnly) {
+		if err = b.restoreWorkloads(backupMetadata); err != nil {
+			return err
+		}
+	}
 	isObjectDiskPresents := false
 	if b.cfg.General.RemoteStorage != "custom" {
 		for _, d := range disks {
@@ -182,21 +208,22 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 				log.Warn().Msgf("can't close BackupDestination error: %v", err)
 			}
 		}()
-		if b.resume {
-			needClean := "false"
-			if dropExists || !dataOnly {
-				needClean = fmt.Sprintf("true.%d", rand.Uint64())
-			}
-			b.resumableState = resumable.NewState(b.GetStateDir(), backupName, "restore", map[string]interface{}{
-				"tablePattern": tablePattern,
-				"partitions":   partitions,
-				"schemaOnly":   schemaOnly,
-				"dataOnly":     dataOnly,
-				"dropExists":   dropExists,
-				"needClean":    needClean,
-			})
-			defer b.resumableState.Close()
-		}
+	}
+	if b.resume {
+		// tables re-created during schema restore lose all restored data, so state shall be cleaned
+		needClean := "false"
+		if dropExists || !dataOnly {
+			needClean = fmt.Sprintf("true.%d", rand.Uint64())
+		}
+		b.resumableState = resumable.NewState(b.GetStateDir(), backupName, "restore", map[string]interface{}{
+			"tablePattern": tablePattern,
+			"partitions":   partitions,
+			"schemaOnly":   schemaOnly,
+			"dataOnly":     dataOnly,
+			"dropExists":   dropExists,
+			"needClean":    needClean,
+		})
+		defer b.resumableState.Close()
 	}
 	var tablesForRestore ListOfTables
 	var partitionsNames map[metadata.TableTitle][]string
@@ -219,6 +246,11 @@ func (b *Backuper) Restore(backupName, tablePattern string, databaseMapping, tab
 			return err
 		}
 	}
+	if b.cfg.ClickHouse.RestoreConsumerState && !rbacOnly && !configsOnly {
+		if err = b.restoreConsumerStates(ctx, tablesForRestore); err != nil {
+			return err
+		}
+	}
 	// https://github.com/Altinity/clickhouse-backup/issues/756
 	if dataOnly && !schemaOnly && !rbacOnly && !configsOnly && len(partitions) > 0 {
 		if err = b.dropExistPartitions(ctx, tablesForRestore, partitionsNames, partitions, version); err != nil {
@@ -397,7 +429,17 @@ func (b *Backuper) restoreEmptyDatabase(ctx context.Context, targetDB, tablePatt
 
 	}
 	substitution := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS ${1}`%s`${3}", targetDB)
-	if err := b.ch.CreateDatabaseFromQuery(ctx, CreateDatabaseRE.ReplaceAllString(database.Query, substitution), b.cfg.General.RestoreSchemaOnCluster); err != nil {
+	query := CreateDatabaseRE.ReplaceAllString(database.Query, substitution)
+	if database.Engine == "Replicated" {
+		engine, err := b.ch.GetDatabaseEngine(ctx, targetDB)
+		if err != nil {
+			return err
+		}
+		if engine == "" {
+			query = b.prepareReplicatedDatabaseQuery(ctx, query, database.Name, targetDB)
+		}
+	}
+	if err := b.ch.CreateDatabaseFromQuery(ctx, query, b.cfg.General.RestoreSchemaOnCluster); err != nil {
 		return err
 	}
 	return nil
@@ -801,13 +843,101 @@ func (b *Backuper) restoreRBACReplicated(backupName string, backupPrefixDir stri
 			return err
 		}
 		log.Info().Msgf("keeper.Restore(%s) -> %s", jsonLFile, replicatedAccessPath)
-		if err := k.Restore(jsonLFile, replicatedAccessPath); err != nil {
+		if _, err := k.Restore(jsonLFile, replicatedAccessPath, keeper.RestoreModeOverwrite); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// restoreNamedCollections - re-create SQL named collections, existing collections with the same name will replace
+func (b *Backuper) restoreNamedCollections(backupMetadata metadata.BackupMetadata) error {
+	if len(backupMetadata.NamedCollections) == 0 {
+		log.Warn().Msgf("'%s' doesn't contains named collections, create backup with --named-collections", backupMetadata.BackupName)
+		return nil
+	}
+	for _, namedCollection := range backupMetadata.NamedCollections {
+		if err := b.ch.CreateNamedCollection(namedCollection.Name, namedCollection.CreateQuery, b.cfg.General.RestoreSchemaOnCluster); err != nil {
+			return fmt.Errorf("can't create named collection `%s`: %v", namedCollection.Name, err)
+		}
+	}
+	log.Info().Msgf("NAMED COLLECTIONS successfully restored")
+	return nil
+}
+
+// restoreWorkloads - resources and workloads stored in backup already ordered, parent workload created before children
+func (b *Backuper) restoreWorkloads(backupMetadata metadata.BackupMetadata) error {
+	for _, workload := range backupMetadata.Workloads {
+		if err := b.ch.CreateWorkload(workload.CreateQuery, b.cfg.General.RestoreSchemaOnCluster); err != nil {
+			return fmt.Errorf("can't create %s `%s`: %v", strings.ToLower(workload.Kind), workload.Name, err)
+		}
+	}
+	return nil
+}
+
+// restoreKeeper - restore backup_name/keeper/*.jsonl[.gz] dumps, created for general.keeper_paths, into paths remapped with general.restore_keeper_path_mapping,
+// existing nodes with different value or ACL processed according to general.keeper_restore_mode
+func (b *Backuper) restoreKeeper(ctx context.Context, backupName string) error {
+	srcBackupDir := path.Join(b.DefaultDataPath, "backup", backupName, "keeper")
+	jsonLFiles, err := filepathx.Glob(path.Join(srcBackupDir, "*.jsonl*"))
+	if err != nil {
+		return err
+	}
+	if len(jsonLFiles) == 0 {
+		log.Warn().Msgf("%s doesn't contain keeper dumps, skip keeper restore", srcBackupDir)
+		return nil
+	}
+	k := keeper.Keeper{}
+	if err = k.Connect(ctx, b.ch); err != nil {
+		return err
+	}
+	defer k.Close()
+	for _, jsonLFile := range jsonLFiles {
+		keeperPath, err := url.PathUnescape(strings.TrimSuffix(strings.TrimSuffix(path.Base(jsonLFile), ".gz"), ".jsonl"))
+		if err != nil {
+			return fmt.Errorf("can't decode keeper path from %s: %v", jsonLFile, err)
+		}
+		restorePath := getRestoreKeeperPath(keeperPath, b.cfg.General.RestoreKeeperPathMapping)
+		log.Info().Msgf("keeper.Restore(%s) -> %s", jsonLFile, restorePath)
+		report, err := k.Restore(jsonLFile, restorePath, keeper.RestoreMode(b.cfg.General.KeeperRestoreMode))
+		logRestoreKeeperReport(restorePath, b.cfg.General.KeeperRestoreMode, report)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func logRestoreKeeperReport(restorePath, mode string, report keeper.RestoreReport) {
+	log.Info().Fields(map[string]interface{}{
+		"path":      restorePath,
+		"mode":      mode,
+		"created":   report.Created,
+		"updated":   report.Updated,
+		"skipped":   report.Skipped,
+		"unchanged": report.Unchanged,
+		"conflicts": len(report.Conflicts),
+	}).Msg("keeper restore report")
+	for _, conflict := range report.Conflicts {
+		log.Warn().Str("mode", mode).Msgf("znode %s already exists with different value or ACL", conflict)
+	}
+}
+
+// getRestoreKeeperPath - replace the longest source prefix from mapping, prefix shall match whole path components
+func getRestoreKeeperPath(keeperPath string, mapping map[string]string) string {
+	matchedSrc, matchedDst := "", ""
+	for src, dst := range mapping {
+		src = path.Clean("/" + src)
+		if (keeperPath == src || src == "/" || strings.HasPrefix(keeperPath, src+"/")) && len(src) > len(matchedSrc) {
+			matchedSrc, matchedDst = src, path.Clean("/"+dst)
+		}
+	}
+	if matchedSrc == "" {
+		return keeperPath
+	}
+	return path.Join(matchedDst, strings.TrimPrefix(keeperPath, matchedSrc))
+}
+
 // restoreConfigs - copy backup_name/configs folder to /etc/clickhouse-server/
 func (b *Backuper) restoreConfigs(backupName string, disks []clickhouse.Disk) error {
 	if err := b.restoreBackupRelatedDir(backupName, "configs", b.ch.Config.ConfigDir, disks, nil); err != nil && os.IsNotExist(err) {
@@ -889,7 +1019,7 @@ func (b *Backuper) dropExistPartitions(ctx context.Context, tablesForRestore Lis
 // RestoreSchema - restore schemas matched by tablePattern from backupName
 func (b *Backuper) RestoreSchema(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, disks []clickhouse.Disk, tablesForRestore ListOfTables, ignoreDependencies bool, version int) error {
 	startRestoreSchema := time.Now()
-	if dropErr := b.dropExistsTables(tablesForRestore, ignoreDependencies, version); dropErr != nil {
+	if dropErr := b.dropExistsTables(ctx, tablesForRestore, ignoreDependencies, version); dropErr != nil {
 		return dropErr
 	}
 	var restoreErr error
@@ -1083,6 +1213,7 @@ func (b *Backuper) restoreSchemaRegular(ctx context.Context, tablesForRestore Li
 	totalRetries := len(tablesForRestore)
 	restoreRetries := 0
 	isDatabaseCreated := common.EmptyMap{}
+	databases := b.newReplicatedDatabases(version)
 	var restoreErr error
 	for restoreRetries < totalRetries {
 		var notRestoredTables ListOfTables
@@ -1095,17 +1226,40 @@ func (b *Backuper) restoreSchemaRegular(ctx context.Context, tablesForRestore Li
 					isDatabaseCreated[schema.Database] = struct{}{}
 				}
 			}
+			onCluster := b.cfg.General.RestoreSchemaOnCluster
+			isReplicatedDatabase, err := databases.isReplicated(ctx, schema.Database)
+			if err != nil {
+				return err
+			}
+			if isReplicatedDatabase {
+				// Replicated database propagates CREATE to all replicas and doesn't allow ON CLUSTER
+				onCluster = ""
+				if err = databases.sync(ctx, schema.Database); err != nil {
+					return err
+				}
+				isExists, err := b.ch.IsTableExists(ctx, schema.Database, schema.Table)
+				if err != nil {
+					return err
+				}
+				if isExists {
+					log.Info().Msgf("`%s`.`%s` already created by Replicated database replication, skip create", schema.Database, schema.Table)
+					continue
+				}
+			}
 			//materialized and window views should restore via ATTACH
 			b.replaceCreateToAttachForView(&schema)
-			// https://github.com/Altinity/clickhouse-backup/issues/849
-			b.checkReplicaAlreadyExistsAndChangeReplicationPath(ctx, &schema, version)
+			// Replicated database manages replication path for ReplicatedMergeTree tables
+			if !isReplicatedDatabase {
+				// https://github.com/Altinity/clickhouse-backup/issues/849
+				b.checkReplicaAlreadyExistsAndChangeReplicationPath(ctx, &schema, version)
+			}
 
 			// https://github.com/Altinity/clickhouse-backup/issues/466
 			b.replaceUUIDMacroValue(&schema)
 			restoreErr = b.ch.CreateTable(clickhouse.Table{
 				Database: schema.Database,
 				Name:     schema.Table,
-			}, schema.Query, false, false, b.cfg.General.RestoreSchemaOnCluster, version, b.DefaultDataPath)
+			}, schema.Query, false, false, onCluster, version, b.DefaultDataPath)
 
 			if restoreErr != nil {
 				restoreRetries++
@@ -1216,13 +1370,21 @@ func (b *Backuper) replaceCreateToAttachForView(schema *metadata.TableMetadata)
 	)
 }
 
-func (b *Backuper) dropExistsTables(tablesForDrop ListOfTables, ignoreDependencies bool, version int) error {
+func (b *Backuper) dropExistsTables(ctx context.Context, tablesForDrop ListOfTables, ignoreDependencies bool, version int) error {
 	var dropErr error
 	dropRetries := 0
 	totalRetries := len(tablesForDrop)
+	databases := b.newReplicatedDatabases(version)
 	for dropRetries < totalRetries {
 		var notDroppedTables ListOfTables
 		for i, schema := range tablesForDrop {
+			// Replicated database propagates DROP to all replicas and doesn't allow ON CLUSTER
+			onCluster := b.cfg.General.RestoreSchemaOnCluster
+			if isReplicatedDatabase, err := databases.isReplicated(ctx, schema.Database); err != nil {
+				return err
+			} else if isReplicatedDatabase {
+				onCluster = ""
+			}
 			if schema.Query == "" {
 				possibleQueries := []string{
 					fmt.Sprintf("CREATE DICTIONARY `%s`.`%s`", schema.Database, schema.Table),
@@ -1237,7 +1399,7 @@ func (b *Backuper) dropExistsTables(tablesForDrop ListOfTables, ignoreDependenci
 					dropErr = b.ch.DropTable(clickhouse.Table{
 						Database: schema.Database,
 						Name:     schema.Table,
-					}, query, b.cfg.General.RestoreSchemaOnCluster, ignoreDependencies, version, b.DefaultDataPath)
+					}, query, onCluster, ignoreDependencies, version, b.DefaultDataPath)
 					if dropErr == nil {
 						tablesForDrop[i].Query = query
 						break
@@ -1247,7 +1409,7 @@ func (b *Backuper) dropExistsTables(tablesForDrop ListOfTables, ignoreDependenci
 				dropErr = b.ch.DropTable(clickhouse.Table{
 					Database: schema.Database,
 					Name:     schema.Table,
-				}, schema.Query, b.cfg.General.RestoreSchemaOnCluster, ignoreDependencies, version, b.DefaultDataPath)
+				}, schema.Query, onCluster, ignoreDependencies, version, b.DefaultDataPath)
 			}
 
 			if dropErr != nil {
@@ -1311,6 +1473,11 @@ func (b *Backuper) RestoreData(ctx context.Context, backupName string, backupMet
 		err = b.restoreDataEmbedded(ctx, backupName, dataOnly, version, tablesForRestore, partitionsNameList)
 	} else {
 		err = b.restoreDataRegular(ctx, backupName, backupMetadata, tablePattern, tablesForRestore, diskMap, diskTypes, disks)
+		if b.cfg.General.RestoreReshardCluster != "" {
+			if dropErr := b.dropReshardStagingDatabase(ctx); dropErr != nil {
+				log.Warn().Msgf("can't drop %s database: %v", reshardStagingDatabase, dropErr)
+			}
+		}
 	}
 	if err != nil {
 		return err
@@ -1339,6 +1506,14 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 		return err
 	}
 
+	version, err := b.ch.GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if err = b.syncReplicatedDatabases(ctx, tablesForRestore, version); err != nil {
+		return err
+	}
+
 	chTables, err := b.ch.GetTables(ctx, tablePattern)
 	if err != nil {
 		return err
@@ -1381,13 +1556,38 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 		}
 		idx := i
 		restoreBackupWorkingGroup.Go(func() error {
+			if b.resume && b.resumableState.IsAlreadyProcessedBool(getRestoreTableResumableKey(dstTable)) {
+				logger.Info().Msg("data already restored, skip")
+				return nil
+			}
+			if table.DataFormat != "" {
+				if restoreErr := b.restoreDataLogical(restoreCtx, backupName, table, diskMap, disks, dstTable, logger); restoreErr != nil {
+					return restoreErr
+				}
+				b.markTableRestored(table, dstTable)
+				log.Ctx(ctx).Info().Fields(map[string]interface{}{
+					"duration":  utils.HumanizeDuration(time.Since(tableRestoreStartTime)),
+					"operation": "restoreDataLogical",
+					"database":  dstTable.Database,
+					"table":     dstTable.Name,
+					"progress":  fmt.Sprintf("%d/%d", idx+1, len(tablesForRestore)),
+				}).Msg("done")
+				return nil
+			}
+			if b.isReshardingRequired(table, dstTable) {
+				if restoreErr := b.restoreDataResharding(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, logger); restoreErr != nil {
+					return restoreErr
+				}
+				b.markTableRestored(table, dstTable)
+				return nil
+			}
 			// https://github.com/Altinity/clickhouse-backup/issues/529
 			if b.cfg.ClickHouse.RestoreAsAttach {
 				if restoreErr := b.restoreDataRegularByAttach(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, logger); restoreErr != nil {
 					return restoreErr
 				}
 			} else {
-				if restoreErr := b.restoreDataRegularByParts(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, logger); restoreErr != nil {
+				if restoreErr := b.restoreDataRegularByParts(restoreCtx, backupName, backupMetadata, table, diskMap, diskTypes, disks, dstTable, true, logger); restoreErr != nil {
 					return restoreErr
 				}
 			}
@@ -1397,7 +1597,8 @@ func (b *Backuper) restoreDataRegular(ctx context.Context, backupName string, ba
 					log.Warn().Msgf("can't apply mutation %s for table `%s`.`%s`	: %v", mutation.Command, tablesForRestore[idx].Database, tablesForRestore[idx].Table, err)
 				}
 			}
-			log.Info().Fields(map[string]interface{}{
+			b.markTableRestored(table, dstTable)
+			log.Ctx(ctx).Info().Fields(map[string]interface{}{
 				"duration":  utils.HumanizeDuration(time.Since(tableRestoreStartTime)),
 				"operation": "restoreDataRegular",
 				"database":  dstTable.Database,
@@ -1433,7 +1634,50 @@ func (b *Backuper) restoreDataRegularByAttach(ctx context.Context, backupName st
 	return nil
 }
 
-func (b *Backuper) restoreDataRegularByParts(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, table metadata.TableMetadata, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, logger zerolog.Logger) error {
+// getRestoreTableResumableKey - resumable state key for table which data restored completely, including mutations
+func getRestoreTableResumableKey(dstTable clickhouse.Table) string {
+	return path.Join("restore_table", common.TablePathEncode(dstTable.Database), common.TablePathEncode(dstTable.Name))
+}
+
+// getAttachPartResumableKey - resumable state key for part which already attached to destination table
+func getAttachPartResumableKey(dstTable clickhouse.Table, disk, partName string) string {
+	return path.Join("attach_part", common.TablePathEncode(dstTable.Database), common.TablePathEncode(dstTable.Name), disk, partName)
+}
+
+func (b *Backuper) markTableRestored(table metadata.TableMetadata, dstTable clickhouse.Table) {
+	if b.resume {
+		b.resumableState.AppendToState(getRestoreTableResumableKey(dstTable), int64(table.TotalBytes))
+	}
+}
+
+// filterAttachedParts - after crash during restore some parts already attached, attach them again will duplicate data,
+// so exclude them from hardlink into detached, object disk copy and attach
+func (b *Backuper) filterAttachedParts(table metadata.TableMetadata, dstTable clickhouse.Table, logger zerolog.Logger) metadata.TableMetadata {
+	attachedParts := 0
+	filteredParts := make(map[string][]metadata.Part, len(table.Parts))
+	for disk, parts := range table.Parts {
+		filteredParts[disk] = make([]metadata.Part, 0, len(parts))
+		for _, part := range parts {
+			if b.resumableState.IsAlreadyProcessedBool(getAttachPartResumableKey(dstTable, disk, part.Name)) {
+				attachedParts += 1
+				continue
+			}
+			filteredParts[disk] = append(filteredParts[disk], part)
+		}
+	}
+	if attachedParts > 0 {
+		logger.Info().Msgf("%d parts already attached, skip", attachedParts)
+	}
+	table.Parts = filteredParts
+	return table
+}
+
+// restoreDataRegularByParts - trackAttachedParts shall be false when dstTable re-created on each restore attempt
+func (b *Backuper) restoreDataRegularByParts(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, table metadata.TableMetadata, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, trackAttachedParts bool, logger zerolog.Logger) error {
+	trackAttachedParts = trackAttachedParts && b.resume
+	if trackAttachedParts {
+		table = b.filterAttachedParts(table, dstTable, logger)
+	}
 	if err := filesystemhelper.HardlinkBackupPartsToStorage(backupName, table, disks, diskMap, dstTable.DataPaths, b.ch, true); err != nil {
 		return fmt.Errorf("can't copy data to detached '%s.%s': %v", table.Database, table.Table, err)
 	}
@@ -1446,7 +1690,11 @@ func (b *Backuper) restoreDataRegularByParts(ctx context.Context, backupName str
 		return fmt.Errorf("can't restore object_disk server-side copy data parts '%s.%s': %v", table.Database, table.Table, err)
 	}
 	log.Info().Str("duration", utils.HumanizeDuration(time.Since(start))).Str("size", utils.FormatBytes(uint64(size))).Msg("download object_disks finish")
-	if err := b.ch.AttachDataParts(table, dstTable); err != nil {
+	if err := b.ch.AttachDataParts(table, dstTable, func(disk string, part metadata.Part) {
+		if trackAttachedParts {
+			b.resumableState.AppendToState(getAttachPartResumableKey(dstTable, disk, part.Name), 0)
+		}
+	}); err != nil {
 		return fmt.Errorf("can't attach data parts for table '%s.%s': %v", table.Database, table.Table, err)
 	}
 	return nil
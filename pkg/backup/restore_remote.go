@@ -1,13 +1,24 @@
 package backup
 
-import "errors"
+import (
+	"errors"
 
-func (b *Backuper) RestoreFromRemote(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume bool, version string, commandId int) error {
-	if err := b.Download(backupName, tablePattern, partitions, schemaOnly, resume, version, commandId); err != nil {
+	"github.com/rs/zerolog/log"
+)
+
+func (b *Backuper) RestoreFromRemote(backupName, tablePattern string, databaseMapping, tableMapping, partitions []string, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, restoreKeeper, restoreNamedCollections, resume bool, version string, commandId int, step StepFunc) error {
+	if err := runStep(step, commandId, "download", func() error {
+		err := b.Download(backupName, tablePattern, partitions, schemaOnly, resume, version, commandId)
 		// https://github.com/Altinity/clickhouse-backup/issues/625
-		if !errors.Is(err, ErrBackupIsAlreadyExists) {
-			return err
+		if errors.Is(err, ErrBackupIsAlreadyExists) {
+			log.Warn().Msgf("%s, skip download", err.Error())
+			return nil
 		}
+		return err
+	}); err != nil {
+		return err
 	}
-	return b.Restore(backupName, tablePattern, databaseMapping, tableMapping, partitions, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume, version, commandId)
+	return runStep(step, commandId, "restore", func() error {
+		return b.Restore(backupName, tablePattern, databaseMapping, tableMapping, partitions, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, restoreKeeper, restoreNamedCollections, resume, version, commandId)
+	})
 }
@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+)
+
+// replicatedDatabases - cache of target database engines, tables in ENGINE=Replicated databases are created on one replica,
+// and DDL replication propagates them to other replicas
+type replicatedDatabases struct {
+	b       *Backuper
+	version int
+	engines map[string]string
+	synced  map[string]bool
+}
+
+func (b *Backuper) newReplicatedDatabases(version int) *replicatedDatabases {
+	return &replicatedDatabases{b: b, version: version, engines: map[string]string{}, synced: map[string]bool{}}
+}
+
+// isReplicated - database engine is Replicated, engine is cached after first successful check
+func (r *replicatedDatabases) isReplicated(ctx context.Context, database string) (bool, error) {
+	if engine, isCached := r.engines[database]; isCached {
+		return engine == "Replicated", nil
+	}
+	engine, err := r.b.ch.GetDatabaseEngine(ctx, database)
+	if err != nil {
+		return false, err
+	}
+	if engine != "" {
+		r.engines[database] = engine
+	}
+	return engine == "Replicated", nil
+}
+
+// sync - apply DDL from database replication log once, other replica could already create restored tables
+func (r *replicatedDatabases) sync(ctx context.Context, database string) error {
+	if r.synced[database] {
+		return nil
+	}
+	if err := r.b.ch.SyncDatabaseReplica(ctx, database, r.version); err != nil {
+		return fmt.Errorf("can't sync Replicated database `%s`: %v", database, err)
+	}
+	r.synced[database] = true
+	return nil
+}
+
+// syncReplicatedDatabases - wait until restored tables created on another replica appear locally, before attach data
+func (b *Backuper) syncReplicatedDatabases(ctx context.Context, tablesForRestore ListOfTables, version int) error {
+	databases := b.newReplicatedDatabases(version)
+	for _, table := range tablesForRestore {
+		dstDatabase := table.Database
+		if targetDB, isMapped := b.cfg.General.RestoreDatabaseMapping[table.Database]; isMapped {
+			dstDatabase = targetDB
+		}
+		isReplicated, err := databases.isReplicated(ctx, dstDatabase)
+		if err != nil {
+			return err
+		}
+		if !isReplicated {
+			continue
+		}
+		if err = databases.sync(ctx, dstDatabase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareReplicatedDatabaseQuery - change ENGINE=Replicated arguments to avoid conflicting replicas, shall be called when target database doesn't exist
+func (b *Backuper) prepareReplicatedDatabaseQuery(ctx context.Context, query, srcDatabase, targetDatabase string) string {
+	zookeeperPath, shardName, replicaName, isReplicated := clickhouse.ParseReplicatedDatabaseEngine(query)
+	if !isReplicated {
+		return query
+	}
+	isRenamed := srcDatabase != targetDatabase
+	isReplicaExists := false
+	if !isRenamed {
+		isReplicaExists = b.isReplicatedDatabaseReplicaExists(ctx, zookeeperPath, shardName, replicaName)
+	}
+	return b.remapReplicatedDatabaseEngine(query, targetDatabase, isRenamed || isReplicaExists)
+}
+
+// remapReplicatedDatabaseEngine - use clickhouse->default_replicated_database_path when database restored with another name
+// or replica already registered, otherwise new database joins replication of the backup database,
+// shard and replica names hardcoded in backup replaced to macros when schema restored ON CLUSTER, each host shall register own replica
+func (b *Backuper) remapReplicatedDatabaseEngine(query, targetDatabase string, remapPath bool) string {
+	zookeeperPath, shardName, replicaName, isReplicated := clickhouse.ParseReplicatedDatabaseEngine(query)
+	if !isReplicated {
+		return query
+	}
+	newPath, newShard, newReplica := zookeeperPath, shardName, replicaName
+	if remapPath {
+		newPath = strings.ReplaceAll(b.cfg.ClickHouse.DefaultReplicatedDatabasePath, "{database}", targetDatabase)
+		newShard, newReplica = b.cfg.ClickHouse.DefaultReplicatedDatabaseShard, b.cfg.ClickHouse.DefaultReplicatedDatabaseReplica
+	} else if b.cfg.General.RestoreSchemaOnCluster != "" && !strings.Contains(shardName, "{") && !strings.Contains(replicaName, "{") {
+		newShard, newReplica = b.cfg.ClickHouse.DefaultReplicatedDatabaseShard, b.cfg.ClickHouse.DefaultReplicatedDatabaseReplica
+	}
+	if newPath == zookeeperPath && newShard == shardName && newReplica == replicaName {
+		return query
+	}
+	log.Warn().Msgf("Replicated database `%s` will restore with Replicated('%s', '%s', '%s') instead of Replicated('%s', '%s', '%s')", targetDatabase, newPath, newShard, newReplica, zookeeperPath, shardName, replicaName)
+	return clickhouse.ReplaceReplicatedDatabaseEngine(query, newPath, newShard, newReplica)
+}
+
+// isReplicatedDatabaseReplicaExists - Replicated database registers replica as <zookeeper_path>/replicas/<shard>|<replica>
+func (b *Backuper) isReplicatedDatabaseReplicaExists(ctx context.Context, zookeeperPath, shardName, replicaName string) bool {
+	var err error
+	if zookeeperPath, err = b.ch.ApplyMacros(ctx, zookeeperPath); err != nil {
+		log.Warn().Msgf("can't ApplyMacros to %s error: %v", zookeeperPath, err)
+		return false
+	}
+	if shardName, err = b.ch.ApplyMacros(ctx, shardName); err != nil {
+		log.Warn().Msgf("can't ApplyMacros to %s error: %v", shardName, err)
+		return false
+	}
+	if replicaName, err = b.ch.ApplyMacros(ctx, replicaName); err != nil {
+		log.Warn().Msgf("can't ApplyMacros to %s error: %v", replicaName, err)
+		return false
+	}
+	isReplicaPresent := uint64(0)
+	replicasPath := path.Join(zookeeperPath, "replicas")
+	if err = b.ch.SelectSingleRow(ctx, &isReplicaPresent, "SELECT count() FROM system.zookeeper WHERE path=? AND name=?", replicasPath, shardName+"|"+replicaName); err != nil {
+		log.Warn().Msgf("can't check replica %s/%s|%s in system.zookeeper error: %v", replicasPath, shardName, replicaName, err)
+		return false
+	}
+	return isReplicaPresent > 0
+}
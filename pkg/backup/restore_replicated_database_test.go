@@ -0,0 +1,34 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+func TestRemapReplicatedDatabaseEngine(t *testing.T) {
+	b := &Backuper{cfg: config.DefaultConfig()}
+	query := "CREATE DATABASE IF NOT EXISTS `db` ENGINE = Replicated('/clickhouse/databases/db', 'shard1', 'replica1')"
+
+	assert.Equal(t, query, b.remapReplicatedDatabaseEngine(query, "db", false))
+	assert.Equal(
+		t,
+		"CREATE DATABASE IF NOT EXISTS `db` ENGINE = Replicated('/clickhouse/databases/db_restored', '{shard}', '{replica}')",
+		b.remapReplicatedDatabaseEngine(query, "db_restored", true),
+	)
+
+	// each host shall register own replica when database created ON CLUSTER
+	b.cfg.General.RestoreSchemaOnCluster = "cluster"
+	assert.Equal(
+		t,
+		"CREATE DATABASE IF NOT EXISTS `db` ENGINE = Replicated('/clickhouse/databases/db', '{shard}', '{replica}')",
+		b.remapReplicatedDatabaseEngine(query, "db", false),
+	)
+	macrosQuery := "CREATE DATABASE IF NOT EXISTS `db` ENGINE = Replicated('/clickhouse/databases/db', '{shard}', '{replica}')"
+	assert.Equal(t, macrosQuery, b.remapReplicatedDatabaseEngine(macrosQuery, "db", false))
+
+	atomicQuery := "CREATE DATABASE IF NOT EXISTS `db` ENGINE = Atomic"
+	assert.Equal(t, atomicQuery, b.remapReplicatedDatabaseEngine(atomicQuery, "db_restored", true))
+}
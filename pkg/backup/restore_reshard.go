@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+const (
+	// reshardStagingDatabase - temporary tables are created in separate non replicated database, destination database could have Replicated engine
+	reshardStagingDatabase   = "_clickhouse_backup_reshard"
+	reshardStagingSuffix     = "_reshard_staging"
+	reshardDistributedSuffix = "_reshard_distributed"
+)
+
+var replicatedEngineWithParamsRE = regexp.MustCompile(`^Replicated(\w*MergeTree)\(\s*'[^']*'\s*,\s*'[^']*'\s*(,\s*)?`)
+var replicatedEngineWithoutParamsRE = regexp.MustCompile(`^Replicated(\w*MergeTree)\b`)
+
+// convertReplicatedEngineToMergeTree - staging table shall not register replica in keeper, so engine_full from system.tables converts to non replicated engine with the same keys and settings
+func convertReplicatedEngineToMergeTree(engineFull string) string {
+	if replicatedEngineWithParamsRE.MatchString(engineFull) {
+		return replicatedEngineWithParamsRE.ReplaceAllString(engineFull, "${1}(")
+	}
+	return replicatedEngineWithoutParamsRE.ReplaceAllString(engineFull, "${1}")
+}
+
+// isReshardingRequired - only *MergeTree tables with data parts are redistributed, other tables use regular restore
+func (b *Backuper) isReshardingRequired(table metadata.TableMetadata, dstTable clickhouse.Table) bool {
+	return b.cfg.General.RestoreReshardCluster != "" && strings.HasSuffix(dstTable.Engine, "MergeTree") && len(table.Parts) > 0
+}
+
+// getReshardTemporaryTables - staging and Distributed tables for destination table, name contains destination database to avoid collisions in reshardStagingDatabase
+func getReshardTemporaryTables(dstTable clickhouse.Table) (clickhouse.Table, clickhouse.Table) {
+	tableName := fmt.Sprintf("%s.%s", dstTable.Database, dstTable.Name)
+	return clickhouse.Table{Database: reshardStagingDatabase, Name: tableName + reshardStagingSuffix}, clickhouse.Table{Database: reshardStagingDatabase, Name: tableName + reshardDistributedSuffix}
+}
+
+// getReshardPartitionResumableKey - resumable state key for partition which already inserted via Distributed table, isStarted key is written before INSERT
+func getReshardPartitionResumableKey(dstTable clickhouse.Table, partitionId string, isStarted bool) string {
+	key := path.Join("reshard_partition", common.TablePathEncode(dstTable.Database), common.TablePathEncode(dstTable.Name), common.TablePathEncode(partitionId))
+	if isStarted {
+		key += ".started"
+	}
+	return key
+}
+
+// createReshardStagingDatabase - Atomic or Ordinary engine explicitly, default_database_engine could be Replicated
+func (b *Backuper) createReshardStagingDatabase(version int) error {
+	engine := "Atomic"
+	if version < 20005000 {
+		engine = "Ordinary"
+	}
+	return b.ch.CreateDatabaseWithEngine(reshardStagingDatabase, engine, "")
+}
+
+// dropReshardStagingDatabase - shall be called after all tables restored, temporary tables of each table drop in restoreDataResharding
+func (b *Backuper) dropReshardStagingDatabase(ctx context.Context) error {
+	return b.ch.QueryContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", reshardStagingDatabase))
+}
+
+// restoreDataResharding - attach backup parts into temporary staging table, then redistribute data partition by partition via temporary Distributed table over general->restore_reshard_cluster
+func (b *Backuper) restoreDataResharding(ctx context.Context, backupName string, backupMetadata metadata.BackupMetadata, table metadata.TableMetadata, diskMap, diskTypes map[string]string, disks []clickhouse.Disk, dstTable clickhouse.Table, logger zerolog.Logger) error {
+	start := time.Now()
+	version, err := b.ch.GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+	engines := make([]struct {
+		EngineFull string `ch:"engine_full"`
+	}, 0)
+	if err = b.ch.SelectContext(ctx, &engines, "SELECT engine_full FROM system.tables WHERE database=? AND name=?", dstTable.Database, dstTable.Name); err != nil {
+		return err
+	}
+	if len(engines) != 1 {
+		return fmt.Errorf("can't find `%s`.`%s` in system.tables", dstTable.Database, dstTable.Name)
+	}
+	if err = b.createReshardStagingDatabase(version); err != nil {
+		return fmt.Errorf("can't create %s database: %v", reshardStagingDatabase, err)
+	}
+	stagingTable, distributedTable := getReshardTemporaryTables(dstTable)
+	dropTemporaryTables := func() error {
+		for _, t := range []clickhouse.Table{distributedTable, stagingTable} {
+			if err := b.ch.DropTable(t, "", "", false, version, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// staging tables could be left after previous failed restore
+	if err = dropTemporaryTables(); err != nil {
+		return err
+	}
+	defer func() {
+		if dropErr := dropTemporaryTables(); dropErr != nil {
+			logger.Warn().Msgf("can't drop resharding temporary tables: %v", dropErr)
+		}
+	}()
+	stagingQuery := fmt.Sprintf("CREATE TABLE `%s`.`%s` AS `%s`.`%s` ENGINE = %s", stagingTable.Database, stagingTable.Name, dstTable.Database, dstTable.Name, convertReplicatedEngineToMergeTree(engines[0].EngineFull))
+	if err = b.ch.QueryContext(ctx, stagingQuery); err != nil {
+		return fmt.Errorf("can't create staging table: %v", err)
+	}
+	shardingKey := b.cfg.General.RestoreReshardShardingKey
+	if shardingKey == "" {
+		shardingKey = "rand()"
+	}
+	distributedQuery := fmt.Sprintf("CREATE TABLE `%s`.`%s` AS `%s`.`%s` ENGINE = Distributed('%s', '%s', '%s', %s)", distributedTable.Database, distributedTable.Name, dstTable.Database, dstTable.Name, b.cfg.General.RestoreReshardCluster, dstTable.Database, dstTable.Name, shardingKey)
+	if err = b.ch.QueryContext(ctx, distributedQuery); err != nil {
+		return fmt.Errorf("can't create distributed table: %v", err)
+	}
+	stagingTables, err := b.ch.GetTables(ctx, fmt.Sprintf("%s.%s", stagingTable.Database, stagingTable.Name))
+	if err != nil {
+		return err
+	}
+	if len(stagingTables) != 1 {
+		return fmt.Errorf("can't find `%s`.`%s` in system.tables", stagingTable.Database, stagingTable.Name)
+	}
+	stagingTable = stagingTables[0]
+	// staging table is not replicated, CheckReplicationInProgress shall be skipped
+	table.Query = stagingQuery
+	// staging table re-created on each attempt, so attached parts can't be tracked in resumable state
+	if err = b.restoreDataRegularByParts(ctx, backupName, backupMetadata, table, diskMap, diskTypes, disks, stagingTable, false, logger); err != nil {
+		return err
+	}
+	for _, mutation := range table.Mutations {
+		if err = b.ch.ApplyMutation(ctx, metadata.TableMetadata{Database: stagingTable.Database, Table: stagingTable.Name}, mutation); err != nil {
+			logger.Warn().Msgf("can't apply mutation %s for table `%s`.`%s`: %v", mutation.Command, stagingTable.Database, stagingTable.Name, err)
+		}
+	}
+	partitionIds := make([]struct {
+		PartitionId string `ch:"partition_id"`
+	}, 0)
+	if err = b.ch.SelectContext(ctx, &partitionIds, "SELECT DISTINCT partition_id FROM system.parts WHERE active AND database=? AND table=? ORDER BY partition_id", stagingTable.Database, stagingTable.Name); err != nil {
+		return err
+	}
+	skippedPartitions := 0
+	for i, p := range partitionIds {
+		partitionStart := time.Now()
+		partitionId := strings.ReplaceAll(p.PartitionId, "'", "\\'")
+		if b.resume {
+			if b.resumableState.IsAlreadyProcessedBool(getReshardPartitionResumableKey(dstTable, p.PartitionId, false)) {
+				skippedPartitions += 1
+				continue
+			}
+			// previous INSERT was interrupted, part of rows could already reach shards, drop them to avoid duplicates
+			if b.resumableState.IsAlreadyProcessedBool(getReshardPartitionResumableKey(dstTable, p.PartitionId, true)) {
+				logger.Warn().Msgf("partition %s redistribution was interrupted, drop it on cluster %s before insert again", p.PartitionId, b.cfg.General.RestoreReshardCluster)
+				dropQuery := fmt.Sprintf("ALTER TABLE `%s`.`%s` ON CLUSTER '%s' DROP PARTITION ID '%s'", dstTable.Database, dstTable.Name, b.cfg.General.RestoreReshardCluster, partitionId)
+				if err = b.ch.QueryContext(ctx, dropQuery); err != nil {
+					return fmt.Errorf("can't drop partially redistributed partition %s: %v", p.PartitionId, err)
+				}
+			}
+			b.resumableState.AppendToState(getReshardPartitionResumableKey(dstTable, p.PartitionId, true), 0)
+		}
+		insertQuery := fmt.Sprintf("INSERT INTO `%s`.`%s` SETTINGS insert_distributed_sync=1 SELECT * FROM `%s`.`%s` WHERE _partition_id='%s'", distributedTable.Database, distributedTable.Name, stagingTable.Database, stagingTable.Name, partitionId)
+		if err = b.ch.QueryContext(ctx, insertQuery); err != nil {
+			return fmt.Errorf("can't redistribute partition %s: %v", p.PartitionId, err)
+		}
+		if b.resume {
+			b.resumableState.AppendToState(getReshardPartitionResumableKey(dstTable, p.PartitionId, false), 0)
+		}
+		logger.Debug().Fields(map[string]interface{}{
+			"partition_id": p.PartitionId,
+			"progress":     fmt.Sprintf("%d/%d", i+1, len(partitionIds)),
+			"duration":     utils.HumanizeDuration(time.Since(partitionStart)),
+		}).Msg("partition redistributed")
+	}
+	if skippedPartitions > 0 {
+		logger.Info().Msgf("%d partitions already redistributed, skip", skippedPartitions)
+	}
+	log.Info().Fields(map[string]interface{}{
+		"operation":  "restoreDataResharding",
+		"database":   dstTable.Database,
+		"table":      dstTable.Name,
+		"cluster":    b.cfg.General.RestoreReshardCluster,
+		"partitions": len(partitionIds),
+		"duration":   utils.HumanizeDuration(time.Since(start)),
+	}).Msg("done")
+	return nil
+}
@@ -2,8 +2,17 @@ package backup
 
 import (
 	"fmt"
-	"github.com/stretchr/testify/assert"
+	"os"
+	"path"
 	"testing"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
 )
 
 func TestDetectRBACObject(t *testing.T) {
@@ -83,3 +92,86 @@ func TestDetectRBACObject(t *testing.T) {
 		}
 	}
 }
+
+func TestConvertReplicatedEngineToMergeTree(t *testing.T) {
+	testCases := map[string]string{
+		"ReplicatedMergeTree('/clickhouse/tables/{shard}/db/t', '{replica}') PARTITION BY toYYYYMM(d) ORDER BY id SETTINGS index_granularity = 8192": "MergeTree() PARTITION BY toYYYYMM(d) ORDER BY id SETTINGS index_granularity = 8192",
+		"ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/db/t', '{replica}', ver) ORDER BY id":                                              "ReplacingMergeTree(ver) ORDER BY id",
+		"ReplicatedSummingMergeTree ORDER BY id": "SummingMergeTree ORDER BY id",
+		"MergeTree ORDER BY id":                  "MergeTree ORDER BY id",
+	}
+	for engineFull, expected := range testCases {
+		assert.Equal(t, expected, convertReplicatedEngineToMergeTree(engineFull))
+	}
+}
+
+func TestGetReshardTemporaryTables(t *testing.T) {
+	stagingTable, distributedTable := getReshardTemporaryTables(clickhouse.Table{Database: "replicated_db", Name: "events"})
+	assert.Equal(t, clickhouse.Table{Database: reshardStagingDatabase, Name: "replicated_db.events_reshard_staging"}, stagingTable)
+	assert.Equal(t, clickhouse.Table{Database: reshardStagingDatabase, Name: "replicated_db.events_reshard_distributed"}, distributedTable)
+
+	dstTable := clickhouse.Table{Database: "db", Name: "events"}
+	assert.Equal(t, "reshard_partition/db/events/202405", getReshardPartitionResumableKey(dstTable, "202405", false))
+	assert.Equal(t, "reshard_partition/db/events/202405.started", getReshardPartitionResumableKey(dstTable, "202405", true))
+}
+
+func TestFilterAttachedParts(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	b := &Backuper{resume: true}
+	b.resumableState = resumable.NewState(stateDir, "test_backup", "restore", nil)
+	defer b.resumableState.Close()
+
+	dstTable := clickhouse.Table{Database: "db", Name: "t"}
+	b.resumableState.AppendToState(getAttachPartResumableKey(dstTable, "default", "all_1_1_0"), 0)
+	b.resumableState.AppendToState(getAttachPartResumableKey(clickhouse.Table{Database: "db", Name: "other"}, "default", "all_2_2_0"), 0)
+
+	table := metadata.TableMetadata{
+		Database: "db",
+		Table:    "t",
+		Parts: map[string][]metadata.Part{
+			"default": {{Name: "all_1_1_0"}, {Name: "all_2_2_0"}},
+			"hdd":     {{Name: "all_1_1_0"}},
+		},
+	}
+	filtered := b.filterAttachedParts(table, dstTable, log.Logger)
+	assert.Equal(t, []metadata.Part{{Name: "all_2_2_0"}}, filtered.Parts["default"])
+	assert.Equal(t, []metadata.Part{{Name: "all_1_1_0"}}, filtered.Parts["hdd"])
+	assert.Len(t, table.Parts["default"], 2, "source table metadata shall not be changed")
+}
+
+func TestGetRestoreKeeperPath(t *testing.T) {
+	mapping := map[string]string{
+		"/clickhouse/app":          "/clickhouse/app_restored",
+		"/clickhouse/app/settings": "/settings",
+		"/other/":                  "other_restored",
+	}
+	testCases := map[string]string{
+		"/clickhouse/app":                "/clickhouse/app_restored",
+		"/clickhouse/app/queue":          "/clickhouse/app_restored/queue",
+		"/clickhouse/app/settings/users": "/settings/users",
+		"/clickhouse/application":        "/clickhouse/application",
+		"/other/node":                    "/other_restored/node",
+		"/unmapped":                      "/unmapped",
+	}
+	for keeperPath, expected := range testCases {
+		assert.Equal(t, expected, getRestoreKeeperPath(keeperPath, mapping), keeperPath)
+	}
+	assert.Equal(t, "/restored/app", getRestoreKeeperPath("/app", map[string]string{"/": "/restored"}))
+}
+
+func TestSortByDictionaryDependencies(t *testing.T) {
+	tables := ListOfTables{
+		{Database: "db", Table: "dict", Query: "CREATE DICTIONARY db.dict (`id` UInt64, `name` String) PRIMARY KEY id SOURCE(CLICKHOUSE(HOST 'localhost' PORT 9000 USER 'default' TABLE 'src_distr' DB 'db')) LIFETIME(MIN 0 MAX 0) LAYOUT(FLAT())"},
+		{Database: "db", Table: "events", Query: "CREATE TABLE db.events (`id` UInt64, `name` String DEFAULT dictGetString('db.dict', 'name', id)) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Table: "src", Query: "CREATE TABLE db.src (`id` UInt64, `name` String) ENGINE = MergeTree ORDER BY id"},
+		{Database: "db", Table: "src_distr", Query: "CREATE TABLE db.src_distr (`id` UInt64, `name` String) ENGINE = Distributed('cluster', 'db', 'src')"},
+		{Database: "db", Table: "view", Query: "CREATE VIEW db.view AS SELECT dictGet('dict', 'name', id) FROM db.src"},
+	}
+	tables.Sort(false)
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Table)
+	}
+	assert.Equal(t, []string{"src_distr", "dict", "events", "src", "view"}, names)
+}
@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/eapache/go-resiliency/retrier"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+// getRemoteResumableStatePath - state checkpoint stored next to backup data, so it will be deleted with the backup
+func getRemoteResumableStatePath(backupName, command string) string {
+	return path.Join(backupName, command+".state2")
+}
+
+// loadRemoteResumableState - pod could be rescheduled to another node with the same local backup on shared storage,
+// so download state checkpoint only when local state file is absent, b.dst shall be connected
+func (b *Backuper) loadRemoteResumableState(ctx context.Context, backupName, command string) error {
+	localStateFile := resumable.GetStateFile(b.GetStateDir(), backupName, command)
+	if _, err := os.Stat(localStateFile); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	remoteStateFile := getRemoteResumableStatePath(backupName, command)
+	if _, err := b.dst.StatFile(ctx, remoteStateFile); err != nil {
+		if errors.Is(err, storage.ErrNotFound) || os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	reader, err := b.dst.GetFileReader(ctx, remoteStateFile)
+	if err != nil {
+		return fmt.Errorf("can't read %s: %v", remoteStateFile, err)
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", remoteStateFile, closeErr)
+		}
+	}()
+	// write into temporary file, partially downloaded state shall not be opened by next resume
+	tmpStateFile := localStateFile + ".tmp"
+	f, err := os.OpenFile(tmpStateFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(f, reader)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("can't download %s -> %s: %v", remoteStateFile, tmpStateFile, err)
+	}
+	if err = os.Rename(tmpStateFile, localStateFile); err != nil {
+		return err
+	}
+	log.Info().Str("remote", remoteStateFile).Int64("size", written).Msgf("resumable state loaded from remote storage")
+	return nil
+}
+
+// checkpointRemoteResumableState - upload consistent copy of b.resumableState
+func (b *Backuper) checkpointRemoteResumableState(ctx context.Context, remoteStateFile string) error {
+	var buf bytes.Buffer
+	if _, err := b.resumableState.WriteTo(&buf); err != nil {
+		return err
+	}
+	retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+	return retry.RunCtx(ctx, func(ctx context.Context) error {
+		return b.dst.PutFile(ctx, remoteStateFile, io.NopCloser(bytes.NewReader(buf.Bytes())))
+	})
+}
+
+// startRemoteResumableStateCheckpoint - upload b.resumableState every interval until returned stop function called,
+// stop(false) uploads last checkpoint for next resume, stop(true) deletes checkpoint after successfully completed command
+func (b *Backuper) startRemoteResumableStateCheckpoint(ctx context.Context, backupName, command string, interval time.Duration) func(isCompleted bool) {
+	remoteStateFile := getRemoteResumableStatePath(backupName, command)
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.checkpointRemoteResumableState(ctx, remoteStateFile); err != nil {
+					log.Warn().Msgf("can't checkpoint resumable state to %s: %v", remoteStateFile, err)
+				}
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func(isCompleted bool) {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			// command could fail cause context canceled, last checkpoint still required
+			stopCtx := context.WithoutCancel(ctx)
+			if isCompleted {
+				if err := b.dst.DeleteFile(stopCtx, remoteStateFile); err != nil && !errors.Is(err, storage.ErrNotFound) && !os.IsNotExist(err) {
+					log.Warn().Msgf("can't delete resumable state checkpoint %s: %v", remoteStateFile, err)
+				}
+				return
+			}
+			if err := b.checkpointRemoteResumableState(stopCtx, remoteStateFile); err != nil {
+				log.Warn().Msgf("can't checkpoint resumable state to %s: %v", remoteStateFile, err)
+			}
+		})
+	}
+}
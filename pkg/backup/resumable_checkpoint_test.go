@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+type checkpointRemoteFile struct {
+	name string
+	size int64
+}
+
+func (f checkpointRemoteFile) Size() int64             { return f.size }
+func (f checkpointRemoteFile) Name() string            { return f.name }
+func (f checkpointRemoteFile) LastModified() time.Time { return time.Now() }
+
+type checkpointStorage struct {
+	storage.RemoteStorage
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *checkpointStorage) StatFile(ctx context.Context, key string) (storage.RemoteFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, exists := s.files[key]
+	if !exists {
+		return nil, storage.ErrNotFound
+	}
+	return checkpointRemoteFile{name: key, size: int64(len(body))}, nil
+}
+
+func (s *checkpointStorage) GetFileReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(s.files[key])), nil
+}
+
+func (s *checkpointStorage) PutFile(ctx context.Context, key string, r io.ReadCloser) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = body
+	return nil
+}
+
+func (s *checkpointStorage) DeleteFile(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+func TestRemoteResumableStateCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	remote := &checkpointStorage{files: map[string][]byte{}}
+	params := map[string]interface{}{"tablePattern": "db.*"}
+	remoteStateFile := getRemoteResumableStatePath("test_backup", "upload")
+	require.Equal(t, "test_backup/upload.state2", remoteStateFile)
+
+	// first host, upload interrupted
+	firstHostDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(firstHostDir, "backup", "test_backup"), 0755))
+	b := &Backuper{cfg: config.DefaultConfig(), DefaultDataPath: firstHostDir, dst: &storage.BackupDestination{RemoteStorage: remote}, resume: true}
+	b.resumableState = resumable.NewState(b.GetStateDir(), "test_backup", "upload", params)
+	stop := b.startRemoteResumableStateCheckpoint(ctx, "test_backup", "upload", time.Hour)
+	b.resumableState.AppendToState("test_backup/shadow/db/t/default_1.tar", 1024)
+	stop(false)
+	b.resumableState.Close()
+	_, err := remote.StatFile(ctx, remoteStateFile)
+	require.NoError(t, err)
+
+	// second host with the same local backup continue upload
+	secondHostDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(secondHostDir, "backup", "test_backup"), 0755))
+	b = &Backuper{cfg: config.DefaultConfig(), DefaultDataPath: secondHostDir, dst: &storage.BackupDestination{RemoteStorage: remote}, resume: true}
+	require.NoError(t, b.loadRemoteResumableState(ctx, "test_backup", "upload"))
+	b.resumableState = resumable.NewState(b.GetStateDir(), "test_backup", "upload", params)
+	isProcessed, size := b.resumableState.IsAlreadyProcessed("test_backup/shadow/db/t/default_1.tar")
+	require.True(t, isProcessed)
+	require.Equal(t, int64(1024), size)
+	stop = b.startRemoteResumableStateCheckpoint(ctx, "test_backup", "upload", time.Hour)
+	stop(true)
+	// stop shall be idempotent, cause deferred stop(false) called after successful stop(true)
+	stop(false)
+	b.resumableState.Close()
+	_, err = remote.StatFile(ctx, remoteStateFile)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// local state has priority over remote checkpoint
+	require.NoError(t, remote.PutFile(ctx, remoteStateFile, io.NopCloser(bytes.NewReader([]byte("broken")))))
+	require.NoError(t, b.loadRemoteResumableState(ctx, "test_backup", "upload"))
+}
@@ -0,0 +1,338 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eapache/go-resiliency/retrier"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+// content addressed parts stored once in <remote_path>/.shared_parts/<hash>/ and referenced by Part.Hash from any backup and table,
+// `data/` directory contains part files when compression_format: none, `data.<ext>` archive contains part files otherwise
+const (
+	sharedPartChecksumsFile = "checksums.txt"
+	sharedPartDataName      = "data"
+	// sharedPartsUploadInProgressMark - written into <remote_path>/<backup_name>/ before upload references any shared part and deleted after metadata.json,
+	// backup without metadata.json is listed as broken, so cleanSharedParts will not delete parts which in-flight upload skipped as already existing
+	sharedPartsUploadInProgressMark = "upload.in_progress"
+)
+
+// calculatePartHash - checksums.txt contains names, sizes and hashes of all part data files, so the same checksums.txt means the same part content,
+// metadata_version.txt is not listed in checksums.txt, but it is required for ATTACH PART, return empty hash when part doesn't contain checksums.txt
+func calculatePartHash(partPath string) (string, error) {
+	h := sha256.New()
+	for _, fileName := range []string{sharedPartChecksumsFile, "metadata_version.txt"} {
+		f, err := os.Open(path.Join(partPath, fileName))
+		if os.IsNotExist(err) {
+			if fileName == sharedPartChecksumsFile {
+				return "", nil
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		if closeErr := f.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", path.Join(partPath, fileName), closeErr)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// calculateTablePartsHashes - set Part.Hash for parts on local disks, object disks parts contain only references to backup related objects, so can't be shared,
+// hashed parts are deduplicated by content, so they shall not be required from diff backup by name
+func (b *Backuper) calculateTablePartsHashes(backupName string, backupMetadata *metadata.BackupMetadata, table *metadata.TableMetadata) error {
+	if table.DataFormat != "" {
+		return nil
+	}
+	dbAndTablePath := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Table))
+	for disk, parts := range table.Parts {
+		if diskType, exists := backupMetadata.DiskTypes[disk]; !exists || diskType != "local" {
+			continue
+		}
+		backupPath := b.getLocalBackupDataPathForTable(backupName, disk, dbAndTablePath)
+		for i := range parts {
+			partPath := path.Join(backupPath, parts[i].Name)
+			if _, err := os.Stat(partPath); os.IsNotExist(err) && parts[i].Required {
+				continue
+			}
+			hash, err := calculatePartHash(partPath)
+			if err != nil {
+				return fmt.Errorf("can't calculate hash for %s: %v", partPath, err)
+			}
+			if hash == "" {
+				continue
+			}
+			parts[i].Hash = hash
+			parts[i].Required = false
+		}
+	}
+	return nil
+}
+
+// getSharedPartRemotePath - remote directory or archive for part content, dataFormat is BackupMetadata.DataFormat or compression_format
+func getSharedPartRemotePath(hash, dataFormat string) string {
+	if dataFormat == DirectoryFormat || dataFormat == "none" {
+		return path.Join(storage.SharedPartsDir, hash, sharedPartDataName)
+	}
+	return path.Join(storage.SharedPartsDir, hash, sharedPartDataName+"."+config.ArchiveExtensions[dataFormat])
+}
+
+// getSharedPartFiles - files relative to part directory, checksums.txt is the last, cause it uses as mark of completed upload
+func getSharedPartFiles(partPath string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(partPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relativePath := strings.TrimPrefix(filePath, partPath)
+		if relativePath != "/"+sharedPartChecksumsFile {
+			files = append(files, relativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(files, "/"+sharedPartChecksumsFile), nil
+}
+
+// uploadSharedPart - upload part into shared area if the same content was not uploaded before by any backup
+func (b *Backuper) uploadSharedPart(ctx context.Context, localPartPath, hash string, deleteSource bool) (int64, error) {
+	compressionFormat := b.cfg.GetCompressionFormat()
+	remotePath := getSharedPartRemotePath(hash, compressionFormat)
+	if b.resume {
+		if isProcessed, processedSize := b.resumableState.IsAlreadyProcessed(remotePath); isProcessed {
+			return processedSize, nil
+		}
+	}
+	files, err := getSharedPartFiles(localPartPath)
+	if err != nil {
+		return 0, err
+	}
+	completeMark := remotePath
+	if compressionFormat == "none" {
+		completeMark = path.Join(remotePath, sharedPartChecksumsFile)
+	}
+	uploadedBytes := int64(0)
+	if _, err = b.dst.StatFile(ctx, completeMark); err == nil {
+		log.Debug().Msgf("%s already exists, skip upload %s", remotePath, localPartPath)
+	} else if compressionFormat == "none" {
+		if uploadedBytes, err = b.dst.UploadPath(ctx, localPartPath, files, remotePath, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.UploadMaxBytesPerSecond); err != nil {
+			return 0, fmt.Errorf("can't upload %s: %v", remotePath, err)
+		}
+	} else {
+		retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+		err = retry.RunCtx(ctx, func(ctx context.Context) error {
+			return b.dst.UploadCompressedStream(ctx, localPartPath, files, remotePath, b.cfg.General.UploadMaxBytesPerSecond)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("can't upload %s: %v", remotePath, err)
+		}
+		remoteFile, err := b.dst.StatFile(ctx, remotePath)
+		if err != nil {
+			return 0, fmt.Errorf("can't check uploaded %s: %v", remotePath, err)
+		}
+		uploadedBytes = remoteFile.Size()
+	}
+	if b.resume {
+		b.resumableState.AppendToState(remotePath, uploadedBytes)
+	}
+	if deleteSource {
+		for _, f := range files {
+			if err = os.Remove(path.Join(localPartPath, f)); err != nil {
+				return 0, fmt.Errorf("can't remove %s, %v", path.Join(localPartPath, f), err)
+			}
+		}
+	}
+	return uploadedBytes, nil
+}
+
+// downloadSharedPart - download part content from shared area into local backup part directory
+func (b *Backuper) downloadSharedPart(ctx context.Context, dataFormat, hash, localPartPath string) error {
+	remotePath := getSharedPartRemotePath(hash, dataFormat)
+	if b.resume && b.isResumableDownloadProcessed(remotePath+":"+localPartPath, localPartPath, true) {
+		return nil
+	}
+	if dataFormat == DirectoryFormat {
+		if err := b.dst.DownloadPath(ctx, remotePath, localPartPath, b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration, b.cfg.General.DownloadMaxBytesPerSecond); err != nil {
+			return err
+		}
+	} else {
+		retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+		err := retry.RunCtx(ctx, func(ctx context.Context) error {
+			return b.dst.DownloadCompressedStream(ctx, remotePath, localPartPath, b.cfg.General.DownloadMaxBytesPerSecond)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if b.resume {
+		partSize, err := getLocalPathSize(localPartPath)
+		if err != nil {
+			return fmt.Errorf("can't calculate size of downloaded %s: %v", localPartPath, err)
+		}
+		b.resumableState.AppendToState(remotePath+":"+localPartPath, partSize)
+	}
+	return nil
+}
+
+// getSharedPartsHashes - all content hashes referenced by table metadata list
+func getSharedPartsHashes(tables []metadata.TableMetadata) common.EmptyMap {
+	hashes := common.EmptyMap{}
+	for _, t := range tables {
+		for _, parts := range t.Parts {
+			for _, p := range parts {
+				if p.Hash != "" {
+					hashes[p.Hash] = struct{}{}
+				}
+			}
+		}
+	}
+	return hashes
+}
+
+// countSharedPartsReferences - increase reference counter for each hash from refCount which is used in tables
+func countSharedPartsReferences(refCount map[string]int, tables []metadata.TableMetadata) {
+	for _, t := range tables {
+		for _, parts := range t.Parts {
+			for _, p := range parts {
+				if _, isCounted := refCount[p.Hash]; isCounted && p.Hash != "" {
+					refCount[p.Hash] += 1
+				}
+			}
+		}
+	}
+}
+
+// readRemoteBackupTables - all table metadata of remote backup without table filters, skip_tables shall not hide references to shared parts
+func (b *Backuper) readRemoteBackupTables(ctx context.Context, backup storage.Backup) ([]metadata.TableMetadata, error) {
+	tables := make([]metadata.TableMetadata, 0, len(backup.Tables))
+	for _, title := range backup.Tables {
+		remoteFile := path.Join(backup.BackupName, "metadata", common.TablePathEncode(title.Database), fmt.Sprintf("%s.json", common.TablePathEncode(title.Table)))
+		reader, err := b.dst.GetFileReader(ctx, remoteFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read %s: %v", remoteFile, err)
+		}
+		body, err := io.ReadAll(reader)
+		if closeErr := reader.Close(); closeErr != nil {
+			log.Warn().Msgf("can't close %s: %v", remoteFile, closeErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't read %s: %v", remoteFile, err)
+		}
+		var t metadata.TableMetadata
+		if err = json.Unmarshal(body, &t); err != nil {
+			return nil, fmt.Errorf("can't parse %s: %v", remoteFile, err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// getRemoteBackupSharedPartsHashes - shall be called before delete remote backup, b.dst shall be connected,
+// read all table metadata only when content_addressed_parts enabled, to avoid slow delete for regular backups
+func (b *Backuper) getRemoteBackupSharedPartsHashes(ctx context.Context, backup storage.Backup) (common.EmptyMap, error) {
+	if !b.cfg.General.ContentAddressedParts || backup.Broken != "" {
+		return common.EmptyMap{}, nil
+	}
+	tables, err := b.readRemoteBackupTables(ctx, backup)
+	if err != nil {
+		return nil, err
+	}
+	return getSharedPartsHashes(tables), nil
+}
+
+// cleanSharedParts - reference counted GC, delete content addressed parts from hashes which are not referenced by remaining remote backups
+func (b *Backuper) cleanSharedParts(ctx context.Context, hashes common.EmptyMap) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	backupList, err := b.dst.BackupList(ctx, true, "")
+	if err != nil {
+		return err
+	}
+	refCount := make(map[string]int, len(hashes))
+	for hash := range hashes {
+		refCount[hash] = 0
+	}
+	for _, backup := range backupList {
+		// broken backup could be upload in progress, which already skip upload for existing shared parts
+		if backup.Broken != "" {
+			log.Warn().Msgf("remote backup %s is %s, skip delete %d shared parts, use `clean_remote_broken` command", backup.BackupName, backup.Broken, len(hashes))
+			return nil
+		}
+		tables, err := b.readRemoteBackupTables(ctx, backup)
+		if err != nil {
+			return err
+		}
+		countSharedPartsReferences(refCount, tables)
+	}
+	unreferencedHashes := make([]string, 0, len(refCount))
+	for hash, count := range refCount {
+		if count == 0 {
+			unreferencedHashes = append(unreferencedHashes, hash)
+		}
+	}
+	if len(unreferencedHashes) == 0 {
+		return nil
+	}
+	// upload which started after first BackupList could skip existing shared part, recheck right before delete
+	currentBackupList, err := b.dst.BackupList(ctx, false, "")
+	if err != nil {
+		return err
+	}
+	if newBackups := getNewBackupNames(backupList, currentBackupList); len(newBackups) > 0 {
+		log.Warn().Msgf("remote backups %v appeared during shared parts reference counting, skip delete %d shared parts", newBackups, len(unreferencedHashes))
+		return nil
+	}
+	deletedParts := 0
+	for _, hash := range unreferencedHashes {
+		if err = b.dst.RemoveRemotePath(ctx, path.Join(storage.SharedPartsDir, hash), b.cfg); err != nil {
+			return fmt.Errorf("can't delete shared part %s: %v", hash, err)
+		}
+		deletedParts += 1
+	}
+	log.Info().Fields(map[string]interface{}{
+		"operation":  "cleanSharedParts",
+		"candidates": len(hashes),
+		"deleted":    deletedParts,
+	}).Msg("done")
+	return nil
+}
+
+// getNewBackupNames - names from currentBackupList which are absent in knownBackupList
+func getNewBackupNames(knownBackupList, currentBackupList []storage.Backup) []string {
+	known := make(common.EmptyMap, len(knownBackupList))
+	for _, backup := range knownBackupList {
+		known[backup.BackupName] = struct{}{}
+	}
+	var newBackups []string
+	for _, backup := range currentBackupList {
+		if _, isKnown := known[backup.BackupName]; !isKnown {
+			newBackups = append(newBackups, backup.BackupName)
+		}
+	}
+	return newBackups
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+func TestCalculatePartHash(t *testing.T) {
+	dir := t.TempDir()
+	writePart := func(name, checksums string) string {
+		partPath := path.Join(dir, name)
+		require.NoError(t, os.MkdirAll(path.Join(partPath, "proj.proj"), 0750))
+		require.NoError(t, os.WriteFile(path.Join(partPath, "checksums.txt"), []byte(checksums), 0640))
+		require.NoError(t, os.WriteFile(path.Join(partPath, "data.bin"), []byte("data"), 0640))
+		require.NoError(t, os.WriteFile(path.Join(partPath, "proj.proj", "data.bin"), []byte("projection"), 0640))
+		return partPath
+	}
+	renamedPart := writePart("all_1_1_0", "checksums")
+	mutatedPart := writePart("all_1_1_0_2", "checksums")
+	otherPart := writePart("all_2_2_0", "other checksums")
+
+	renamedHash, err := calculatePartHash(renamedPart)
+	require.NoError(t, err)
+	mutatedHash, err := calculatePartHash(mutatedPart)
+	require.NoError(t, err)
+	otherHash, err := calculatePartHash(otherPart)
+	require.NoError(t, err)
+	assert.Len(t, renamedHash, 64)
+	assert.Equal(t, renamedHash, mutatedHash)
+	assert.NotEqual(t, renamedHash, otherHash)
+
+	require.NoError(t, os.WriteFile(path.Join(mutatedPart, "metadata_version.txt"), []byte("1"), 0640))
+	mutatedHash, err = calculatePartHash(mutatedPart)
+	require.NoError(t, err)
+	assert.NotEqual(t, renamedHash, mutatedHash)
+
+	require.NoError(t, os.MkdirAll(path.Join(dir, "all"), 0750))
+	emptyHash, err := calculatePartHash(path.Join(dir, "all"))
+	require.NoError(t, err)
+	assert.Empty(t, emptyHash)
+
+	files, err := getSharedPartFiles(renamedPart)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/data.bin", "/proj.proj/data.bin", "/checksums.txt"}, files)
+}
+
+func TestGetSharedPartRemotePath(t *testing.T) {
+	assert.Equal(t, ".shared_parts/abc/data", getSharedPartRemotePath("abc", DirectoryFormat))
+	assert.Equal(t, ".shared_parts/abc/data", getSharedPartRemotePath("abc", "none"))
+	assert.Equal(t, ".shared_parts/abc/data.tar.gz", getSharedPartRemotePath("abc", "gzip"))
+}
+
+func TestCountSharedPartsReferences(t *testing.T) {
+	deleted := []metadata.TableMetadata{
+		{Database: "db", Table: "t1", Parts: map[string][]metadata.Part{"default": {{Name: "all_1_1_0", Hash: "a"}, {Name: "all_2_2_0", Hash: "b"}, {Name: "all_3_3_0"}}}},
+		{Database: "db", Table: "t2", Parts: map[string][]metadata.Part{"hdd": {{Name: "all_1_1_0", Hash: "c"}}}},
+	}
+	hashes := getSharedPartsHashes(deleted)
+	assert.Len(t, hashes, 3)
+
+	refCount := map[string]int{}
+	for hash := range hashes {
+		refCount[hash] = 0
+	}
+	remaining := []metadata.TableMetadata{
+		{Database: "db", Table: "t1", Parts: map[string][]metadata.Part{"default": {{Name: "all_1_1_0_5", Hash: "a"}, {Name: "all_4_4_0", Hash: "d"}}}},
+		{Database: "db2", Table: "t1", Parts: map[string][]metadata.Part{"default": {{Name: "all_1_1_0", Hash: "a"}, {Name: "all_5_5_0"}}}},
+	}
+	countSharedPartsReferences(refCount, remaining)
+	assert.Equal(t, map[string]int{"a": 2, "b": 0, "c": 0}, refCount)
+}
+
+func TestGetNewBackupNames(t *testing.T) {
+	known := []storage.Backup{{BackupMetadata: metadata.BackupMetadata{BackupName: "b1"}}, {BackupMetadata: metadata.BackupMetadata{BackupName: "b2"}}}
+	assert.Empty(t, getNewBackupNames(known, known[:1]))
+	current := append([]storage.Backup{{BackupMetadata: metadata.BackupMetadata{BackupName: "b3"}}}, known...)
+	assert.Equal(t, []string{"b3"}, getNewBackupNames(known, current))
+}
@@ -0,0 +1,219 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// BackupContents - decoded metadata.json with all table metadata, result of `clickhouse-backup show`
+type BackupContents struct {
+	metadata.BackupMetadata
+	Location      string                   `json:"location"`
+	TableMetadata []metadata.TableMetadata `json:"table_metadata"`
+	FullSize      uint64                   `json:"full_size"`
+}
+
+var tableEngineRE = regexp.MustCompile(`ENGINE = (\w+)`)
+
+// getTableEngine - engine name from CREATE query, views and dictionaries don't contain ENGINE clause
+func getTableEngine(query string) string {
+	if matches := tableEngineRE.FindStringSubmatch(query); len(matches) > 1 {
+		return matches[1]
+	}
+	for _, prefix := range []string{"CREATE DICTIONARY", "CREATE MATERIALIZED VIEW", "ATTACH MATERIALIZED VIEW", "CREATE LIVE VIEW", "CREATE WINDOW VIEW", "CREATE VIEW"} {
+		if strings.HasPrefix(query, prefix) {
+			return strings.TrimPrefix(strings.TrimPrefix(prefix, "CREATE "), "ATTACH ")
+		}
+	}
+	return ""
+}
+
+// Show - print full contents of local or remote backup, metadata.json and each table metadata
+func (b *Backuper) Show(backupName string, remote bool, tablePattern, format string, showParts bool, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("backup name is required")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	location := "local"
+	if remote {
+		location = "remote"
+		if err = b.connectRemoteForRead(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := b.dst.Close(ctx); closeErr != nil {
+				log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+			}
+		}()
+	}
+	backupMetadata, tables, err := b.readBackupContents(ctx, backupName, remote, tablePattern)
+	if err != nil {
+		return err
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].Database != tables[j].Database {
+			return tables[i].Database < tables[j].Database
+		}
+		return tables[i].Table < tables[j].Table
+	})
+	contents := BackupContents{
+		BackupMetadata: *backupMetadata,
+		Location:       location,
+		TableMetadata:  tables,
+		FullSize:       backupMetadata.GetFullSize(),
+	}
+	return printBackupContents(os.Stdout, contents, format, showParts)
+}
+
+func printBackupContents(out io.Writer, contents BackupContents, format string, showParts bool) error {
+	if format == "json" {
+		body, err := json.MarshalIndent(contents, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(body))
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	printRow := func(format string, args ...interface{}) {
+		if bytes, err := fmt.Fprintf(w, format, args...); err != nil {
+			log.Error().Msgf("fmt.Fprintf write %d bytes return error: %v", bytes, err)
+		}
+	}
+	printRow("backup_name:\t%s\n", contents.BackupName)
+	printRow("location:\t%s\n", contents.Location)
+	printRow("creation_date:\t%s\n", contents.CreationDate.Format(common.TimeFormat))
+	printRow("version:\t%s\n", contents.ClickhouseBackupVersion)
+	if contents.ClickHouseVersion != "" {
+		printRow("clickhouse_version:\t%s\n", contents.ClickHouseVersion)
+	}
+	if contents.Tags != "" {
+		printRow("tags:\t%s\n", contents.Tags)
+	}
+	if contents.DataFormat != "" {
+		printRow("data_format:\t%s\n", contents.DataFormat)
+	}
+	if contents.RequiredBackup != "" {
+		printRow("required_backup:\t%s\n", contents.RequiredBackup)
+	}
+	if contents.SnapshotTime != nil {
+		printRow("snapshot_time:\t%s\n", contents.SnapshotTime.Format(common.TimeFormat))
+		printRow("freeze_window:\t%s\n", contents.FreezeWindow)
+	}
+	if contents.Since != "" {
+		printRow("since:\t%s\n", contents.Since)
+	}
+	if contents.Watermark != nil {
+		printRow("watermark:\t%s\n", contents.Watermark.Time.Format(common.TimeFormat))
+	}
+	diskNames := make([]string, 0, len(contents.Disks))
+	for disk := range contents.Disks {
+		diskNames = append(diskNames, disk)
+	}
+	sort.Strings(diskNames)
+	for _, disk := range diskNames {
+		printRow("disk:\t%s\t%s\t%s\n", disk, contents.Disks[disk], contents.DiskTypes[disk])
+	}
+	printRow("full_size:\t%s\n", utils.FormatBytes(contents.FullSize))
+	printRow("data_size:\t%s\n", utils.FormatBytes(contents.DataSize))
+	printRow("object_disk_size:\t%s\n", utils.FormatBytes(contents.ObjectDiskSize))
+	printRow("metadata_size:\t%s\n", utils.FormatBytes(contents.MetadataSize))
+	printRow("rbac_size:\t%s\n", utils.FormatBytes(contents.RBACSize))
+	printRow("config_size:\t%s\n", utils.FormatBytes(contents.ConfigSize))
+	printRow("keeper_size:\t%s\n", utils.FormatBytes(contents.KeeperSize))
+	printRow("compressed_size:\t%s\n", utils.FormatBytes(contents.CompressedSize))
+	for _, db := range contents.Databases {
+		printRow("database:\t%s\t%s\n", db.Name, db.Engine)
+	}
+	for _, f := range contents.Functions {
+		printRow("function:\t%s\n", f.Name)
+	}
+	for _, nc := range contents.NamedCollections {
+		printRow("named_collection:\t%s\n", nc.Name)
+	}
+	for _, w := range contents.Workloads {
+		printRow("%s:\t%s\n", strings.ToLower(w.Kind), w.Name)
+	}
+	for _, t := range contents.TableMetadata {
+		printRow("\ntable:\t%s.%s\n", t.Database, t.Table)
+		if engine := getTableEngine(t.Query); engine != "" {
+			printRow("  engine:\t%s\n", engine)
+		}
+		printRow("  query:\t%s\n", t.Query)
+		printRow("  total_bytes:\t%s\n", utils.FormatBytes(t.TotalBytes))
+		if t.DataFormat != "" {
+			printRow("  data_format:\t%s\n", t.DataFormat)
+		}
+		if t.MetadataOnly {
+			printRow("  metadata_only:\t%v\n", t.MetadataOnly)
+		}
+		if t.DependenciesTable != "" {
+			printRow("  dependencies:\t%s.%s\n", t.DependenciesDatabase, t.DependenciesTable)
+		}
+		if t.ConsumerState != nil {
+			if len(t.ConsumerState.KafkaOffsets) > 0 {
+				printRow("  kafka_offsets:\t%s %d partitions\n", t.ConsumerState.KafkaGroup, len(t.ConsumerState.KafkaOffsets))
+			}
+			if t.ConsumerState.KeeperPath != "" {
+				printRow("  queue_state:\t%s %d nodes\n", t.ConsumerState.KeeperPath, len(t.ConsumerState.KeeperNodes))
+			}
+		}
+		tableDisks := make([]string, 0, len(t.Parts))
+		for disk := range t.Parts {
+			tableDisks = append(tableDisks, disk)
+		}
+		sort.Strings(tableDisks)
+		for _, disk := range tableDisks {
+			requiredParts := 0
+			for _, part := range t.Parts[disk] {
+				if part.Required {
+					requiredParts++
+				}
+			}
+			printRow("  disk:\t%s\t%d parts\t%s", disk, len(t.Parts[disk]), utils.FormatBytes(uint64(t.Size[disk])))
+			if requiredParts > 0 {
+				printRow("\t%d parts required from %s", requiredParts, contents.RequiredBackup)
+			}
+			printRow("\n")
+			if showParts {
+				for _, part := range t.Parts[disk] {
+					if part.Required {
+						printRow("    part:\t%s\trequired from %s\n", part.Name, contents.RequiredBackup)
+					} else if part.Hash != "" {
+						printRow("    part:\t%s\tshared %s\n", part.Name, part.Hash)
+					} else {
+						printRow("    part:\t%s\n", part.Name)
+					}
+				}
+			}
+		}
+		for _, m := range t.Mutations {
+			printRow("  mutation:\t%s\t%s\n", m.MutationId, m.Command)
+		}
+	}
+	return w.Flush()
+}
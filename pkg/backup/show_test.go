@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestGetTableEngine(t *testing.T) {
+	assert.Equal(t, "ReplicatedMergeTree", getTableEngine("CREATE TABLE db.t (id UInt64) ENGINE = ReplicatedMergeTree('/clickhouse/{shard}/db/t', '{replica}') ORDER BY id"))
+	assert.Equal(t, "MATERIALIZED VIEW", getTableEngine("CREATE MATERIALIZED VIEW db.mv TO db.t AS SELECT id FROM db.src"))
+	assert.Equal(t, "DICTIONARY", getTableEngine("CREATE DICTIONARY db.dict (id UInt64) PRIMARY KEY id SOURCE(NULL()) LAYOUT(FLAT()) LIFETIME(0)"))
+	assert.Equal(t, "", getTableEngine("CREATE FUNCTION f AS x -> x"))
+}
+
+func TestPrintBackupContents(t *testing.T) {
+	contents := BackupContents{
+		BackupMetadata: metadata.BackupMetadata{
+			BackupName:     "increment",
+			Disks:          map[string]string{"default": "/var/lib/clickhouse"},
+			DiskTypes:      map[string]string{"default": "local"},
+			DataSize:       2048,
+			RBACSize:       10,
+			ConfigSize:     20,
+			RequiredBackup: "full",
+		},
+		Location: "remote",
+		TableMetadata: []metadata.TableMetadata{
+			{
+				Database: "db",
+				Table:    "events",
+				Query:    "CREATE TABLE db.events (id UInt64) ENGINE = MergeTree ORDER BY id",
+				Parts: map[string][]metadata.Part{
+					"default": {{Name: "all_1_1_0", Required: true}, {Name: "all_2_2_0"}},
+				},
+				Size:      map[string]int64{"default": 2048},
+				Mutations: []metadata.MutationMetadata{{MutationId: "0000000001", Command: "DELETE WHERE id=1"}},
+			},
+			{Database: "db", Table: "dict", Query: "CREATE DICTIONARY db.dict (id UInt64) PRIMARY KEY id SOURCE(CLICKHOUSE(TABLE 'events')) LAYOUT(FLAT()) LIFETIME(0)", DependenciesDatabase: "db", DependenciesTable: "events"},
+		},
+	}
+	out := &bytes.Buffer{}
+	assert.NoError(t, printBackupContents(out, contents, "text", true))
+	assert.Contains(t, out.String(), "required_backup:")
+	assert.Contains(t, out.String(), "MergeTree")
+	assert.Contains(t, out.String(), "1 parts required from full")
+	assert.Contains(t, out.String(), "all_1_1_0")
+	assert.Contains(t, out.String(), "0000000001")
+	assert.Contains(t, out.String(), "db.events")
+
+	out.Reset()
+	assert.NoError(t, printBackupContents(out, contents, "json", false))
+	assert.Contains(t, out.String(), `"required_backup": "full"`)
+	assert.Contains(t, out.String(), `"table_metadata": [`)
+}
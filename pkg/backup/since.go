@@ -0,0 +1,305 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+var sinceTimeFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// sinceWatermark - watermark which `create --since` compares with active parts, baseBackup is empty when --since is timestamp
+type sinceWatermark struct {
+	baseBackup string
+	// base backup exists in local backups, required parts will hardlink from it
+	isBaseLocal bool
+	watermark   metadata.Watermark
+	baseTables  map[metadata.TableTitle]metadata.TableMetadata
+}
+
+// sinceTableParts - parts selected by `create --since` for one table
+type sinceTableParts struct {
+	// partitions which contain selected parts, other partitions will not freeze
+	partitions []string
+	// selected parts, other parts from frozen partitions will skip
+	parts common.EmptyMap
+	// all active parts from system.parts during selection, frozen parts which absent here were merged or inserted after selection
+	knownParts common.EmptyMap
+	// parts which already exist in base backup, will add with required flag
+	requiredParts map[string][]metadata.Part
+	// local base backup name, empty when base backup exists only on remote storage
+	localBaseBackup string
+}
+
+// parseSinceTime - timestamp without time zone is local time
+func parseSinceTime(since string) (time.Time, bool) {
+	for _, format := range sinceTimeFormats {
+		if sinceTime, err := time.ParseInLocation(format, since, time.Local); err == nil {
+			return sinceTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// getBackupWatermark - shall be captured before freeze, parts created during freeze will select again by next `create --since`
+func (b *Backuper) getBackupWatermark(ctx context.Context, tables []clickhouse.Table) (*metadata.Watermark, error) {
+	watermark := &metadata.Watermark{Time: time.Now().UTC(), Tables: make([]metadata.TableWatermark, 0)}
+	maxBlocks, err := b.ch.GetPartitionsMaxBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tableIndexes := map[metadata.TableTitle]int{}
+	for _, table := range getFreezableTables(tables) {
+		tableIndexes[metadata.TableTitle{Database: table.Database, Table: table.Name}] = -1
+	}
+	for _, maxBlock := range maxBlocks {
+		title := metadata.TableTitle{Database: maxBlock.Database, Table: maxBlock.Table}
+		idx, exists := tableIndexes[title]
+		if !exists {
+			continue
+		}
+		if idx < 0 {
+			idx = len(watermark.Tables)
+			tableIndexes[title] = idx
+			watermark.Tables = append(watermark.Tables, metadata.TableWatermark{Database: title.Database, Table: title.Table, MaxBlocks: map[string]int64{}})
+		}
+		watermark.Tables[idx].MaxBlocks[maxBlock.PartitionID] = maxBlock.MaxBlockNumber
+	}
+	return watermark, nil
+}
+
+// getSinceWatermark - --since could be local or remote backup name, or timestamp
+func (b *Backuper) getSinceWatermark(ctx context.Context, since, tablePattern string) (*sinceWatermark, error) {
+	if sinceTime, isTime := parseSinceTime(since); isTime {
+		return &sinceWatermark{watermark: metadata.Watermark{Time: sinceTime}}, nil
+	}
+	var baseMetadata *metadata.BackupMetadata
+	var baseTables map[metadata.TableTitle]metadata.TableMetadata
+	localMetadata, err := b.ReadBackupMetadataLocal(ctx, since)
+	isBaseLocal := err == nil
+	if isBaseLocal {
+		baseMetadata = localMetadata
+		if baseTables, err = b.getTablesDiffFromLocal(ctx, since, tablePattern); err != nil {
+			return nil, err
+		}
+	} else if b.cfg.General.RemoteStorage != "none" && b.cfg.General.RemoteStorage != "custom" {
+		if baseMetadata, baseTables, err = b.getSinceBackupRemote(ctx, since, tablePattern); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("--since=%s is not timestamp and local backup not found: %v", since, err)
+	}
+	if strings.Contains(baseMetadata.Tags, "embedded") {
+		return nil, fmt.Errorf("--since=%s is embedded backup, which doesn't contain parts list", since)
+	}
+	watermark := metadata.Watermark{Time: baseMetadata.CreationDate}
+	if baseMetadata.Watermark != nil {
+		watermark = *baseMetadata.Watermark
+	} else {
+		log.Warn().Msgf("%s doesn't contain watermark, will use creation_date %s and parts from backup", since, baseMetadata.CreationDate.Format(common.TimeFormat))
+	}
+	return &sinceWatermark{baseBackup: since, isBaseLocal: isBaseLocal, watermark: watermark, baseTables: baseTables}, nil
+}
+
+func (b *Backuper) getSinceBackupRemote(ctx context.Context, since, tablePattern string) (*metadata.BackupMetadata, map[metadata.TableTitle]metadata.TableMetadata, error) {
+	dst, err := storage.NewBackupDestination(ctx, b.cfg, b.ch, since)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = dst.Connect(ctx); err != nil {
+		return nil, nil, fmt.Errorf("can't connect to %s: %v", dst.Kind(), err)
+	}
+	defer func() {
+		if closeErr := dst.Close(ctx); closeErr != nil {
+			log.Warn().Msgf("can't close connection to %s: %v", dst.Kind(), closeErr)
+		}
+	}()
+	prevDst := b.dst
+	b.dst = dst
+	defer func() {
+		b.dst = prevDst
+	}()
+	backupList, err := b.dst.BackupList(ctx, true, since)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, backup := range backupList {
+		if backup.BackupName == since {
+			baseTables, err := b.getTablesDiffFromRemote(ctx, since, tablePattern)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &backup.BackupMetadata, baseTables, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("--since=%s is not timestamp and not found in local and remote backups", since)
+}
+
+// getSinceTablesParts - select parts for all tables before freeze, consistent snapshot shall know which partitions freeze,
+// merges between selection and freeze are handled by selectShadowParts
+func (b *Backuper) getSinceTablesParts(ctx context.Context, since *sinceWatermark, tables []clickhouse.Table, partitionsIdMap map[metadata.TableTitle]common.EmptyMap) (map[metadata.TableTitle]*sinceTableParts, error) {
+	sinceTablesParts := map[metadata.TableTitle]*sinceTableParts{}
+	for _, table := range getFreezableTables(tables) {
+		activeParts, err := b.ch.GetActiveParts(ctx, &table)
+		if err != nil {
+			return nil, err
+		}
+		title := metadata.TableTitle{Database: table.Database, Table: table.Name}
+		tableParts := getSinceTableParts(activeParts, since, title, partitionsIdMap[title])
+		requiredCount := 0
+		for _, requiredParts := range tableParts.requiredParts {
+			requiredCount += len(requiredParts)
+		}
+		log.Info().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Int("active", len(activeParts)).Int("selected", len(tableParts.parts)).Int("required", requiredCount).Int("partitions", len(tableParts.partitions)).Msg("since watermark")
+		sinceTablesParts[title] = tableParts
+	}
+	return sinceTablesParts, nil
+}
+
+// getSinceTableParts - select parts which modification_time or max_block_number exceed watermark,
+// when --since is backup name, parts from base backup mark as required and parts which absent in base backup are selected even when they don't exceed watermark
+func getSinceTableParts(activeParts []clickhouse.ActivePart, since *sinceWatermark, title metadata.TableTitle, partitionsIdsMap common.EmptyMap) *sinceTableParts {
+	tableParts := &sinceTableParts{
+		partitions:    make([]string, 0),
+		parts:         common.EmptyMap{},
+		knownParts:    common.EmptyMap{},
+		requiredParts: map[string][]metadata.Part{},
+	}
+	if since.isBaseLocal {
+		tableParts.localBaseBackup = since.baseBackup
+	}
+	var maxBlocks map[string]int64
+	for _, tableWatermark := range since.watermark.Tables {
+		if tableWatermark.Database == title.Database && tableWatermark.Table == title.Table {
+			maxBlocks = tableWatermark.MaxBlocks
+			break
+		}
+	}
+	baseTable, isBaseTableExists := since.baseTables[title]
+	// modification_time has seconds precision
+	watermarkTime := since.watermark.Time.Truncate(time.Second)
+	selectedPartitions := common.EmptyMap{}
+	for _, part := range activeParts {
+		if len(partitionsIdsMap) != 0 && !filesystemhelper.IsPartInPartition(part.Name, partitionsIdsMap) {
+			continue
+		}
+		tableParts.knownParts[part.Name] = struct{}{}
+		isSelected := !part.ModificationTime.Before(watermarkTime)
+		if !isSelected && maxBlocks != nil {
+			maxBlock, isPartitionExists := maxBlocks[part.PartitionID]
+			isSelected = !isPartitionExists || part.MaxBlockNumber > maxBlock
+		}
+		if since.baseBackup != "" {
+			if isBaseTableExists && isPartExistsInBackup(baseTable, part) {
+				tableParts.requiredParts[part.DiskName] = append(tableParts.requiredParts[part.DiskName], metadata.Part{Name: part.Name, Required: true})
+				continue
+			}
+			isSelected = true
+		}
+		if !isSelected {
+			continue
+		}
+		tableParts.parts[part.Name] = struct{}{}
+		if _, exists := selectedPartitions[part.PartitionID]; !exists {
+			selectedPartitions[part.PartitionID] = struct{}{}
+			tableParts.partitions = append(tableParts.partitions, part.PartitionID)
+		}
+	}
+	return tableParts
+}
+
+// selectShadowParts - parts selected from system.parts before FREEZE could be merged before FREEZE happens,
+// so frozen parts which were unknown during selection are selected too, and required parts of frozen partitions which absent in shadow are dropped,
+// their data is inside selected merged part, returns parts for MoveShadowToBackup and required parts for each disk
+func (tableParts *sinceTableParts) selectShadowParts(shadowParts []string) (common.EmptyMap, map[string][]metadata.Part) {
+	selectedParts := common.EmptyMap{}
+	frozenParts := common.EmptyMap{}
+	frozenPartitions := common.EmptyMap{}
+	for _, partName := range shadowParts {
+		frozenParts[partName] = struct{}{}
+		frozenPartitions[strings.Split(partName, "_")[0]] = struct{}{}
+		_, isSelected := tableParts.parts[partName]
+		_, isKnown := tableParts.knownParts[partName]
+		if isSelected || !isKnown {
+			selectedParts[partName] = struct{}{}
+		}
+	}
+	requiredParts := make(map[string][]metadata.Part, len(tableParts.requiredParts))
+	for diskName, parts := range tableParts.requiredParts {
+		for _, part := range parts {
+			_, isFrozenPartition := frozenPartitions[strings.Split(part.Name, "_")[0]]
+			_, isFrozen := frozenParts[part.Name]
+			if isFrozenPartition && !isFrozen {
+				continue
+			}
+			requiredParts[diskName] = append(requiredParts[diskName], part)
+		}
+	}
+	return selectedParts, requiredParts
+}
+
+// getShadowParts - frozen parts of table from all disks
+func getShadowParts(diskList []clickhouse.Disk, shadowBackupUUID string) ([]string, error) {
+	shadowParts := make([]string, 0)
+	for _, disk := range diskList {
+		shadowPath := path.Join(disk.Path, "shadow", shadowBackupUUID)
+		if _, err := os.Stat(shadowPath); err != nil && os.IsNotExist(err) {
+			continue
+		}
+		parts, err := filesystemhelper.GetShadowParts(shadowPath)
+		if err != nil {
+			return nil, err
+		}
+		shadowParts = append(shadowParts, parts...)
+	}
+	return shadowParts, nil
+}
+
+// hardlinkRequiredParts - required parts of backup created with --since=<local_backup> hardlink from base backup,
+// so local restore doesn't depend on base backup, and upload still skips them cause they are marked as required
+func (b *Backuper) hardlinkRequiredParts(backupName, baseBackupName string, table *clickhouse.Table, diskList []clickhouse.Disk, requiredParts map[string][]metadata.Part) error {
+	dbAndTablePath := path.Join(common.TablePathEncode(table.Database), common.TablePathEncode(table.Name))
+	for diskName, parts := range requiredParts {
+		diskPath := ""
+		for _, disk := range diskList {
+			if disk.Name == diskName {
+				diskPath = disk.Path
+				break
+			}
+		}
+		if diskPath == "" {
+			return fmt.Errorf("required parts of `%s`.`%s` placed on unknown disk %s", table.Database, table.Name, diskName)
+		}
+		for _, part := range parts {
+			existsPath := path.Join(diskPath, "backup", baseBackupName, "shadow", dbAndTablePath, diskName, part.Name)
+			if _, err := os.Stat(existsPath); err != nil {
+				return fmt.Errorf("required part %s is absent in local base backup %s: %v", existsPath, baseBackupName, err)
+			}
+			newPath := path.Join(diskPath, "backup", backupName, "shadow", dbAndTablePath, diskName, part.Name)
+			if err := b.makePartHardlinks(existsPath, newPath); err != nil {
+				return fmt.Errorf("can't hardlink required part %s -> %s: %v", existsPath, newPath, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isPartExistsInBackup(table metadata.TableMetadata, part clickhouse.ActivePart) bool {
+	for _, backupPart := range table.Parts[part.DiskName] {
+		if backupPart.Name == part.Name {
+			return true
+		}
+	}
+	return false
+}
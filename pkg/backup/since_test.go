@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+func TestParseSinceTime(t *testing.T) {
+	r := require.New(t)
+	sinceTime, isTime := parseSinceTime("2024-05-01T10:00:00Z")
+	r.True(isTime)
+	r.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).Unix(), sinceTime.Unix())
+
+	sinceTime, isTime = parseSinceTime("2024-05-01 10:00:00")
+	r.True(isTime)
+	r.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local).Unix(), sinceTime.Unix())
+
+	_, isTime = parseSinceTime("2024-05-01")
+	r.True(isTime)
+
+	_, isTime = parseSinceTime("shard0-full-20240501100000")
+	r.False(isTime)
+}
+
+func sinceSelectedParts(tableParts *sinceTableParts) []string {
+	parts := make([]string, 0, len(tableParts.parts))
+	for part := range tableParts.parts {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return parts
+}
+
+func TestGetSinceTablePartsByTimestamp(t *testing.T) {
+	r := require.New(t)
+	watermarkTime := time.Date(2024, 5, 1, 10, 0, 0, 500, time.UTC)
+	title := metadata.TableTitle{Database: "db", Table: "events"}
+	activeParts := []clickhouse.ActivePart{
+		{Name: "202404_1_10_2", PartitionID: "202404", DiskName: "default", ModificationTime: watermarkTime.Add(-time.Hour), MaxBlockNumber: 10},
+		{Name: "202405_11_20_1", PartitionID: "202405", DiskName: "default", ModificationTime: watermarkTime.Add(-time.Minute), MaxBlockNumber: 20},
+		// the same second as watermark, modification_time has seconds precision
+		{Name: "202405_21_21_0", PartitionID: "202405", DiskName: "default", ModificationTime: watermarkTime.Truncate(time.Second), MaxBlockNumber: 21},
+		{Name: "202405_22_22_0", PartitionID: "202405", DiskName: "hot", ModificationTime: watermarkTime.Add(time.Minute), MaxBlockNumber: 22},
+	}
+	since := &sinceWatermark{watermark: metadata.Watermark{Time: watermarkTime}}
+	tableParts := getSinceTableParts(activeParts, since, title, nil)
+	r.Equal([]string{"202405_21_21_0", "202405_22_22_0"}, sinceSelectedParts(tableParts))
+	r.Equal([]string{"202405"}, tableParts.partitions)
+	r.Empty(tableParts.requiredParts)
+
+	// max block exceeds watermark of partition, or partition is new
+	since.watermark.Tables = []metadata.TableWatermark{{Database: "db", Table: "events", MaxBlocks: map[string]int64{"202404": 10, "202405": 15}}}
+	tableParts = getSinceTableParts(activeParts, since, title, nil)
+	r.Equal([]string{"202405_11_20_1", "202405_21_21_0", "202405_22_22_0"}, sinceSelectedParts(tableParts))
+	since.watermark.Tables[0].MaxBlocks = map[string]int64{"202405": 20}
+	tableParts = getSinceTableParts(activeParts, since, title, nil)
+	r.Equal([]string{"202404_1_10_2", "202405_21_21_0", "202405_22_22_0"}, sinceSelectedParts(tableParts))
+	r.Equal([]string{"202404", "202405"}, tableParts.partitions)
+
+	// --partitions filter
+	tableParts = getSinceTableParts(activeParts, since, title, common.EmptyMap{"202405": {}})
+	r.Equal([]string{"202405_21_21_0", "202405_22_22_0"}, sinceSelectedParts(tableParts))
+}
+
+func TestGetSinceTablePartsByBackup(t *testing.T) {
+	r := require.New(t)
+	watermarkTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	title := metadata.TableTitle{Database: "db", Table: "events"}
+	activeParts := []clickhouse.ActivePart{
+		{Name: "202404_1_10_2", PartitionID: "202404", DiskName: "default", ModificationTime: watermarkTime.Add(-time.Hour), MaxBlockNumber: 10},
+		// merged after base backup
+		{Name: "202405_11_21_1", PartitionID: "202405", DiskName: "default", ModificationTime: watermarkTime.Add(time.Minute), MaxBlockNumber: 21},
+		// doesn't exceed watermark, but absent in base backup
+		{Name: "202403_1_1_0", PartitionID: "202403", DiskName: "default", ModificationTime: watermarkTime.Add(-time.Hour), MaxBlockNumber: 1},
+		// moved to another disk after base backup
+		{Name: "202402_1_5_1", PartitionID: "202402", DiskName: "hot", ModificationTime: watermarkTime.Add(-time.Hour), MaxBlockNumber: 5},
+	}
+	since := &sinceWatermark{
+		baseBackup: "base",
+		watermark: metadata.Watermark{Time: watermarkTime, Tables: []metadata.TableWatermark{
+			{Database: "db", Table: "events", MaxBlocks: map[string]int64{"202402": 5, "202403": 1, "202404": 10, "202405": 20}},
+		}},
+		baseTables: map[metadata.TableTitle]metadata.TableMetadata{
+			title: {Database: "db", Table: "events", Parts: map[string][]metadata.Part{
+				"default": {{Name: "202402_1_5_1"}, {Name: "202404_1_10_2"}, {Name: "202405_11_20_1"}},
+			}},
+		},
+	}
+	tableParts := getSinceTableParts(activeParts, since, title, nil)
+	r.Equal([]string{"202402_1_5_1", "202403_1_1_0", "202405_11_21_1"}, sinceSelectedParts(tableParts))
+	r.Equal([]string{"202405", "202403", "202402"}, tableParts.partitions)
+	r.Equal(map[string][]metadata.Part{"default": {{Name: "202404_1_10_2", Required: true}}}, tableParts.requiredParts)
+	r.Empty(tableParts.localBaseBackup)
+
+	since.isBaseLocal = true
+	tableParts = getSinceTableParts(activeParts, since, title, nil)
+	r.Equal("base", tableParts.localBaseBackup)
+
+	// table absent in base backup
+	tableParts = getSinceTableParts(activeParts, since, metadata.TableTitle{Database: "db", Table: "new_table"}, nil)
+	r.Len(tableParts.parts, len(activeParts))
+	r.Empty(tableParts.requiredParts)
+}
+
+func TestSelectShadowParts(t *testing.T) {
+	r := require.New(t)
+	tableParts := &sinceTableParts{
+		partitions: []string{"202405"},
+		parts:      common.EmptyMap{"202405_11_11_0": {}},
+		knownParts: common.EmptyMap{"202404_1_5_1": {}, "202405_1_10_1": {}, "202405_11_11_0": {}, "202405_12_12_0": {}},
+		requiredParts: map[string][]metadata.Part{
+			"default": {{Name: "202404_1_5_1", Required: true}, {Name: "202405_1_10_1", Required: true}},
+		},
+	}
+	// nothing merged between selection and freeze, known but not selected part skipped
+	selectedParts, requiredParts := tableParts.selectShadowParts([]string{"202405_1_10_1", "202405_11_11_0", "202405_12_12_0"})
+	r.Equal(common.EmptyMap{"202405_11_11_0": {}}, selectedParts)
+	r.Equal(tableParts.requiredParts, requiredParts)
+
+	// required and selected parts merged and new part inserted between selection and freeze, data shall not lose and shall not duplicate
+	selectedParts, requiredParts = tableParts.selectShadowParts([]string{"202405_1_11_2", "202405_12_12_0", "202405_13_13_0"})
+	r.Equal(common.EmptyMap{"202405_1_11_2": {}, "202405_13_13_0": {}}, selectedParts)
+	r.Equal(map[string][]metadata.Part{"default": {{Name: "202404_1_5_1", Required: true}}}, requiredParts)
+}
+
+func TestGetShadowParts(t *testing.T) {
+	r := require.New(t)
+	diskPath := t.TempDir()
+	shadowBackupUUID := "0123456789abcdef"
+	for _, dir := range []string{
+		"store/1f9/1f9dc899-0de9-41f8-b95c-26c1f0d67d93/202405_1_1_0/x.proj",
+		"store/1f9/1f9dc899-0de9-41f8-b95c-26c1f0d67d93/202405_2_2_0",
+		"data/db/events/202404_1_1_0",
+	} {
+		r.NoError(os.MkdirAll(path.Join(diskPath, "shadow", shadowBackupUUID, dir), 0750))
+	}
+	shadowParts, err := getShadowParts([]clickhouse.Disk{{Name: "default", Path: diskPath}, {Name: "absent", Path: path.Join(diskPath, "absent")}}, shadowBackupUUID)
+	r.NoError(err)
+	sort.Strings(shadowParts)
+	r.Equal([]string{"202404_1_1_0", "202405_1_1_0", "202405_2_2_0"}, shadowParts)
+}
+
+func TestHardlinkRequiredParts(t *testing.T) {
+	r := require.New(t)
+	diskPath := t.TempDir()
+	diskList := []clickhouse.Disk{{Name: "default", Path: diskPath}}
+	table := &clickhouse.Table{Database: "db", Name: "events"}
+	basePartPath := path.Join(diskPath, "backup", "base", "shadow", "db", "events", "default", "202404_1_10_2")
+	r.NoError(os.MkdirAll(basePartPath, 0750))
+	r.NoError(os.WriteFile(path.Join(basePartPath, "checksums.txt"), []byte("checksums"), 0640))
+
+	b := &Backuper{}
+	requiredParts := map[string][]metadata.Part{"default": {{Name: "202404_1_10_2", Required: true}}}
+	r.NoError(b.hardlinkRequiredParts("increment", "base", table, diskList, requiredParts))
+	baseInfo, err := os.Stat(path.Join(basePartPath, "checksums.txt"))
+	r.NoError(err)
+	newInfo, err := os.Stat(path.Join(diskPath, "backup", "increment", "shadow", "db", "events", "default", "202404_1_10_2", "checksums.txt"))
+	r.NoError(err)
+	r.True(os.SameFile(baseInfo, newInfo))
+
+	// base backup created with --since=<remote_backup> doesn't contain own required parts
+	requiredParts = map[string][]metadata.Part{"default": {{Name: "202403_1_1_0", Required: true}}}
+	r.ErrorContains(b.hardlinkRequiredParts("increment", "base", table, diskList, requiredParts), "absent in local base backup base")
+	requiredParts = map[string][]metadata.Part{"hot": {{Name: "202403_1_1_0", Required: true}}}
+	r.ErrorContains(b.hardlinkRequiredParts("increment", "base", table, diskList, requiredParts), "unknown disk hot")
+}
+
+func TestCheckRequiredBackupExistsRemote(t *testing.T) {
+	r := require.New(t)
+	remoteBackups := []storage.Backup{
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "full"}},
+		{BackupMetadata: metadata.BackupMetadata{BackupName: "broken"}, Broken: "broken (can't stat metadata.json)"},
+	}
+	r.NoError(checkRequiredBackupExistsRemote(&metadata.BackupMetadata{BackupName: "full"}, remoteBackups))
+	r.NoError(checkRequiredBackupExistsRemote(&metadata.BackupMetadata{BackupName: "increment", RequiredBackup: "full"}, remoteBackups))
+	r.ErrorContains(checkRequiredBackupExistsRemote(&metadata.BackupMetadata{BackupName: "increment", RequiredBackup: "local_only"}, remoteBackups), "upload local_only first")
+	r.Error(checkRequiredBackupExistsRemote(&metadata.BackupMetadata{BackupName: "increment", RequiredBackup: "broken"}, remoteBackups))
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+// abortStaleUploads - b.dst shall be connected, remote storage without multipart uploads or uncommitted blocks is skipped,
+// empty backupName means whole path and object_disk_path
+func (b *Backuper) abortStaleUploads(ctx context.Context, backupName string, olderThan time.Duration) (int, error) {
+	aborter, isSupported := b.dst.RemoteStorage.(storage.StaleUploadsAborter)
+	if !isSupported {
+		log.Debug().Msgf("remote_storage: %s doesn't keep incomplete uploads, skip abort stale uploads", b.cfg.General.RemoteStorage)
+		return 0, nil
+	}
+	return aborter.AbortStaleUploads(ctx, backupName, olderThan)
+}
+
+// CleanRemoteStaleUploads - abort S3 multipart uploads and discard Azure uncommitted blocks left after killed upload under whole path and object_disk_path,
+// empty olderThan means general->abort_stale_uploads_after
+func (b *Backuper) CleanRemoteStaleUploads(olderThan string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	olderThanDuration := b.cfg.General.AbortStaleUploadsDuration
+	if olderThan != "" {
+		if olderThanDuration, err = time.ParseDuration(olderThan); err != nil {
+			return fmt.Errorf("invalid --older-than: %v", err)
+		}
+	}
+	if olderThanDuration <= 0 {
+		return fmt.Errorf("--older-than or general->abort_stale_uploads_after is required, uploads which are still running could be aborted otherwise")
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	if err = b.connectRemoteForRead(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := b.dst.Close(ctx); closeErr != nil {
+			log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+		}
+	}()
+	aborted, err := b.abortStaleUploads(ctx, "", olderThanDuration)
+	if err != nil {
+		return err
+	}
+	log.Info().Fields(map[string]interface{}{
+		"operation":  "clean_remote_stale_uploads",
+		"older_than": olderThanDuration.String(),
+		"aborted":    aborted,
+		"duration":   utils.HumanizeDuration(time.Since(start)),
+	}).Msg("done")
+	return nil
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+)
+
+type staleUploadsStorage struct {
+	storage.RemoteStorage
+	backupName string
+	olderThan  time.Duration
+}
+
+func (s *staleUploadsStorage) AbortStaleUploads(ctx context.Context, backupName string, olderThan time.Duration) (int, error) {
+	s.backupName = backupName
+	s.olderThan = olderThan
+	return 2, nil
+}
+
+type noStaleUploadsStorage struct {
+	storage.RemoteStorage
+}
+
+func TestAbortStaleUploads(t *testing.T) {
+	remote := &staleUploadsStorage{}
+	b := &Backuper{cfg: config.DefaultConfig(), dst: &storage.BackupDestination{RemoteStorage: remote}}
+	aborted, err := b.abortStaleUploads(context.Background(), "test_backup", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, aborted)
+	assert.Equal(t, "test_backup", remote.backupName)
+	assert.Equal(t, time.Hour, remote.olderThan)
+
+	b.dst = &storage.BackupDestination{RemoteStorage: &noStaleUploadsStorage{}}
+	aborted, err = b.abortStaleUploads(context.Background(), "", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, aborted)
+	assert.Equal(t, time.Duration(0), config.DefaultConfig().General.AbortStaleUploadsDuration)
+}
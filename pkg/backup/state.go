@@ -0,0 +1,270 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/storage"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
+)
+
+var resumableStateCommands = []string{"upload", "download", "restore"}
+
+// ResumableStateKey - already processed remote file, local file, table or part, Size is zero when it is not applicable
+type ResumableStateKey struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// ResumableStateInfo - contents of <backup_name>/<command>.state2 or <backup_name>/upload.<storage>.state2, result of `clickhouse-backup state show`
+type ResumableStateInfo struct {
+	Command   string                 `json:"command"`
+	Storage   string                 `json:"storage,omitempty"`
+	StateFile string                 `json:"state_file"`
+	Params    map[string]interface{} `json:"params"`
+	Keys      []ResumableStateKey    `json:"keys"`
+	TotalSize int64                  `json:"total_size"`
+}
+
+func getResumableStateCommands(command string) ([]string, error) {
+	if command == "" {
+		return resumableStateCommands, nil
+	}
+	for _, c := range resumableStateCommands {
+		if c == command {
+			return []string{command}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown command '%s', expected one of %v", command, resumableStateCommands)
+}
+
+// getResumableStateName - `upload` to named storage keeps own state, so fan-out to several storages doesn't cleanup state of each other
+func getResumableStateName(command, storageName string) string {
+	if command == "upload" && storageName != "" {
+		return command + "." + storageName
+	}
+	return command
+}
+
+// resumableStateFile - local state file of command, storage is not empty only for `upload --storage`
+type resumableStateFile struct {
+	command   string
+	storage   string
+	stateFile string
+}
+
+// getResumableStateFiles - exists state files for backup, state could be created in default disk or in embedded backup disk,
+// upload states of all named storages are returned when --storage is not defined
+func (b *Backuper) getResumableStateFiles(ctx context.Context, backupName string, commands []string) ([]resumableStateFile, error) {
+	disks, err := b.ch.GetDisks(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if b.DefaultDataPath, err = b.ch.GetDefaultPath(disks); err != nil {
+		return nil, ErrUnknownClickhouseDataPath
+	}
+	stateDirs := []string{b.DefaultDataPath}
+	embeddedBackupPath, err := b.ch.GetEmbeddedBackupPath(disks)
+	if err != nil {
+		return nil, err
+	}
+	if embeddedBackupPath != "" && embeddedBackupPath != b.DefaultDataPath {
+		stateDirs = append(stateDirs, embeddedBackupPath)
+	}
+	return findResumableStateFiles(stateDirs, backupName, commands, b.cfg.StorageName)
+}
+
+func findResumableStateFiles(stateDirs []string, backupName string, commands []string, storageName string) ([]resumableStateFile, error) {
+	stateFiles := make([]resumableStateFile, 0)
+	for _, command := range commands {
+		storages := []string{storageName}
+		if command == "upload" && storageName == "" {
+			for _, stateDir := range stateDirs {
+				storageStateFiles, err := filepath.Glob(resumable.GetStateFile(stateDir, backupName, getResumableStateName(command, "*")))
+				if err != nil {
+					return nil, err
+				}
+				for _, storageStateFile := range storageStateFiles {
+					storage := strings.TrimSuffix(strings.TrimPrefix(path.Base(storageStateFile), command+"."), ".state2")
+					if !slices.Contains(storages, storage) {
+						storages = append(storages, storage)
+					}
+				}
+			}
+			sort.Strings(storages[1:])
+		}
+		for _, storage := range storages {
+			stateName := getResumableStateName(command, storage)
+			for _, stateDir := range stateDirs {
+				stateFile := resumable.GetStateFile(stateDir, backupName, stateName)
+				if _, err := os.Stat(stateFile); err == nil {
+					stateFiles = append(stateFiles, resumableStateFile{command: command, storage: storage, stateFile: stateFile})
+					break
+				} else if !os.IsNotExist(err) {
+					return nil, err
+				}
+			}
+		}
+	}
+	return stateFiles, nil
+}
+
+func readResumableStateInfo(command, stateFile string) (ResumableStateInfo, error) {
+	info := ResumableStateInfo{Command: command, StateFile: stateFile, Keys: make([]ResumableStateKey, 0)}
+	state, err := resumable.OpenStateReadOnly(stateFile)
+	if err != nil {
+		return info, err
+	}
+	defer state.Close()
+	info.Params = state.GetParams()
+	err = state.ForEachProcessed(func(key string, size int64) error {
+		info.Keys = append(info.Keys, ResumableStateKey{Key: key, Size: size})
+		info.TotalSize += size
+		return nil
+	})
+	return info, err
+}
+
+// ShowResumableState - print params, already processed keys and total processed bytes from resumable state, command is empty for all commands
+func (b *Backuper) ShowResumableState(backupName, command, format string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("backup name is required")
+	}
+	commands, err := getResumableStateCommands(command)
+	if err != nil {
+		return err
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	stateFiles, err := b.getResumableStateFiles(ctx, backupName, commands)
+	if err != nil {
+		return err
+	}
+	states := make([]ResumableStateInfo, 0, len(stateFiles))
+	for _, stateFile := range stateFiles {
+		info, err := readResumableStateInfo(stateFile.command, stateFile.stateFile)
+		if err != nil {
+			return err
+		}
+		info.Storage = stateFile.storage
+		states = append(states, info)
+	}
+	if len(states) == 0 {
+		log.Info().Msgf("resumable state for %s not found", backupName)
+	}
+	switch format {
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(states)
+	case "text", "":
+		return printResumableStates(os.Stdout, states)
+	}
+	return fmt.Errorf("unsupported format %s", format)
+}
+
+func printResumableStates(out io.Writer, states []ResumableStateInfo) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, state := range states {
+		params, err := json.Marshal(state.Params)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "command:\t%s\n", state.Command); err != nil {
+			return err
+		}
+		if state.Storage != "" {
+			if _, err = fmt.Fprintf(w, "storage:\t%s\n", state.Storage); err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(w, "state_file:\t%s\nparams:\t%s\n", state.StateFile, params); err != nil {
+			return err
+		}
+		for _, k := range state.Keys {
+			if _, err = fmt.Fprintf(w, "\t%s\t%s\n", k.Key, utils.FormatBytes(uint64(k.Size))); err != nil {
+				return err
+			}
+		}
+		if _, err = fmt.Fprintf(w, "total:\t%d keys\t%s\n\n", len(state.Keys), utils.FormatBytes(uint64(state.TotalSize))); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// ResetResumableState - delete resumable state, next resumable command will start from the beginning, command is empty for all commands
+func (b *Backuper) ResetResumableState(backupName, command string, commandId int) error {
+	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
+	if err != nil {
+		return err
+	}
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	backupName = utils.CleanBackupNameRE.ReplaceAllString(backupName, "")
+	if backupName == "" {
+		return fmt.Errorf("backup name is required")
+	}
+	commands, err := getResumableStateCommands(command)
+	if err != nil {
+		return err
+	}
+	if err = b.ch.Connect(); err != nil {
+		return fmt.Errorf("can't connect to clickhouse: %v", err)
+	}
+	defer b.ch.Close()
+	stateFiles, err := b.getResumableStateFiles(ctx, backupName, commands)
+	if err != nil {
+		return err
+	}
+	for _, stateFile := range stateFiles {
+		if err = os.Remove(stateFile.stateFile); err != nil {
+			return fmt.Errorf("can't remove %s: %v", stateFile.stateFile, err)
+		}
+		log.Info().Str("state_file", stateFile.stateFile).Msg("resumable state removed")
+	}
+	// upload --resume loads remote checkpoint when local state is absent, checkpoint is stored in remote storage selected by --storage
+	if (command == "" || command == "upload") && b.cfg.General.ResumableStateCheckpointDuration > 0 {
+		if err = b.connectRemoteForRead(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := b.dst.Close(ctx); closeErr != nil {
+				log.Warn().Msgf("can't close BackupDestination error: %v", closeErr)
+			}
+		}()
+		remoteStateFile := getRemoteResumableStatePath(backupName, getResumableStateName("upload", b.cfg.StorageName))
+		if _, err = b.dst.StatFile(ctx, remoteStateFile); err != nil {
+			if errors.Is(err, storage.ErrNotFound) || os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err = b.dst.DeleteFile(ctx, remoteStateFile); err != nil {
+			return fmt.Errorf("can't delete %s: %v", remoteStateFile, err)
+		}
+		log.Info().Str("remote_state_file", remoteStateFile).Msg("resumable state removed")
+	}
+	return nil
+}
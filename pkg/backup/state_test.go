@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/resumable"
+)
+
+func TestGetResumableStateCommands(t *testing.T) {
+	commands, err := getResumableStateCommands("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"upload", "download", "restore"}, commands)
+	commands, err = getResumableStateCommands("restore")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"restore"}, commands)
+	_, err = getResumableStateCommands("create")
+	assert.Error(t, err)
+}
+
+func TestReadAndPrintResumableState(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	state := resumable.NewState(stateDir, "test_backup", "upload", map[string]interface{}{"tablePattern": "db.*"})
+	state.AppendToState("test_backup/shadow/db/t/default_1.tar", 2048)
+	state.AppendToState("test_backup/metadata/db/t.json", 1024)
+	state.Close()
+
+	stateFile := resumable.GetStateFile(stateDir, "test_backup", "upload")
+	info, err := readResumableStateInfo("upload", stateFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tablePattern": "db.*"}, info.Params)
+	assert.Equal(t, []ResumableStateKey{
+		{Key: "test_backup/metadata/db/t.json", Size: 1024},
+		{Key: "test_backup/shadow/db/t/default_1.tar", Size: 2048},
+	}, info.Keys)
+	assert.Equal(t, int64(3072), info.TotalSize)
+
+	var out bytes.Buffer
+	require.NoError(t, printResumableStates(&out, []ResumableStateInfo{info}))
+	assert.Contains(t, out.String(), "params:      {\"tablePattern\":\"db.*\"}")
+	assert.Contains(t, out.String(), "test_backup/shadow/db/t/default_1.tar")
+	assert.Contains(t, out.String(), "total:       2 keys")
+	assert.Contains(t, out.String(), "3.00KiB")
+}
+
+func TestResumeUploadToTwoStorages(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	uploadParams := func(storage string) map[string]interface{} {
+		return map[string]interface{}{"tablePattern": "db.*", "storage": storage}
+	}
+	// create_remote --storage=s3,sftp killed during upload to sftp, after upload to s3 was also interrupted and resumed
+	s3State := resumable.NewState(stateDir, "test_backup", getResumableStateName("upload", "s3"), uploadParams("s3"))
+	s3State.AppendToState("test_backup/shadow/db/t/default_1.tar", 2048)
+	s3State.Close()
+	sftpState := resumable.NewState(stateDir, "test_backup", getResumableStateName("upload", "sftp"), uploadParams("sftp"))
+	sftpState.AppendToState("test_backup/metadata/db/t.json", 1024)
+	sftpState.Close()
+
+	// resume of each storage shall not cleanup state of other storage
+	s3State = resumable.NewState(stateDir, "test_backup", getResumableStateName("upload", "s3"), uploadParams("s3"))
+	assert.True(t, s3State.IsAlreadyProcessedBool("test_backup/shadow/db/t/default_1.tar"))
+	assert.False(t, s3State.IsAlreadyProcessedBool("test_backup/metadata/db/t.json"))
+	s3State.Close()
+	sftpState = resumable.NewState(stateDir, "test_backup", getResumableStateName("upload", "sftp"), uploadParams("sftp"))
+	assert.True(t, sftpState.IsAlreadyProcessedBool("test_backup/metadata/db/t.json"))
+	sftpState.Close()
+
+	// `state show|reset` without --storage finds upload states of all storages, with --storage only own state
+	defaultState := resumable.NewState(stateDir, "test_backup", getResumableStateName("upload", ""), uploadParams(""))
+	defaultState.Close()
+	stateFiles, err := findResumableStateFiles([]string{stateDir}, "test_backup", []string{"upload", "download"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []resumableStateFile{
+		{command: "upload", stateFile: resumable.GetStateFile(stateDir, "test_backup", "upload")},
+		{command: "upload", storage: "s3", stateFile: resumable.GetStateFile(stateDir, "test_backup", "upload.s3")},
+		{command: "upload", storage: "sftp", stateFile: resumable.GetStateFile(stateDir, "test_backup", "upload.sftp")},
+	}, stateFiles)
+	stateFiles, err = findResumableStateFiles([]string{stateDir}, "test_backup", []string{"upload"}, "sftp")
+	require.NoError(t, err)
+	assert.Equal(t, []resumableStateFile{{command: "upload", storage: "sftp", stateFile: resumable.GetStateFile(stateDir, "test_backup", "upload.sftp")}}, stateFiles)
+}
@@ -25,11 +25,73 @@ import (
 
 type ListOfTables []metadata.TableMetadata
 
-// Sort - sorting ListOfTables slice orderly by engine priority
+// Sort - sorting ListOfTables slice orderly by engine priority, for create also move dictionaries after source tables and dictGet callers after dictionaries
 func (lt ListOfTables) Sort(dropTable bool) {
-	sort.Slice(lt, func(i, j int) bool {
+	sort.SliceStable(lt, func(i, j int) bool {
 		return getOrderByEngine(lt[i].Query, dropTable) < getOrderByEngine(lt[j].Query, dropTable)
 	})
+	if !dropTable {
+		copy(lt, sortByDictionaryDependencies(lt))
+	}
+}
+
+var dictionaryClickHouseSourceRE = regexp.MustCompile(`(?is)SOURCE\s*\(\s*CLICKHOUSE\s*\((.*?)\)\s*\)`)
+var dictionarySourceTableRE = regexp.MustCompile(`(?i)\bTABLE\s+'([^']+)'`)
+var dictionarySourceDbRE = regexp.MustCompile(`(?i)\bDB\s+'([^']+)'`)
+var dictGetRE = regexp.MustCompile(`(?i)\bdictGet\w*\s*\(\s*'([^']+)'`)
+
+// getDictionaryDependencies - source table of dictionary with CLICKHOUSE source, and dictionaries used in dictGet* functions
+func getDictionaryDependencies(t metadata.TableMetadata) []metadata.TableTitle {
+	var dependencies []metadata.TableTitle
+	if strings.HasPrefix(t.Query, "CREATE DICTIONARY") || strings.HasPrefix(t.Query, "ATTACH DICTIONARY") {
+		if source := dictionaryClickHouseSourceRE.FindStringSubmatch(t.Query); len(source) > 1 {
+			if table := dictionarySourceTableRE.FindStringSubmatch(source[1]); len(table) > 1 {
+				dependency := metadata.TableTitle{Database: t.Database, Table: table[1]}
+				if db := dictionarySourceDbRE.FindStringSubmatch(source[1]); len(db) > 1 {
+					dependency.Database = db[1]
+				}
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	for _, dictGet := range dictGetRE.FindAllStringSubmatch(t.Query, -1) {
+		dependency := metadata.TableTitle{Database: t.Database, Table: dictGet[1]}
+		if dbAndName := strings.SplitN(dictGet[1], ".", 2); len(dbAndName) == 2 {
+			dependency = metadata.TableTitle{Database: dbAndName[0], Table: dbAndName[1]}
+		}
+		if dependency.Database != t.Database || dependency.Table != t.Table {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+// sortByDictionaryDependencies - keep engine priority order, but table from the list which is dictionary dependency moves before dependent table
+func sortByDictionaryDependencies(lt ListOfTables) ListOfTables {
+	tableIndex := make(map[metadata.TableTitle]int, len(lt))
+	for i, t := range lt {
+		tableIndex[metadata.TableTitle{Database: t.Database, Table: t.Table}] = i
+	}
+	sorted := make(ListOfTables, 0, len(lt))
+	// cyclic dependencies don't break sorting, retry in restoreSchemaRegular will resolve them
+	isVisited := make([]bool, len(lt))
+	var visit func(i int)
+	visit = func(i int) {
+		if isVisited[i] {
+			return
+		}
+		isVisited[i] = true
+		for _, dependency := range getDictionaryDependencies(lt[i]) {
+			if j, exists := tableIndex[dependency]; exists {
+				visit(j)
+			}
+		}
+		sorted = append(sorted, lt[i])
+	}
+	for i := range lt {
+		visit(i)
+	}
+	return sorted
 }
 
 func addTableToListIfNotExistsOrEnrichQueryAndParts(tables ListOfTables, table metadata.TableMetadata) ListOfTables {
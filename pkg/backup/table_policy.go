@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+// applyTablePolicies - `tables->schema_only` and `tables->priority` for create, `tables->skip` already applied by clickhouse.GetTables
+func applyTablePolicies(policies config.TablePolicies, tables []clickhouse.Table) {
+	if len(policies) == 0 {
+		return
+	}
+	for i := range tables {
+		if policy := policies.Get(tables[i].Database, tables[i].Name); policy != nil && policy.SchemaOnly && tables[i].BackupType == clickhouse.ShardBackupFull {
+			tables[i].BackupType = clickhouse.ShardBackupSchema
+		}
+	}
+	// stable sort keeps the biggest tables first inside the same priority
+	sort.SliceStable(tables, func(i, j int) bool {
+		return getTablePolicyPriority(policies, tables[i].Database, tables[i].Name) > getTablePolicyPriority(policies, tables[j].Database, tables[j].Name)
+	})
+}
+
+// sortTablesForUploadByPolicyPriority - tables with higher `tables->priority` upload first
+func sortTablesForUploadByPolicyPriority(policies config.TablePolicies, tables ListOfTables) {
+	if len(policies) == 0 {
+		return
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		return getTablePolicyPriority(policies, tables[i].Database, tables[i].Table) > getTablePolicyPriority(policies, tables[j].Database, tables[j].Table)
+	})
+}
+
+func getTablePolicyPriority(policies config.TablePolicies, database, table string) int {
+	if policy := policies.Get(database, table); policy != nil {
+		return policy.Priority
+	}
+	return 0
+}
+
+// getTablePolicyPartitions - `tables->partitions` in --partitions format, table specific, used only when --partitions is empty
+func getTablePolicyPartitions(policies config.TablePolicies, tables []clickhouse.Table) []string {
+	partitions := make([]string, 0)
+	for _, table := range tables {
+		if policy := policies.Get(table.Database, table.Name); policy != nil {
+			for _, partition := range policy.Partitions {
+				partitions = append(partitions, fmt.Sprintf("%s.%s:%s", table.Database, table.Name, partition))
+			}
+		}
+	}
+	return partitions
+}
+
+// getTablePolicyPartitionsWhere - condition for system.parts when table shall freeze by partitions according to `tables` policy,
+// `tables->max_partition_age` selects partitions which contain parts newer than max_partition_age, parts without date in partition key always selected
+func getTablePolicyPartitionsWhere(policy *config.TablePolicy, chCfg *config.ClickHouseConfig, now time.Time) (string, bool, error) {
+	if policy == nil {
+		return "", false, nil
+	}
+	where := ""
+	isByPartitions := false
+	freezeByPart := chCfg.FreezeByPart
+	if policy.FreezeByPart != nil {
+		freezeByPart = *policy.FreezeByPart
+	}
+	if freezeByPart {
+		where = chCfg.FreezeByPartWhere
+		if policy.FreezeByPartWhere != "" {
+			where = policy.FreezeByPartWhere
+		}
+		isByPartitions = policy.FreezeByPart != nil || policy.FreezeByPartWhere != ""
+	}
+	if policy.MaxPartitionAge != "" {
+		maxPartitionAge, err := time.ParseDuration(policy.MaxPartitionAge)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid `tables->max_partition_age: %s` for `%s`: %v", policy.MaxPartitionAge, policy.Pattern, err)
+		}
+		since := now.Add(-maxPartitionAge).Unix()
+		where += fmt.Sprintf(" AND active AND (max_date >= toDate(toDateTime(%d)) OR max_time >= toDateTime(%d) OR (max_date = toDate(0) AND max_time = toDateTime(0)))", since, since)
+		isByPartitions = true
+	}
+	return where, isByPartitions, nil
+}
+
+// freezeTable - FREEZE whole table, or only partitions selected by `create --since` and `tables` policy, replica shall be already synced
+func (b *Backuper) freezeTable(ctx context.Context, table *clickhouse.Table, shadowBackupUUID string, sinceParts *sinceTableParts) error {
+	policy := b.cfg.Tables.Get(table.Database, table.Name)
+	where, isByPartitions, err := getTablePolicyPartitionsWhere(policy, &b.cfg.ClickHouse, time.Now())
+	if err != nil {
+		return err
+	}
+	if !isByPartitions {
+		if sinceParts != nil {
+			return b.ch.FreezeTablePartitions(ctx, table, shadowBackupUUID, sinceParts.partitions)
+		}
+		if policy != nil && policy.FreezeByPart != nil && !*policy.FreezeByPart {
+			return b.ch.FreezeWholeTable(ctx, table, shadowBackupUUID)
+		}
+		return b.ch.FreezeTableWithoutSync(ctx, table, shadowBackupUUID)
+	}
+	partitionIDs, err := b.ch.GetPartitionIDs(ctx, table, where)
+	if err != nil {
+		return err
+	}
+	b.logTablePolicySkippedPartitions(ctx, table, policy, partitionIDs)
+	if sinceParts != nil {
+		partitionIDs = intersectPartitionIDs(sinceParts.partitions, partitionIDs)
+	}
+	return b.ch.FreezeTablePartitions(ctx, table, shadowBackupUUID, partitionIDs)
+}
+
+// logTablePolicySkippedPartitions - partitions which don't match `tables` policy are absent in backup without any error, so list them
+func (b *Backuper) logTablePolicySkippedPartitions(ctx context.Context, table *clickhouse.Table, policy *config.TablePolicy, partitionIDs []string) {
+	allPartitionIDs, err := b.ch.GetPartitionIDs(ctx, table, " AND active")
+	if err != nil {
+		log.Warn().Msgf("can't get partitions skipped by `tables` policy for `%s`.`%s`: %v", table.Database, table.Name, err)
+		return
+	}
+	if skippedPartitionIDs := subtractPartitionIDs(allPartitionIDs, partitionIDs); len(skippedPartitionIDs) > 0 {
+		log.Warn().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Str("pattern", policy.Pattern).Str("max_partition_age", policy.MaxPartitionAge).Strs("partitions", skippedPartitionIDs).Msg("partitions skipped by `tables` policy")
+	}
+}
+
+func subtractPartitionIDs(allPartitionIDs, selectedPartitionIDs []string) []string {
+	selectedPartitionsMap := common.EmptyMap{}
+	for _, partitionID := range selectedPartitionIDs {
+		selectedPartitionsMap[partitionID] = struct{}{}
+	}
+	partitionIDs := make([]string, 0)
+	for _, partitionID := range allPartitionIDs {
+		if _, exists := selectedPartitionsMap[partitionID]; !exists {
+			partitionIDs = append(partitionIDs, partitionID)
+		}
+	}
+	return partitionIDs
+}
+
+func intersectPartitionIDs(sincePartitions, policyPartitions []string) []string {
+	policyPartitionsMap := common.EmptyMap{}
+	for _, partitionID := range policyPartitions {
+		policyPartitionsMap[partitionID] = struct{}{}
+	}
+	partitionIDs := make([]string, 0, len(sincePartitions))
+	for _, partitionID := range sincePartitions {
+		if _, exists := policyPartitionsMap[partitionID]; exists {
+			partitionIDs = append(partitionIDs, partitionID)
+		}
+	}
+	return partitionIDs
+}
+
+// getTableCompression - `tables->compression_format` overrides compression_format of remote storage for table data archives
+func (b *Backuper) getTableCompression(database, table string) (string, int, bool) {
+	if policy := b.cfg.Tables.Get(database, table); policy != nil && policy.CompressionFormat != "" {
+		level := b.cfg.GetCompressionLevel()
+		if policy.CompressionLevel != 0 {
+			level = policy.CompressionLevel
+		}
+		return policy.CompressionFormat, level, true
+	}
+	return b.cfg.GetCompressionFormat(), b.cfg.GetCompressionLevel(), false
+}
+
+// getTableArchiveFormat - table data archives could be compressed by `tables->compression_format` instead of backup data_format
+func getTableArchiveFormat(backupDataFormat string, table metadata.TableMetadata) string {
+	if table.CompressionFormat != "" {
+		return table.CompressionFormat
+	}
+	return backupDataFormat
+}
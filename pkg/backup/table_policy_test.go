@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/clickhouse"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestTablePoliciesGet(t *testing.T) {
+	r := require.New(t)
+	policies := config.TablePolicies{
+		{Pattern: "logs.raw_*", Priority: 1},
+		{Pattern: "logs.*, audit.events", Priority: 2},
+	}
+	r.Equal(1, policies.Get("logs", "raw_events").Priority)
+	r.Equal(2, policies.Get("logs", "events").Priority)
+	r.Equal(2, policies.Get("audit", "events").Priority)
+	r.Nil(policies.Get("default", "events"))
+	r.Nil(config.TablePolicies(nil).Get("logs", "events"))
+}
+
+func TestApplyTablePolicies(t *testing.T) {
+	r := require.New(t)
+	policies := config.TablePolicies{
+		{Pattern: "db.cache", SchemaOnly: true},
+		{Pattern: "db.important", Priority: 10},
+		{Pattern: "db.lookup", Priority: 5},
+	}
+	tables := []clickhouse.Table{
+		{Database: "db", Name: "big", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "cache", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "lookup", BackupType: clickhouse.ShardBackupFull},
+		{Database: "db", Name: "important", BackupType: clickhouse.ShardBackupNone},
+		{Database: "db", Name: "small", BackupType: clickhouse.ShardBackupFull},
+	}
+	applyTablePolicies(policies, tables)
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	r.Equal([]string{"important", "lookup", "big", "cache", "small"}, names)
+	r.Equal(clickhouse.ShardBackupType(clickhouse.ShardBackupSchema), tables[3].BackupType)
+	// sharded backup type shall not change
+	r.Equal(clickhouse.ShardBackupType(clickhouse.ShardBackupNone), tables[0].BackupType)
+
+	tablesForUpload := ListOfTables{{Database: "db", Table: "big"}, {Database: "db", Table: "lookup"}, {Database: "db", Table: "important"}}
+	sortTablesForUploadByPolicyPriority(policies, tablesForUpload)
+	r.Equal(ListOfTables{{Database: "db", Table: "important"}, {Database: "db", Table: "lookup"}, {Database: "db", Table: "big"}}, tablesForUpload)
+}
+
+func TestGetTablePolicyPartitions(t *testing.T) {
+	r := require.New(t)
+	policies := config.TablePolicies{
+		{Pattern: "db.events", Partitions: []string{"202401,202402", "(2024,'EU')"}},
+		{Pattern: "db.*"},
+	}
+	tables := []clickhouse.Table{{Database: "db", Name: "events"}, {Database: "db", Name: "users"}}
+	r.Equal([]string{"db.events:202401,202402", "db.events:(2024,'EU')"}, getTablePolicyPartitions(policies, tables))
+	r.Empty(getTablePolicyPartitions(nil, tables))
+}
+
+func TestGetTablePolicyPartitionsWhere(t *testing.T) {
+	r := require.New(t)
+	isTrue, isFalse := true, false
+	chCfg := &config.ClickHouseConfig{FreezeByPart: true, FreezeByPartWhere: " AND partition_id != 'tmp'"}
+	now := time.Unix(1714557600, 0)
+
+	where, isByPartitions, err := getTablePolicyPartitionsWhere(nil, chCfg, now)
+	r.NoError(err)
+	r.False(isByPartitions)
+	r.Empty(where)
+
+	// global freeze_by_part is applied by clickhouse.FreezeTableWithoutSync
+	_, isByPartitions, err = getTablePolicyPartitionsWhere(&config.TablePolicy{Priority: 1}, chCfg, now)
+	r.NoError(err)
+	r.False(isByPartitions)
+
+	where, isByPartitions, err = getTablePolicyPartitionsWhere(&config.TablePolicy{FreezeByPartWhere: " AND partition_id > '2024'"}, chCfg, now)
+	r.NoError(err)
+	r.True(isByPartitions)
+	r.Equal(" AND partition_id > '2024'", where)
+
+	where, isByPartitions, err = getTablePolicyPartitionsWhere(&config.TablePolicy{FreezeByPart: &isTrue}, &config.ClickHouseConfig{FreezeByPartWhere: " AND 1"}, now)
+	r.NoError(err)
+	r.True(isByPartitions)
+	r.Equal(" AND 1", where)
+
+	_, isByPartitions, err = getTablePolicyPartitionsWhere(&config.TablePolicy{FreezeByPart: &isFalse, FreezeByPartWhere: " AND 1"}, chCfg, now)
+	r.NoError(err)
+	r.False(isByPartitions)
+
+	where, isByPartitions, err = getTablePolicyPartitionsWhere(&config.TablePolicy{MaxPartitionAge: "24h", FreezeByPart: &isFalse}, chCfg, now)
+	r.NoError(err)
+	r.True(isByPartitions)
+	r.Equal(" AND active AND (max_date >= toDate(toDateTime(1714471200)) OR max_time >= toDateTime(1714471200) OR (max_date = toDate(0) AND max_time = toDateTime(0)))", where)
+
+	where, _, err = getTablePolicyPartitionsWhere(&config.TablePolicy{MaxPartitionAge: "24h", FreezeByPart: &isTrue}, chCfg, now)
+	r.NoError(err)
+	r.Contains(where, " AND partition_id != 'tmp' AND active AND ")
+
+	_, _, err = getTablePolicyPartitionsWhere(&config.TablePolicy{MaxPartitionAge: "30d"}, chCfg, now)
+	r.Error(err)
+}
+
+func TestSubtractPartitionIDs(t *testing.T) {
+	r := require.New(t)
+	r.Equal([]string{"202404"}, subtractPartitionIDs([]string{"202405", "202404", "202403"}, []string{"202403", "202405", "202406"}))
+	r.Empty(subtractPartitionIDs([]string{"202405"}, []string{"202405"}))
+}
+
+func TestIntersectPartitionIDs(t *testing.T) {
+	r := require.New(t)
+	r.Equal([]string{"202405", "202403"}, intersectPartitionIDs([]string{"202405", "202404", "202403"}, []string{"202403", "202405", "202406"}))
+	r.Empty(intersectPartitionIDs([]string{"202405"}, nil))
+}
+
+func TestGetTableCompression(t *testing.T) {
+	r := require.New(t)
+	cfg := config.DefaultConfig()
+	cfg.General.RemoteStorage = "s3"
+	cfg.S3.CompressionFormat = "tar"
+	cfg.S3.CompressionLevel = 1
+	cfg.Tables = config.TablePolicies{
+		{Pattern: "db.archive", CompressionFormat: "zstd", CompressionLevel: 19},
+		{Pattern: "db.logs", CompressionFormat: "gzip"},
+	}
+	b := &Backuper{cfg: cfg}
+	format, level, isOverridden := b.getTableCompression("db", "archive")
+	r.Equal("zstd", format)
+	r.Equal(19, level)
+	r.True(isOverridden)
+	format, level, isOverridden = b.getTableCompression("db", "logs")
+	r.Equal("gzip", format)
+	r.Equal(1, level)
+	r.True(isOverridden)
+	format, _, isOverridden = b.getTableCompression("db", "events")
+	r.Equal("tar", format)
+	r.False(isOverridden)
+
+	r.Equal("zstd", getTableArchiveFormat("tar", metadata.TableMetadata{CompressionFormat: "zstd"}))
+	r.Equal("tar", getTableArchiveFormat("tar", metadata.TableMetadata{}))
+}
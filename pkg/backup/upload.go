@@ -25,6 +25,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/filesystemhelper"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/utils"
@@ -66,6 +67,15 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		}
 	}()
 
+	// upload of the same backup killed before could leave multipart uploads which billed until abort, other backups could upload right now, so only own prefix
+	if b.cfg.General.AbortStaleUploadsDuration > 0 {
+		if aborted, abortErr := b.abortStaleUploads(ctx, backupName, b.cfg.General.AbortStaleUploadsDuration); abortErr != nil {
+			log.Warn().Msgf("can't abort stale uploads: %v", abortErr)
+		} else if aborted > 0 {
+			log.Info().Msgf("aborted %d stale uploads older than %s", aborted, b.cfg.General.AbortStaleUploadsDuration)
+		}
+	}
+
 	remoteBackups, err := b.dst.BackupList(ctx, false, "")
 	if err != nil {
 		return fmt.Errorf("b.dst.BackupList return error: %v", err)
@@ -83,6 +93,10 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 	if err != nil {
 		return fmt.Errorf("b.ReadBackupMetadataLocal return error: %v", err)
 	}
+	// `create --since` and `create --diff-from-remote` mark parts from required backup, which will not upload
+	if err = checkRequiredBackupExistsRemote(backupMetadata, remoteBackups); err != nil {
+		return err
+	}
 	var tablesForUpload ListOfTables
 	b.isEmbedded = strings.Contains(backupMetadata.Tags, "embedded")
 	// will ignore partitions cause can't manipulate .backup
@@ -94,6 +108,7 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		if err != nil {
 			return fmt.Errorf("b.prepareTableListToUpload return error: %v", err)
 		}
+		sortTablesForUploadByPolicyPriority(b.cfg.Tables, tablesForUpload)
 	}
 	tablesForUploadFromDiff := map[metadata.TableTitle]metadata.TableMetadata{}
 
@@ -111,14 +126,38 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 		}
 		backupMetadata.RequiredBackup = diffFromRemote
 	}
+	stopStateCheckpoint := func(isCompleted bool) {}
 	if b.resume {
-		b.resumableState = resumable.NewState(b.GetStateDir(), backupName, "upload", map[string]interface{}{
+		stateName := getResumableStateName("upload", b.cfg.StorageName)
+		if b.cfg.General.ResumableStateCheckpointDuration > 0 {
+			if err = b.loadRemoteResumableState(ctx, backupName, stateName); err != nil {
+				log.Warn().Msgf("can't load resumable state from remote storage: %v", err)
+			}
+		}
+		b.resumableState = resumable.NewState(b.GetStateDir(), backupName, stateName, map[string]interface{}{
 			"diffFrom":       diffFrom,
 			"diffFromRemote": diffFromRemote,
 			"tablePattern":   tablePattern,
 			"partitions":     partitions,
 			"schemaOnly":     schemaOnly,
+			"storage":        b.cfg.StorageName,
 		})
+		if b.cfg.General.ResumableStateCheckpointDuration > 0 {
+			stopStateCheckpoint = b.startRemoteResumableStateCheckpoint(ctx, backupName, stateName, b.cfg.General.ResumableStateCheckpointDuration)
+			defer stopStateCheckpoint(false)
+		}
+	}
+
+	sharedPartsUploadInProgressFile := ""
+	if b.cfg.General.ContentAddressedParts && !b.isEmbedded && !schemaOnly {
+		sharedPartsUploadInProgressFile = path.Join(backupName, sharedPartsUploadInProgressMark)
+		retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
+		err = retry.RunCtx(ctx, func(ctx context.Context) error {
+			return b.dst.PutFile(ctx, sharedPartsUploadInProgressFile, io.NopCloser(bytes.NewReader([]byte(backupVersion))))
+		})
+		if err != nil {
+			return fmt.Errorf("can't upload %s: %v", sharedPartsUploadInProgressFile, err)
+		}
 	}
 
 	compressedDataSize := int64(0)
@@ -138,6 +177,11 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 				checkLocalPart := diffFrom != "" && diffFromRemote == ""
 				b.markDuplicatedParts(backupMetadata, &diffTable, &table, checkLocalPart)
 			}
+			if b.cfg.General.ContentAddressedParts && !b.isEmbedded {
+				if err = b.calculateTablePartsHashes(backupName, backupMetadata, &tablesForUpload[i]); err != nil {
+					return err
+				}
+			}
 		}
 		idx := i
 		uploadGroup.Go(func() error {
@@ -152,13 +196,18 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 				}
 				atomic.AddInt64(&compressedDataSize, uploadedBytes)
 				tablesForUpload[idx].Files = files
+				// local metadata could contain format from downloaded backup
+				tablesForUpload[idx].CompressionFormat = ""
+				if compressionFormat, _, isOverridden := b.getTableCompression(tablesForUpload[idx].Database, tablesForUpload[idx].Table); isOverridden && len(files) > 0 {
+					tablesForUpload[idx].CompressionFormat = compressionFormat
+				}
 			}
 			tableMetadataSize, err := b.uploadTableMetadata(uploadCtx, backupName, backupMetadata.RequiredBackup, tablesForUpload[idx])
 			if err != nil {
 				return err
 			}
 			atomic.AddInt64(&metadataSize, tableMetadataSize)
-			log.Info().Fields(map[string]interface{}{
+			log.Ctx(ctx).Info().Fields(map[string]interface{}{
 				"operation": "upload_data",
 				"table":     fmt.Sprintf("%s.%s", tablesForUpload[idx].Database, tablesForUpload[idx].Table),
 				"progress":  fmt.Sprintf("%d/%d", idx+1, len(tablesForUpload)),
@@ -182,6 +231,11 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 	if backupMetadata.ConfigSize, err = b.uploadConfigData(ctx, backupName); err != nil {
 		return fmt.Errorf("b.uploadConfigData return error: %v", err)
 	}
+
+	// upload keeper dumps for backup
+	if backupMetadata.KeeperSize, err = b.uploadKeeperData(ctx, backupName); err != nil {
+		return fmt.Errorf("b.uploadKeeperData return error: %v", err)
+	}
 	//upload embedded .backup file
 	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" && backupMetadata.Tables != nil && len(backupMetadata.Tables) > 0 {
 		localClickHouseBackupFile := path.Join(b.EmbeddedBackupDataPath, backupName, ".backup")
@@ -224,14 +278,20 @@ func (b *Backuper) Upload(backupName string, deleteSource bool, diffFrom, diffFr
 			return fmt.Errorf("can't upload %s: %v", remoteBackupMetaFile, err)
 		}
 	}
+	if sharedPartsUploadInProgressFile != "" {
+		if err = b.dst.DeleteFile(ctx, sharedPartsUploadInProgressFile); err != nil {
+			return fmt.Errorf("can't delete %s: %v", sharedPartsUploadInProgressFile, err)
+		}
+	}
 	if b.resume {
+		stopStateCheckpoint(true)
 		b.resumableState.Close()
 	}
 	log.Info().Fields(map[string]interface{}{
 		"backup":           backupName,
 		"operation":        "upload",
 		"duration":         utils.HumanizeDuration(time.Since(startUpload)),
-		"upload_size":      utils.FormatBytes(uint64(compressedDataSize) + uint64(metadataSize) + uint64(len(newBackupMetadataBody)) + backupMetadata.RBACSize + backupMetadata.ConfigSize),
+		"upload_size":      utils.FormatBytes(uint64(compressedDataSize) + uint64(metadataSize) + uint64(len(newBackupMetadataBody)) + backupMetadata.RBACSize + backupMetadata.ConfigSize + backupMetadata.KeeperSize),
 		"object_disk_size": utils.FormatBytes(backupMetadata.ObjectDiskSize),
 		"version":          backupVersion,
 	}).Msg("done")
@@ -269,15 +329,24 @@ func (b *Backuper) RemoveOldBackupsRemote(ctx context.Context) error {
 		"operation": "RemoveOldBackupsRemote",
 		"duration":  utils.HumanizeDuration(time.Since(start)),
 	}).Msg("calculate backup list for delete remote")
+	sharedPartsHashes := common.EmptyMap{}
 	for _, backupToDelete := range backupsToDelete {
 		startDelete := time.Now()
 		err = b.cleanEmbeddedAndObjectDiskRemoteIfSameLocalNotPresent(ctx, backupToDelete)
 		if err != nil {
 			return err
 		}
+		backupHashes, err := b.getRemoteBackupSharedPartsHashes(ctx, backupToDelete)
+		if err != nil {
+			return err
+		}
 
 		if err := b.dst.RemoveBackupRemote(ctx, backupToDelete, b.cfg); err != nil {
 			log.Warn().Msgf("can't deleteKey %s return error : %v", backupToDelete.BackupName, err)
+		} else {
+			for hash := range backupHashes {
+				sharedPartsHashes[hash] = struct{}{}
+			}
 		}
 		log.Info().Fields(map[string]interface{}{
 			"operation": "RemoveOldBackupsRemote",
@@ -286,6 +355,9 @@ func (b *Backuper) RemoveOldBackupsRemote(ctx context.Context) error {
 			"duration":  utils.HumanizeDuration(time.Since(startDelete)),
 		}).Msg("done")
 	}
+	if err = b.cleanSharedParts(ctx, sharedPartsHashes); err != nil {
+		return err
+	}
 	log.Info().Fields(map[string]interface{}{"operation": "RemoveOldBackupsRemote", "duration": utils.HumanizeDuration(time.Since(start))}).Msg("done")
 	return nil
 }
@@ -396,6 +468,22 @@ func (b *Backuper) uploadConfigData(ctx context.Context, backupName string) (uin
 	return b.uploadBackupRelatedDir(ctx, configBackupPath, configFilesGlobPattern, remoteConfigsArchive)
 }
 
+func (b *Backuper) uploadKeeperData(ctx context.Context, backupName string) (uint64, error) {
+	backupPath := b.DefaultDataPath
+	keeperBackupPath := path.Join(backupPath, "backup", backupName, "keeper")
+	if b.isEmbedded && b.cfg.ClickHouse.EmbeddedBackupDisk != "" {
+		backupPath = b.EmbeddedBackupDataPath
+		keeperBackupPath = path.Join(backupPath, backupName, "keeper")
+	}
+	keeperFilesGlobPattern := path.Join(keeperBackupPath, "*.jsonl*")
+	if b.cfg.GetCompressionFormat() == "none" {
+		remoteKeeperDir := path.Join(backupName, "keeper")
+		return b.uploadBackupRelatedDir(ctx, keeperBackupPath, keeperFilesGlobPattern, remoteKeeperDir)
+	}
+	remoteKeeperArchive := path.Join(backupName, fmt.Sprintf("keeper.%s", b.cfg.GetArchiveExtension()))
+	return b.uploadBackupRelatedDir(ctx, keeperBackupPath, keeperFilesGlobPattern, remoteKeeperArchive)
+}
+
 func (b *Backuper) uploadRBACData(ctx context.Context, backupName string) (uint64, error) {
 	backupPath := b.DefaultDataPath
 	rbacBackupPath := path.Join(backupPath, "backup", backupName, "access")
@@ -485,13 +573,20 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 	dataGroup, ctx := errgroup.WithContext(ctx)
 	dataGroup.SetLimit(int(b.cfg.General.UploadConcurrency))
 	var uploadedBytes int64
+	compressionFormat, compressionLevel, _ := b.getTableCompression(table.Database, table.Table)
 
 	splitParts := make(map[string][]metadata.SplitPartFiles)
 	splitPartsOffset := make(map[string]int)
 	splitPartsCapacity := 0
 	for disk := range table.Parts {
 		backupPath := b.getLocalBackupDataPathForTable(backupName, disk, dbAndTablePath)
-		splitPartsList, err := b.splitPartFiles(backupPath, table.Parts[disk])
+		regularParts := make([]metadata.Part, 0, len(table.Parts[disk]))
+		for _, part := range table.Parts[disk] {
+			if part.Hash == "" {
+				regularParts = append(regularParts, part)
+			}
+		}
+		splitPartsList, err := b.splitPartFiles(backupPath, regularParts)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -541,7 +636,7 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 					return nil
 				})
 			} else {
-				fileName := fmt.Sprintf("%s_%s.%s", disk, common.TablePathEncode(partSuffix), b.cfg.GetArchiveExtension())
+				fileName := fmt.Sprintf("%s_%s.%s", disk, common.TablePathEncode(partSuffix), config.ArchiveExtensions[compressionFormat])
 				uploadedFiles[disk] = append(uploadedFiles[disk], fileName)
 				remoteDataFile := path.Join(baseRemoteDataPath, fileName)
 				localFiles := partFiles
@@ -555,7 +650,7 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 					log.Debug().Msgf("start upload %d files to %s", len(localFiles), remoteDataFile)
 					retry := retrier.New(retrier.ConstantBackoff(b.cfg.General.RetriesOnFailure, b.cfg.General.RetriesDuration), nil)
 					err := retry.RunCtx(ctx, func(ctx context.Context) error {
-						return b.dst.UploadCompressedStream(ctx, backupPath, localFiles, remoteDataFile, b.cfg.General.UploadMaxBytesPerSecond)
+						return b.dst.UploadCompressedStreamWithFormat(ctx, backupPath, localFiles, remoteDataFile, b.cfg.General.UploadMaxBytesPerSecond, compressionFormat, compressionLevel)
 					})
 					if err != nil {
 						log.Error().Msgf("UploadCompressedStream return error: %v", err)
@@ -589,6 +684,24 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 			}
 		}
 	}
+	for disk := range table.Parts {
+		backupPath := b.getLocalBackupDataPathForTable(backupName, disk, dbAndTablePath)
+		for _, part := range table.Parts[disk] {
+			if part.Hash == "" {
+				continue
+			}
+			localPartPath := path.Join(backupPath, part.Name)
+			partHash := part.Hash
+			dataGroup.Go(func() error {
+				sharedPartBytes, err := b.uploadSharedPart(ctx, localPartPath, partHash, deleteSource)
+				if err != nil {
+					return err
+				}
+				atomic.AddInt64(&uploadedBytes, sharedPartBytes)
+				return nil
+			})
+		}
+	}
 	if err := dataGroup.Wait(); err != nil {
 		return nil, 0, fmt.Errorf("one of uploadTableData go-routine return error: %v", err)
 	}
@@ -596,6 +709,19 @@ func (b *Backuper) uploadTableData(ctx context.Context, backupName string, delet
 	return uploadedFiles, uploadedBytes, nil
 }
 
+// checkRequiredBackupExistsRemote - required parts are not uploaded, so remote backup without its required backup can't be downloaded
+func checkRequiredBackupExistsRemote(backupMetadata *metadata.BackupMetadata, remoteBackups []storage.Backup) error {
+	if backupMetadata.RequiredBackup == "" {
+		return nil
+	}
+	for _, remoteBackup := range remoteBackups {
+		if remoteBackup.BackupName == backupMetadata.RequiredBackup && remoteBackup.Broken == "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s requires %s, which is not found on remote storage, upload %s first", backupMetadata.BackupName, backupMetadata.RequiredBackup, backupMetadata.RequiredBackup)
+}
+
 func (b *Backuper) uploadTableMetadata(ctx context.Context, backupName string, requiredBackupName string, tableMetadata metadata.TableMetadata) (int64, error) {
 	if b.isEmbedded {
 		if sqlSize, err := b.uploadTableMetadataEmbedded(ctx, backupName, requiredBackupName, tableMetadata); err != nil {
@@ -678,6 +804,10 @@ func (b *Backuper) uploadTableMetadataEmbedded(ctx context.Context, backupName s
 }
 
 func (b *Backuper) markDuplicatedParts(backup *metadata.BackupMetadata, existsTable *metadata.TableMetadata, newTable *metadata.TableMetadata, checkLocal bool) {
+	// logical backup pseudo parts named by partition_id, the same name doesn't mean the same data
+	if newTable.DataFormat != "" || existsTable.DataFormat != "" {
+		return
+	}
 	for disk, newParts := range newTable.Parts {
 		if _, diskExists := existsTable.Parts[disk]; diskExists {
 			if len(existsTable.Parts[disk]) == 0 {
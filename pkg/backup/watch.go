@@ -59,6 +59,15 @@ func (b *Backuper) ValidateWatchParams(watchInterval, fullInterval, watchBackupN
 	return nil
 }
 
+// loadWatchConfig - reloaded config doesn't contain named storage overlay, so it shall be applied again
+func loadWatchConfig(configPath string, storages []string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil || len(storages) == 0 {
+		return cfg, err
+	}
+	return cfg.WithStorage(storages[0])
+}
+
 // Watch
 // - run create_remote full + delete local full, even when upload failed
 //   - if success save backup type full, next will increment, until reach full interval
@@ -66,13 +75,18 @@ func (b *Backuper) ValidateWatchParams(watchInterval, fullInterval, watchBackupN
 //
 // - each watch-interval, run create_remote increment --diff-from=prev-name + delete local increment, even when upload failed
 //   - save previous backup type incremental, next try will also incremental, until reach full interval
-func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern string, partitions []string, schemaOnly, backupRBAC, backupConfigs, skipCheckPartsColumns bool, version string, commandId int, metrics metrics.APIMetricsInterface, cliCtx *cli.Context) error {
+//
+// - storages are names from `storages` config section, each backup uploads to all of them, first storage is used for previous backup detection and retention
+func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern string, partitions []string, schemaOnly, backupRBAC, backupConfigs, backupKeeper, backupNamedCollections, skipCheckPartsColumns bool, storages []string, version string, commandId int, metrics metrics.APIMetricsInterface, cliCtx *cli.Context) error {
 	ctx, cancel, err := status.Current.GetContextWithCancel(commandId)
 	if err != nil {
 		return err
 	}
 	ctx, cancel = context.WithCancel(ctx)
 	defer cancel()
+	if len(storages) == 0 && b.cfg.StorageName != "" {
+		storages = []string{b.cfg.StorageName}
+	}
 
 	if err := b.ValidateWatchParams(watchInterval, fullInterval, watchBackupNameTemplate); err != nil {
 		return err
@@ -103,7 +117,7 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 			return ctx.Err()
 		default:
 			if cliCtx != nil {
-				if cfg, err := config.LoadConfig(config.GetConfigPath(cliCtx)); err == nil {
+				if cfg, err := loadWatchConfig(config.GetConfigPath(cliCtx), storages); err == nil {
 					b.cfg = cfg
 				} else {
 					log.Warn().Msgf("watch config.LoadConfig error: %v", err)
@@ -120,16 +134,22 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 			if backupType == "increment" {
 				diffFromRemote = prevBackupName
 			}
+			releaseQueue := func() {}
+			if b.queueGate != nil {
+				if releaseQueue, err = b.queueGate(ctx, "create_remote", "watch create_remote "+backupName); err != nil {
+					return err
+				}
+			}
 			if metrics != nil {
 				createRemoteErr, createRemoteErrCount = metrics.ExecuteWithMetrics("create_remote", createRemoteErrCount, func() error {
-					return b.CreateToRemote(backupName, false, "", diffFromRemote, tablePattern, partitions, schemaOnly, backupRBAC, false, backupConfigs, false, skipCheckPartsColumns, false, version, commandId)
+					return b.CreateToRemote(backupName, false, "", diffFromRemote, "", tablePattern, partitions, schemaOnly, backupRBAC, false, backupConfigs, false, backupKeeper, backupNamedCollections, skipCheckPartsColumns, false, storages, version, commandId, nil)
 				})
 				deleteLocalErr, deleteLocalErrCount = metrics.ExecuteWithMetrics("delete", deleteLocalErrCount, func() error {
 					return b.RemoveBackupLocal(ctx, backupName, nil)
 				})
 
 			} else {
-				createRemoteErr = b.CreateToRemote(backupName, false, "", diffFromRemote, tablePattern, partitions, schemaOnly, backupRBAC, false, backupConfigs, false, skipCheckPartsColumns, false, version, commandId)
+				createRemoteErr = b.CreateToRemote(backupName, false, "", diffFromRemote, "", tablePattern, partitions, schemaOnly, backupRBAC, false, backupConfigs, false, backupKeeper, backupNamedCollections, skipCheckPartsColumns, false, storages, version, commandId, nil)
 				if createRemoteErr != nil {
 					cmd := "create_remote"
 					if diffFromRemote != "" {
@@ -150,9 +170,18 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 					if backupConfigs {
 						cmd += " --configs"
 					}
+					if backupKeeper {
+						cmd += " --keeper"
+					}
+					if backupNamedCollections {
+						cmd += " --named-collections"
+					}
 					if skipCheckPartsColumns {
 						cmd += " --skip-check-parts-columns"
 					}
+					if len(storages) > 0 {
+						cmd += " --storage=" + strings.Join(storages, ",")
+					}
 					cmd += " " + backupName
 					log.Error().Msgf("%s return error: %v", cmd, createRemoteErr)
 					createRemoteErrCount += 1
@@ -171,6 +200,7 @@ func (b *Backuper) Watch(watchInterval, fullInterval, watchBackupNameTemplate, t
 				}
 
 			}
+			releaseQueue()
 
 			if createRemoteErrCount > b.cfg.General.BackupsToKeepRemote || deleteLocalErrCount > b.cfg.General.BackupsToKeepLocal {
 				return fmt.Errorf("too many errors create_remote: %d, delete local: %d, during watch full_interval: %s, abort watching", createRemoteErrCount, deleteLocalErrCount, b.cfg.General.FullInterval)
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWatchConfig(t *testing.T) {
+	r := require.New(t)
+	configPath := path.Join(t.TempDir(), "config.yml")
+	r.NoError(os.WriteFile(configPath, []byte(`
+general:
+  remote_storage: s3
+s3:
+  bucket: primary
+storages:
+  offsite:
+    remote_storage: sftp
+    sftp:
+      address: backup.example.com
+      path: /backups
+`), 0640))
+	cfg, err := loadWatchConfig(configPath, nil)
+	r.NoError(err)
+	r.Equal("s3", cfg.General.RemoteStorage)
+	r.Empty(cfg.StorageName)
+
+	// `watch --storage` shall not fall back to general->remote_storage after config reload
+	cfg, err = loadWatchConfig(configPath, []string{"offsite", "other"})
+	r.NoError(err)
+	r.Equal("sftp", cfg.General.RemoteStorage)
+	r.Equal("offsite", cfg.StorageName)
+
+	_, err = loadWatchConfig(configPath, []string{"unknown"})
+	r.Error(err)
+}
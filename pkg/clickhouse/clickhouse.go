@@ -11,6 +11,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +32,7 @@ import (
 // ClickHouse - provide
 type ClickHouse struct {
 	Config              *config.ClickHouseConfig
+	TablePolicies       config.TablePolicies
 	conn                driver.Conn
 	version             int
 	IsOpen              bool
@@ -211,6 +213,24 @@ func (ch *ClickHouse) GetEmbeddedBackupPath(disks []Disk) (string, error) {
 	return "", fmt.Errorf("%s not found in system.disks %v", ch.Config.EmbeddedBackupDisk, disks)
 }
 
+// GetUserFilesPath - file() table function can read and write only inside user_files_path
+func (ch *ClickHouse) GetUserFilesPath(ctx context.Context, disks []Disk) (string, error) {
+	userFilesPath := make([]struct {
+		Value string `ch:"value"`
+	}, 0)
+	if err := ch.SelectContext(ctx, &userFilesPath, "SELECT value FROM system.server_settings WHERE name='user_files_path'"); err != nil {
+		log.Debug().Msgf("can't get user_files_path from system.server_settings: %v", err)
+	}
+	if len(userFilesPath) == 1 && userFilesPath[0].Value != "" {
+		return userFilesPath[0].Value, nil
+	}
+	defaultPath, err := ch.GetDefaultPath(disks)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(defaultPath, "user_files"), nil
+}
+
 func (ch *ClickHouse) GetDefaultPath(disks []Disk) (string, error) {
 	defaultPath := "/var/lib/clickhouse"
 	for _, d := range disks {
@@ -385,6 +405,10 @@ func (ch *ClickHouse) GetTables(ctx context.Context, tablePattern string) ([]Tab
 				break
 			}
 		}
+		// `tables` policy could include table from skip_tables or exclude additional table
+		if policy := ch.TablePolicies.Get(t.Database, t.Name); policy != nil && policy.Skip != nil {
+			t.Skip = *policy.Skip
+		}
 		if ch.Config.UseEmbeddedBackupRestore && (strings.HasPrefix(t.Name, ".inner_id.") /*|| strings.HasPrefix(t.Name, ".inner.")*/) {
 			t.Skip = true
 		}
@@ -684,27 +708,45 @@ func (ch *ClickHouse) GetVersionDescribe(ctx context.Context) string {
 // FreezeTableByParts - freeze all partitions in table one by one
 // also ally `freeze_by_part_where`
 func (ch *ClickHouse) FreezeTableByParts(ctx context.Context, table *Table, name string) error {
+	partitionIDs, err := ch.GetPartitionIDs(ctx, table, ch.Config.FreezeByPartWhere)
+	if err != nil {
+		return err
+	}
+	return ch.FreezeTablePartitions(ctx, table, name, partitionIDs)
+}
+
+// GetPartitionIDs - distinct partition_id from system.parts, where shall be empty or begin with AND
+func (ch *ClickHouse) GetPartitionIDs(ctx context.Context, table *Table, where string) ([]string, error) {
 	var partitions []struct {
 		PartitionID string `ch:"partition_id"`
 	}
-	q := fmt.Sprintf("SELECT DISTINCT partition_id FROM `system`.`parts` WHERE database='%s' AND table='%s' %s", table.Database, table.Name, ch.Config.FreezeByPartWhere)
+	q := fmt.Sprintf("SELECT DISTINCT partition_id FROM `system`.`parts` WHERE database='%s' AND table='%s' %s", table.Database, table.Name, where)
 	if err := ch.SelectContext(ctx, &partitions, q); err != nil {
-		return fmt.Errorf("can't get partitions for '%s.%s': %w", table.Database, table.Name, err)
+		return nil, fmt.Errorf("can't get partitions for '%s.%s': %w", table.Database, table.Name, err)
 	}
+	partitionIDs := make([]string, len(partitions))
+	for i, item := range partitions {
+		partitionIDs[i] = item.PartitionID
+	}
+	return partitionIDs, nil
+}
+
+// FreezeTablePartitions - freeze selected partitions in table one by one
+func (ch *ClickHouse) FreezeTablePartitions(ctx context.Context, table *Table, name string, partitionIDs []string) error {
 	withNameQuery := ""
 	if name != "" {
 		withNameQuery = fmt.Sprintf("WITH NAME '%s'", name)
 	}
-	for _, item := range partitions {
-		log.Debug().Msgf("  partition '%v'", item.PartitionID)
+	for _, partitionID := range partitionIDs {
+		log.Debug().Msgf("  partition '%v'", partitionID)
 		query := fmt.Sprintf(
 			"ALTER TABLE `%v`.`%v` FREEZE PARTITION ID '%v' %s;",
 			table.Database,
 			table.Name,
-			item.PartitionID,
+			partitionID,
 			withNameQuery,
 		)
-		if item.PartitionID == "all" {
+		if partitionID == "all" {
 			query = fmt.Sprintf(
 				"ALTER TABLE `%v`.`%v` FREEZE PARTITION tuple() %s;",
 				table.Database,
@@ -716,20 +758,42 @@ func (ch *ClickHouse) FreezeTableByParts(ctx context.Context, table *Table, name
 			if (strings.Contains(err.Error(), "code: 60") || strings.Contains(err.Error(), "code: 81")) && ch.Config.IgnoreNotExistsErrorDuringFreeze {
 				log.Warn().Msgf("can't freeze partition: %v", err)
 			} else {
-				return fmt.Errorf("can't freeze partition '%s': %w", item.PartitionID, err)
+				return fmt.Errorf("can't freeze partition '%s': %w", partitionID, err)
 			}
 		}
 	}
 	return nil
 }
 
+// GetActiveParts - active parts of table with modification time and max block number, to compare with backup watermark
+func (ch *ClickHouse) GetActiveParts(ctx context.Context, table *Table) ([]ActivePart, error) {
+	parts := make([]ActivePart, 0)
+	query := "SELECT name, partition_id, disk_name, modification_time, max_block_number FROM system.parts WHERE active AND database=? AND table=? ORDER BY partition_id, min_block_number"
+	if err := ch.SelectContext(ctx, &parts, query, table.Database, table.Name); err != nil {
+		return nil, fmt.Errorf("can't get active parts for `%s`.`%s`: %v", table.Database, table.Name, err)
+	}
+	return parts, nil
+}
+
+// GetPartitionsMaxBlocks - max block number of active parts for each partition of all tables
+func (ch *ClickHouse) GetPartitionsMaxBlocks(ctx context.Context) ([]PartitionMaxBlock, error) {
+	maxBlocks := make([]PartitionMaxBlock, 0)
+	query := "SELECT database, table, partition_id, max(max_block_number) AS max_block_number FROM system.parts WHERE active GROUP BY database, table, partition_id ORDER BY database, table, partition_id"
+	if err := ch.SelectContext(ctx, &maxBlocks, query); err != nil {
+		return nil, fmt.Errorf("can't get partitions max blocks: %v", err)
+	}
+	return maxBlocks, nil
+}
+
 // FreezeTable - freeze all partitions for table
 // This way available for ClickHouse since v19.1
 func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string) error {
-	version, err := ch.GetVersion(ctx)
-	if err != nil {
-		return err
-	}
+	ch.SyncReplica(ctx, table)
+	return ch.FreezeTableWithoutSync(ctx, table, name)
+}
+
+// SyncReplica - execute SYSTEM SYNC REPLICA when sync_replicated_tables enabled, errors are logged only
+func (ch *ClickHouse) SyncReplica(ctx context.Context, table *Table) {
 	if strings.HasPrefix(table.Engine, "Replicated") && ch.Config.SyncReplicatedTables {
 		query := fmt.Sprintf("SYSTEM SYNC REPLICA `%s`.`%s`;", table.Database, table.Name)
 		if err := ch.QueryContext(ctx, query); err != nil {
@@ -738,9 +802,22 @@ func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string
 			log.Debug().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Name)).Msg("replica synced")
 		}
 	}
+}
+
+// FreezeTableWithoutSync - FREEZE table which replica already synced, or when sync shall not happen inside freeze window
+func (ch *ClickHouse) FreezeTableWithoutSync(ctx context.Context, table *Table, name string) error {
+	version, err := ch.GetVersion(ctx)
+	if err != nil {
+		return err
+	}
 	if version < 19001005 || ch.Config.FreezeByPart {
 		return ch.FreezeTableByParts(ctx, table, name)
 	}
+	return ch.FreezeWholeTable(ctx, table, name)
+}
+
+// FreezeWholeTable - FREEZE table by one query, doesn't depend on freeze_by_part
+func (ch *ClickHouse) FreezeWholeTable(ctx context.Context, table *Table, name string) error {
 	withNameQuery := ""
 	if name != "" {
 		withNameQuery = fmt.Sprintf("WITH NAME '%s'", name)
@@ -756,8 +833,8 @@ func (ch *ClickHouse) FreezeTable(ctx context.Context, table *Table, name string
 	return nil
 }
 
-// AttachDataParts - execute ALTER TABLE ... ATTACH PART command for specific table
-func (ch *ClickHouse) AttachDataParts(table metadata.TableMetadata, dstTable Table) error {
+// AttachDataParts - execute ALTER TABLE ... ATTACH PART command for specific table, onAttached called after each attached part and could be nil
+func (ch *ClickHouse) AttachDataParts(table metadata.TableMetadata, dstTable Table, onAttached func(disk string, part metadata.Part)) error {
 	if dstTable.Database != "" && dstTable.Database != table.Database {
 		table.Database = dstTable.Database
 	}
@@ -781,6 +858,9 @@ func (ch *ClickHouse) AttachDataParts(table metadata.TableMetadata, dstTable Tab
 					return err
 				}
 				log.Debug().Str("table", fmt.Sprintf("%s.%s", table.Database, table.Table)).Str("disk", disk).Str("part", part.Name).Msg("attached")
+				if onAttached != nil {
+					onAttached(disk, part)
+				}
 			}
 		}
 	}
@@ -1092,6 +1172,55 @@ func (ch *ClickHouse) IsAtomic(database string) (bool, error) {
 	return isDatabaseAtomic == "Atomic", nil
 }
 
+// GetDatabaseEngine - return engine from system.databases, empty string when database doesn't exist
+func (ch *ClickHouse) GetDatabaseEngine(ctx context.Context, database string) (string, error) {
+	engines := make([]struct {
+		Engine string `ch:"engine"`
+	}, 0)
+	if err := ch.SelectContext(ctx, &engines, "SELECT engine FROM system.databases WHERE name=?", database); err != nil {
+		return "", err
+	}
+	if len(engines) == 0 {
+		return "", nil
+	}
+	return engines[0].Engine, nil
+}
+
+// IsTableExists - check table, view or dictionary in system.tables
+func (ch *ClickHouse) IsTableExists(ctx context.Context, database, table string) (bool, error) {
+	var isExists uint64
+	if err := ch.SelectSingleRow(ctx, &isExists, "SELECT count() FROM system.tables WHERE database=? AND name=?", database, table); err != nil {
+		return false, err
+	}
+	return isExists > 0, nil
+}
+
+// SyncDatabaseReplica - wait until Replicated database replica applies all DDL queries from database replication log
+func (ch *ClickHouse) SyncDatabaseReplica(ctx context.Context, database string, version int) error {
+	if version < 22008000 {
+		log.Warn().Msgf("SYSTEM SYNC DATABASE REPLICA requires ClickHouse 22.8+, skip sync for `%s`", database)
+		return nil
+	}
+	return ch.QueryContext(ctx, fmt.Sprintf("SYSTEM SYNC DATABASE REPLICA `%s`", database))
+}
+
+var replicatedDatabaseEngineRE = regexp.MustCompile(`ENGINE\s*=\s*Replicated\(\s*'([^']*)'\s*,\s*'([^']*)'\s*,\s*'([^']*)'\s*\)`)
+
+// ParseReplicatedDatabaseEngine - extract zookeeper_path, shard_name and replica_name from `ENGINE = Replicated(...)` in CREATE DATABASE query
+func ParseReplicatedDatabaseEngine(query string) (zookeeperPath, shardName, replicaName string, isReplicated bool) {
+	matches := replicatedDatabaseEngineRE.FindStringSubmatch(query)
+	if len(matches) == 0 {
+		return "", "", "", false
+	}
+	return matches[1], matches[2], matches[3], true
+}
+
+// ReplaceReplicatedDatabaseEngine - replace `ENGINE = Replicated(...)` arguments in CREATE DATABASE query
+func ReplaceReplicatedDatabaseEngine(query, zookeeperPath, shardName, replicaName string) string {
+	engine := fmt.Sprintf("ENGINE = Replicated('%s', '%s', '%s')", zookeeperPath, shardName, replicaName)
+	return replicatedDatabaseEngineRE.ReplaceAllLiteralString(query, engine)
+}
+
 // GetAccessManagementPath extract path from following sources system.user_directories, access_control_path from /var/lib/clickhouse/preprocessed_configs/config.xml, system.disks
 func (ch *ClickHouse) GetAccessManagementPath(ctx context.Context, disks []Disk) (string, error) {
 	accessPath := "/var/lib/clickhouse/access"
@@ -1161,6 +1290,189 @@ func (ch *ClickHouse) CreateUserDefinedFunction(name string, query string, clust
 	return ch.Query(query)
 }
 
+// GetKafkaConsumerOffsets - current positions of Kafka engine table consumers from system.kafka_consumers, empty when table doesn't have active consumers
+func (ch *ClickHouse) GetKafkaConsumerOffsets(ctx context.Context, database, table string) ([]metadata.KafkaOffset, error) {
+	offsets := make([]metadata.KafkaOffset, 0)
+	var isKafkaConsumersExists uint64
+	if err := ch.SelectSingleRow(ctx, &isKafkaConsumersExists, "SELECT count() FROM system.tables WHERE database='system' AND name='kafka_consumers'"); err != nil {
+		return nil, err
+	}
+	if isKafkaConsumersExists == 0 {
+		return offsets, nil
+	}
+	var rows []struct {
+		Topic     string `ch:"topic"`
+		Partition int32  `ch:"partition_id"`
+		Offset    int64  `ch:"current_offset"`
+	}
+	offsetsSQL := "SELECT topic, partition_id, max(current_offset) AS current_offset FROM system.kafka_consumers " +
+		"ARRAY JOIN assignments.topic AS topic, assignments.partition_id AS partition_id, assignments.current_offset AS current_offset " +
+		"WHERE database=? AND table=? AND current_offset >= 0 GROUP BY topic, partition_id ORDER BY topic, partition_id"
+	if err := ch.SelectContext(ctx, &rows, offsetsSQL, database, table); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		offsets = append(offsets, metadata.KafkaOffset{Topic: row.Topic, Partition: row.Partition, Offset: row.Offset})
+	}
+	return offsets, nil
+}
+
+// GetNamedCollections - SQL defined named collections, secret values require `display_secrets_in_show_and_select` and displaySecretsInShowAndSelect grant
+func (ch *ClickHouse) GetNamedCollections(ctx context.Context) ([]NamedCollection, error) {
+	allNamedCollections := make([]NamedCollection, 0)
+	var detectColumns []struct {
+		Name string `ch:"name"`
+	}
+	detectSQL := "SELECT name FROM system.columns WHERE database='system' AND table='named_collections'"
+	if err := ch.SelectContext(ctx, &detectColumns, detectSQL); err != nil {
+		return nil, err
+	}
+	if len(detectColumns) == 0 {
+		return allNamedCollections, nil
+	}
+	namedCollectionsSQL := "SELECT name, collection FROM system.named_collections"
+	isSourceExists := false
+	for _, column := range detectColumns {
+		if column.Name == "source" {
+			isSourceExists = true
+		}
+	}
+	if isSourceExists {
+		namedCollectionsSQL += " WHERE source='SQL'"
+	} else {
+		log.Warn().Msg("system.named_collections doesn't contain `source` column, named collections defined in configuration files will backup too")
+	}
+	namedCollectionsSQL += " ORDER BY name SETTINGS format_display_secrets_in_show_and_select=1"
+	var rows []struct {
+		Name       string            `ch:"name"`
+		Collection map[string]string `ch:"collection"`
+	}
+	if err := ch.SelectContext(ctx, &rows, namedCollectionsSQL); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for key, value := range row.Collection {
+			if value == "[HIDDEN]" {
+				return nil, fmt.Errorf("named collection `%s` key `%s` value is hidden, enable `display_secrets_in_show_and_select` in server configuration and GRANT displaySecretsInShowAndSelect to `%s`", row.Name, key, ch.Config.Username)
+			}
+		}
+		allNamedCollections = append(allNamedCollections, NamedCollection{
+			Name:        row.Name,
+			CreateQuery: BuildNamedCollectionQuery(row.Name, row.Collection),
+		})
+	}
+	return allNamedCollections, nil
+}
+
+// BuildNamedCollectionQuery - CREATE NAMED COLLECTION query with keys in stable order
+func BuildNamedCollectionQuery(name string, collection map[string]string) string {
+	keys := make([]string, 0, len(collection))
+	for key := range collection {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("`%s` = '%s'", key, escapeSingleQuotes(collection[key]))
+	}
+	return fmt.Sprintf("CREATE NAMED COLLECTION `%s` AS %s", name, strings.Join(pairs, ", "))
+}
+
+func escapeSingleQuotes(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+func (ch *ClickHouse) CreateNamedCollection(name string, query string, cluster string) error {
+	dropQuery := fmt.Sprintf("DROP NAMED COLLECTION IF EXISTS `%s`", name)
+	if cluster != "" {
+		dropQuery += fmt.Sprintf(" ON CLUSTER '%s'", cluster)
+		query = strings.Replace(query, " AS ", fmt.Sprintf(" ON CLUSTER '%s' AS ", cluster), 1)
+	}
+	if err := ch.Query(dropQuery); err != nil {
+		return err
+	}
+	return ch.Query(query)
+}
+
+// GetWorkloads - workload scheduling resources and workloads, resources returned first, each workload returned after parent
+func (ch *ClickHouse) GetWorkloads(ctx context.Context) ([]Workload, error) {
+	allWorkloads := make([]Workload, 0)
+	var detectTables []struct {
+		Name string `ch:"name"`
+	}
+	detectSQL := "SELECT name FROM system.tables WHERE database='system' AND name IN ('resources','workloads')"
+	if err := ch.SelectContext(ctx, &detectTables, detectSQL); err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{"resources", "workloads"} {
+		isExists := false
+		for _, t := range detectTables {
+			if t.Name == kind {
+				isExists = true
+			}
+		}
+		if !isExists {
+			continue
+		}
+		var rows []Workload
+		workloadsSQL := "SELECT name, 'RESOURCE' AS kind, '' AS parent, create_query FROM system.resources ORDER BY name"
+		if kind == "workloads" {
+			workloadsSQL = "SELECT name, 'WORKLOAD' AS kind, parent, create_query FROM system.workloads ORDER BY name"
+		}
+		if err := ch.SelectContext(ctx, &rows, workloadsSQL); err != nil {
+			return nil, err
+		}
+		allWorkloads = append(allWorkloads, rows...)
+	}
+	return SortWorkloads(allWorkloads), nil
+}
+
+// SortWorkloads - resources first, then workloads in hierarchy order, parent shall exist before CREATE WORKLOAD ... IN parent
+func SortWorkloads(workloads []Workload) []Workload {
+	sorted := make([]Workload, 0, len(workloads))
+	isAdded := map[string]bool{}
+	for _, w := range workloads {
+		if w.Kind == "RESOURCE" {
+			sorted = append(sorted, w)
+		}
+	}
+	byName := map[string]Workload{}
+	for _, w := range workloads {
+		if w.Kind != "RESOURCE" {
+			byName[w.Name] = w
+		}
+	}
+	var addWorkload func(w Workload)
+	addWorkload = func(w Workload) {
+		if isAdded[w.Name] {
+			return
+		}
+		isAdded[w.Name] = true
+		if parent, exists := byName[w.Parent]; exists && w.Parent != "" {
+			addWorkload(parent)
+		}
+		sorted = append(sorted, w)
+	}
+	for _, w := range workloads {
+		if w.Kind != "RESOURCE" {
+			addWorkload(w)
+		}
+	}
+	return sorted
+}
+
+var workloadQueryRE = regexp.MustCompile(`^CREATE (?:OR REPLACE )?(WORKLOAD|RESOURCE) (\S+)`)
+
+// CreateWorkload - CREATE OR REPLACE keeps children of existing workload
+func (ch *ClickHouse) CreateWorkload(query string, cluster string) error {
+	onCluster := ""
+	if cluster != "" {
+		onCluster = fmt.Sprintf(" ON CLUSTER '%s'", cluster)
+	}
+	query = workloadQueryRE.ReplaceAllString(query, "CREATE OR REPLACE $1 $2"+onCluster)
+	return ch.Query(query)
+}
+
 func (ch *ClickHouse) CalculateMaxFileSize(ctx context.Context, cfg *config.Config) (int64, error) {
 	var rows int64
 	maxSizeQuery := "SELECT max(toInt64(bytes_on_disk * 1.02)) AS max_file_size FROM system.parts"
@@ -118,3 +118,43 @@ func TestExtractStoragePolicy(t *testing.T) {
 		assert.Equal(t, policy, ch.ExtractStoragePolicy(query))
 	}
 }
+
+func TestParseReplicatedDatabaseEngine(t *testing.T) {
+	query := "CREATE DATABASE db\nENGINE = Replicated('/clickhouse/databases/db', '{shard}', '{replica}')\nSETTINGS max_broken_tables_ratio = 1"
+	zookeeperPath, shardName, replicaName, isReplicated := ParseReplicatedDatabaseEngine(query)
+	assert.True(t, isReplicated)
+	assert.Equal(t, "/clickhouse/databases/db", zookeeperPath)
+	assert.Equal(t, "{shard}", shardName)
+	assert.Equal(t, "{replica}", replicaName)
+	assert.Equal(
+		t,
+		"CREATE DATABASE db\nENGINE = Replicated('/clickhouse/databases/db2', 's1', 'r1')\nSETTINGS max_broken_tables_ratio = 1",
+		ReplaceReplicatedDatabaseEngine(query, "/clickhouse/databases/db2", "s1", "r1"),
+	)
+
+	_, _, _, isReplicated = ParseReplicatedDatabaseEngine("CREATE DATABASE db\nENGINE = Atomic")
+	assert.False(t, isReplicated)
+}
+
+func TestBuildNamedCollectionQuery(t *testing.T) {
+	assert.Equal(
+		t,
+		"CREATE NAMED COLLECTION `s3_nc` AS `access_key_id` = 'key', `secret_access_key` = 'it\\'s \\\\secret', `url` = 'https://s3/bucket/'",
+		BuildNamedCollectionQuery("s3_nc", map[string]string{"url": "https://s3/bucket/", "access_key_id": "key", "secret_access_key": `it's \secret`}),
+	)
+}
+
+func TestSortWorkloads(t *testing.T) {
+	workloads := SortWorkloads([]Workload{
+		{Name: "development", Kind: "WORKLOAD", Parent: "all"},
+		{Name: "production", Kind: "WORKLOAD", Parent: "all"},
+		{Name: "all", Kind: "WORKLOAD"},
+		{Name: "network_read", Kind: "RESOURCE"},
+		{Name: "adhoc", Kind: "WORKLOAD", Parent: "development"},
+	})
+	var names []string
+	for _, w := range workloads {
+		names = append(names, w.Name)
+	}
+	assert.Equal(t, []string{"network_read", "all", "development", "production", "adhoc"}, names)
+}
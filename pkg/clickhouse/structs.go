@@ -59,6 +59,20 @@ type Function struct {
 	CreateQuery string `ch:"create_query"`
 }
 
+// NamedCollection - SQL defined named collection from system.named_collections
+type NamedCollection struct {
+	Name        string
+	CreateQuery string
+}
+
+// Workload - workload scheduling entity from system.resources or system.workloads
+type Workload struct {
+	Name        string `ch:"name"`
+	Kind        string `ch:"kind"`
+	Parent      string `ch:"parent"`
+	CreateQuery string `ch:"create_query"`
+}
+
 // Macro - info from system.macros
 type Macro struct {
 	Macro        string `ch:"macro"`
@@ -93,6 +107,23 @@ type BackupDataSize struct {
 	Size uint64 `ch:"backup_data_size"`
 }
 
+// ActivePart - info from system.parts for `create --since`
+type ActivePart struct {
+	Name             string    `ch:"name"`
+	PartitionID      string    `ch:"partition_id"`
+	DiskName         string    `ch:"disk_name"`
+	ModificationTime time.Time `ch:"modification_time"`
+	MaxBlockNumber   int64     `ch:"max_block_number"`
+}
+
+// PartitionMaxBlock - max block number of active parts from system.parts, used as backup watermark
+type PartitionMaxBlock struct {
+	Database       string `ch:"database"`
+	Table          string `ch:"table"`
+	PartitionID    string `ch:"partition_id"`
+	MaxBlockNumber int64  `ch:"max_block_number"`
+}
+
 type UserDirectory struct {
 	Name string `ch:"name"`
 }
@@ -0,0 +1,700 @@
+// Package client - typed Go client for `clickhouse-backup server` REST API, see /openapi.json for specification
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client - REST API client, all methods are safe for concurrent use
+type Client struct {
+	baseURL    *url.URL
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithBasicAuth - pass api.username and api.password
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithHTTPClient - use custom http.Client, for example with TLS client certificates
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New - create client for API server, baseURL like http://127.0.0.1:7171
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("can't parse baseURL %s: %w", baseURL, err)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// APIError - API server return non 2xx status
+type APIError struct {
+	StatusCode int
+	Status     string `json:"status"`
+	Operation  string `json:"operation"`
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("clickhouse-backup API status=%d operation=%s error: %s", e.StatusCode, e.Operation, e.Message)
+}
+
+// IsLocked - another operation is currently running and `allow_parallel: false`
+func (e *APIError) IsLocked() bool {
+	return e.StatusCode == http.StatusLocked
+}
+
+// IsQueueFull - operation queue overflow or queued operation canceled by API server restart, could be retried later
+func (e *APIError) IsQueueFull() bool {
+	return e.StatusCode == http.StatusServiceUnavailable
+}
+
+// OperationStatus - response for sync commands and `/backup/actions`
+type OperationStatus struct {
+	Status      string `json:"status"`
+	Operation   string `json:"operation"`
+	Command     string `json:"command,omitempty"`
+	Error       string `json:"error,omitempty"`
+	OperationId string `json:"operation_id,omitempty"`
+}
+
+// BackupOperation - response for async commands create, upload, download, restore
+type BackupOperation struct {
+	Status      string `json:"status"`
+	Operation   string `json:"operation"`
+	BackupName  string `json:"backup_name"`
+	BackupFrom  string `json:"backup_from,omitempty"`
+	Diff        bool   `json:"diff,omitempty"`
+	OperationId string `json:"operation_id"`
+}
+
+// DeleteStatus - response for `/backup/delete/{where}/{name}`
+type DeleteStatus struct {
+	Status     string `json:"status"`
+	Operation  string `json:"operation"`
+	BackupName string `json:"backup_name"`
+	Location   string `json:"location"`
+}
+
+// Backup - row from `/backup/list`
+type Backup struct {
+	Name           string `json:"name"`
+	Created        string `json:"created"`
+	Size           uint64 `json:"size,omitempty"`
+	Location       string `json:"location"`
+	RequiredBackup string `json:"required"`
+	Desc           string `json:"desc"`
+}
+
+// Table - row from `/backup/tables`
+type Table struct {
+	Database         string
+	Name             string
+	Engine           string
+	DataPath         string
+	DataPaths        []string
+	UUID             string
+	CreateTableQuery string
+	TotalBytes       uint64
+	Skip             bool
+	BackupType       string
+}
+
+// ActionStatus - row from `/backup/status` and `/backup/actions`
+type ActionStatus struct {
+	Id          int    `json:"id"`
+	Command     string `json:"command"`
+	Status      string `json:"status"`
+	Start       string `json:"start,omitempty"`
+	Finish      string `json:"finish,omitempty"`
+	Error       string `json:"error,omitempty"`
+	OperationId string `json:"operation_id,omitempty"`
+	Step        string `json:"step,omitempty"`
+}
+
+// QueueItem - row from `/backup/queue`
+type QueueItem struct {
+	Id          int    `json:"id"`
+	Command     string `json:"command"`
+	Operation   string `json:"operation"`
+	Priority    int    `json:"priority"`
+	Status      string `json:"status"`
+	Enqueued    string `json:"enqueued"`
+	Start       string `json:"start,omitempty"`
+	OperationId string `json:"operation_id,omitempty"`
+}
+
+// CallbackResponse - payload which API server POST to `callback` URL
+type CallbackResponse struct {
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+	OperationId string `json:"operation_id"`
+}
+
+// CreateOptions - same as `clickhouse-backup create` CLI flags
+type CreateOptions struct {
+	Name                  string
+	Tables                string
+	Partitions            []string
+	DiffFromRemote        string
+	Since                 string
+	Schema                bool
+	RBAC                  bool
+	RBACOnly              bool
+	Configs               bool
+	ConfigsOnly           bool
+	Keeper                bool
+	NamedCollections      bool
+	SkipCheckPartsColumns bool
+	Resume                bool
+	Callbacks             []string
+	// Storage - name of storage from `storages` config section, main remote storage when empty
+	Storage string
+	// Priority - position in API server operation queue, higher priority starts first
+	Priority int
+}
+
+func (o CreateOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "name", o.Name)
+	setString(q, "table", o.Tables)
+	setString(q, "diff-from-remote", o.DiffFromRemote)
+	setString(q, "since", o.Since)
+	setSlice(q, "partitions", o.Partitions)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "rbac", o.RBAC)
+	setBool(q, "rbac-only", o.RBACOnly)
+	setBool(q, "configs", o.Configs)
+	setBool(q, "configs-only", o.ConfigsOnly)
+	setBool(q, "keeper", o.Keeper)
+	setBool(q, "named-collections", o.NamedCollections)
+	setBool(q, "skip-check-parts-columns", o.SkipCheckPartsColumns)
+	setBool(q, "resume", o.Resume)
+	setString(q, "storage", o.Storage)
+	setSlice(q, "callback", o.Callbacks)
+	setInt(q, "priority", o.Priority)
+	return q
+}
+
+// UploadOptions - same as `clickhouse-backup upload` CLI flags
+type UploadOptions struct {
+	Tables         string
+	Partitions     []string
+	DiffFrom       string
+	DiffFromRemote string
+	Schema         bool
+	Resume         bool
+	DeleteSource   bool
+	Callbacks      []string
+	// Storage - name of storage from `storages` config section, main remote storage when empty
+	Storage string
+	// Priority - position in API server operation queue, higher priority starts first
+	Priority int
+}
+
+func (o UploadOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "table", o.Tables)
+	setSlice(q, "partitions", o.Partitions)
+	setString(q, "diff-from", o.DiffFrom)
+	setString(q, "diff-from-remote", o.DiffFromRemote)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "resume", o.Resume)
+	setBool(q, "delete-source", o.DeleteSource)
+	setString(q, "storage", o.Storage)
+	setSlice(q, "callback", o.Callbacks)
+	setInt(q, "priority", o.Priority)
+	return q
+}
+
+// DownloadOptions - same as `clickhouse-backup download` CLI flags
+type DownloadOptions struct {
+	Tables     string
+	Partitions []string
+	Schema     bool
+	Resume     bool
+	Callbacks  []string
+	// Storage - name of storage from `storages` config section, main remote storage when empty
+	Storage string
+	// Priority - position in API server operation queue, higher priority starts first
+	Priority int
+}
+
+func (o DownloadOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "table", o.Tables)
+	setSlice(q, "partitions", o.Partitions)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "resume", o.Resume)
+	setString(q, "storage", o.Storage)
+	setSlice(q, "callback", o.Callbacks)
+	setInt(q, "priority", o.Priority)
+	return q
+}
+
+// RestoreOptions - same as `clickhouse-backup restore` CLI flags
+type RestoreOptions struct {
+	Tables             string
+	Partitions         []string
+	DatabaseMapping    []string
+	TableMapping       []string
+	Schema             bool
+	Data               bool
+	Drop               bool
+	IgnoreDependencies bool
+	RBAC               bool
+	RBACOnly           bool
+	Configs            bool
+	ConfigsOnly        bool
+	Keeper             bool
+	NamedCollections   bool
+	Resume             bool
+	Callbacks          []string
+	// Storage - name of storage from `storages` config section, used only by RestoreRemote
+	Storage string
+	// Priority - position in API server operation queue, higher priority starts first
+	Priority int
+}
+
+func (o RestoreOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "table", o.Tables)
+	setSlice(q, "partitions", o.Partitions)
+	setSlice(q, "restore_database_mapping", o.DatabaseMapping)
+	setSlice(q, "restore_table_mapping", o.TableMapping)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "data", o.Data)
+	setBool(q, "drop", o.Drop)
+	setBool(q, "ignore_dependencies", o.IgnoreDependencies)
+	setBool(q, "rbac", o.RBAC)
+	setBool(q, "rbac-only", o.RBACOnly)
+	setBool(q, "configs", o.Configs)
+	setBool(q, "configs-only", o.ConfigsOnly)
+	setBool(q, "keeper", o.Keeper)
+	setBool(q, "named-collections", o.NamedCollections)
+	setBool(q, "resume", o.Resume)
+	setString(q, "storage", o.Storage)
+	setSlice(q, "callback", o.Callbacks)
+	setInt(q, "priority", o.Priority)
+	return q
+}
+
+// CreateRemoteOptions - same as `clickhouse-backup create_remote` CLI flags
+type CreateRemoteOptions struct {
+	Name                  string
+	Tables                string
+	Partitions            []string
+	DiffFrom              string
+	DiffFromRemote        string
+	Since                 string
+	Schema                bool
+	RBAC                  bool
+	RBACOnly              bool
+	Configs               bool
+	ConfigsOnly           bool
+	Keeper                bool
+	NamedCollections      bool
+	SkipCheckPartsColumns bool
+	Resume                bool
+	DeleteSource          bool
+	Callbacks             []string
+	// Storages - names of storages from `storages` config section, the same backup uploads to each storage
+	Storages []string
+	// Priority - position in API server operation queue, higher priority starts first
+	Priority int
+}
+
+func (o CreateRemoteOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "name", o.Name)
+	setString(q, "table", o.Tables)
+	setSlice(q, "partitions", o.Partitions)
+	setString(q, "diff-from", o.DiffFrom)
+	setString(q, "diff-from-remote", o.DiffFromRemote)
+	setString(q, "since", o.Since)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "rbac", o.RBAC)
+	setBool(q, "rbac-only", o.RBACOnly)
+	setBool(q, "configs", o.Configs)
+	setBool(q, "configs-only", o.ConfigsOnly)
+	setBool(q, "keeper", o.Keeper)
+	setBool(q, "named-collections", o.NamedCollections)
+	setBool(q, "skip-check-parts-columns", o.SkipCheckPartsColumns)
+	setBool(q, "resume", o.Resume)
+	setBool(q, "delete-source", o.DeleteSource)
+	setString(q, "storage", strings.Join(o.Storages, ","))
+	setSlice(q, "callback", o.Callbacks)
+	setInt(q, "priority", o.Priority)
+	return q
+}
+
+// WatchOptions - same as `clickhouse-backup watch` CLI flags
+type WatchOptions struct {
+	WatchInterval           string
+	FullInterval            string
+	WatchBackupNameTemplate string
+	Tables                  string
+	Partitions              []string
+	Schema                  bool
+	RBAC                    bool
+	Configs                 bool
+	Keeper                  bool
+	NamedCollections        bool
+	SkipCheckPartsColumns   bool
+	// Storages - names of storages from `storages` config section, each backup uploads to all of them
+	Storages []string
+}
+
+func (o WatchOptions) query() url.Values {
+	q := url.Values{}
+	setString(q, "watch_interval", o.WatchInterval)
+	setString(q, "full_interval", o.FullInterval)
+	setString(q, "watch_backup_name_template", o.WatchBackupNameTemplate)
+	setString(q, "table", o.Tables)
+	setSlice(q, "partitions", o.Partitions)
+	setBool(q, "schema", o.Schema)
+	setBool(q, "rbac", o.RBAC)
+	setBool(q, "configs", o.Configs)
+	setBool(q, "keeper", o.Keeper)
+	setBool(q, "named_collections", o.NamedCollections)
+	setBool(q, "skip_check_parts_columns", o.SkipCheckPartsColumns)
+	setString(q, "storage", strings.Join(o.Storages, ","))
+	return q
+}
+
+func setString(q url.Values, name, value string) {
+	if value != "" {
+		q.Set(name, value)
+	}
+}
+
+func setSlice(q url.Values, name string, values []string) {
+	for _, v := range values {
+		q.Add(name, v)
+	}
+}
+
+func setInt(q url.Values, name string, value int) {
+	if value != 0 {
+		q.Set(name, strconv.Itoa(value))
+	}
+}
+
+// setBool - API server check only presence of boolean query parameters
+func setBool(q url.Values, name string, value bool) {
+	if value {
+		q.Set(name, "1")
+	}
+}
+
+// Version - GET /backup/version
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var rows []struct {
+		Version string `json:"version"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/backup/version", nil, nil, &rows); err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("empty /backup/version response")
+	}
+	return rows[0].Version, nil
+}
+
+// Tables - GET /backup/tables or /backup/tables/all when all=true
+func (c *Client) Tables(ctx context.Context, tablePattern, remoteBackup string, all bool) ([]Table, error) {
+	q := url.Values{}
+	setString(q, "table", tablePattern)
+	setString(q, "remote_backup", remoteBackup)
+	endpoint := "/backup/tables"
+	if all {
+		endpoint = "/backup/tables/all"
+	}
+	var rows []Table
+	err := c.do(ctx, http.MethodGet, endpoint, q, nil, &rows)
+	return rows, err
+}
+
+// List - GET /backup/list/{where}, where could be empty, `local` or `remote`
+func (c *Client) List(ctx context.Context, where string) ([]Backup, error) {
+	endpoint := "/backup/list"
+	if where != "" {
+		endpoint += "/" + url.PathEscape(where)
+	}
+	var rows []Backup
+	err := c.do(ctx, http.MethodGet, endpoint, nil, nil, &rows)
+	return rows, err
+}
+
+// Create - POST /backup/create, asynchronous, use Status or callback to wait result
+func (c *Client) Create(ctx context.Context, opts CreateOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/create", opts.query())
+}
+
+// Upload - POST /backup/upload/{name}, asynchronous
+func (c *Client) Upload(ctx context.Context, name string, opts UploadOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/upload/"+url.PathEscape(name), opts.query())
+}
+
+// Download - POST /backup/download/{name}, asynchronous
+func (c *Client) Download(ctx context.Context, name string, opts DownloadOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/download/"+url.PathEscape(name), opts.query())
+}
+
+// Restore - POST /backup/restore/{name}, asynchronous
+func (c *Client) Restore(ctx context.Context, name string, opts RestoreOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/restore/"+url.PathEscape(name), opts.query())
+}
+
+// CreateRemote - POST /backup/create_remote, asynchronous, `step` field of Status show current sub-step
+func (c *Client) CreateRemote(ctx context.Context, opts CreateRemoteOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/create_remote", opts.query())
+}
+
+// RestoreRemote - POST /backup/restore_remote/{name}, asynchronous, accept the same options as Restore
+func (c *Client) RestoreRemote(ctx context.Context, name string, opts RestoreOptions) (*BackupOperation, error) {
+	return c.backupOperation(ctx, "/backup/restore_remote/"+url.PathEscape(name), opts.query())
+}
+
+func (c *Client) backupOperation(ctx context.Context, endpoint string, q url.Values) (*BackupOperation, error) {
+	var rows []BackupOperation
+	if err := c.do(ctx, http.MethodPost, endpoint, q, nil, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty %s response", endpoint)
+	}
+	return &rows[0], nil
+}
+
+// Delete - POST /backup/delete/{where}/{name}, where is `local` or `remote`
+func (c *Client) Delete(ctx context.Context, where, name string) (*DeleteStatus, error) {
+	var rows []DeleteStatus
+	if err := c.do(ctx, http.MethodPost, "/backup/delete/"+url.PathEscape(where)+"/"+url.PathEscape(name), nil, nil, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty /backup/delete response")
+	}
+	return &rows[0], nil
+}
+
+// Clean - POST /backup/clean
+func (c *Client) Clean(ctx context.Context) (*OperationStatus, error) {
+	return c.operationStatus(ctx, http.MethodPost, "/backup/clean", nil)
+}
+
+// CleanRemoteBroken - POST /backup/clean/remote_broken
+func (c *Client) CleanRemoteBroken(ctx context.Context) (*OperationStatus, error) {
+	return c.operationStatus(ctx, http.MethodPost, "/backup/clean/remote_broken", nil)
+}
+
+// Kill - POST /backup/kill, kill first `in progress` command when command is empty
+func (c *Client) Kill(ctx context.Context, command string) (*OperationStatus, error) {
+	q := url.Values{}
+	setString(q, "command", command)
+	return c.operationStatus(ctx, http.MethodPost, "/backup/kill", q)
+}
+
+// Watch - POST /backup/watch, asynchronous
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) (*OperationStatus, error) {
+	return c.operationStatus(ctx, http.MethodPost, "/backup/watch", opts.query())
+}
+
+// Restart - POST /restart
+func (c *Client) Restart(ctx context.Context) (*OperationStatus, error) {
+	return c.operationStatus(ctx, http.MethodPost, "/restart", nil)
+}
+
+func (c *Client) operationStatus(ctx context.Context, method, endpoint string, q url.Values) (*OperationStatus, error) {
+	var rows []OperationStatus
+	if err := c.do(ctx, method, endpoint, q, nil, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty %s response", endpoint)
+	}
+	return &rows[0], nil
+}
+
+// Status - GET /backup/status, return last command
+func (c *Client) Status(ctx context.Context) ([]ActionStatus, error) {
+	var rows []ActionStatus
+	err := c.do(ctx, http.MethodGet, "/backup/status", nil, nil, &rows)
+	return rows, err
+}
+
+// Queue - GET /backup/queue, running and pending operations when `api->queue_enabled: true`
+func (c *Client) Queue(ctx context.Context) ([]QueueItem, error) {
+	var rows []QueueItem
+	err := c.do(ctx, http.MethodGet, "/backup/queue", nil, nil, &rows)
+	return rows, err
+}
+
+// ActionsLog - GET /backup/actions, last=0 means all commands
+func (c *Client) ActionsLog(ctx context.Context, filter string, last int) ([]ActionStatus, error) {
+	q := url.Values{}
+	setString(q, "filter", filter)
+	if last > 0 {
+		q.Set("last", strconv.Itoa(last))
+	}
+	var rows []ActionStatus
+	err := c.do(ctx, http.MethodGet, "/backup/actions", q, nil, &rows)
+	return rows, err
+}
+
+// Actions - POST /backup/actions, each command is a CLI command line, like `create_remote backup_name`
+func (c *Client) Actions(ctx context.Context, commands ...string) ([]OperationStatus, error) {
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	for _, command := range commands {
+		if err := encoder.Encode(struct {
+			Command string `json:"command"`
+		}{Command: command}); err != nil {
+			return nil, err
+		}
+	}
+	var rows []OperationStatus
+	err := c.do(ctx, http.MethodPost, "/backup/actions", nil, body, &rows)
+	return rows, err
+}
+
+// StreamEvent - one Server-Sent Event from /backup/actions/{id}/stream,
+// Event is `status`, `log`, `progress` or `end`, Data is JSON object
+type StreamEvent struct {
+	Event string
+	Data  json.RawMessage
+}
+
+// Stream - GET /backup/actions/{id}/stream, id is `operation_id` or ActionStatus.Id,
+// call handler for each event until command finished, handler error stops streaming
+func (c *Client) Stream(ctx context.Context, id string, handler func(StreamEvent) error) error {
+	resp, err := c.request(ctx, http.MethodGet, "/backup/actions/"+url.PathEscape(id)+"/stream", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	event := StreamEvent{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.Event == "" {
+				continue
+			}
+			if err = handler(event); err != nil {
+				return err
+			}
+			if event.Event == "end" {
+				return nil
+			}
+			event = StreamEvent{}
+		case strings.HasPrefix(line, ":"):
+			// heartbeat comment
+		case strings.HasPrefix(line, "event: "):
+			event.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.Data = append(event.Data, strings.TrimPrefix(line, "data: ")...)
+		}
+	}
+	return scanner.Err()
+}
+
+// do - execute request and decode JSONEachRow response into pointer to slice
+func (c *Client) do(ctx context.Context, method, endpoint string, q url.Values, body io.Reader, rows interface{}) error {
+	resp, err := c.request(ctx, method, endpoint, q, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return decodeJSONEachRow(resp.Body, rows)
+}
+
+// request - execute request, return *APIError for non 2xx status
+func (c *Client) request(ctx context.Context, method, endpoint string, q url.Values, body io.Reader) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + endpoint
+	if len(q) > 0 {
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		respBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if jsonErr := json.Unmarshal(bytes.TrimSpace(respBody), apiErr); jsonErr != nil {
+			apiErr.Message = strings.TrimSpace(string(respBody))
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// decodeJSONEachRow - decode one JSON object per line, rows shall be pointer to slice
+func decodeJSONEachRow(r io.Reader, rows interface{}) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	items := make([]json.RawMessage, 0)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items = append(items, append(json.RawMessage{}, line...))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	arr, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(arr, rows); err != nil {
+		return fmt.Errorf("can't decode JSONEachRow response: %w", err)
+	}
+	return nil
+}
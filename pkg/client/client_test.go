@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	r := require.New(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/backup/list/remote", func(w http.ResponseWriter, req *http.Request) {
+		user, pass, _ := req.BasicAuth()
+		if user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"name":"b1","created":"2024-01-01 00:00:00","size":100,"location":"remote","required":"","desc":"tar"}`)
+		_, _ = fmt.Fprintln(w, `{"name":"b2","created":"2024-01-02 00:00:00","size":10,"location":"remote","required":"b1","desc":"tar"}`)
+	})
+	mux.HandleFunc("/backup/restore/b2", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("table") != "db.*" || len(q["partitions"]) != 2 || q.Get("rbac") == "" || q.Get("schema") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(w, `{"status":"error","operation":"restore","error":"unexpected query `+req.URL.RawQuery+`"}`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"status":"acknowledged","operation":"restore","backup_name":"b2","operation_id":"uuid"}`)
+	})
+	mux.HandleFunc("/backup/create", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusLocked)
+		_, _ = fmt.Fprintln(w, `{"status":"error","operation":"create","error":"another operation is currently running"}`)
+	})
+	mux.HandleFunc("/backup/actions", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != "{\"command\":\"create b3\"}\n{\"command\":\"upload b3\"}\n" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"status":"acknowledged","operation":"create b3"}`)
+		_, _ = fmt.Fprintln(w, `{"status":"acknowledged","operation":"upload b3"}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := New(srv.URL+"/", WithBasicAuth("user", "pass"))
+	r.NoError(err)
+	ctx := context.Background()
+
+	backups, err := c.List(ctx, "remote")
+	r.NoError(err)
+	r.Len(backups, 2)
+	r.Equal("b1", backups[1].RequiredBackup)
+	r.Equal(uint64(100), backups[0].Size)
+
+	op, err := c.Restore(ctx, "b2", RestoreOptions{Tables: "db.*", Partitions: []string{"1", "2"}, RBAC: true})
+	r.NoError(err)
+	r.Equal("uuid", op.OperationId)
+
+	_, err = c.Create(ctx, CreateOptions{Name: "b3"})
+	var apiErr *APIError
+	r.True(errors.As(err, &apiErr))
+	r.True(apiErr.IsLocked())
+	r.Equal("create", apiErr.Operation)
+
+	results, err := c.Actions(ctx, "create b3", "upload b3")
+	r.NoError(err)
+	r.Len(results, 2)
+	r.Equal("upload b3", results[1].Operation)
+}
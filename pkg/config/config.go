@@ -3,10 +3,13 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"maps"
 	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,16 +27,89 @@ const (
 
 // Config - config file format
 type Config struct {
-	General    GeneralConfig    `yaml:"general" envconfig:"_"`
-	ClickHouse ClickHouseConfig `yaml:"clickhouse" envconfig:"_"`
-	S3         S3Config         `yaml:"s3" envconfig:"_"`
-	GCS        GCSConfig        `yaml:"gcs" envconfig:"_"`
-	COS        COSConfig        `yaml:"cos" envconfig:"_"`
-	API        APIConfig        `yaml:"api" envconfig:"_"`
-	FTP        FTPConfig        `yaml:"ftp" envconfig:"_"`
-	SFTP       SFTPConfig       `yaml:"sftp" envconfig:"_"`
-	AzureBlob  AzureBlobConfig  `yaml:"azblob" envconfig:"_"`
-	Custom     CustomConfig     `yaml:"custom" envconfig:"_"`
+	General    GeneralConfig        `yaml:"general" envconfig:"_"`
+	ClickHouse ClickHouseConfig     `yaml:"clickhouse" envconfig:"_"`
+	S3         S3Config             `yaml:"s3" envconfig:"_"`
+	GCS        GCSConfig            `yaml:"gcs" envconfig:"_"`
+	COS        COSConfig            `yaml:"cos" envconfig:"_"`
+	API        APIConfig            `yaml:"api" envconfig:"_"`
+	FTP        FTPConfig            `yaml:"ftp" envconfig:"_"`
+	SFTP       SFTPConfig           `yaml:"sftp" envconfig:"_"`
+	AzureBlob  AzureBlobConfig      `yaml:"azblob" envconfig:"_"`
+	Custom     CustomConfig         `yaml:"custom" envconfig:"_"`
+	Tables     TablePolicies        `yaml:"tables" ignored:"true"`
+	Storages   map[string]yaml.Node `yaml:"storages" ignored:"true"`
+	// StorageName - name from `storages` section which applied by --storage, empty for general->remote_storage
+	StorageName string `yaml:"-" ignored:"true"`
+	// mainConfig - config before --storage applied, other named storages override it instead of current storage
+	mainConfig *Config
+}
+
+// storageProfile - keys allowed inside `storages` items, they override the same keys of main config
+type storageProfile struct {
+	RemoteStorage       *string          `yaml:"remote_storage"`
+	BackupsToKeepRemote *int             `yaml:"backups_to_keep_remote"`
+	S3                  *S3Config        `yaml:"s3"`
+	GCS                 *GCSConfig       `yaml:"gcs"`
+	COS                 *COSConfig       `yaml:"cos"`
+	FTP                 *FTPConfig       `yaml:"ftp"`
+	SFTP                *SFTPConfig      `yaml:"sftp"`
+	AzureBlob           *AzureBlobConfig `yaml:"azblob"`
+	Custom              *CustomConfig    `yaml:"custom"`
+}
+
+var storageNameRE = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// WithStorage - copy of config where named storage from `storages` section overrides general->remote_storage and remote storage sections
+func (cfg *Config) WithStorage(name string) (*Config, error) {
+	if name == "" {
+		return cfg, nil
+	}
+	mainConfig := cfg
+	if cfg.mainConfig != nil {
+		mainConfig = cfg.mainConfig
+	}
+	storageNode, exists := mainConfig.Storages[name]
+	if !exists {
+		return nil, fmt.Errorf("storage `%s` not found in `storages` config section", name)
+	}
+	storageCfg := *mainConfig
+	storageCfg.mainConfig = mainConfig
+	// maps shall not be shared with main config, yaml decoder merges keys into existing map
+	storageCfg.S3.CustomStorageClassMap = maps.Clone(mainConfig.S3.CustomStorageClassMap)
+	storageCfg.S3.ObjectLabels = maps.Clone(mainConfig.S3.ObjectLabels)
+	storageCfg.GCS.CustomStorageClassMap = maps.Clone(mainConfig.GCS.CustomStorageClassMap)
+	storageCfg.GCS.ObjectLabels = maps.Clone(mainConfig.GCS.ObjectLabels)
+	profile := storageProfile{
+		RemoteStorage:       &storageCfg.General.RemoteStorage,
+		BackupsToKeepRemote: &storageCfg.General.BackupsToKeepRemote,
+		S3:                  &storageCfg.S3,
+		GCS:                 &storageCfg.GCS,
+		COS:                 &storageCfg.COS,
+		FTP:                 &storageCfg.FTP,
+		SFTP:                &storageCfg.SFTP,
+		AzureBlob:           &storageCfg.AzureBlob,
+		Custom:              &storageCfg.Custom,
+	}
+	if err := storageNode.Decode(&profile); err != nil {
+		return nil, fmt.Errorf("can't parse `storages->%s`: %v", name, err)
+	}
+	storageCfg.StorageName = name
+	storageCfg.trimStoragePaths()
+	if err := ValidateConfig(&storageCfg); err != nil {
+		return nil, fmt.Errorf("`storages->%s`: %v", name, err)
+	}
+	return &storageCfg, nil
+}
+
+// GetStorageNames - names of all storages from `storages` config section in alphabetical order
+func (cfg *Config) GetStorageNames() []string {
+	names := make([]string, 0, len(cfg.Storages))
+	for name := range cfg.Storages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // GeneralConfig - general setting section
@@ -51,13 +127,19 @@ type GeneralConfig struct {
 	ObjectDiskServerSideCopyConcurrency uint8             `yaml:"object_disk_server_side_copy_concurrency" envconfig:"OBJECT_DISK_SERVER_SIDE_COPY_CONCURRENCY"`
 	AllowObjectDiskStreaming            bool              `yaml:"allow_object_disk_streaming" envconfig:"ALLOW_OBJECT_DISK_STREAMING"`
 	UseResumableState                   bool              `yaml:"use_resumable_state" envconfig:"USE_RESUMABLE_STATE"`
+	ResumableStateCheckpointInterval    string            `yaml:"resumable_state_checkpoint_interval" envconfig:"RESUMABLE_STATE_CHECKPOINT_INTERVAL"`
 	RestoreSchemaOnCluster              string            `yaml:"restore_schema_on_cluster" envconfig:"RESTORE_SCHEMA_ON_CLUSTER"`
+	RestoreReshardCluster               string            `yaml:"restore_reshard_cluster" envconfig:"RESTORE_RESHARD_CLUSTER"`
+	RestoreReshardShardingKey           string            `yaml:"restore_reshard_sharding_key" envconfig:"RESTORE_RESHARD_SHARDING_KEY"`
+	DataFormat                          string            `yaml:"data_format" envconfig:"DATA_FORMAT"`
+	ContentAddressedParts               bool              `yaml:"content_addressed_parts" envconfig:"CONTENT_ADDRESSED_PARTS"`
 	UploadByPart                        bool              `yaml:"upload_by_part" envconfig:"UPLOAD_BY_PART"`
 	DownloadByPart                      bool              `yaml:"download_by_part" envconfig:"DOWNLOAD_BY_PART"`
 	RestoreDatabaseMapping              map[string]string `yaml:"restore_database_mapping" envconfig:"RESTORE_DATABASE_MAPPING"`
 	RestoreTableMapping                 map[string]string `yaml:"restore_table_mapping" envconfig:"RESTORE_TABLE_MAPPING"`
 	RetriesOnFailure                    int               `yaml:"retries_on_failure" envconfig:"RETRIES_ON_FAILURE"`
 	RetriesPause                        string            `yaml:"retries_pause" envconfig:"RETRIES_PAUSE"`
+	AbortStaleUploadsAfter              string            `yaml:"abort_stale_uploads_after" envconfig:"ABORT_STALE_UPLOADS_AFTER"`
 	WatchInterval                       string            `yaml:"watch_interval" envconfig:"WATCH_INTERVAL"`
 	FullInterval                        string            `yaml:"full_interval" envconfig:"FULL_INTERVAL"`
 	WatchBackupNameTemplate             string            `yaml:"watch_backup_name_template" envconfig:"WATCH_BACKUP_NAME_TEMPLATE"`
@@ -66,7 +148,13 @@ type GeneralConfig struct {
 	IONicePriority                      string            `yaml:"io_nice_priority" envconfig:"IO_NICE_PRIORITY"`
 	RBACBackupAlways                    bool              `yaml:"rbac_backup_always" envconfig:"RBAC_BACKUP_ALWAYS"`
 	RBACConflictResolution              string            `yaml:"rbac_conflict_resolution" envconfig:"RBAC_CONFLICT_RESOLUTION"`
+	KeeperPaths                         []string          `yaml:"keeper_paths" envconfig:"KEEPER_PATHS"`
+	RestoreKeeperPathMapping            map[string]string `yaml:"restore_keeper_path_mapping" envconfig:"RESTORE_KEEPER_PATH_MAPPING"`
+	KeeperDumpCompression               string            `yaml:"keeper_dump_compression" envconfig:"KEEPER_DUMP_COMPRESSION"`
+	KeeperRestoreMode                   string            `yaml:"keeper_restore_mode" envconfig:"KEEPER_RESTORE_MODE"`
 	RetriesDuration                     time.Duration
+	AbortStaleUploadsDuration           time.Duration
+	ResumableStateCheckpointDuration    time.Duration
 	WatchDuration                       time.Duration
 	FullDuration                        time.Duration
 }
@@ -183,17 +271,23 @@ type FTPConfig struct {
 
 // SFTPConfig - sftp settings section
 type SFTPConfig struct {
-	Address           string `yaml:"address" envconfig:"SFTP_ADDRESS"`
-	Port              uint   `yaml:"port" envconfig:"SFTP_PORT"`
-	Username          string `yaml:"username" envconfig:"SFTP_USERNAME"`
-	Password          string `yaml:"password" envconfig:"SFTP_PASSWORD"`
-	Key               string `yaml:"key" envconfig:"SFTP_KEY"`
-	Path              string `yaml:"path" envconfig:"SFTP_PATH"`
-	ObjectDiskPath    string `yaml:"object_disk_path" envconfig:"SFTP_OBJECT_DISK_PATH"`
-	CompressionFormat string `yaml:"compression_format" envconfig:"SFTP_COMPRESSION_FORMAT"`
-	CompressionLevel  int    `yaml:"compression_level" envconfig:"SFTP_COMPRESSION_LEVEL"`
-	Concurrency       int    `yaml:"concurrency" envconfig:"SFTP_CONCURRENCY"`
-	Debug             bool   `yaml:"debug" envconfig:"SFTP_DEBUG"`
+	Address               string `yaml:"address" envconfig:"SFTP_ADDRESS"`
+	Port                  uint   `yaml:"port" envconfig:"SFTP_PORT"`
+	Username              string `yaml:"username" envconfig:"SFTP_USERNAME"`
+	Password              string `yaml:"password" envconfig:"SFTP_PASSWORD"`
+	Key                   string `yaml:"key" envconfig:"SFTP_KEY"`
+	KeyPassphrase         string `yaml:"key_passphrase" envconfig:"SFTP_KEY_PASSPHRASE"`
+	Certificate           string `yaml:"certificate" envconfig:"SFTP_CERTIFICATE"`
+	UseAgent              bool   `yaml:"use_agent" envconfig:"SFTP_USE_AGENT"`
+	KnownHosts            string `yaml:"known_hosts" envconfig:"SFTP_KNOWN_HOSTS"`
+	HostKeyFingerprint    string `yaml:"host_key_fingerprint" envconfig:"SFTP_HOST_KEY_FINGERPRINT"`
+	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key" envconfig:"SFTP_INSECURE_IGNORE_HOST_KEY"`
+	Path                  string `yaml:"path" envconfig:"SFTP_PATH"`
+	ObjectDiskPath        string `yaml:"object_disk_path" envconfig:"SFTP_OBJECT_DISK_PATH"`
+	CompressionFormat     string `yaml:"compression_format" envconfig:"SFTP_COMPRESSION_FORMAT"`
+	CompressionLevel      int    `yaml:"compression_level" envconfig:"SFTP_COMPRESSION_LEVEL"`
+	Concurrency           int    `yaml:"concurrency" envconfig:"SFTP_CONCURRENCY"`
+	Debug                 bool   `yaml:"debug" envconfig:"SFTP_DEBUG"`
 }
 
 // CustomConfig - custom CLI storage settings section
@@ -233,6 +327,18 @@ type ClickHouseConfig struct {
 	CheckReplicasBeforeAttach        bool              `yaml:"check_replicas_before_attach" envconfig:"CLICKHOUSE_CHECK_REPLICAS_BEFORE_ATTACH"`
 	DefaultReplicaPath               string            `yaml:"default_replica_path" envconfig:"CLICKHOUSE_DEFAULT_REPLICA_PATH"`
 	DefaultReplicaName               string            `yaml:"default_replica_name" envconfig:"CLICKHOUSE_DEFAULT_REPLICA_NAME"`
+	DefaultReplicatedDatabasePath    string            `yaml:"default_replicated_database_path" envconfig:"CLICKHOUSE_DEFAULT_REPLICATED_DATABASE_PATH"`
+	DefaultReplicatedDatabaseShard   string            `yaml:"default_replicated_database_shard" envconfig:"CLICKHOUSE_DEFAULT_REPLICATED_DATABASE_SHARD"`
+	DefaultReplicatedDatabaseReplica string            `yaml:"default_replicated_database_replica" envconfig:"CLICKHOUSE_DEFAULT_REPLICATED_DATABASE_REPLICA"`
+	BackupConsumerState              bool              `yaml:"backup_consumer_state" envconfig:"CLICKHOUSE_BACKUP_CONSUMER_STATE"`
+	RestoreConsumerState             bool              `yaml:"restore_consumer_state" envconfig:"CLICKHOUSE_RESTORE_CONSUMER_STATE"`
+	KafkaSASLMechanism               string            `yaml:"kafka_sasl_mechanism" envconfig:"CLICKHOUSE_KAFKA_SASL_MECHANISM"`
+	KafkaSASLUsername                string            `yaml:"kafka_sasl_username" envconfig:"CLICKHOUSE_KAFKA_SASL_USERNAME"`
+	KafkaSASLPassword                string            `yaml:"kafka_sasl_password" envconfig:"CLICKHOUSE_KAFKA_SASL_PASSWORD"`
+	KafkaTLS                         bool              `yaml:"kafka_tls" envconfig:"CLICKHOUSE_KAFKA_TLS"`
+	ConsistentSnapshot               bool              `yaml:"consistent_snapshot" envconfig:"CLICKHOUSE_CONSISTENT_SNAPSHOT"`
+	ConsistentSnapshotPauseCommand   string            `yaml:"consistent_snapshot_pause_command" envconfig:"CLICKHOUSE_CONSISTENT_SNAPSHOT_PAUSE_COMMAND"`
+	ConsistentSnapshotResumeCommand  string            `yaml:"consistent_snapshot_resume_command" envconfig:"CLICKHOUSE_CONSISTENT_SNAPSHOT_RESUME_COMMAND"`
 	TLSKey                           string            `yaml:"tls_key" envconfig:"CLICKHOUSE_TLS_KEY"`
 	TLSCert                          string            `yaml:"tls_cert" envconfig:"CLICKHOUSE_TLS_CERT"`
 	TLSCa                            string            `yaml:"tls_ca" envconfig:"CLICKHOUSE_TLS_CA"`
@@ -241,21 +347,61 @@ type ClickHouseConfig struct {
 }
 
 type APIConfig struct {
-	ListenAddr                    string `yaml:"listen" envconfig:"API_LISTEN"`
-	EnableMetrics                 bool   `yaml:"enable_metrics" envconfig:"API_ENABLE_METRICS"`
-	EnablePprof                   bool   `yaml:"enable_pprof" envconfig:"API_ENABLE_PPROF"`
-	Username                      string `yaml:"username" envconfig:"API_USERNAME"`
-	Password                      string `yaml:"password" envconfig:"API_PASSWORD"`
-	Secure                        bool   `yaml:"secure" envconfig:"API_SECURE"`
-	CertificateFile               string `yaml:"certificate_file" envconfig:"API_CERTIFICATE_FILE"`
-	PrivateKeyFile                string `yaml:"private_key_file" envconfig:"API_PRIVATE_KEY_FILE"`
-	CAKeyFile                     string `yaml:"ca_cert_file" envconfig:"API_CA_KEY_FILE"`
-	CACertFile                    string `yaml:"ca_key_file" envconfig:"API_CA_CERT_FILE"`
-	CreateIntegrationTables       bool   `yaml:"create_integration_tables" envconfig:"API_CREATE_INTEGRATION_TABLES"`
-	IntegrationTablesHost         string `yaml:"integration_tables_host" envconfig:"API_INTEGRATION_TABLES_HOST"`
-	AllowParallel                 bool   `yaml:"allow_parallel" envconfig:"API_ALLOW_PARALLEL"`
-	CompleteResumableAfterRestart bool   `yaml:"complete_resumable_after_restart" envconfig:"API_COMPLETE_RESUMABLE_AFTER_RESTART"`
-	WatchIsMainProcess            bool   `yaml:"watch_is_main_process" envconfig:"WATCH_IS_MAIN_PROCESS"`
+	ListenAddr                    string         `yaml:"listen" envconfig:"API_LISTEN"`
+	EnableMetrics                 bool           `yaml:"enable_metrics" envconfig:"API_ENABLE_METRICS"`
+	EnablePprof                   bool           `yaml:"enable_pprof" envconfig:"API_ENABLE_PPROF"`
+	Username                      string         `yaml:"username" envconfig:"API_USERNAME"`
+	Password                      string         `yaml:"password" envconfig:"API_PASSWORD"`
+	Secure                        bool           `yaml:"secure" envconfig:"API_SECURE"`
+	CertificateFile               string         `yaml:"certificate_file" envconfig:"API_CERTIFICATE_FILE"`
+	PrivateKeyFile                string         `yaml:"private_key_file" envconfig:"API_PRIVATE_KEY_FILE"`
+	CAKeyFile                     string         `yaml:"ca_cert_file" envconfig:"API_CA_KEY_FILE"`
+	CACertFile                    string         `yaml:"ca_key_file" envconfig:"API_CA_CERT_FILE"`
+	CreateIntegrationTables       bool           `yaml:"create_integration_tables" envconfig:"API_CREATE_INTEGRATION_TABLES"`
+	IntegrationTablesHost         string         `yaml:"integration_tables_host" envconfig:"API_INTEGRATION_TABLES_HOST"`
+	AllowParallel                 bool           `yaml:"allow_parallel" envconfig:"API_ALLOW_PARALLEL"`
+	CompleteResumableAfterRestart bool           `yaml:"complete_resumable_after_restart" envconfig:"API_COMPLETE_RESUMABLE_AFTER_RESTART"`
+	WatchIsMainProcess            bool           `yaml:"watch_is_main_process" envconfig:"WATCH_IS_MAIN_PROCESS"`
+	QueueEnabled                  bool           `yaml:"queue_enabled" envconfig:"API_QUEUE_ENABLED"`
+	QueueSize                     int            `yaml:"queue_size" envconfig:"API_QUEUE_SIZE"`
+	QueueMaxConcurrency           int            `yaml:"queue_max_concurrency" envconfig:"API_QUEUE_MAX_CONCURRENCY"`
+	QueueCommandConcurrency       map[string]int `yaml:"queue_command_concurrency" envconfig:"API_QUEUE_COMMAND_CONCURRENCY"`
+}
+
+// TablePolicy - per-table overrides of backup settings, tables from `pattern` use it instead of global settings
+type TablePolicy struct {
+	Pattern           string   `yaml:"pattern"`
+	Skip              *bool    `yaml:"skip,omitempty"`
+	SchemaOnly        bool     `yaml:"schema_only,omitempty"`
+	CompressionFormat string   `yaml:"compression_format,omitempty"`
+	CompressionLevel  int      `yaml:"compression_level,omitempty"`
+	Partitions        []string `yaml:"partitions,omitempty"`
+	FreezeByPart      *bool    `yaml:"freeze_by_part,omitempty"`
+	FreezeByPartWhere string   `yaml:"freeze_by_part_where,omitempty"`
+	MaxPartitionAge   string   `yaml:"max_partition_age,omitempty"`
+	Priority          int      `yaml:"priority,omitempty"`
+}
+
+// IsMatch - pattern is comma separated list of `database.table` globs, the same as --tables
+func (p *TablePolicy) IsMatch(database, table string) bool {
+	for _, pattern := range strings.Split(p.Pattern, ",") {
+		if matched, _ := filepath.Match(strings.Trim(pattern, " \t\r\n"), fmt.Sprintf("%s.%s", database, table)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+type TablePolicies []TablePolicy
+
+// Get - first policy which pattern match table, nil when table use global settings
+func (policies TablePolicies) Get(database, table string) *TablePolicy {
+	for i := range policies {
+		if policies[i].IsMatch(database, table) {
+			return &policies[i]
+		}
+	}
+	return nil
 }
 
 // ArchiveExtensions - list of available compression formats and associated file extensions
@@ -290,6 +436,25 @@ func (cfg *Config) GetArchiveExtension() string {
 	}
 }
 
+func (cfg *Config) GetCompressionLevel() int {
+	switch cfg.General.RemoteStorage {
+	case "s3":
+		return cfg.S3.CompressionLevel
+	case "gcs":
+		return cfg.GCS.CompressionLevel
+	case "cos":
+		return cfg.COS.CompressionLevel
+	case "ftp":
+		return cfg.FTP.CompressionLevel
+	case "sftp":
+		return cfg.SFTP.CompressionLevel
+	case "azblob":
+		return cfg.AzureBlob.CompressionLevel
+	default:
+		return 0
+	}
+}
+
 func (cfg *Config) GetCompressionFormat() string {
 	switch cfg.General.RemoteStorage {
 	case "s3":
@@ -311,6 +476,22 @@ func (cfg *Config) GetCompressionFormat() string {
 	}
 }
 
+func (cfg *Config) trimStoragePaths() {
+	cfg.AzureBlob.Path = strings.Trim(cfg.AzureBlob.Path, "/ \t\r\n")
+	cfg.S3.Path = strings.Trim(cfg.S3.Path, "/ \t\r\n")
+	cfg.GCS.Path = strings.Trim(cfg.GCS.Path, "/ \t\r\n")
+	cfg.COS.Path = strings.Trim(cfg.COS.Path, "/ \t\r\n")
+	cfg.FTP.Path = strings.TrimRight(strings.Trim(cfg.FTP.Path, " \t\r\n"), "/")
+	cfg.SFTP.Path = strings.TrimRight(strings.Trim(cfg.SFTP.Path, " \t\r\n"), "/")
+
+	cfg.AzureBlob.ObjectDiskPath = strings.Trim(cfg.AzureBlob.ObjectDiskPath, "/ \t\n")
+	cfg.S3.ObjectDiskPath = strings.Trim(cfg.S3.ObjectDiskPath, "/ \t\r\n")
+	cfg.GCS.ObjectDiskPath = strings.Trim(cfg.GCS.ObjectDiskPath, "/ \t\r\n")
+	cfg.COS.ObjectDiskPath = strings.Trim(cfg.COS.ObjectDiskPath, "/ \t\r\n")
+	cfg.FTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.FTP.ObjectDiskPath, " \t\r\n"), "/")
+	cfg.SFTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.SFTP.ObjectDiskPath, " \t\r\n"), "/")
+}
+
 var freezeByPartBeginAndRE = regexp.MustCompile(`(?im)^\s*AND\s+`)
 
 // LoadConfig - load config from file + environment variables
@@ -338,24 +519,17 @@ func LoadConfig(configLocation string) (*Config, error) {
 	if (cfg.General.RemoteStorage == "gcs" || cfg.General.RemoteStorage == "azblob" || cfg.General.RemoteStorage == "cos") && cfgWithoutDefault.General.UploadConcurrency == 0 {
 		cfg.General.UploadConcurrency = uint8(runtime.NumCPU() / 2)
 	}
-	cfg.AzureBlob.Path = strings.Trim(cfg.AzureBlob.Path, "/ \t\r\n")
-	cfg.S3.Path = strings.Trim(cfg.S3.Path, "/ \t\r\n")
-	cfg.GCS.Path = strings.Trim(cfg.GCS.Path, "/ \t\r\n")
-	cfg.COS.Path = strings.Trim(cfg.COS.Path, "/ \t\r\n")
-	cfg.FTP.Path = strings.TrimRight(strings.Trim(cfg.FTP.Path, " \t\r\n"), "/")
-	cfg.SFTP.Path = strings.TrimRight(strings.Trim(cfg.SFTP.Path, " \t\r\n"), "/")
-
-	cfg.AzureBlob.ObjectDiskPath = strings.Trim(cfg.AzureBlob.ObjectDiskPath, "/ \t\n")
-	cfg.S3.ObjectDiskPath = strings.Trim(cfg.S3.ObjectDiskPath, "/ \t\r\n")
-	cfg.GCS.ObjectDiskPath = strings.Trim(cfg.GCS.ObjectDiskPath, "/ \t\r\n")
-	cfg.COS.ObjectDiskPath = strings.Trim(cfg.COS.ObjectDiskPath, "/ \t\r\n")
-	cfg.FTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.FTP.ObjectDiskPath, " \t\r\n"), "/")
-	cfg.SFTP.ObjectDiskPath = strings.TrimRight(strings.Trim(cfg.SFTP.ObjectDiskPath, " \t\r\n"), "/")
+	cfg.trimStoragePaths()
 
 	// https://github.com/Altinity/clickhouse-backup/issues/855
 	if cfg.ClickHouse.FreezeByPart && cfg.ClickHouse.FreezeByPartWhere != "" && !freezeByPartBeginAndRE.MatchString(cfg.ClickHouse.FreezeByPartWhere) {
 		cfg.ClickHouse.FreezeByPartWhere = " AND " + cfg.ClickHouse.FreezeByPartWhere
 	}
+	for i := range cfg.Tables {
+		if cfg.Tables[i].FreezeByPartWhere != "" && !freezeByPartBeginAndRE.MatchString(cfg.Tables[i].FreezeByPartWhere) {
+			cfg.Tables[i].FreezeByPartWhere = " AND " + cfg.Tables[i].FreezeByPartWhere
+		}
+	}
 
 	log_helper.SetLogLevelFromString(cfg.General.LogLevel)
 
@@ -394,6 +568,38 @@ func ValidateConfig(cfg *Config) error {
 	if cfg.ClickHouse.FreezeByPart && cfg.ClickHouse.UseEmbeddedBackupRestore {
 		return fmt.Errorf("`freeze_by_part: %v` is not compatible with `use_embedded_backup_restore: %v`", cfg.ClickHouse.FreezeByPart, cfg.ClickHouse.UseEmbeddedBackupRestore)
 	}
+	if cfg.General.DataFormat != "" && cfg.General.DataFormat != "native" && cfg.General.DataFormat != "parquet" {
+		return fmt.Errorf("`general->data_format: %s` shall be empty, native or parquet", cfg.General.DataFormat)
+	}
+	if cfg.ClickHouse.ConsistentSnapshot && (cfg.ClickHouse.UseEmbeddedBackupRestore || cfg.General.DataFormat != "") {
+		return fmt.Errorf("`consistent_snapshot: %v` is compatible only with FREEZE based backup, disable `use_embedded_backup_restore` and `general->data_format`", cfg.ClickHouse.ConsistentSnapshot)
+	}
+	if cfg.General.ContentAddressedParts && cfg.ClickHouse.UseEmbeddedBackupRestore {
+		return fmt.Errorf("`content_addressed_parts: %v` is not compatible with `use_embedded_backup_restore: %v`", cfg.General.ContentAddressedParts, cfg.ClickHouse.UseEmbeddedBackupRestore)
+	}
+	if cfg.General.DataFormat != "" && cfg.ClickHouse.UseEmbeddedBackupRestore {
+		return fmt.Errorf("`data_format: %s` is not compatible with `use_embedded_backup_restore: %v`", cfg.General.DataFormat, cfg.ClickHouse.UseEmbeddedBackupRestore)
+	}
+	for name := range cfg.Storages {
+		if !storageNameRE.MatchString(name) {
+			return fmt.Errorf("`storages->%s` name shall contain only letters, digits, `_` and `-`", name)
+		}
+	}
+	for _, policy := range cfg.Tables {
+		if err := validateTablePolicy(cfg, policy); err != nil {
+			return err
+		}
+	}
+	if cfg.API.QueueEnabled {
+		if cfg.API.QueueSize <= 0 || cfg.API.QueueMaxConcurrency <= 0 {
+			return fmt.Errorf("`api->queue_size: %d` and `api->queue_max_concurrency: %d` shall be greater than 0", cfg.API.QueueSize, cfg.API.QueueMaxConcurrency)
+		}
+		for command, concurrency := range cfg.API.QueueCommandConcurrency {
+			if concurrency <= 0 {
+				return fmt.Errorf("`api->queue_command_concurrency->%s: %d` shall be greater than 0", command, concurrency)
+			}
+		}
+	}
 	if _, err := time.ParseDuration(cfg.COS.Timeout); err != nil {
 		return fmt.Errorf("invalid cos timeout: %v", err)
 	}
@@ -458,6 +664,29 @@ func ValidateConfig(cfg *Config) error {
 	} else {
 		return fmt.Errorf("empty retries pause")
 	}
+	if cfg.General.AbortStaleUploadsAfter != "" {
+		if duration, err := time.ParseDuration(cfg.General.AbortStaleUploadsAfter); err != nil {
+			return fmt.Errorf("invalid abort stale uploads after: %v", err)
+		} else {
+			cfg.General.AbortStaleUploadsDuration = duration
+		}
+	}
+	if cfg.General.ResumableStateCheckpointInterval != "" {
+		if duration, err := time.ParseDuration(cfg.General.ResumableStateCheckpointInterval); err != nil {
+			return fmt.Errorf("invalid resumable state checkpoint interval: %v", err)
+		} else {
+			cfg.General.ResumableStateCheckpointDuration = duration
+		}
+	}
+	if cfg.ClickHouse.KafkaSASLMechanism != "" && cfg.ClickHouse.KafkaSASLMechanism != "PLAIN" && cfg.ClickHouse.KafkaSASLMechanism != "SCRAM-SHA-256" && cfg.ClickHouse.KafkaSASLMechanism != "SCRAM-SHA-512" {
+		return fmt.Errorf("clickhouse.kafka_sasl_mechanism shall be empty or one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512")
+	}
+	if cfg.General.KeeperDumpCompression != "none" && cfg.General.KeeperDumpCompression != "gzip" {
+		return fmt.Errorf("general.keeper_dump_compression shall be one of none, gzip")
+	}
+	if cfg.General.KeeperRestoreMode != "skip" && cfg.General.KeeperRestoreMode != "overwrite" && cfg.General.KeeperRestoreMode != "fail" {
+		return fmt.Errorf("general.keeper_restore_mode shall be one of skip, overwrite, fail")
+	}
 	if cfg.General.WatchInterval != "" {
 		if duration, err := time.ParseDuration(cfg.General.WatchInterval); err != nil {
 			return fmt.Errorf("invalid watch interval: %v", err)
@@ -475,6 +704,29 @@ func ValidateConfig(cfg *Config) error {
 	return nil
 }
 
+func validateTablePolicy(cfg *Config, policy TablePolicy) error {
+	if strings.Trim(policy.Pattern, " \t\r\n,") == "" {
+		return fmt.Errorf("`tables->pattern` shall be not empty")
+	}
+	if policy.CompressionFormat != "" {
+		if _, ok := ArchiveExtensions[policy.CompressionFormat]; !ok || policy.CompressionFormat == "lz4" {
+			return fmt.Errorf("`tables->compression_format: %s` for `%s` is unsupported compression format", policy.CompressionFormat, policy.Pattern)
+		}
+		if cfg.GetCompressionFormat() == "none" {
+			return fmt.Errorf("`tables->compression_format: %s` for `%s` requires archive `compression_format` for `%s` remote storage", policy.CompressionFormat, policy.Pattern, cfg.General.RemoteStorage)
+		}
+	}
+	if policy.MaxPartitionAge != "" {
+		if maxPartitionAge, err := time.ParseDuration(policy.MaxPartitionAge); err != nil || maxPartitionAge <= 0 {
+			return fmt.Errorf("`tables->max_partition_age: %s` for `%s` shall be positive duration", policy.MaxPartitionAge, policy.Pattern)
+		}
+	}
+	if (policy.MaxPartitionAge != "" || (policy.FreezeByPart != nil && *policy.FreezeByPart)) && (cfg.ClickHouse.UseEmbeddedBackupRestore || cfg.General.DataFormat != "") {
+		return fmt.Errorf("`tables->freeze_by_part` and `tables->max_partition_age` for `%s` are compatible only with FREEZE based backup, disable `use_embedded_backup_restore` and `general->data_format`", policy.Pattern)
+	}
+	return nil
+}
+
 func ValidateObjectDiskConfig(cfg *Config) error {
 	if !cfg.ClickHouse.UseEmbeddedBackupRestore {
 		if cfg.General.RemoteStorage == "s3" && ((cfg.S3.ObjectDiskPath == "" && cfg.S3.Path == "") || (cfg.S3.Path != "" && strings.HasPrefix(cfg.S3.Path, cfg.S3.ObjectDiskPath))) {
@@ -532,9 +784,11 @@ func DefaultConfig() *Config {
 			DownloadConcurrency:                 downloadConcurrency,
 			ObjectDiskServerSideCopyConcurrency: objectDiskServerSideCopyConcurrency,
 			RestoreSchemaOnCluster:              "",
+			RestoreReshardShardingKey:           "rand()",
 			UploadByPart:                        true,
 			DownloadByPart:                      true,
 			UseResumableState:                   true,
+			ResumableStateCheckpointInterval:    "0s",
 			RetriesOnFailure:                    3,
 			RetriesPause:                        "5s",
 			RetriesDuration:                     5 * time.Second,
@@ -545,6 +799,10 @@ func DefaultConfig() *Config {
 			WatchBackupNameTemplate:             "shard{shard}-{type}-{time:20060102150405}",
 			RestoreDatabaseMapping:              make(map[string]string),
 			RestoreTableMapping:                 make(map[string]string),
+			KeeperPaths:                         make([]string, 0),
+			RestoreKeeperPathMapping:            make(map[string]string),
+			KeeperDumpCompression:               "none",
+			KeeperRestoreMode:                   "overwrite",
 			IONicePriority:                      "idle",
 			CPUNicePriority:                     15,
 			RBACBackupAlways:                    true,
@@ -574,6 +832,9 @@ func DefaultConfig() *Config {
 			CheckPartsColumns:                true,
 			DefaultReplicaPath:               "/clickhouse/tables/{cluster}/{shard}/{database}/{table}",
 			DefaultReplicaName:               "{replica}",
+			DefaultReplicatedDatabasePath:    "/clickhouse/databases/{database}",
+			DefaultReplicatedDatabaseShard:   "{shard}",
+			DefaultReplicatedDatabaseReplica: "{replica}",
 			MaxConnections:                   int(downloadConcurrency),
 		},
 		AzureBlob: AzureBlobConfig{
@@ -619,6 +880,8 @@ func DefaultConfig() *Config {
 			ListenAddr:                    "localhost:7171",
 			EnableMetrics:                 true,
 			CompleteResumableAfterRestart: true,
+			QueueSize:                     100,
+			QueueMaxConcurrency:           1,
 		},
 		FTP: FTPConfig{
 			Timeout:           "2m",
@@ -647,9 +910,38 @@ func GetConfigFromCli(ctx *cli.Context) *Config {
 		log.Fatal().Stack().Err(err).Send()
 	}
 	RestoreEnvVars(oldEnvValues)
+	// create_remote and watch upload to all storages from --storage, other commands work with one storage
+	if storageNames := GetStorageNamesFromCli(ctx); len(storageNames) > 0 {
+		if len(storageNames) > 1 && ctx.Command.Name != "create_remote" && ctx.Command.Name != "watch" {
+			log.Fatal().Msgf("--storage=%s, several storages supported only by create_remote and watch", strings.Join(storageNames, ","))
+		}
+		if cfg, err = cfg.WithStorage(storageNames[0]); err != nil {
+			log.Fatal().Stack().Err(err).Send()
+		}
+	}
 	return cfg
 }
 
+// GetStorageNamesFromCli - comma separated names from `storages` config section
+func GetStorageNamesFromCli(ctx *cli.Context) []string {
+	storage := ctx.String("storage")
+	if storage == "" {
+		storage = ctx.GlobalString("storage")
+	}
+	return ParseStorageNames(storage)
+}
+
+// ParseStorageNames - split comma separated --storage value, empty names are ignored
+func ParseStorageNames(storage string) []string {
+	storageNames := make([]string, 0)
+	for _, name := range strings.Split(storage, ",") {
+		if name = strings.Trim(name, " \t\r\n"); name != "" {
+			storageNames = append(storageNames, name)
+		}
+	}
+	return storageNames
+}
+
 func GetConfigPath(ctx *cli.Context) string {
 	if ctx.String("config") != DefaultConfigPath {
 		return ctx.String("config")
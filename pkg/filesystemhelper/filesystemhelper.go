@@ -248,7 +248,28 @@ func IsFileInPartition(disk, fileName string, partitionsBackupMap common.EmptyMa
 	return false
 }
 
-func MoveShadowToBackup(shadowPath, backupPartsPath string, partitionsBackupMap common.EmptyMap, tableDiffFromRemote metadata.TableMetadata, disk clickhouse.Disk, version int) ([]metadata.Part, int64, error) {
+// GetShadowParts - names of frozen parts, relative path layout is the same as in MoveShadowToBackup
+func GetShadowParts(shadowPath string) ([]string, error) {
+	parts := make([]string, 0)
+	err := filepath.Walk(shadowPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || strings.Contains(info.Name(), "frozen_metadata") {
+			return nil
+		}
+		pathParts := strings.Split(strings.Trim(strings.TrimPrefix(filePath, shadowPath), "/"), "/")
+		if len(pathParts) != 4 {
+			return nil
+		}
+		parts = append(parts, pathParts[3])
+		return filepath.SkipDir
+	})
+	return parts, err
+}
+
+// MoveShadowToBackup - hardlink frozen parts into backup, partsBackupMap filters parts by name when not nil
+func MoveShadowToBackup(shadowPath, backupPartsPath string, partitionsBackupMap, partsBackupMap common.EmptyMap, tableDiffFromRemote metadata.TableMetadata, disk clickhouse.Disk, version int) ([]metadata.Part, int64, error) {
 	size := int64(0)
 	parts := make([]metadata.Part, 0)
 	err := filepath.Walk(shadowPath, func(filePath string, info os.FileInfo, err error) error {
@@ -270,6 +291,11 @@ func MoveShadowToBackup(shadowPath, backupPartsPath string, partitionsBackupMap
 		if len(partitionsBackupMap) != 0 && !IsPartInPartition(pathParts[3], partitionsBackupMap) {
 			return nil
 		}
+		if partsBackupMap != nil {
+			if _, exists := partsBackupMap[strings.SplitN(pathParts[3], "/", 2)[0]]; !exists {
+				return nil
+			}
+		}
 		var isRequiredPartFound, partExists bool
 		if tableDiffFromRemote.Database != "" && tableDiffFromRemote.Table != "" && len(tableDiffFromRemote.Parts) > 0 && len(tableDiffFromRemote.Parts[disk.Name]) > 0 {
 			parts, isRequiredPartFound, partExists = addRequiredPartIfNotExists(parts, pathParts[3], tableDiffFromRemote, disk)
@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+// Kafka - admin client for read and commit consumer group offsets of Kafka engine tables, doesn't join consumer group
+type Kafka struct {
+	client *kgo.Client
+	admin  *kadm.Client
+}
+
+// Connect - brokers is comma separated list, the same format as kafka_broker_list setting
+func (k *Kafka) Connect(brokers string, cfg *config.ClickHouseConfig) error {
+	seeds := make([]string, 0)
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			seeds = append(seeds, broker)
+		}
+	}
+	if len(seeds) == 0 {
+		return fmt.Errorf("empty kafka broker list")
+	}
+	opts := []kgo.Opt{kgo.SeedBrokers(seeds...)}
+	switch cfg.KafkaSASLMechanism {
+	case "PLAIN":
+		opts = append(opts, kgo.SASL(plain.Auth{User: cfg.KafkaSASLUsername, Pass: cfg.KafkaSASLPassword}.AsMechanism()))
+	case "SCRAM-SHA-256":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.KafkaSASLUsername, Pass: cfg.KafkaSASLPassword}.AsSha256Mechanism()))
+	case "SCRAM-SHA-512":
+		opts = append(opts, kgo.SASL(scram.Auth{User: cfg.KafkaSASLUsername, Pass: cfg.KafkaSASLPassword}.AsSha512Mechanism()))
+	}
+	if cfg.KafkaTLS {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{}))
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("can't create kafka client for %s: %v", brokers, err)
+	}
+	k.client = client
+	k.admin = kadm.NewClient(client)
+	return nil
+}
+
+// FetchOffsets - committed offsets of consumer group, sorted by topic and partition
+func (k *Kafka) FetchOffsets(ctx context.Context, group string) ([]metadata.KafkaOffset, error) {
+	responses, err := k.admin.FetchOffsets(ctx, group)
+	// group without committed offsets could be already deleted by broker
+	if errors.Is(err, kerr.GroupIDNotFound) {
+		return []metadata.KafkaOffset{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch offsets for consumer group %s: %v", group, err)
+	}
+	if err = responses.Error(); err != nil {
+		return nil, fmt.Errorf("can't fetch offsets for consumer group %s: %v", group, err)
+	}
+	offsets := make([]metadata.KafkaOffset, 0)
+	responses.Each(func(response kadm.OffsetResponse) {
+		if response.At >= 0 {
+			offsets = append(offsets, metadata.KafkaOffset{Topic: response.Topic, Partition: response.Partition, Offset: response.At})
+		}
+	})
+	SortOffsets(offsets)
+	return offsets, nil
+}
+
+// CommitOffsets - commit offsets on behalf of consumer group, broker rejects commit when group has active members
+func (k *Kafka) CommitOffsets(ctx context.Context, group string, offsets []metadata.KafkaOffset) error {
+	toCommit := kadm.Offsets{}
+	for _, offset := range offsets {
+		toCommit.AddOffset(offset.Topic, offset.Partition, offset.Offset, -1)
+	}
+	if err := k.admin.CommitAllOffsets(ctx, group, toCommit); err != nil {
+		return fmt.Errorf("can't commit offsets for consumer group %s: %v", group, err)
+	}
+	log.Debug().Str("group", group).Int("partitions", len(offsets)).Msg("kafka offsets committed")
+	return nil
+}
+
+func (k *Kafka) Close() {
+	k.admin.Close()
+}
+
+// SortOffsets - stable order for backup metadata
+func SortOffsets(offsets []metadata.KafkaOffset) {
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+}
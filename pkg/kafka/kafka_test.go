@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/metadata"
+)
+
+func TestCommitAndFetchOffsets(t *testing.T) {
+	r := require.New(t)
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "events", "logs"))
+	r.NoError(err)
+	defer cluster.Close()
+
+	k := &Kafka{}
+	r.NoError(k.Connect(strings.Join(cluster.ListenAddrs(), ","), &config.DefaultConfig().ClickHouse))
+	defer k.Close()
+	ctx := context.Background()
+
+	offsets, err := k.FetchOffsets(ctx, "clickhouse_group")
+	r.NoError(err)
+	r.Empty(offsets)
+
+	expected := []metadata.KafkaOffset{
+		{Topic: "logs", Partition: 0, Offset: 7},
+		{Topic: "events", Partition: 1, Offset: 42},
+		{Topic: "events", Partition: 0, Offset: 0},
+	}
+	r.NoError(k.CommitOffsets(ctx, "clickhouse_group", expected))
+	offsets, err = k.FetchOffsets(ctx, "clickhouse_group")
+	r.NoError(err)
+	SortOffsets(expected)
+	r.Equal(expected, offsets)
+
+	otherOffsets, err := k.FetchOffsets(ctx, "other_group")
+	r.NoError(err)
+	r.Empty(otherOffsets)
+}
+
+func TestConnectEmptyBrokers(t *testing.T) {
+	k := &Kafka{}
+	require.Error(t, k.Connect(" , ", &config.DefaultConfig().ClickHouse))
+}
@@ -2,12 +2,16 @@ package keeper
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/antchfx/xmlquery"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"io"
 	"os"
 	"path"
 	"strconv"
@@ -37,9 +41,80 @@ func (KeeperLogToApexLogAdapter LogKeeperToApexLogAdapter) Printf(msg string, ar
 	}
 }
 
+// DumpNode - one line in keeper dump, ACL and Stat are empty in dumps created by previous versions
 type DumpNode struct {
-	Path  string `json:"path"`
-	Value string `json:"value"`
+	Path  string    `json:"path"`
+	Value string    `json:"value"`
+	ACL   []DumpACL `json:"acl,omitempty"`
+	Stat  *DumpStat `json:"stat,omitempty"`
+}
+
+type DumpACL struct {
+	Perms  int32  `json:"perms"`
+	Scheme string `json:"scheme"`
+	ID     string `json:"id"`
+}
+
+// DumpStat - node versions and timestamps from backup host, informational only, keeper assigns new values during restore
+type DumpStat struct {
+	Version  int32 `json:"version"`
+	CVersion int32 `json:"cversion"`
+	AVersion int32 `json:"aversion"`
+	Ctime    int64 `json:"ctime"`
+	Mtime    int64 `json:"mtime"`
+}
+
+func newDumpNode(nodePath string, value []byte, acl []zk.ACL, stat *zk.Stat) DumpNode {
+	node := DumpNode{Path: nodePath, Value: string(value), ACL: make([]DumpACL, len(acl))}
+	for i := range acl {
+		node.ACL[i] = DumpACL{Perms: acl[i].Perms, Scheme: acl[i].Scheme, ID: acl[i].ID}
+	}
+	if stat != nil {
+		node.Stat = &DumpStat{Version: stat.Version, CVersion: stat.Cversion, AVersion: stat.Aversion, Ctime: stat.Ctime, Mtime: stat.Mtime}
+	}
+	return node
+}
+
+// GetACL - ACL for create node, world:anyone with all permissions for dumps without ACL
+func (node DumpNode) GetACL() []zk.ACL {
+	if len(node.ACL) == 0 {
+		return zk.WorldACL(zk.PermAll)
+	}
+	acl := make([]zk.ACL, len(node.ACL))
+	for i := range node.ACL {
+		acl[i] = zk.ACL{Perms: node.ACL[i].Perms, Scheme: node.ACL[i].Scheme, ID: node.ACL[i].ID}
+	}
+	return acl
+}
+
+func isSameACL(a, b []zk.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RestoreMode - action for existing node with different value or ACL
+type RestoreMode string
+
+const (
+	RestoreModeSkip      RestoreMode = "skip"
+	RestoreModeOverwrite RestoreMode = "overwrite"
+	RestoreModeFail      RestoreMode = "fail"
+)
+
+// RestoreReport - result of diff between dump and existing nodes
+type RestoreReport struct {
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Skipped   int      `json:"skipped"`
+	Unchanged int      `json:"unchanged"`
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 type Keeper struct {
@@ -116,6 +191,7 @@ func (k *Keeper) GetReplicatedAccessPath(userDirectory string) (string, error) {
 	return zookeeperPath, nil
 }
 
+// Dump - write prefix subtree as DumpNode JSON lines, dumpFile with .gz suffix will compress with gzip, ephemeral nodes are skipped
 func (k *Keeper) Dump(prefix, dumpFile string) (int, error) {
 	f, err := os.Create(dumpFile)
 	if err != nil {
@@ -126,13 +202,28 @@ func (k *Keeper) Dump(prefix, dumpFile string) (int, error) {
 			log.Warn().Msgf("can't close %s: %v", dumpFile, err)
 		}
 	}()
+	var w io.Writer = f
+	var gzipWriter *gzip.Writer
+	if strings.HasSuffix(dumpFile, ".gz") {
+		gzipWriter = gzip.NewWriter(f)
+		w = gzipWriter
+	}
+	bufWriter := bufio.NewWriter(w)
 	if k.root != "" && !strings.HasPrefix(prefix, k.root) {
 		prefix = path.Join(k.root, prefix)
 	}
-	bytes, err := k.dumpNodeRecursive(prefix, "", f)
+	bytes, err := k.dumpNodeRecursive(prefix, "", bufWriter)
 	if err != nil {
 		return 0, fmt.Errorf("dumpNodeRecursive(%s) return error: %v", prefix, err)
 	}
+	if err = bufWriter.Flush(); err != nil {
+		return 0, err
+	}
+	if gzipWriter != nil {
+		if err = gzipWriter.Close(); err != nil {
+			return 0, err
+		}
+	}
 	return bytes, nil
 }
 
@@ -145,12 +236,20 @@ func (k *Keeper) ChildCount(prefix, nodePath string) (int, error) {
 	return len(childrenNodes), err
 }
 
-func (k *Keeper) dumpNodeRecursive(prefix, nodePath string, f *os.File) (int, error) {
-	value, _, err := k.conn.Get(path.Join(prefix, nodePath))
+func (k *Keeper) dumpNodeRecursive(prefix, nodePath string, w io.Writer) (int, error) {
+	value, stat, err := k.conn.Get(path.Join(prefix, nodePath))
+	if err != nil {
+		return 0, err
+	}
+	// ephemeral nodes belong to client sessions and can't have children
+	if stat.EphemeralOwner != 0 {
+		return 0, nil
+	}
+	acl, _, err := k.conn.GetACL(path.Join(prefix, nodePath))
 	if err != nil {
 		return 0, err
 	}
-	bytes, err := k.writeJsonString(f, DumpNode{Path: strings.TrimPrefix(nodePath, k.root), Value: string(value)})
+	bytes, err := k.writeJsonString(w, newDumpNode(strings.TrimPrefix(nodePath, k.root), value, acl, stat))
 	if err != nil {
 		return 0, err
 	}
@@ -159,7 +258,7 @@ func (k *Keeper) dumpNodeRecursive(prefix, nodePath string, f *os.File) (int, er
 		return 0, err
 	}
 	for _, childPath := range children {
-		if childBytes, err := k.dumpNodeRecursive(prefix, path.Join(nodePath, childPath), f); err != nil {
+		if childBytes, err := k.dumpNodeRecursive(prefix, path.Join(nodePath, childPath), w); err != nil {
 			return 0, err
 		} else {
 			bytes += childBytes
@@ -168,20 +267,21 @@ func (k *Keeper) dumpNodeRecursive(prefix, nodePath string, f *os.File) (int, er
 	return bytes, nil
 }
 
-func (k *Keeper) writeJsonString(f *os.File, node DumpNode) (int, error) {
+func (k *Keeper) writeJsonString(w io.Writer, node DumpNode) (int, error) {
 	jsonLine, err := json.Marshal(node)
 	if err != nil {
 		return 0, err
 	}
-	bytes, err := f.Write(jsonLine)
+	bytes, err := w.Write(jsonLine)
 	if err != nil {
 		return bytes, err
 	}
-	lnBytes, err := f.Write([]byte("\n"))
+	lnBytes, err := w.Write([]byte("\n"))
 	return bytes + lnBytes, err
 }
 
-func (k *Keeper) Restore(dumpFile, prefix string) error {
+// ReadDump - call callback for each DumpNode from dumpFile, gzip compressed dump detected by magic bytes
+func ReadDump(dumpFile string, callback func(node DumpNode) error) error {
 	f, err := os.Open(dumpFile)
 	if err != nil {
 		return fmt.Errorf("can't open %s: %v", dumpFile, err)
@@ -191,31 +291,218 @@ func (k *Keeper) Restore(dumpFile, prefix string) error {
 			log.Warn().Msgf("can't close %s: %v", dumpFile, err)
 		}
 	}()
+	if err = readDumpNodes(f, callback); err != nil {
+		return fmt.Errorf("can't read %s, error: %v", dumpFile, err)
+	}
+	return nil
+}
+
+func readDumpNodes(r io.Reader, callback func(node DumpNode) error) error {
+	bufReader := bufio.NewReader(r)
+	var reader io.Reader = bufReader
+	if magic, err := bufReader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := gzipReader.Close(); closeErr != nil {
+				log.Warn().Msgf("can't close gzip reader: %v", closeErr)
+			}
+		}()
+		reader = gzipReader
+	}
+	// json.Decoder doesn't limit line length, znode value could be up to jute.maxbuffer
+	decoder := json.NewDecoder(reader)
+	for {
+		node := DumpNode{}
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := callback(node); err != nil {
+			return err
+		}
+	}
+}
+
+// nodesReader - call callback for each node from dump file or memory
+type nodesReader = func(callback func(node DumpNode) error) error
+
+func readDumpFile(dumpFile string) nodesReader {
+	return func(callback func(node DumpNode) error) error {
+		return ReadDump(dumpFile, callback)
+	}
+}
+
+func readNodes(nodes []DumpNode) nodesReader {
+	return func(callback func(node DumpNode) error) error {
+		for _, node := range nodes {
+			if err := callback(node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// DumpNodes - read prefix subtree into memory, ephemeral nodes are skipped, node paths are relative to prefix, shall be used only for small subtrees
+// not exists prefix returns empty list
+func (k *Keeper) DumpNodes(prefix string) ([]DumpNode, error) {
 	if k.root != "" && !strings.HasPrefix(prefix, k.root) {
 		prefix = path.Join(k.root, prefix)
 	}
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		node := DumpNode{}
-		if err = json.Unmarshal(scanner.Bytes(), &node); err != nil {
+	if exists, _, err := k.conn.Exists(prefix); err != nil || !exists {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if _, err := k.dumpNodeRecursive(prefix, "", buf); err != nil {
+		return nil, fmt.Errorf("dumpNodeRecursive(%s) return error: %v", prefix, err)
+	}
+	var nodes []DumpNode
+	err := readDumpNodes(buf, func(node DumpNode) error {
+		nodes = append(nodes, node)
+		return nil
+	})
+	return nodes, err
+}
+
+// Diff - compare dumpFile with existing nodes under prefix without changes, Created contains count of not exists nodes
+func (k *Keeper) Diff(dumpFile, prefix string) (RestoreReport, error) {
+	return k.diffNodes(readDumpFile(dumpFile), prefix)
+}
+
+func (k *Keeper) diffNodes(reader nodesReader, prefix string) (RestoreReport, error) {
+	report := RestoreReport{}
+	if k.root != "" && !strings.HasPrefix(prefix, k.root) {
+		prefix = path.Join(k.root, prefix)
+	}
+	err := reader(func(node DumpNode) error {
+		nodePath := path.Join(prefix, node.Path)
+		isExists, _, isValueChanged, isACLChanged, err := k.compareNode(nodePath, node)
+		if err != nil {
 			return err
 		}
-		node.Path = path.Join(prefix, node.Path)
-		version := int32(0)
-		_, stat, err := k.conn.Get(node.Path)
+		if !isExists {
+			report.Created++
+		} else if isValueChanged || isACLChanged {
+			report.Conflicts = append(report.Conflicts, nodePath)
+		} else {
+			report.Unchanged++
+		}
+		return nil
+	})
+	return report, err
+}
+
+// Restore - create or update nodes from dumpFile under prefix, existing nodes with different value or ACL are processed according to mode,
+// RestoreModeFail checks all nodes before any change
+func (k *Keeper) Restore(dumpFile, prefix string, mode RestoreMode) (RestoreReport, error) {
+	return k.restoreNodes(readDumpFile(dumpFile), dumpFile, prefix, mode)
+}
+
+// RestoreNodes - same as Restore for nodes returned by DumpNodes
+func (k *Keeper) RestoreNodes(nodes []DumpNode, prefix string, mode RestoreMode) (RestoreReport, error) {
+	return k.restoreNodes(readNodes(nodes), "memory", prefix, mode)
+}
+
+func (k *Keeper) restoreNodes(reader nodesReader, source, prefix string, mode RestoreMode) (RestoreReport, error) {
+	if mode == RestoreModeFail {
+		if report, err := k.diffNodes(reader, prefix); err != nil || len(report.Conflicts) > 0 {
+			if err == nil {
+				err = fmt.Errorf("%d znodes from %s already exist with different value or ACL: %s", len(report.Conflicts), source, strings.Join(report.Conflicts, ", "))
+			}
+			return report, err
+		}
+	}
+	report := RestoreReport{}
+	if k.root != "" && !strings.HasPrefix(prefix, k.root) {
+		prefix = path.Join(k.root, prefix)
+	}
+	err := reader(func(node DumpNode) error {
+		nodePath := path.Join(prefix, node.Path)
+		acl := node.GetACL()
+		isExists, stat, isValueChanged, isACLChanged, err := k.compareNode(nodePath, node)
 		if err != nil {
-			_, err = k.conn.Create(node.Path, []byte(node.Value), 0, zk.WorldACL(zk.PermAll))
+			return err
+		}
+		if !isExists {
+			_, err = k.conn.Create(nodePath, []byte(node.Value), 0, acl)
+			if errors.Is(err, zk.ErrNoNode) {
+				if err = k.createParents(nodePath); err == nil {
+					_, err = k.conn.Create(nodePath, []byte(node.Value), 0, acl)
+				}
+			}
 			if err != nil {
-				return fmt.Errorf("can't create znode %s, error: %v", node.Path, err)
+				return fmt.Errorf("can't create znode %s, error: %v", nodePath, err)
 			}
-		} else {
-			version = stat.Version
-			_, err = k.conn.Set(node.Path, []byte(node.Value), version)
+			report.Created++
+			return nil
+		}
+		if !isValueChanged && !isACLChanged {
+			report.Unchanged++
+			return nil
+		}
+		report.Conflicts = append(report.Conflicts, nodePath)
+		switch mode {
+		case RestoreModeSkip:
+			report.Skipped++
+			return nil
+		case RestoreModeFail:
+			return fmt.Errorf("znode %s changed during restore", nodePath)
+		}
+		if isValueChanged {
+			if _, err = k.conn.Set(nodePath, []byte(node.Value), stat.Version); err != nil {
+				return fmt.Errorf("can't set znode %s, error: %v", nodePath, err)
+			}
+		}
+		if isACLChanged {
+			if _, err = k.conn.SetACL(nodePath, acl, -1); err != nil {
+				return fmt.Errorf("can't set znode %s ACL, error: %v", nodePath, err)
+			}
+		}
+		report.Updated++
+		return nil
+	})
+	return report, err
+}
+
+// compareNode - ACL is compared only when dump contains ACL
+func (k *Keeper) compareNode(nodePath string, node DumpNode) (isExists bool, stat *zk.Stat, isValueChanged, isACLChanged bool, err error) {
+	value, stat, err := k.conn.Get(nodePath)
+	if errors.Is(err, zk.ErrNoNode) {
+		return false, nil, false, false, nil
+	}
+	if err != nil {
+		return false, nil, false, false, fmt.Errorf("can't get znode %s, error: %v", nodePath, err)
+	}
+	isValueChanged = string(value) != node.Value
+	if len(node.ACL) > 0 {
+		existsACL, _, err := k.conn.GetACL(nodePath)
+		if err != nil {
+			return false, nil, false, false, fmt.Errorf("can't get znode %s ACL, error: %v", nodePath, err)
 		}
+		isACLChanged = !isSameACL(existsACL, node.GetACL())
 	}
+	return true, stat, isValueChanged, isACLChanged, nil
+}
 
-	if err = scanner.Err(); err != nil {
-		return fmt.Errorf("can't scan %s, error: %s", dumpFile, err)
+// createParents - create empty parent znodes, restore prefix could be remapped to not exists path
+func (k *Keeper) createParents(nodePath string) error {
+	parentPath := path.Dir(nodePath)
+	if parentPath == "/" || parentPath == "." {
+		return nil
+	}
+	if exists, _, err := k.conn.Exists(parentPath); err != nil || exists {
+		return err
+	}
+	if err := k.createParents(parentPath); err != nil {
+		return err
+	}
+	if _, err := k.conn.Create(parentPath, []byte{}, 0, zk.WorldACL(zk.PermAll)); err != nil && !errors.Is(err, zk.ErrNodeExists) {
+		return err
 	}
 	return nil
 }
@@ -250,6 +537,33 @@ func (k *Keeper) Walk(prefix, relativePath string, recursive bool, callback Walk
 	return nil
 }
 
+// DeleteTree - delete prefix with all children, not exists prefix is not an error
+func (k *Keeper) DeleteTree(prefix string) error {
+	if k.root != "" && !strings.HasPrefix(prefix, k.root) {
+		prefix = path.Join(k.root, prefix)
+	}
+	return k.deleteTreeRecursive(prefix)
+}
+
+func (k *Keeper) deleteTreeRecursive(nodePath string) error {
+	children, _, err := k.conn.Children(nodePath)
+	if errors.Is(err, zk.ErrNoNode) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err = k.deleteTreeRecursive(path.Join(nodePath, child)); err != nil {
+			return err
+		}
+	}
+	if err = k.conn.Delete(nodePath, -1); err != nil && !errors.Is(err, zk.ErrNoNode) {
+		return err
+	}
+	return nil
+}
+
 func (k *Keeper) Delete(nodePath string) error {
 	return k.conn.Delete(nodePath, -1)
 }
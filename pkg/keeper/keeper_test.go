@@ -0,0 +1,56 @@
+package keeper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDumpNodes(t *testing.T) {
+	r := require.New(t)
+	k := &Keeper{}
+	nodes := []DumpNode{
+		newDumpNode("/", []byte("root"), zk.WorldACL(zk.PermAll), &zk.Stat{Version: 1, Cversion: 2, Aversion: 3}),
+		newDumpNode("/child", []byte("line1\nline2"), zk.DigestACL(zk.PermRead, "user", "pass"), nil),
+	}
+	plain := &bytes.Buffer{}
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	for _, node := range nodes {
+		_, err := k.writeJsonString(plain, node)
+		r.NoError(err)
+		_, err = k.writeJsonString(gzipWriter, node)
+		r.NoError(err)
+	}
+	r.NoError(gzipWriter.Close())
+
+	for _, dump := range []*bytes.Buffer{plain, compressed} {
+		var readNodes []DumpNode
+		r.NoError(readDumpNodes(dump, func(node DumpNode) error {
+			readNodes = append(readNodes, node)
+			return nil
+		}))
+		r.Equal(nodes, readNodes)
+		r.True(isSameACL(zk.DigestACL(zk.PermRead, "user", "pass"), readNodes[1].GetACL()))
+		r.Equal(int32(3), readNodes[0].Stat.AVersion)
+		r.Nil(readNodes[1].Stat)
+	}
+}
+
+func TestReadDumpNodesWithoutACL(t *testing.T) {
+	r := require.New(t)
+	dump := `{"path":"/","value":""}` + "\n" + `{"path":"/node","value":"value"}` + "\n"
+	var readNodes []DumpNode
+	r.NoError(readDumpNodes(strings.NewReader(dump), func(node DumpNode) error {
+		readNodes = append(readNodes, node)
+		return nil
+	}))
+	r.Len(readNodes, 2)
+	r.Equal("value", readNodes[1].Value)
+	r.True(isSameACL(zk.WorldACL(zk.PermAll), readNodes[1].GetACL()))
+	r.False(isSameACL(zk.WorldACL(zk.PermRead), readNodes[1].GetACL()))
+}
@@ -9,28 +9,47 @@ import (
 )
 
 type BackupMetadata struct {
-	BackupName              string            `json:"backup_name"`
-	Disks                   map[string]string `json:"disks"`      // "default": "/var/lib/clickhouse"
-	DiskTypes               map[string]string `json:"disk_types"` // "default": "local"
-	ClickhouseBackupVersion string            `json:"version"`
-	CreationDate            time.Time         `json:"creation_date"`
-	Tags                    string            `json:"tags,omitempty"` // "regular,embedded"
-	ClickHouseVersion       string            `json:"clickhouse_version,omitempty"`
-	DataSize                uint64            `json:"data_size,omitempty"`
-	ObjectDiskSize          uint64            `json:"object_disk_size,omitempty"`
-	MetadataSize            uint64            `json:"metadata_size"`
-	RBACSize                uint64            `json:"rbac_size,omitempty"`
-	ConfigSize              uint64            `json:"config_size,omitempty"`
-	CompressedSize          uint64            `json:"compressed_size,omitempty"`
-	Databases               []DatabasesMeta   `json:"databases,omitempty"`
-	Tables                  []TableTitle      `json:"tables"`
-	Functions               []FunctionsMeta   `json:"functions"`
-	DataFormat              string            `json:"data_format"`
-	RequiredBackup          string            `json:"required_backup,omitempty"`
+	BackupName              string                 `json:"backup_name"`
+	Disks                   map[string]string      `json:"disks"`      // "default": "/var/lib/clickhouse"
+	DiskTypes               map[string]string      `json:"disk_types"` // "default": "local"
+	ClickhouseBackupVersion string                 `json:"version"`
+	CreationDate            time.Time              `json:"creation_date"`
+	Tags                    string                 `json:"tags,omitempty"` // "regular,embedded"
+	ClickHouseVersion       string                 `json:"clickhouse_version,omitempty"`
+	DataSize                uint64                 `json:"data_size,omitempty"`
+	ObjectDiskSize          uint64                 `json:"object_disk_size,omitempty"`
+	MetadataSize            uint64                 `json:"metadata_size"`
+	RBACSize                uint64                 `json:"rbac_size,omitempty"`
+	ConfigSize              uint64                 `json:"config_size,omitempty"`
+	KeeperSize              uint64                 `json:"keeper_size,omitempty"`
+	CompressedSize          uint64                 `json:"compressed_size,omitempty"`
+	Databases               []DatabasesMeta        `json:"databases,omitempty"`
+	Tables                  []TableTitle           `json:"tables"`
+	Functions               []FunctionsMeta        `json:"functions"`
+	NamedCollections        []NamedCollectionsMeta `json:"named_collections,omitempty"`
+	Workloads               []WorkloadsMeta        `json:"workloads,omitempty"`
+	DataFormat              string                 `json:"data_format"`
+	RequiredBackup          string                 `json:"required_backup,omitempty"`
+	SnapshotTime            *time.Time             `json:"snapshot_time,omitempty"` // single point in time for all tables, when clickhouse->consistent_snapshot enabled
+	FreezeWindow            string                 `json:"freeze_window,omitempty"` // how long merges and inserts were paused
+	Since                   string                 `json:"since,omitempty"`         // backup name or timestamp from `create --since`
+	Watermark               *Watermark             `json:"watermark,omitempty"`
+}
+
+// Watermark - time before freeze and max block number for each partition, `create --since` selects only parts which exceed it
+type Watermark struct {
+	Time   time.Time        `json:"time"`
+	Tables []TableWatermark `json:"tables,omitempty"`
+}
+
+type TableWatermark struct {
+	Database  string           `json:"database"`
+	Table     string           `json:"table"`
+	MaxBlocks map[string]int64 `json:"max_blocks"` // "partition_id": max_block_number
 }
 
 func (b *BackupMetadata) GetFullSize() uint64 {
-	size := b.MetadataSize + b.ConfigSize + b.RBACSize
+	size := b.MetadataSize + b.ConfigSize + b.RBACSize + b.KeeperSize
 	if strings.Contains(b.Tags, "embedded") {
 		size += b.DataSize + b.CompressedSize
 	} else {
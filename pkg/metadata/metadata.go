@@ -16,6 +16,18 @@ type FunctionsMeta struct {
 	CreateQuery string `json:"create_query"`
 }
 
+type NamedCollectionsMeta struct {
+	Name        string `json:"name"`
+	CreateQuery string `json:"create_query"`
+}
+
+type WorkloadsMeta struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Parent      string `json:"parent,omitempty"`
+	CreateQuery string `json:"create_query"`
+}
+
 type MutationMetadata struct {
 	MutationId string `json:"mutation_id" ch:"mutation_id"`
 	Command    string `json:"command" ch:"command"`
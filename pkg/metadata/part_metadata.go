@@ -10,6 +10,8 @@ type Part struct {
 	Name           string `json:"name"`
 	Required       bool   `json:"required,omitempty"`
 	RebalancedDisk string `json:"rebalanced_disk,omitempty"`
+	// Hash - sha256 of checksums.txt, when not empty, part data stored in shared content addressed area on remote storage
+	Hash string `json:"hash,omitempty"`
 }
 
 // SortPartsByMinBlock need to avoid wrong restore for Replacing, Collapsing, https://github.com/ClickHouse/ClickHouse/issues/71009
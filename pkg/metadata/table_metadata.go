@@ -21,6 +21,31 @@ type TableMetadata struct {
 	Mutations            []MutationMetadata  `json:"mutations,omitempty"`
 	MetadataOnly         bool                `json:"metadata_only"`
 	LocalFile            string              `json:"local_file,omitempty"`
+	DataFormat           string              `json:"data_format,omitempty"`        // native or parquet for logical backup, empty for data parts
+	CompressionFormat    string              `json:"compression_format,omitempty"` // archive format from `tables` policy, empty when table uses backup data_format
+	ConsumerState        *ConsumerState      `json:"consumer_state,omitempty"`
+}
+
+// ConsumerState - position of streaming engine consumer, Kafka consumer group offsets or S3Queue processed files from Keeper
+type ConsumerState struct {
+	KafkaBrokers string        `json:"kafka_brokers,omitempty"`
+	KafkaGroup   string        `json:"kafka_group,omitempty"`
+	KafkaOffsets []KafkaOffset `json:"kafka_offsets,omitempty"`
+	KeeperPath   string        `json:"keeper_path,omitempty"`
+	KeeperNodes  []KeeperNode  `json:"keeper_nodes,omitempty"`
+}
+
+// KafkaOffset - next offset for consume in topic partition
+type KafkaOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// KeeperNode - path relative to ConsumerState.KeeperPath
+type KeeperNode struct {
+	Path  string `json:"path"`
+	Value string `json:"value"`
 }
 
 func (tm *TableMetadata) Save(location string, metadataOnly bool) (uint64, error) {
@@ -30,6 +55,7 @@ func (tm *TableMetadata) Save(location string, metadataOnly bool) (uint64, error
 		Query:                tm.Query,
 		DependenciesTable:    tm.DependenciesTable,
 		DependenciesDatabase: tm.DependenciesDatabase,
+		ConsumerState:        tm.ConsumerState,
 		MetadataOnly:         true,
 	}
 
@@ -38,6 +64,8 @@ func (tm *TableMetadata) Save(location string, metadataOnly bool) (uint64, error
 		newTM.Parts = tm.Parts
 		newTM.Size = tm.Size
 		newTM.TotalBytes = tm.TotalBytes
+		newTM.DataFormat = tm.DataFormat
+		newTM.CompressionFormat = tm.CompressionFormat
 		newTM.MetadataOnly = false
 	}
 	if err := os.MkdirAll(path.Dir(location), 0750); err != nil {
@@ -7,8 +7,9 @@ import (
 	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
 	"github.com/rs/zerolog/log"
 	bolt "go.etcd.io/bbolt"
-	"math/big"
+	"io"
 	"path"
+	"time"
 )
 
 var bucketName = []byte("clickhouse-backup")
@@ -19,9 +20,14 @@ type State struct {
 	params    map[string]interface{}
 }
 
+// GetStateFile - local path of state file for backup and command
+func GetStateFile(stateBackupDir, backupName, command string) string {
+	return path.Join(stateBackupDir, "backup", backupName, fmt.Sprintf("%s.state2", command))
+}
+
 func NewState(stateBackupDir, backupName, command string, params map[string]interface{}) *State {
 	s := State{
-		stateFile: path.Join(stateBackupDir, "backup", backupName, fmt.Sprintf("%s.state2", command)),
+		stateFile: GetStateFile(stateBackupDir, backupName, command),
 		db:        nil,
 	}
 	if db, err := bolt.Open(s.stateFile, 0600, nil); err == nil {
@@ -36,6 +42,33 @@ func NewState(stateBackupDir, backupName, command string, params map[string]inte
 	return &s
 }
 
+// OpenStateReadOnly - open exists state file without cleanup, for inspect state which could be used by other running command
+func OpenStateReadOnly(stateFile string) (*State, error) {
+	s := State{
+		stateFile: stateFile,
+	}
+	db, err := bolt.Open(s.stateFile, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("resumable state: can't open %s: %v", s.stateFile, err)
+	}
+	s.db = db
+	if err = s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketName) == nil {
+			return fmt.Errorf("bucket %s not found", bucketName)
+		}
+		return nil
+	}); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("resumable state: can't read %s: %v", s.stateFile, err)
+	}
+	s.LoadParams()
+	return &s, nil
+}
+
+func (s *State) GetStateFile() string {
+	return s.stateFile
+}
+
 func (s *State) GetParams() map[string]interface{} {
 	return s.params
 }
@@ -169,7 +202,7 @@ func (s *State) IsAlreadyProcessed(path string) (bool, int64) {
 		buf := b.Get([]byte(path))
 		if buf != nil {
 			found = true
-			size = new(big.Int).SetBytes(buf).Int64()
+			size, _ = binary.Varint(buf)
 			log.Info().Msgf("%s already processed", path)
 		}
 		return nil
@@ -181,6 +214,36 @@ func (s *State) IsAlreadyProcessed(path string) (bool, int64) {
 	return found, size
 }
 
+// ForEachProcessed - call fn for each already processed key with stored size in key order
+func (s *State) ForEachProcessed(fn func(key string, size int64) error) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		return s.getBucket(tx).ForEach(func(k, v []byte) error {
+			if string(k) == "params" {
+				return nil
+			}
+			size, _ := binary.Varint(v)
+			return fn(string(k), size)
+		})
+	})
+}
+
+// WriteTo - write consistent copy of state file, which could be opened by NewState later, allow to checkpoint state during upload
+func (s *State) WriteTo(w io.Writer) (int64, error) {
+	if s.db == nil {
+		return 0, fmt.Errorf("resumable state: %s is not opened", s.stateFile)
+	}
+	written := int64(0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+	return written, err
+}
+
 func (s *State) Close() {
 	if s.db == nil {
 		return
@@ -0,0 +1,97 @@
+package resumable
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateAppendAndIsAlreadyProcessed(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	params := map[string]interface{}{"tablePattern": "db.*"}
+	s := NewState(stateDir, "test_backup", "download", params)
+	s.AppendToState("test_backup/shadow/db/t/default_all_1_1_0.tar", 1234567)
+	s.AppendToState("test_backup/shadow/db/t/default_all_2_2_0.tar", 0)
+	s.Close()
+
+	s = NewState(stateDir, "test_backup", "download", params)
+	defer s.Close()
+	isProcessed, size := s.IsAlreadyProcessed("test_backup/shadow/db/t/default_all_1_1_0.tar")
+	require.True(t, isProcessed)
+	require.Equal(t, int64(1234567), size)
+	isProcessed, size = s.IsAlreadyProcessed("test_backup/shadow/db/t/default_all_2_2_0.tar")
+	require.True(t, isProcessed)
+	require.Equal(t, int64(0), size)
+	require.False(t, s.IsAlreadyProcessedBool("test_backup/shadow/db/t/default_all_3_3_0.tar"))
+}
+
+func TestStateCleanupIfParamsChange(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	s := NewState(stateDir, "test_backup", "restore", map[string]interface{}{"tablePattern": "db.*"})
+	s.AppendToState("db.t", 1)
+	s.Close()
+
+	s = NewState(stateDir, "test_backup", "restore", map[string]interface{}{"tablePattern": "other.*"})
+	defer s.Close()
+	require.False(t, s.IsAlreadyProcessedBool("db.t"))
+	require.Equal(t, "other.*", s.GetParams()["tablePattern"])
+}
+
+func TestStateWriteTo(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	params := map[string]interface{}{"tablePattern": "db.*"}
+	s := NewState(stateDir, "test_backup", "upload", params)
+	s.AppendToState("test_backup/metadata/db/t.json", 100)
+	var buf bytes.Buffer
+	_, err := s.WriteTo(&buf)
+	require.NoError(t, err)
+	s.Close()
+
+	otherDir := t.TempDir()
+	stateFile := GetStateFile(otherDir, "test_backup", "upload")
+	require.NoError(t, os.MkdirAll(path.Dir(stateFile), 0755))
+	require.NoError(t, os.WriteFile(stateFile, buf.Bytes(), 0600))
+	s = NewState(otherDir, "test_backup", "upload", params)
+	defer s.Close()
+	isProcessed, size := s.IsAlreadyProcessed("test_backup/metadata/db/t.json")
+	require.True(t, isProcessed)
+	require.Equal(t, int64(100), size)
+}
+
+func TestOpenStateReadOnly(t *testing.T) {
+	stateDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(stateDir, "backup", "test_backup"), 0755))
+	params := map[string]interface{}{"tablePattern": "db.*"}
+	s := NewState(stateDir, "test_backup", "download", params)
+	s.AppendToState("b", 20)
+	s.AppendToState("a", 10)
+	s.Close()
+
+	_, err := OpenStateReadOnly(GetStateFile(stateDir, "test_backup", "upload"))
+	require.Error(t, err)
+
+	s, err = OpenStateReadOnly(GetStateFile(stateDir, "test_backup", "download"))
+	require.NoError(t, err)
+	require.Equal(t, params, s.GetParams())
+	keys := make([]string, 0)
+	totalSize := int64(0)
+	require.NoError(t, s.ForEachProcessed(func(key string, size int64) error {
+		keys = append(keys, key)
+		totalSize += size
+		return nil
+	}))
+	s.Close()
+	require.Equal(t, []string{"a", "b"}, keys)
+	require.Equal(t, int64(30), totalSize)
+
+	// read only open shall not cleanup state
+	s = NewState(stateDir, "test_backup", "download", params)
+	defer s.Close()
+	require.True(t, s.IsAlreadyProcessedBool("a"))
+}
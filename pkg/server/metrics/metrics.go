@@ -252,3 +252,47 @@ func (m *APIMetrics) ExecuteWithMetrics(command string, errCounter int, f func()
 	}
 	return err, errCounter
 }
+
+// ExecuteWithSubCommandsMetrics - unlike ExecuteWithMetrics, which copy command timestamps to all SubCommands,
+// measure each sub-command separately when it runs via step callback, sub-commands don't change InProgressCommands
+func (m *APIMetrics) ExecuteWithSubCommandsMetrics(command string, f func(step func(subCommand string, stepFunc func() error) error) error) error {
+	startTime := time.Now()
+	if _, exists := m.LastStart[command]; exists {
+		m.LastStart[command].Set(float64(startTime.Unix()))
+		m.InProgressCommands.Inc()
+	} else {
+		log.Warn().Msgf("%s not found in LastStart metrics", command)
+	}
+	err := f(func(subCommand string, stepFunc func() error) error {
+		stepStartTime := time.Now()
+		if _, exists := m.LastStart[subCommand]; exists {
+			m.LastStart[subCommand].Set(float64(stepStartTime.Unix()))
+		}
+		stepErr := stepFunc()
+		if _, exists := m.LastFinish[subCommand]; exists {
+			m.LastDuration[subCommand].Set(float64(time.Since(stepStartTime).Nanoseconds()))
+			m.LastFinish[subCommand].Set(float64(time.Now().Unix()))
+		}
+		if stepErr != nil {
+			log.Error().Msgf("metrics.ExecuteWithSubCommandsMetrics(%s->%s) return error: %v", command, subCommand, stepErr)
+			m.Failure(subCommand)
+		} else {
+			m.Success(subCommand)
+		}
+		return stepErr
+	})
+	if _, exists := m.LastFinish[command]; exists {
+		m.LastDuration[command].Set(float64(time.Since(startTime).Nanoseconds()))
+		m.LastFinish[command].Set(float64(time.Now().Unix()))
+		m.InProgressCommands.Dec()
+	} else {
+		log.Warn().Msgf("%s not found in LastFinish", command)
+	}
+	if err != nil {
+		log.Error().Msgf("metrics.ExecuteWithSubCommandsMetrics(%s) return error: %v", command, err)
+		m.Failure(command)
+	} else {
+		m.Success(command)
+	}
+	return err
+}
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// openAPIParam - query or path parameter description, used to generate /openapi.json
+type openAPIParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+	Multiple    bool
+}
+
+// openAPIOperation - one method + path pair, Response contains name of schema from openAPISchemas
+type openAPIOperation struct {
+	Method      string
+	Path        string
+	OperationId string
+	Summary     string
+	Params      []openAPIParam
+	RequestBody string
+	Response    string
+	// ResponseStatus - http status for success response, http.StatusOK by default
+	ResponseStatus int
+}
+
+func queryParam(name, paramType, description string) openAPIParam {
+	return openAPIParam{Name: name, In: "query", Type: paramType, Description: description}
+}
+
+func queryParamMultiple(name, description string) openAPIParam {
+	return openAPIParam{Name: name, In: "query", Type: "string", Description: description, Multiple: true}
+}
+
+func pathParam(name, description string) openAPIParam {
+	return openAPIParam{Name: name, In: "path", Type: "string", Description: description, Required: true}
+}
+
+var callbackParam = openAPIParam{Name: "callback", In: "query", Type: "string", Multiple: true, Description: "URL which will be called with POST and CallbackResponse payload when operation finished"}
+
+var storageParam = queryParam("storage", "string", "same as --storage, name of storage from `storages` config section")
+
+var priorityParam = queryParam("priority", "integer", "position in operation queue when `api->queue_enabled: true`, higher priority starts first, 0 by default")
+
+var killParams = []openAPIParam{
+	queryParam("command", "string", "command to kill, kill first `in progress` command when omitted"),
+}
+
+var watchParams = []openAPIParam{
+	queryParam("watch_interval", "string", "same as --watch-interval"),
+	queryParam("full_interval", "string", "same as --full-interval"),
+	queryParam("watch_backup_name_template", "string", "same as --watch-backup-name-template"),
+	queryParam("table", "string", "same as --tables"),
+	queryParamMultiple("partitions", "same as --partitions"),
+	queryParam("schema", "boolean", "same as --schema"),
+	queryParam("rbac", "boolean", "same as --rbac"),
+	queryParam("configs", "boolean", "same as --configs"),
+	queryParam("skip_check_parts_columns", "boolean", "same as --skip-check-parts-columns"),
+	storageParam,
+}
+
+// openAPIOperations - describe all routes registered in registerHTTPHandlers, TestOpenAPICoversAllRoutes check it
+var openAPIOperations = []openAPIOperation{
+	{Method: "GET", Path: "/", OperationId: "index", Summary: "List all applicable HTTP routes", Response: "text"},
+	{Method: "POST", Path: "/", OperationId: "restartRoot", Summary: "Restart HTTP server", Response: "OperationStatus", ResponseStatus: http.StatusCreated},
+	{Method: "POST", Path: "/restart", OperationId: "restart", Summary: "Restart HTTP server", Response: "OperationStatus", ResponseStatus: http.StatusCreated},
+	{Method: "GET", Path: "/backup/version", OperationId: "version", Summary: "Show clickhouse-backup version", Response: "Version"},
+	{Method: "GET", Path: "/restart", OperationId: "restartGet", Summary: "Restart HTTP server", Response: "OperationStatus", ResponseStatus: http.StatusCreated},
+	{Method: "POST", Path: "/backup/kill", OperationId: "kill", Summary: "Kill selected command from GET /backup/actions", Response: "KillStatus", Params: killParams},
+	{Method: "GET", Path: "/backup/kill", OperationId: "killGet", Summary: "Kill selected command from GET /backup/actions", Response: "KillStatus", Params: killParams},
+	{Method: "POST", Path: "/backup/watch", OperationId: "watch", Summary: "Run background watch process", Response: "CommandStatus", ResponseStatus: http.StatusCreated, Params: watchParams},
+	{Method: "GET", Path: "/backup/watch", OperationId: "watchGet", Summary: "Run background watch process", Response: "CommandStatus", ResponseStatus: http.StatusCreated, Params: watchParams},
+	{Method: "GET", Path: "/backup/tables", OperationId: "tables", Summary: "List of tables, exclude skip_tables", Response: "Table", Params: []openAPIParam{
+		queryParam("table", "string", "same as --tables"),
+		queryParam("remote_backup", "string", "same as --remote-backup"),
+		storageParam,
+	}},
+	{Method: "GET", Path: "/backup/tables/all", OperationId: "tablesAll", Summary: "List of tables, include skip_tables", Response: "Table", Params: []openAPIParam{
+		queryParam("table", "string", "same as --tables"),
+		queryParam("remote_backup", "string", "same as --remote-backup"),
+		storageParam,
+	}},
+	{Method: "GET", Path: "/backup/list", OperationId: "list", Summary: "List of local and remote backups", Response: "Backup", Params: []openAPIParam{
+		storageParam,
+	}},
+	{Method: "GET", Path: "/backup/list/{where}", OperationId: "listWhere", Summary: "List of local or remote backups", Response: "Backup", Params: []openAPIParam{
+		pathParam("where", "`local` or `remote`"),
+		storageParam,
+	}},
+	{Method: "POST", Path: "/backup/create", OperationId: "create", Summary: "Create new backup", Response: "BackupOperation", ResponseStatus: http.StatusCreated, Params: []openAPIParam{
+		queryParam("name", "string", "backup name, generated when omitted"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParam("diff-from-remote", "string", "same as --diff-from-remote"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("rbac", "boolean", "same as --rbac"),
+		queryParam("rbac-only", "boolean", "same as --rbac-only"),
+		queryParam("configs", "boolean", "same as --configs"),
+		queryParam("configs-only", "boolean", "same as --configs-only"),
+		queryParam("skip-check-parts-columns", "boolean", "same as --skip-check-parts-columns"),
+		queryParam("resume", "boolean", "same as --resume"),
+		storageParam,
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/clean", OperationId: "clean", Summary: "Clean shadow folders for all disks", Response: "OperationStatus"},
+	{Method: "POST", Path: "/backup/clean/remote_broken", OperationId: "cleanRemoteBroken", Summary: "Remove all broken remote backups", Response: "OperationStatus", Params: []openAPIParam{
+		storageParam,
+	}},
+	{Method: "POST", Path: "/backup/upload/{name}", OperationId: "upload", Summary: "Upload backup to remote storage", Response: "BackupOperation", Params: []openAPIParam{
+		pathParam("name", "local backup name"),
+		queryParam("delete-source", "boolean", "same as --delete-source"),
+		queryParam("diff-from", "string", "same as --diff-from"),
+		queryParam("diff-from-remote", "string", "same as --diff-from-remote"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("resumable", "boolean", "same as --resumable"),
+		queryParam("resume", "boolean", "same as --resume"),
+		storageParam,
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/download/{name}", OperationId: "download", Summary: "Download backup from remote storage", Response: "BackupOperation", Params: []openAPIParam{
+		pathParam("name", "remote backup name"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("resumable", "boolean", "same as --resumable"),
+		queryParam("resume", "boolean", "same as --resume"),
+		storageParam,
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/restore/{name}", OperationId: "restore", Summary: "Create schema and restore data from local backup", Response: "BackupOperation", Params: []openAPIParam{
+		pathParam("name", "local backup name"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParamMultiple("restore_database_mapping", "same as --restore-database-mapping"),
+		queryParamMultiple("restore_table_mapping", "same as --restore-table-mapping"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("data", "boolean", "same as --data"),
+		queryParam("drop", "boolean", "same as --drop"),
+		queryParam("rm", "boolean", "same as --rm"),
+		queryParam("ignore_dependencies", "boolean", "same as --ignore-dependencies"),
+		queryParam("rbac", "boolean", "same as --rbac"),
+		queryParam("rbac-only", "boolean", "same as --rbac-only"),
+		queryParam("configs", "boolean", "same as --configs"),
+		queryParam("configs-only", "boolean", "same as --configs-only"),
+		queryParam("resumable", "boolean", "same as --resumable"),
+		queryParam("resume", "boolean", "same as --resume"),
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/create_remote", OperationId: "createRemote", Summary: "Create new backup and upload it to remote storage", Response: "BackupOperation", ResponseStatus: http.StatusCreated, Params: []openAPIParam{
+		queryParam("name", "string", "backup name, generated when omitted"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParam("diff-from", "string", "same as --diff-from"),
+		queryParam("diff-from-remote", "string", "same as --diff-from-remote"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("rbac", "boolean", "same as --rbac"),
+		queryParam("rbac-only", "boolean", "same as --rbac-only"),
+		queryParam("configs", "boolean", "same as --configs"),
+		queryParam("configs-only", "boolean", "same as --configs-only"),
+		queryParam("skip-check-parts-columns", "boolean", "same as --skip-check-parts-columns"),
+		queryParam("resumable", "boolean", "same as --resumable"),
+		queryParam("resume", "boolean", "same as --resume"),
+		queryParam("delete-source", "boolean", "same as --delete-source"),
+		queryParam("storage", "string", "same as --storage, comma separated list of storages from `storages` config section uploads the same backup to each storage"),
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/restore_remote/{name}", OperationId: "restoreRemote", Summary: "Download backup from remote storage and restore it", Response: "BackupOperation", Params: []openAPIParam{
+		pathParam("name", "remote backup name"),
+		queryParam("table", "string", "same as --tables"),
+		queryParamMultiple("partitions", "same as --partitions"),
+		queryParamMultiple("restore_database_mapping", "same as --restore-database-mapping"),
+		queryParamMultiple("restore_table_mapping", "same as --restore-table-mapping"),
+		queryParam("schema", "boolean", "same as --schema"),
+		queryParam("data", "boolean", "same as --data"),
+		queryParam("drop", "boolean", "same as --drop"),
+		queryParam("rm", "boolean", "same as --rm"),
+		queryParam("ignore_dependencies", "boolean", "same as --ignore-dependencies"),
+		queryParam("rbac", "boolean", "same as --rbac"),
+		queryParam("rbac-only", "boolean", "same as --rbac-only"),
+		queryParam("configs", "boolean", "same as --configs"),
+		queryParam("configs-only", "boolean", "same as --configs-only"),
+		queryParam("resumable", "boolean", "same as --resumable"),
+		queryParam("resume", "boolean", "same as --resume"),
+		storageParam,
+		priorityParam,
+		callbackParam,
+	}},
+	{Method: "POST", Path: "/backup/delete/{where}/{name}", OperationId: "delete", Summary: "Delete local or remote backup", Response: "DeleteStatus", Params: []openAPIParam{
+		pathParam("where", "`local` or `remote`"),
+		pathParam("name", "backup name"),
+		storageParam,
+		priorityParam,
+	}},
+	{Method: "GET", Path: "/backup/status", OperationId: "status", Summary: "Show last running asynchronous operation", Response: "ActionStatus"},
+	{Method: "GET", Path: "/backup/queue", OperationId: "queue", Summary: "List of running and pending operations when `api->queue_enabled: true`", Response: "QueueItem"},
+	{Method: "GET", Path: "/backup/actions", OperationId: "actionsLog", Summary: "List of all operations from start of API server", Response: "ActionStatus", Params: []openAPIParam{
+		queryParam("filter", "string", "filter actions by command, status or error substring"),
+		queryParam("last", "integer", "show only last N actions"),
+	}},
+	{Method: "POST", Path: "/backup/actions", OperationId: "actions", Summary: "Execute commands, one JSON object per line", RequestBody: "Action", Response: "ActionResult"},
+	{Method: "GET", Path: "/backup/actions/{id}/stream", OperationId: "actionStream", Summary: "Stream log lines and progress of command as Server-Sent Events until it finished", Response: "eventStream", Params: []openAPIParam{
+		pathParam("id", "`id` from GET /backup/actions or `operation_id` returned by async commands"),
+	}},
+	{Method: "GET", Path: "/openapi.json", OperationId: "openapi", Summary: "OpenAPI 3 specification of this API", Response: "object"},
+}
+
+func stringProperty(description string) map[string]interface{} {
+	p := map[string]interface{}{"type": "string"}
+	if description != "" {
+		p["description"] = description
+	}
+	return p
+}
+
+func objectSchema(required []string, properties map[string]interface{}) map[string]interface{} {
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// openAPISchemas - shapes of JSON rows returned by handlers, keep in sync with structs in server.go and pkg/client
+var openAPISchemas = map[string]interface{}{
+	"Error": objectSchema([]string{"status", "error"}, map[string]interface{}{
+		"status":    stringProperty("always `error`"),
+		"operation": stringProperty(""),
+		"error":     stringProperty(""),
+	}),
+	"OperationStatus": objectSchema([]string{"status", "operation"}, map[string]interface{}{
+		"status":    stringProperty("`acknowledged` or `success`"),
+		"operation": stringProperty(""),
+	}),
+	"CommandStatus": objectSchema([]string{"status", "operation"}, map[string]interface{}{
+		"status":    stringProperty(""),
+		"operation": stringProperty(""),
+		"command":   stringProperty(""),
+	}),
+	"KillStatus": objectSchema([]string{"status", "operation"}, map[string]interface{}{
+		"status":    stringProperty(""),
+		"operation": stringProperty(""),
+		"command":   stringProperty(""),
+		"error":     stringProperty(""),
+	}),
+	"Version": objectSchema([]string{"version"}, map[string]interface{}{
+		"version": stringProperty(""),
+	}),
+	"BackupOperation": objectSchema([]string{"status", "operation", "backup_name"}, map[string]interface{}{
+		"status":       stringProperty("`acknowledged`, or `queued` when operation waits in queue"),
+		"operation":    stringProperty(""),
+		"backup_name":  stringProperty(""),
+		"backup_from":  stringProperty(""),
+		"diff":         map[string]interface{}{"type": "boolean"},
+		"operation_id": stringProperty("random UUID, passed to callback, for duplicated queued operation return operation_id of already queued one"),
+	}),
+	"DeleteStatus": objectSchema([]string{"status", "operation", "backup_name", "location"}, map[string]interface{}{
+		"status":      stringProperty(""),
+		"operation":   stringProperty(""),
+		"backup_name": stringProperty(""),
+		"location":    stringProperty("`local` or `remote`"),
+	}),
+	"Backup": objectSchema([]string{"name", "created", "location"}, map[string]interface{}{
+		"name":     stringProperty(""),
+		"created":  stringProperty("format 2006-01-02 15:04:05"),
+		"size":     map[string]interface{}{"type": "integer", "format": "uint64"},
+		"location": stringProperty("`local` or `remote`"),
+		"required": stringProperty("required backup name for incremental backup"),
+		"desc":     stringProperty("data format, broken reason and tags"),
+	}),
+	"Table": objectSchema([]string{"Database", "Name"}, map[string]interface{}{
+		"Database":         stringProperty(""),
+		"Name":             stringProperty(""),
+		"Engine":           stringProperty(""),
+		"DataPath":         stringProperty(""),
+		"DataPaths":        map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		"UUID":             stringProperty(""),
+		"CreateTableQuery": stringProperty(""),
+		"TotalBytes":       map[string]interface{}{"type": "integer", "format": "uint64"},
+		"Skip":             map[string]interface{}{"type": "boolean"},
+		"BackupType":       stringProperty("`full`, `none` or `schema-only`"),
+	}),
+	"ActionStatus": objectSchema([]string{"id", "command", "status"}, map[string]interface{}{
+		"id":           map[string]interface{}{"type": "integer"},
+		"command":      stringProperty(""),
+		"status":       stringProperty("`in progress`, `success`, `cancel` or `error`"),
+		"start":        stringProperty(""),
+		"finish":       stringProperty(""),
+		"error":        stringProperty(""),
+		"operation_id": stringProperty(""),
+		"step":         stringProperty("current sub-step of `create_remote` and `restore_remote`, failed sub-step when error happens"),
+	}),
+	"Action": objectSchema([]string{"command"}, map[string]interface{}{
+		"command": stringProperty("CLI command line, for example `create backup_name`"),
+	}),
+	"ActionResult": objectSchema([]string{"status", "operation"}, map[string]interface{}{
+		"status":       stringProperty("`acknowledged`, `queued` or `success`"),
+		"operation":    stringProperty(""),
+		"operation_id": stringProperty("present for asynchronous commands, could be used with /backup/actions/{id}/stream"),
+	}),
+	"QueueItem": objectSchema([]string{"id", "command", "operation", "priority", "status", "enqueued"}, map[string]interface{}{
+		"id":           map[string]interface{}{"type": "integer"},
+		"command":      stringProperty("full command, the same command is not queued twice"),
+		"operation":    stringProperty("operation name, used for `api->queue_command_concurrency`"),
+		"priority":     map[string]interface{}{"type": "integer"},
+		"status":       stringProperty("`queued` or `running`"),
+		"enqueued":     stringProperty("format 2006-01-02 15:04:05"),
+		"start":        stringProperty("format 2006-01-02 15:04:05"),
+		"operation_id": stringProperty("present for asynchronous operations"),
+	}),
+	"CallbackResponse": objectSchema([]string{"status", "operation_id"}, map[string]interface{}{
+		"status":       stringProperty("`success` or `error`"),
+		"error":        stringProperty(""),
+		"operation_id": stringProperty(""),
+	}),
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonEachRowContent - all handlers return one JSON object per line, describe it as `application/x-ndjson`
+func jsonEachRowContent(name string) map[string]interface{} {
+	switch name {
+	case "text":
+		return map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	case "object":
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}}
+	case "eventStream":
+		return map[string]interface{}{"text/event-stream": map[string]interface{}{
+			"schema": map[string]interface{}{
+				"type":        "string",
+				"description": "events `status` and `end` contain ActionStatus, `log` contains JSON log line, `progress` contains progress, operation and table fields",
+			},
+		}}
+	}
+	return map[string]interface{}{
+		"application/json":     map[string]interface{}{"schema": schemaRef(name)},
+		"application/x-ndjson": map[string]interface{}{"schema": schemaRef(name)},
+	}
+}
+
+// buildOpenAPISpec - generate OpenAPI 3 document from openAPIOperations
+func buildOpenAPISpec(version string) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, op := range openAPIOperations {
+		pathItem, exists := paths[op.Path]
+		if !exists {
+			pathItem = map[string]interface{}{}
+			paths[op.Path] = pathItem
+		}
+		params := make([]interface{}, 0, len(op.Params))
+		for _, p := range op.Params {
+			schema := map[string]interface{}{"type": p.Type}
+			if p.Multiple {
+				schema = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": p.Type}}
+			}
+			param := map[string]interface{}{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.Required,
+				"schema":   schema,
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Multiple {
+				param["explode"] = true
+			}
+			params = append(params, param)
+		}
+		responseStatus := op.ResponseStatus
+		if responseStatus == 0 {
+			responseStatus = http.StatusOK
+		}
+		operation := map[string]interface{}{
+			"operationId": op.OperationId,
+			"summary":     op.Summary,
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				strconv.Itoa(responseStatus): map[string]interface{}{
+					"description": http.StatusText(responseStatus),
+					"content":     jsonEachRowContent(op.Response),
+				},
+				"default": map[string]interface{}{
+					"description": "error",
+					"content":     jsonEachRowContent("Error"),
+				},
+			},
+		}
+		if op.RequestBody != "" {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonEachRowContent(op.RequestBody),
+			}
+		}
+		pathItem.(map[string]interface{})[strings.ToLower(op.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "clickhouse-backup REST API",
+			"version":     version,
+			"description": "Responses are JSONEachRow, one JSON object per line, https://github.com/Altinity/clickhouse-backup#api",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+			"securitySchemes": map[string]interface{}{
+				"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"basicAuth": []interface{}{}}},
+	}
+}
+
+// httpOpenAPIHandler - serve OpenAPI 3 specification
+func (api *APIServer) httpOpenAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	api.sendJSONEachRow(w, http.StatusOK, buildOpenAPISpec(api.cliApp.Version))
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/urfave/cli"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	api := &APIServer{
+		cliApp: cli.NewApp(),
+		config: config.DefaultConfig(),
+	}
+	srv := api.registerHTTPHandlers()
+	router, ok := srv.Handler.(*mux.Router)
+	if !ok {
+		t.Fatalf("unexpected handler type %T", srv.Handler)
+	}
+	spec := buildOpenAPISpec("test")
+	paths := spec["paths"].(map[string]interface{})
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		pathTemplate, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// routes from registerMetricsHandlers don't have methods
+			return nil
+		}
+		pathItem, exists := paths[pathTemplate]
+		if !exists {
+			t.Errorf("route %s not described in openAPIOperations", pathTemplate)
+			return nil
+		}
+		for _, method := range methods {
+			if method == http.MethodHead {
+				continue
+			}
+			if _, exists := pathItem.(map[string]interface{})[strings.ToLower(method)]; !exists {
+				t.Errorf("route %s %s not described in openAPIOperations", method, pathTemplate)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("router.Walk return error: %v", err)
+	}
+
+	operationIds := map[string]struct{}{}
+	for _, op := range openAPIOperations {
+		if _, exists := operationIds[op.OperationId]; exists {
+			t.Errorf("duplicate operationId %s", op.OperationId)
+		}
+		operationIds[op.OperationId] = struct{}{}
+		switch op.Response {
+		case "text", "object", "eventStream":
+		default:
+			if _, exists := openAPISchemas[op.Response]; !exists {
+				t.Errorf("%s response schema %s not defined", op.OperationId, op.Response)
+			}
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	api := &APIServer{
+		cliApp: cli.NewApp(),
+		config: config.DefaultConfig(),
+	}
+	api.cliApp.Version = "1.2.3"
+	w := httptest.NewRecorder()
+	api.httpOpenAPIHandler(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	spec := struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			OperationId string `json:"operationId"`
+		} `json:"paths"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("can't parse /openapi.json: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" || spec.Info.Version != "1.2.3" {
+		t.Fatalf("unexpected header openapi=%s version=%s", spec.OpenAPI, spec.Info.Version)
+	}
+	if spec.Paths["/backup/upload/{name}"]["post"].OperationId != "upload" {
+		t.Fatalf("unexpected /backup/upload/{name} description %#v", spec.Paths["/backup/upload/{name}"])
+	}
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/common"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+var (
+	ErrQueueFull     = errors.New("operation queue is full")
+	ErrQueueCanceled = errors.New("queued operation canceled")
+)
+
+const (
+	queueStatusQueued  = "queued"
+	queueStatusRunning = "running"
+)
+
+// queueRow - operation queue state, returned by GET /backup/queue
+type queueRow struct {
+	Id          int    `json:"id"`
+	Command     string `json:"command"`
+	Operation   string `json:"operation"`
+	Priority    int    `json:"priority"`
+	Status      string `json:"status"`
+	Enqueued    string `json:"enqueued"`
+	Start       string `json:"start,omitempty"`
+	OperationId string `json:"operation_id,omitempty"`
+}
+
+type queueEntry struct {
+	queueRow
+	seq int
+	// dedupe - async operations with the same full command and callbacks are merged, synchronous callers always wait own turn
+	dedupe bool
+	// callbacks - `callback` query parameters, caller of merged operation shall receive own callbacks, so they are part of dedupe key
+	callbacks []string
+	// enqueue - called under queue lock when entry added to pending list and not merged with existing one
+	enqueue func()
+	start   func()
+	cancel  func(error)
+}
+
+// operationQueue - replace 423 Locked responses when `api->queue_enabled: true`,
+// pending operations start by priority (higher first) and FIFO order inside the same priority,
+// limited by `api->queue_max_concurrency` and `api->queue_command_concurrency`.
+// Only operations which passed through the queue are counted, `kill` is not queued, `watch` waits for slot before each iteration.
+type operationQueue struct {
+	getConfig func() *config.APIConfig
+	pending   []*queueEntry
+	running   []*queueEntry
+	lastSeq   int
+	sync.Mutex
+}
+
+func newOperationQueue(getConfig func() *config.APIConfig) *operationQueue {
+	return &operationQueue{
+		getConfig: getConfig,
+		pending:   make([]*queueEntry, 0),
+		running:   make([]*queueEntry, 0),
+	}
+}
+
+// push - add entry to pending list, return state of already queued or running entry when the same command deduplicated
+func (q *operationQueue) push(entry *queueEntry) (queueRow, error) {
+	q.Lock()
+	defer q.Unlock()
+	if entry.dedupe {
+		for _, list := range [][]*queueEntry{q.running, q.pending} {
+			for _, existing := range list {
+				if existing.dedupe && existing.dedupeKey() == entry.dedupeKey() {
+					log.Info().Str("operation_id", existing.OperationId).Msgf("%s already %s, skip duplicate", existing.Command, existing.Status)
+					return existing.queueRow, nil
+				}
+			}
+		}
+	}
+	if len(q.pending) >= q.getConfig().QueueSize {
+		return queueRow{}, ErrQueueFull
+	}
+	q.lastSeq++
+	entry.seq = q.lastSeq
+	entry.Id = q.lastSeq
+	entry.Status = queueStatusQueued
+	entry.Enqueued = time.Now().Format(common.TimeFormat)
+	if entry.enqueue != nil {
+		entry.enqueue()
+	}
+	q.pending = append(q.pending, entry)
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].Priority != q.pending[j].Priority {
+			return q.pending[i].Priority > q.pending[j].Priority
+		}
+		return q.pending[i].seq < q.pending[j].seq
+	})
+	q.dispatch()
+	return entry.queueRow, nil
+}
+
+func (entry *queueEntry) dedupeKey() string {
+	return entry.Command + "\n" + strings.Join(entry.callbacks, "\n")
+}
+
+// dispatch - start pending entries while concurrency limits allow, shall be called under lock
+func (q *operationQueue) dispatch() {
+	cfg := q.getConfig()
+	runningByOperation := map[string]int{}
+	for _, entry := range q.running {
+		runningByOperation[entry.Operation]++
+	}
+	pending := make([]*queueEntry, 0, len(q.pending))
+	for _, entry := range q.pending {
+		if len(q.running) >= cfg.QueueMaxConcurrency {
+			pending = append(pending, entry)
+			continue
+		}
+		if limit, exists := cfg.QueueCommandConcurrency[entry.Operation]; exists && runningByOperation[entry.Operation] >= limit {
+			pending = append(pending, entry)
+			continue
+		}
+		entry.Status = queueStatusRunning
+		entry.Start = time.Now().Format(common.TimeFormat)
+		q.running = append(q.running, entry)
+		runningByOperation[entry.Operation]++
+		go entry.start()
+	}
+	q.pending = pending
+}
+
+// done - release concurrency slot and start next pending entries
+func (q *operationQueue) done(entry *queueEntry) {
+	q.Lock()
+	defer q.Unlock()
+	for i, runningEntry := range q.running {
+		if runningEntry == entry {
+			q.running = append(q.running[:i], q.running[i+1:]...)
+			break
+		}
+	}
+	q.dispatch()
+}
+
+// remove - remove entry from pending list, return false when entry already started
+func (q *operationQueue) remove(entry *queueEntry) bool {
+	q.Lock()
+	defer q.Unlock()
+	for i, pendingEntry := range q.pending {
+		if pendingEntry == entry {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// cancelPending - drop all pending entries with error, running entries are canceled via status.Current.CancelAll
+func (q *operationQueue) cancelPending(err error) {
+	q.Lock()
+	pending := q.pending
+	q.pending = make([]*queueEntry, 0)
+	q.Unlock()
+	for _, entry := range pending {
+		log.Warn().Str("operation_id", entry.OperationId).Msgf("%s removed from queue: %v", entry.Command, err)
+		if entry.cancel != nil {
+			entry.cancel(err)
+		}
+	}
+}
+
+// list - running entries first, then pending entries in start order
+func (q *operationQueue) list() []queueRow {
+	q.Lock()
+	defer q.Unlock()
+	rows := make([]queueRow, 0, len(q.running)+len(q.pending))
+	for _, entry := range q.running {
+		rows = append(rows, entry.queueRow)
+	}
+	for _, entry := range q.pending {
+		rows = append(rows, entry.queueRow)
+	}
+	return rows
+}
+
+func (api *APIServer) queueEnabled() bool {
+	return api.config.API.QueueEnabled && api.queue != nil
+}
+
+// isLocked - when queue disabled and `api->allow_parallel: false`, reject operation if any other is in progress
+func (api *APIServer) isLocked() bool {
+	return !api.queueEnabled() && !api.config.API.AllowParallel && status.Current.InProgress()
+}
+
+// getPriority - parse `priority` query parameter, higher priority operations leave queue first
+func (api *APIServer) getPriority(query map[string][]string) (int, error) {
+	if priority, exists := api.getQueryParameter(query, "priority"); exists && priority != "" {
+		return strconv.Atoi(priority)
+	}
+	return 0, nil
+}
+
+// startAsync - start async operation immediately or put it into queue, return "acknowledged" or "queued" status and operation_id,
+// run shall call status.Current.Stop for passed commandId, queued operation has `queued` status row from enqueue,
+// when it removed from queue without start, status row finished with error and error callbacks are called,
+// operation_id differs from passed one when the same command with the same callbacks already queued or running
+func (api *APIServer) startAsync(operation, fullCommand string, priority int, operationId string, callbacks []string, run func(commandId int)) (string, string, error) {
+	if !api.queueEnabled() {
+		commandId, _ := status.Current.Start(fullCommand)
+		status.Current.SetOperationId(commandId, operationId)
+		go run(commandId)
+		return "acknowledged", operationId, nil
+	}
+	entry := &queueEntry{
+		queueRow: queueRow{
+			Command:     fullCommand,
+			Operation:   operation,
+			Priority:    priority,
+			OperationId: operationId,
+		},
+		dedupe:    true,
+		callbacks: callbacks,
+	}
+	commandId := status.NotFromAPI
+	entry.enqueue = func() {
+		commandId = status.Current.Enqueue(fullCommand)
+		status.Current.SetOperationId(commandId, operationId)
+	}
+	entry.start = func() {
+		defer api.queue.done(entry)
+		status.Current.Run(commandId)
+		run(commandId)
+	}
+	entry.cancel = func(err error) {
+		status.Current.Stop(commandId, err)
+		if callback, callbackErr := parseCallback(url.Values{"callback": callbacks}); callbackErr == nil {
+			api.errorCallback(context.Background(), err, operationId, callback)
+		}
+	}
+	queued, err := api.queue.push(entry)
+	if err != nil {
+		return "", "", err
+	}
+	if queued.Status == queueStatusRunning {
+		return "acknowledged", queued.OperationId, nil
+	}
+	return queued.Status, queued.OperationId, nil
+}
+
+// waitQueue - block synchronous operation until queue allows it, returned function shall be called when operation finished
+func (api *APIServer) waitQueue(ctx context.Context, operation, fullCommand string, priority int) (func(), error) {
+	if !api.queueEnabled() {
+		return func() {}, nil
+	}
+	ready := make(chan error, 1)
+	entry := &queueEntry{
+		queueRow: queueRow{
+			Command:   fullCommand,
+			Operation: operation,
+			Priority:  priority,
+		},
+		start: func() {
+			ready <- nil
+		},
+		cancel: func(err error) {
+			ready <- err
+		},
+	}
+	if _, err := api.queue.push(entry); err != nil {
+		return nil, err
+	}
+	select {
+	case err := <-ready:
+		if err != nil {
+			return nil, err
+		}
+		return func() { api.queue.done(entry) }, nil
+	case <-ctx.Done():
+		if !api.queue.remove(entry) {
+			api.queue.done(entry)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// watchQueueGate - each watch iteration waits in queue as `create_remote` operation
+func (api *APIServer) watchQueueGate(ctx context.Context, operation, command string) (func(), error) {
+	return api.waitQueue(ctx, operation, command, 0)
+}
+
+// writeQueueError - queue overflow and canceled waiting are temporary conditions, client could retry later
+func (api *APIServer) writeQueueError(w http.ResponseWriter, operation string, err error) {
+	log.Warn().Str("operation", operation).Err(err).Send()
+	if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueCanceled) || errors.Is(err, context.Canceled) {
+		api.writeError(w, http.StatusServiceUnavailable, operation, err)
+		return
+	}
+	api.writeError(w, http.StatusInternalServerError, operation, err)
+}
+
+// httpQueueHandler - show running and pending operations of queue
+func (api *APIServer) httpQueueHandler(w http.ResponseWriter, _ *http.Request) {
+	if api.queue == nil {
+		api.sendJSONEachRow(w, http.StatusOK, []queueRow{})
+		return
+	}
+	api.sendJSONEachRow(w, http.StatusOK, api.queue.list())
+}
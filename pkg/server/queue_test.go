@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+func TestOperationQueue(t *testing.T) {
+	r := require.New(t)
+	cfg := config.DefaultConfig()
+	cfg.API.QueueEnabled = true
+	cfg.API.QueueSize = 3
+	cfg.API.QueueMaxConcurrency = 2
+	cfg.API.QueueCommandConcurrency = map[string]int{"upload": 1}
+	q := newOperationQueue(func() *config.APIConfig { return &cfg.API })
+
+	var mu sync.Mutex
+	started := make([]string, 0)
+	entries := map[string]*queueEntry{}
+	push := func(operation, command string, priority int) (queueRow, error) {
+		entry := &queueEntry{
+			queueRow: queueRow{Command: command, Operation: operation, Priority: priority, OperationId: command},
+			dedupe:   true,
+		}
+		entry.start = func() {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, command)
+		}
+		mu.Lock()
+		entries[command] = entry
+		mu.Unlock()
+		return q.push(entry)
+	}
+	startedCommands := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		result := make([]string, len(started))
+		copy(result, started)
+		return result
+	}
+
+	row, err := push("upload", "upload backup1", 0)
+	r.NoError(err)
+	r.Equal(queueStatusRunning, row.Status)
+	// upload limited by queue_command_concurrency
+	row, err = push("upload", "upload backup2", 0)
+	r.NoError(err)
+	r.Equal(queueStatusQueued, row.Status)
+	row, err = push("create", "create backup3", 0)
+	r.NoError(err)
+	r.Equal(queueStatusRunning, row.Status)
+	// limited by queue_max_concurrency
+	_, err = push("download", "download backup4", 0)
+	r.NoError(err)
+	_, err = push("download", "download backup5", 10)
+	r.NoError(err)
+	// duplicate doesn't consume queue_size
+	row, err = push("download", "download backup4", 0)
+	r.NoError(err)
+	r.Equal("download backup4", row.OperationId)
+	r.Equal(queueStatusQueued, row.Status)
+	_, err = push("create", "create backup6", 0)
+	r.True(errors.Is(err, ErrQueueFull))
+
+	rows := q.list()
+	r.Len(rows, 5)
+	r.Equal([]string{"upload backup1", "create backup3", "download backup5", "upload backup2", "download backup4"}, []string{rows[0].Command, rows[1].Command, rows[2].Command, rows[3].Command, rows[4].Command})
+
+	// higher priority first, then upload backup2 still waits for upload backup1
+	q.done(entries["create backup3"])
+	q.done(entries["download backup5"])
+	r.Eventually(func() bool {
+		return len(startedCommands()) == 4
+	}, time.Second, 10*time.Millisecond)
+	r.ElementsMatch([]string{"upload backup1", "create backup3", "download backup5", "download backup4"}, startedCommands())
+	q.done(entries["upload backup1"])
+	r.Eventually(func() bool {
+		return len(startedCommands()) == 5
+	}, time.Second, 10*time.Millisecond)
+	r.Equal("upload backup2", startedCommands()[4])
+}
+
+func TestWaitQueueCanceled(t *testing.T) {
+	r := require.New(t)
+	api := &APIServer{config: config.DefaultConfig()}
+	api.config.API.QueueEnabled = true
+	api.queue = newOperationQueue(func() *config.APIConfig { return &api.config.API })
+
+	release, err := api.waitQueue(context.Background(), "delete", "delete local backup1", 0)
+	r.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = api.waitQueue(ctx, "delete", "delete local backup2", 0)
+	r.True(errors.Is(err, context.DeadlineExceeded))
+	r.Len(api.queue.list(), 1)
+
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := api.waitQueue(context.Background(), "clean", "clean", 0)
+		waitErr <- err
+	}()
+	r.Eventually(func() bool {
+		return len(api.queue.list()) == 2
+	}, time.Second, 10*time.Millisecond)
+	api.queue.cancelPending(ErrQueueCanceled)
+	r.True(errors.Is(<-waitErr, ErrQueueCanceled))
+	release()
+	r.Len(api.queue.list(), 0)
+}
+
+func TestOperationQueueDedupeCallbacks(t *testing.T) {
+	r := require.New(t)
+	cfg := config.DefaultConfig()
+	cfg.API.QueueEnabled = true
+	cfg.API.QueueMaxConcurrency = 1
+	q := newOperationQueue(func() *config.APIConfig { return &cfg.API })
+	push := func(operationId string, callbacks []string) queueRow {
+		entry := &queueEntry{
+			queueRow:  queueRow{Command: "create backup1", Operation: "create", OperationId: operationId},
+			dedupe:    true,
+			callbacks: callbacks,
+			start:     func() {},
+		}
+		row, err := q.push(entry)
+		r.NoError(err)
+		return row
+	}
+	r.Equal("op1", push("op1", []string{"http://callback1"}).OperationId)
+	r.Equal("op1", push("op2", []string{"http://callback1"}).OperationId)
+	// different callbacks shall not be lost
+	r.Equal("op3", push("op3", []string{"http://callback2"}).OperationId)
+	r.Equal("op4", push("op4", nil).OperationId)
+	r.Len(q.list(), 3)
+}
+
+func TestStartAsyncQueuedStatus(t *testing.T) {
+	r := require.New(t)
+	api := &APIServer{config: config.DefaultConfig()}
+	api.config.API.QueueEnabled = true
+	api.config.API.QueueMaxConcurrency = 1
+	api.queue = newOperationQueue(func() *config.APIConfig { return &api.config.API })
+
+	callbackPayload := make(chan CallbackResponse, 1)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload CallbackResponse
+		_ = json.NewDecoder(req.Body).Decode(&payload)
+		callbackPayload <- payload
+	}))
+	defer callbackServer.Close()
+
+	running := make(chan struct{})
+	finish := make(chan struct{})
+	ackStatus, _, err := api.startAsync("upload", "upload queued_status1", 0, "queued-status-op1", nil, func(commandId int) {
+		close(running)
+		<-finish
+		status.Current.Stop(commandId, nil)
+	})
+	r.NoError(err)
+	r.Equal("acknowledged", ackStatus)
+	<-running
+
+	ackStatus, operationId, err := api.startAsync("upload", "upload queued_status2", 0, "queued-status-op2", []string{callbackServer.URL}, func(commandId int) {
+		status.Current.Stop(commandId, nil)
+	})
+	r.NoError(err)
+	r.Equal(queueStatusQueued, ackStatus)
+	// operation_id returned by API shall be visible in /backup/actions and /backup/actions/{id}/stream before start
+	queued, err := status.Current.FindCommand(operationId)
+	r.NoError(err)
+	r.Equal(status.QueuedStatus, queued.Status)
+	_, _, unsubscribe, err := status.Current.SubscribeLogs(queued.Id)
+	r.NoError(err)
+	unsubscribe()
+
+	api.queue.cancelPending(ErrQueueCanceled)
+	canceled, err := status.Current.GetCommandStatus(queued.Id)
+	r.NoError(err)
+	r.Equal(status.ErrorStatus, canceled.Status)
+	r.Equal(ErrQueueCanceled.Error(), canceled.Error)
+	select {
+	case payload := <-callbackPayload:
+		r.Equal("error", payload.Status)
+		r.Equal("queued-status-op2", payload.OperationId)
+	case <-time.After(time.Second):
+		t.Fatal("error callback not called for canceled queued operation")
+	}
+	close(finish)
+}
@@ -50,6 +50,7 @@ type APIServer struct {
 	metrics                 *metrics.APIMetrics
 	routes                  []string
 	clickhouseBackupVersion string
+	queue                   *operationQueue
 }
 
 var (
@@ -91,6 +92,7 @@ func Run(cliCtx *cli.Context, cliApp *cli.App, configPath string, clickhouseBack
 		metrics:                 metrics.NewAPIMetrics(),
 		stop:                    make(chan struct{}),
 	}
+	api.queue = newOperationQueue(func() *config.APIConfig { return &api.config.API })
 	if cfg.API.CreateIntegrationTables {
 		if err := api.CreateIntegrationTables(); err != nil {
 			log.Error().Err(err).Send()
@@ -154,11 +156,11 @@ func (api *APIServer) GetMetrics() *metrics.APIMetrics {
 
 func (api *APIServer) RunWatch(cliCtx *cli.Context) {
 	log.Info().Msg("Starting API Server in watch mode")
-	b := backup.NewBackuper(api.config)
+	b := backup.NewBackuper(api.config, backup.WithQueueGate(api.watchQueueGate))
 	commandId, _ := status.Current.Start("watch")
 	err := b.Watch(
 		cliCtx.String("watch-interval"), cliCtx.String("full-interval"), cliCtx.String("watch-backup-name-template"),
-		"*.*", nil, false, false, false, false,
+		"*.*", nil, false, false, false, false, false, false, config.GetStorageNamesFromCli(cliCtx),
 		api.clickhouseBackupVersion, commandId, api.GetMetrics(), cliCtx,
 	)
 	api.handleWatchResponse(commandId, err)
@@ -166,6 +168,9 @@ func (api *APIServer) RunWatch(cliCtx *cli.Context) {
 
 // Stop cancel all running commands, @todo think about graceful period
 func (api *APIServer) Stop() error {
+	if api.queue != nil {
+		api.queue.cancelPending(ErrQueueCanceled)
+	}
 	status.Current.CancelAll("canceled during server stop")
 	return api.server.Close()
 }
@@ -175,6 +180,9 @@ func (api *APIServer) Restart() error {
 	if err != nil {
 		return err
 	}
+	if api.queue != nil {
+		api.queue.cancelPending(ErrQueueCanceled)
+	}
 	status.Current.CancelAll("canceled via API /restart")
 	if api.server != nil {
 		_ = api.server.Close()
@@ -234,11 +242,16 @@ func (api *APIServer) registerHTTPHandlers() *http.Server {
 	r.HandleFunc("/backup/upload/{name}", api.httpUploadHandler).Methods("POST")
 	r.HandleFunc("/backup/download/{name}", api.httpDownloadHandler).Methods("POST")
 	r.HandleFunc("/backup/restore/{name}", api.httpRestoreHandler).Methods("POST")
+	r.HandleFunc("/backup/create_remote", api.httpCreateRemoteHandler).Methods("POST")
+	r.HandleFunc("/backup/restore_remote/{name}", api.httpRestoreRemoteHandler).Methods("POST")
 	r.HandleFunc("/backup/delete/{where}/{name}", api.httpDeleteHandler).Methods("POST")
 	r.HandleFunc("/backup/status", api.httpBackupStatusHandler).Methods("GET")
+	r.HandleFunc("/backup/queue", api.httpQueueHandler).Methods("GET")
 
 	r.HandleFunc("/backup/actions", api.actionsLog).Methods("GET", "HEAD")
 	r.HandleFunc("/backup/actions", api.actions).Methods("POST")
+	r.HandleFunc("/backup/actions/{id}/stream", api.httpActionStreamHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", api.httpOpenAPIHandler).Methods("GET")
 
 	var routes []string
 	if err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
@@ -304,8 +317,9 @@ func (api *APIServer) basicAuthMiddleware(next http.Handler) http.Handler {
 }
 
 type actionsResultsRow struct {
-	Status    string `json:"status"`
-	Operation string `json:"operation"`
+	Status      string `json:"status"`
+	Operation   string `json:"operation"`
+	OperationId string `json:"operation_id,omitempty"`
 }
 
 // CREATE TABLE system.backup_actions (command String, start DateTime, finish DateTime, status String, error String) ENGINE=URL('http://127.0.0.1:7171/backup/actions?user=user&pass=pass', JSONEachRow)
@@ -386,11 +400,16 @@ func (api *APIServer) actions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *APIServer) actionsDeleteHandler(row status.ActionRow, args []string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		return actionsResults, ErrAPILocked
 	}
+	release, err := api.waitQueue(context.Background(), "delete", row.Command, 0)
+	if err != nil {
+		return actionsResults, err
+	}
+	defer release()
 	commandId, _ := status.Current.Start(row.Command)
-	err := api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
+	err = api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
 	status.Current.Stop(commandId, err)
 	if err != nil {
 		return actionsResults, err
@@ -409,12 +428,12 @@ func (api *APIServer) actionsDeleteHandler(row status.ActionRow, args []string,
 }
 
 func (api *APIServer) actionsAsyncCommandsHandler(command string, args []string, row status.ActionRow, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		return actionsResults, ErrAPILocked
 	}
-	// to avoid race condition between GET /backup/actions and POST /backup/actions
-	commandId, _ := status.Current.Start(row.Command)
-	go func() {
+	// to avoid race condition between GET /backup/actions and POST /backup/actions, command started or queued before response
+	operationId, _ := uuid.NewUUID()
+	ackStatus, ackOperationId, err := api.startAsync(command, row.Command, 0, operationId.String(), nil, func(commandId int) {
 		err, _ := api.metrics.ExecuteWithMetrics(command, 0, func() error {
 			return api.cliApp.Run(append([]string{"clickhouse-backup", "-c", api.configPath, "--command-id", strconv.FormatInt(int64(commandId), 10)}, args...))
 		})
@@ -428,10 +447,14 @@ func (api *APIServer) actionsAsyncCommandsHandler(command string, args []string,
 				log.Error().Msgf("UpdateBackupMetrics return error: %v", err)
 			}
 		}()
-	}()
+	})
+	if err != nil {
+		return actionsResults, err
+	}
 	actionsResults = append(actionsResults, actionsResultsRow{
-		Status:    "acknowledged",
-		Operation: row.Command,
+		Status:      ackStatus,
+		Operation:   row.Command,
+		OperationId: ackOperationId,
 	})
 	return actionsResults, nil
 }
@@ -455,10 +478,15 @@ func (api *APIServer) actionsKillHandler(row status.ActionRow, args []string, ac
 }
 
 func (api *APIServer) actionsCleanHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Msgf(ErrAPILocked.Error())
 		return actionsResults, ErrAPILocked
 	}
+	release, err := api.waitQueue(context.Background(), "clean", command, 0)
+	if err != nil {
+		return actionsResults, err
+	}
+	defer release()
 	commandId, ctx := status.Current.Start(command)
 	cfg, err := api.ReloadConfig(w, "clean")
 	if err != nil {
@@ -487,10 +515,15 @@ func (api *APIServer) actionsCleanHandler(w http.ResponseWriter, row status.Acti
 }
 
 func (api *APIServer) actionsCleanRemoteBrokenHandler(w http.ResponseWriter, row status.ActionRow, command string, actionsResults []actionsResultsRow) ([]actionsResultsRow, error) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		return actionsResults, ErrAPILocked
 	}
+	release, err := api.waitQueue(context.Background(), "clean_remote_broken", command, 0)
+	if err != nil {
+		return actionsResults, err
+	}
+	defer release()
 	commandId, _ := status.Current.Start(command)
 	cfg, err := api.ReloadConfig(w, "clean_remote_broken")
 	if err != nil {
@@ -532,10 +565,13 @@ func (api *APIServer) actionsWatchHandler(w http.ResponseWriter, row status.Acti
 	schemaOnly := false
 	rbacOnly := false
 	configsOnly := false
+	backupKeeper := false
+	backupNamedCollections := false
 	skipCheckPartsColumns := false
 	watchInterval := ""
 	fullInterval := ""
 	watchBackupNameTemplate := ""
+	var storageNames []string
 	fullCommand := "watch"
 
 	simpleParseArg := func(i int, args []string, paramName string) (bool, string) {
@@ -586,16 +622,33 @@ func (api *APIServer) actionsWatchHandler(w http.ResponseWriter, row status.Acti
 			configsOnly = true
 			fullCommand = fmt.Sprintf("%s --configs", fullCommand)
 		}
+		if matchParam, _ = simpleParseArg(i, args, "--keeper"); matchParam {
+			backupKeeper = true
+			fullCommand = fmt.Sprintf("%s --keeper", fullCommand)
+		}
+		if matchParam, _ = simpleParseArg(i, args, "--named-collections"); matchParam {
+			backupNamedCollections = true
+			fullCommand = fmt.Sprintf("%s --named-collections", fullCommand)
+		}
 		if matchParam, _ = simpleParseArg(i, args, "--skip-check-parts-columns"); matchParam {
 			skipCheckPartsColumns = true
 			fullCommand = fmt.Sprintf("%s --skip-check-parts-columns", fullCommand)
 		}
+		if matchParam, storage := simpleParseArg(i, args, "--storage"); matchParam {
+			storageNames = config.ParseStorageNames(storage)
+			fullCommand = fmt.Sprintf("%s --storage=%s", fullCommand, strings.Join(storageNames, ","))
+		}
+	}
+	if len(storageNames) > 0 {
+		if cfg, err = cfg.WithStorage(storageNames[0]); err != nil {
+			return actionsResults, err
+		}
 	}
 
 	commandId, _ := status.Current.Start(fullCommand)
 	go func() {
-		b := backup.NewBackuper(cfg)
-		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, skipCheckPartsColumns, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
+		b := backup.NewBackuper(cfg, backup.WithQueueGate(api.watchQueueGate))
+		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, backupKeeper, backupNamedCollections, skipCheckPartsColumns, storageNames, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
 		api.handleWatchResponse(commandId, err)
 	}()
 
@@ -713,8 +766,11 @@ func (api *APIServer) httpTablesHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
-	b := backup.NewBackuper(cfg)
 	q := r.URL.Query()
+	if cfg, _, err = api.getStorageConfig(w, "tables", cfg, q); err != nil {
+		return
+	}
+	b := backup.NewBackuper(cfg)
 	var tables []clickhouse.Table
 	// https://github.com/Altinity/clickhouse-backup/issues/778
 	if remoteBackup, exists := api.getQueryParameter(q, "remote_backup"); exists {
@@ -777,6 +833,13 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	where, wherePresent := vars["where"]
 	fullCommand := "list"
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "list", cfg, r.URL.Query()); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + storageNames[0]
+	}
 	if wherePresent {
 		fullCommand += " " + where
 	}
@@ -856,7 +919,7 @@ func (api *APIServer) httpListHandler(w http.ResponseWriter, r *http.Request) {
 
 // httpCreateHandler - create a backup
 func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "create", ErrAPILocked)
 		return
@@ -867,6 +930,7 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 	}
 	tablePattern := ""
 	diffFromRemote := ""
+	since := ""
 	partitionsToBackup := make([]string, 0)
 	backupName := backup.NewBackupName()
 	schemaOnly := false
@@ -874,11 +938,20 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 	rbacOnly := false
 	createConfigs := false
 	configsOnly := false
+	createKeeper := false
+	createNamedCollections := false
 	checkPartsColumns := true
 	resume := false
 	fullCommand := "create"
 	query := r.URL.Query()
 	operationId, _ := uuid.NewUUID()
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "create", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
 
 	if tp, exist := query["table"]; exist {
 		tablePattern = tp[0]
@@ -887,6 +960,10 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 	if baseBackup, exists := api.getQueryParameter(query, "diff-from-remote"); exists {
 		diffFromRemote = baseBackup
 	}
+	if sinceValue, exists := api.getQueryParameter(query, "since"); exists {
+		since = sinceValue
+		fullCommand = fmt.Sprintf("%s --since=\"%s\"", fullCommand, since)
+	}
 	if partitions, exist := query["partitions"]; exist {
 		partitionsToBackup = append(partitionsToBackup, partitions...)
 		fullCommand = fmt.Sprintf("%s --partitions=\"%s\"", fullCommand, strings.Join(partitions, "\" --partitions=\""))
@@ -911,6 +988,14 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 		configsOnly = true
 		fullCommand += " --configs-only"
 	}
+	if _, exist := query["keeper"]; exist {
+		createKeeper = true
+		fullCommand += " --keeper"
+	}
+	if _, exist := api.getQueryParameter(query, "named-collections"); exist {
+		createNamedCollections = true
+		fullCommand += " --named-collections"
+	}
 
 	if _, exist := api.getQueryParameter(query, "skip-check-parts-columns"); exist {
 		checkPartsColumns = true
@@ -924,8 +1009,9 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 
 	if name, exist := query["name"]; exist {
 		backupName = utils.CleanBackupNameRE.ReplaceAllString(name[0], "")
-		fullCommand = fmt.Sprintf("%s %s", fullCommand, backupName)
 	}
+	// generated backup name is a part of command, two unnamed operations shall not be merged by queue dedupe
+	fullCommand = fmt.Sprintf("%s %s", fullCommand, backupName)
 
 	callback, err := parseCallback(query)
 	if err != nil {
@@ -933,12 +1019,16 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 		api.writeError(w, http.StatusBadRequest, "create", err)
 		return
 	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "create", err)
+		return
+	}
 
-	commandId, _ := status.Current.Start(fullCommand)
-	go func() {
+	ackStatus, ackOperationId, err := api.startAsync("create", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
 		err, _ := api.metrics.ExecuteWithMetrics("create", 0, func() error {
 			b := backup.NewBackuper(cfg)
-			return b.CreateBackup(backupName, diffFromRemote, tablePattern, partitionsToBackup, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, checkPartsColumns, resume, api.clickhouseBackupVersion, commandId)
+			return b.CreateBackup(backupName, diffFromRemote, since, tablePattern, partitionsToBackup, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, createKeeper, createNamedCollections, checkPartsColumns, resume, api.clickhouseBackupVersion, commandId)
 		})
 		if err != nil {
 			log.Error().Msgf("API /backup/create error: %v", err)
@@ -954,23 +1044,27 @@ func (api *APIServer) httpCreateHandler(w http.ResponseWriter, r *http.Request)
 
 		status.Current.Stop(commandId, nil)
 		api.successCallback(context.Background(), operationId.String(), callback)
-	}()
+	})
+	if err != nil {
+		api.writeQueueError(w, "create", err)
+		return
+	}
 	api.sendJSONEachRow(w, http.StatusCreated, struct {
 		Status      string `json:"status"`
 		Operation   string `json:"operation"`
 		BackupName  string `json:"backup_name"`
 		OperationId string `json:"operation_id"`
 	}{
-		Status:      "acknowledged",
+		Status:      ackStatus,
 		Operation:   "create",
 		BackupName:  backupName,
-		OperationId: operationId.String(),
+		OperationId: ackOperationId,
 	})
 }
 
 // httpWatchHandler - run watch command go routine, can't run the same watch command twice
 func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "watch", ErrAPILocked)
 		return
@@ -984,6 +1078,8 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 	schemaOnly := false
 	rbacOnly := false
 	configsOnly := false
+	backupKeeper := false
+	backupNamedCollections := false
 	skipCheckPartsColumns := false
 	watchInterval := ""
 	fullInterval := ""
@@ -1028,10 +1124,29 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 			fullCommand = fmt.Sprintf("%s --configs", fullCommand)
 		}
 	}
+	if keeper, exist := query["keeper"]; exist {
+		backupKeeper, _ = strconv.ParseBool(keeper[0])
+		if backupKeeper {
+			fullCommand = fmt.Sprintf("%s --keeper", fullCommand)
+		}
+	}
+	if namedCollections, exist := api.getQueryParameter(query, "named-collections"); exist {
+		backupNamedCollections, _ = strconv.ParseBool(namedCollections)
+		if backupNamedCollections {
+			fullCommand = fmt.Sprintf("%s --named-collections", fullCommand)
+		}
+	}
 	if _, exist := api.getQueryParameter(query, "skip_check_parts_columns"); exist {
 		skipCheckPartsColumns = true
 		fullCommand = fmt.Sprintf("%s --skip-check-parts-columns", fullCommand)
 	}
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "watch", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand = fmt.Sprintf("%s --storage=%s", fullCommand, strings.Join(storageNames, ","))
+	}
 
 	if status.Current.CheckCommandInProgress(fullCommand) {
 		log.Warn().Msgf("%s error: %v", fullCommand, ErrAPILocked)
@@ -1041,8 +1156,8 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 
 	commandId, _ := status.Current.Start(fullCommand)
 	go func() {
-		b := backup.NewBackuper(cfg)
-		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, skipCheckPartsColumns, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
+		b := backup.NewBackuper(cfg, backup.WithQueueGate(api.watchQueueGate))
+		err := b.Watch(watchInterval, fullInterval, watchBackupNameTemplate, tablePattern, partitionsToBackup, schemaOnly, rbacOnly, configsOnly, backupKeeper, backupNamedCollections, skipCheckPartsColumns, storageNames, api.clickhouseBackupVersion, commandId, api.GetMetrics(), api.cliCtx)
 		api.handleWatchResponse(commandId, err)
 	}()
 	api.sendJSONEachRow(w, http.StatusCreated, struct {
@@ -1057,9 +1172,15 @@ func (api *APIServer) httpWatchHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // httpCleanHandler - clean ./shadow directory
-func (api *APIServer) httpCleanHandler(w http.ResponseWriter, _ *http.Request) {
+func (api *APIServer) httpCleanHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	fullCommand := "clean"
+	release, err := api.waitQueue(r.Context(), "clean", fullCommand, 0)
+	if err != nil {
+		api.writeQueueError(w, "clean", err)
+		return
+	}
+	defer release()
 	commandId, ctx := status.Current.Start(fullCommand)
 	b := backup.NewBackuper(api.config)
 	err = b.Clean(ctx)
@@ -1079,11 +1200,20 @@ func (api *APIServer) httpCleanHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 // httpCleanRemoteBrokenHandler - delete all remote backups with `broken` in description
-func (api *APIServer) httpCleanRemoteBrokenHandler(w http.ResponseWriter, _ *http.Request) {
+func (api *APIServer) httpCleanRemoteBrokenHandler(w http.ResponseWriter, r *http.Request) {
 	cfg, err := api.ReloadConfig(w, "clean_remote_broken")
 	if err != nil {
 		return
 	}
+	if cfg, _, err = api.getStorageConfig(w, "clean_remote_broken", cfg, r.URL.Query()); err != nil {
+		return
+	}
+	release, err := api.waitQueue(r.Context(), "clean_remote_broken", "clean_remote_broken", 0)
+	if err != nil {
+		api.writeQueueError(w, "clean_remote_broken", err)
+		return
+	}
+	defer release()
 	commandId, _ := status.Current.Start("clean_remote_broken")
 	defer status.Current.Stop(commandId, err)
 
@@ -1111,7 +1241,7 @@ func (api *APIServer) httpCleanRemoteBrokenHandler(w http.ResponseWriter, _ *htt
 
 // httpUploadHandler - upload a backup to remote storage
 func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "upload", ErrAPILocked)
 		return
@@ -1131,6 +1261,13 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 	schemaOnly := false
 	resume := false
 	fullCommand := "upload"
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "upload", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
 	operationId, _ := uuid.NewUUID()
 
 	if _, exist := api.getQueryParameter(query, "delete-source"); exist {
@@ -1175,9 +1312,13 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 		api.writeError(w, http.StatusBadRequest, "upload", err)
 		return
 	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "upload", err)
+		return
+	}
 
-	go func() {
-		commandId, _ := status.Current.Start(fullCommand)
+	ackStatus, ackOperationId, err := api.startAsync("upload", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
 		err, _ := api.metrics.ExecuteWithMetrics("upload", 0, func() error {
 			b := backup.NewBackuper(cfg)
 			return b.Upload(name, deleteSource, diffFrom, diffFromRemote, tablePattern, partitionsToBackup, schemaOnly, resume, api.cliApp.Version, commandId)
@@ -1195,7 +1336,11 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 		}()
 		status.Current.Stop(commandId, nil)
 		api.successCallback(context.Background(), operationId.String(), callback)
-	}()
+	})
+	if err != nil {
+		api.writeQueueError(w, "upload", err)
+		return
+	}
 	api.sendJSONEachRow(w, http.StatusOK, struct {
 		Status      string `json:"status"`
 		Operation   string `json:"operation"`
@@ -1204,30 +1349,39 @@ func (api *APIServer) httpUploadHandler(w http.ResponseWriter, r *http.Request)
 		Diff        bool   `json:"diff"`
 		OperationId string `json:"operation_id"`
 	}{
-		Status:      "acknowledged",
+		Status:      ackStatus,
 		Operation:   "upload",
 		BackupName:  name,
 		BackupFrom:  diffFrom,
 		Diff:        diffFrom != "",
-		OperationId: operationId.String(),
+		OperationId: ackOperationId,
 	})
 }
 
 var databaseMappingRE = regexp.MustCompile(`[\w+]:[\w+]`)
 var tableMappingRE = regexp.MustCompile(`[\w+]:[\w+]`)
 
-// httpRestoreHandler - restore a backup from local storage
-func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
-		log.Warn().Err(ErrAPILocked).Send()
-		api.writeError(w, http.StatusLocked, "restore", ErrAPILocked)
-		return
-	}
-	_, err := api.ReloadConfig(w, "restore")
-	if err != nil {
-		return
-	}
-	vars := mux.Vars(r)
+// restoreParams - query arguments shared by /backup/restore and /backup/restore_remote
+type restoreParams struct {
+	tablePattern            string
+	databaseMapping         []string
+	tableMapping            []string
+	partitions              []string
+	schemaOnly              bool
+	dataOnly                bool
+	dropExists              bool
+	ignoreDependencies      bool
+	restoreRBAC             bool
+	rbacOnly                bool
+	restoreConfigs          bool
+	configsOnly             bool
+	restoreKeeper           bool
+	restoreNamedCollections bool
+	resume                  bool
+}
+
+// parseRestoreParams - parse restore query arguments, return arguments and fullCommand with appended CLI flags
+func (api *APIServer) parseRestoreParams(query url.Values, fullCommand string) (restoreParams, string, error) {
 	tablePattern := ""
 	databaseMappingToRestore := make([]string, 0)
 	tableMappingToRestore := make([]string, 0)
@@ -1240,11 +1394,10 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 	rbacOnly := false
 	restoreConfigs := false
 	configsOnly := false
+	restoreKeeper := false
+	restoreNamedCollections := false
 	resume := false
-	fullCommand := "restore"
-	operationId, _ := uuid.NewUUID()
 
-	query := r.URL.Query()
 	if tp, exist := query["table"]; exist {
 		tablePattern = tp[0]
 		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
@@ -1260,9 +1413,7 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 				mappingItems := strings.Split(databaseMapping, ",")
 				for _, m := range mappingItems {
 					if strings.Count(m, ":") != 1 || !databaseMappingRE.MatchString(m) {
-						api.writeError(w, http.StatusInternalServerError, "restore", fmt.Errorf("invalid values in restore_database_mapping %s", m))
-						return
-
+						return restoreParams{}, "", fmt.Errorf("invalid values in restore_database_mapping %s", m)
 					}
 				}
 				databaseMappingToRestore = append(databaseMappingToRestore, mappingItems...)
@@ -1284,8 +1435,7 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 				mappingItems := strings.Split(tableMapping, ",")
 				for _, m := range mappingItems {
 					if strings.Count(m, ":") != 1 || !tableMappingRE.MatchString(m) {
-						api.writeError(w, http.StatusInternalServerError, "restore", fmt.Errorf("invalid values in restore_table_mapping %s", m))
-						return
+						return restoreParams{}, "", fmt.Errorf("invalid values in restore_table_mapping %s", m)
 					}
 				}
 				tableMappingToRestore = append(tableMappingToRestore, mappingItems...)
@@ -1335,6 +1485,14 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		configsOnly = true
 		fullCommand += " --configs-only"
 	}
+	if _, exist := query["keeper"]; exist {
+		restoreKeeper = true
+		fullCommand += " --keeper"
+	}
+	if _, exist := api.getQueryParameter(query, "named-collections"); exist {
+		restoreNamedCollections = true
+		fullCommand += " --named-collections"
+	}
 	if _, exist := query["resumable"]; exist {
 		resume = true
 		fullCommand += " --resumable"
@@ -1344,6 +1502,45 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		fullCommand += " --resume"
 	}
 
+	return restoreParams{
+		tablePattern:            tablePattern,
+		databaseMapping:         databaseMappingToRestore,
+		tableMapping:            tableMappingToRestore,
+		partitions:              partitionsToBackup,
+		schemaOnly:              schemaOnly,
+		dataOnly:                dataOnly,
+		dropExists:              dropExists,
+		ignoreDependencies:      ignoreDependencies,
+		restoreRBAC:             restoreRBAC,
+		rbacOnly:                rbacOnly,
+		restoreConfigs:          restoreConfigs,
+		configsOnly:             configsOnly,
+		restoreKeeper:           restoreKeeper,
+		restoreNamedCollections: restoreNamedCollections,
+		resume:                  resume,
+	}, fullCommand, nil
+}
+
+// httpRestoreHandler - restore a backup from local storage
+func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if api.isLocked() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "restore", ErrAPILocked)
+		return
+	}
+	_, err := api.ReloadConfig(w, "restore")
+	if err != nil {
+		return
+	}
+	vars := mux.Vars(r)
+	operationId, _ := uuid.NewUUID()
+	query := r.URL.Query()
+	params, fullCommand, err := api.parseRestoreParams(query, "restore")
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, "restore", err)
+		return
+	}
+
 	name := utils.CleanBackupNameRE.ReplaceAllString(vars["name"], "")
 	fullCommand += fmt.Sprintf(" %s", name)
 
@@ -1353,12 +1550,16 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 		api.writeError(w, http.StatusBadRequest, "restore", err)
 		return
 	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "restore", err)
+		return
+	}
 
-	commandId, _ := status.Current.Start(fullCommand)
-	go func() {
+	ackStatus, ackOperationId, err := api.startAsync("restore", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
 		err, _ := api.metrics.ExecuteWithMetrics("restore", 0, func() error {
 			b := backup.NewBackuper(api.config)
-			return b.Restore(name, tablePattern, databaseMappingToRestore, tableMappingToRestore, partitionsToBackup, schemaOnly, dataOnly, dropExists, ignoreDependencies, restoreRBAC, rbacOnly, restoreConfigs, configsOnly, resume, api.cliApp.Version, commandId)
+			return b.Restore(name, params.tablePattern, params.databaseMapping, params.tableMapping, params.partitions, params.schemaOnly, params.dataOnly, params.dropExists, params.ignoreDependencies, params.restoreRBAC, params.rbacOnly, params.restoreConfigs, params.configsOnly, params.restoreKeeper, params.restoreNamedCollections, params.resume, api.cliApp.Version, commandId)
 		})
 		go func() {
 			if metricsErr := api.UpdateBackupMetrics(context.Background(), true); metricsErr != nil {
@@ -1372,23 +1573,27 @@ func (api *APIServer) httpRestoreHandler(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		api.successCallback(context.Background(), operationId.String(), callback)
-	}()
+	})
+	if err != nil {
+		api.writeQueueError(w, "restore", err)
+		return
+	}
 	api.sendJSONEachRow(w, http.StatusOK, struct {
 		Status      string `json:"status"`
 		Operation   string `json:"operation"`
 		BackupName  string `json:"backup_name"`
 		OperationId string `json:"operation_id"`
 	}{
-		Status:      "acknowledged",
+		Status:      ackStatus,
 		Operation:   "restore",
 		BackupName:  name,
-		OperationId: operationId.String(),
+		OperationId: ackOperationId,
 	})
 }
 
 // httpDownloadHandler - download a backup from remote to local storage
 func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "download", ErrAPILocked)
 		return
@@ -1406,6 +1611,13 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 	schemaOnly := false
 	resume := false
 	fullCommand := "download"
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "download", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
 	operationId, _ := uuid.NewUUID()
 
 	if tp, exist := query["table"]; exist {
@@ -1437,9 +1649,13 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 		api.writeError(w, http.StatusBadRequest, "download", err)
 		return
 	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "download", err)
+		return
+	}
 
-	go func() {
-		commandId, _ := status.Current.Start(fullCommand)
+	ackStatus, ackOperationId, err := api.startAsync("download", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
 		err, _ := api.metrics.ExecuteWithMetrics("download", 0, func() error {
 			b := backup.NewBackuper(cfg)
 			return b.Download(name, tablePattern, partitionsToBackup, schemaOnly, resume, api.cliApp.Version, commandId)
@@ -1457,23 +1673,262 @@ func (api *APIServer) httpDownloadHandler(w http.ResponseWriter, r *http.Request
 		}()
 		status.Current.Stop(commandId, nil)
 		api.successCallback(context.Background(), operationId.String(), callback)
-	}()
+	})
+	if err != nil {
+		api.writeQueueError(w, "download", err)
+		return
+	}
 	api.sendJSONEachRow(w, http.StatusOK, struct {
 		Status      string `json:"status"`
 		Operation   string `json:"operation"`
 		BackupName  string `json:"backup_name"`
 		OperationId string `json:"operation_id"`
 	}{
-		Status:      "acknowledged",
+		Status:      ackStatus,
 		Operation:   "download",
 		BackupName:  name,
-		OperationId: operationId.String(),
+		OperationId: ackOperationId,
+	})
+}
+
+// httpCreateRemoteHandler - create a backup and upload it to remote storage, the same as `create_remote` command
+func (api *APIServer) httpCreateRemoteHandler(w http.ResponseWriter, r *http.Request) {
+	if api.isLocked() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "create_remote", ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(w, "create_remote")
+	if err != nil {
+		return
+	}
+	tablePattern := ""
+	diffFrom := ""
+	diffFromRemote := ""
+	since := ""
+	partitionsToBackup := make([]string, 0)
+	backupName := backup.NewBackupName()
+	schemaOnly := false
+	createRBAC := false
+	rbacOnly := false
+	createConfigs := false
+	configsOnly := false
+	createKeeper := false
+	createNamedCollections := false
+	skipCheckPartsColumns := false
+	resume := false
+	deleteSource := false
+	fullCommand := "create_remote"
+	query := r.URL.Query()
+	operationId, _ := uuid.NewUUID()
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "create_remote", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
+
+	if tp, exist := query["table"]; exist {
+		tablePattern = tp[0]
+		fullCommand = fmt.Sprintf("%s --tables=\"%s\"", fullCommand, tablePattern)
+	}
+	if partitions, exist := query["partitions"]; exist {
+		partitionsToBackup = append(partitionsToBackup, partitions...)
+		fullCommand = fmt.Sprintf("%s --partitions=\"%s\"", fullCommand, strings.Join(partitions, "\" --partitions=\""))
+	}
+	if df, exist := api.getQueryParameter(query, "diff-from"); exist {
+		diffFrom = df
+		fullCommand = fmt.Sprintf("%s --diff-from=\"%s\"", fullCommand, diffFrom)
+	}
+	if df, exist := api.getQueryParameter(query, "diff-from-remote"); exist {
+		diffFromRemote = df
+		fullCommand = fmt.Sprintf("%s --diff-from-remote=\"%s\"", fullCommand, diffFromRemote)
+	}
+	if sinceValue, exist := api.getQueryParameter(query, "since"); exist {
+		since = sinceValue
+		fullCommand = fmt.Sprintf("%s --since=\"%s\"", fullCommand, since)
+	}
+	if _, exist := query["schema"]; exist {
+		schemaOnly = true
+		fullCommand += " --schema"
+	}
+	if _, exist := query["rbac"]; exist {
+		createRBAC = true
+		fullCommand += " --rbac"
+	}
+	if _, exist := api.getQueryParameter(query, "rbac-only"); exist {
+		rbacOnly = true
+		fullCommand += " --rbac-only"
+	}
+	if _, exist := query["configs"]; exist {
+		createConfigs = true
+		fullCommand += " --configs"
+	}
+	if _, exist := api.getQueryParameter(query, "configs-only"); exist {
+		configsOnly = true
+		fullCommand += " --configs-only"
+	}
+	if _, exist := query["keeper"]; exist {
+		createKeeper = true
+		fullCommand += " --keeper"
+	}
+	if _, exist := api.getQueryParameter(query, "named-collections"); exist {
+		createNamedCollections = true
+		fullCommand += " --named-collections"
+	}
+	if _, exist := api.getQueryParameter(query, "skip-check-parts-columns"); exist {
+		skipCheckPartsColumns = true
+		fullCommand += " --skip-check-parts-columns"
+	}
+	if _, exist := query["resumable"]; exist {
+		resume = true
+		fullCommand += " --resumable"
+	}
+	if _, exist := query["resume"]; exist {
+		resume = true
+		fullCommand += " --resume"
+	}
+	if _, exist := api.getQueryParameter(query, "delete-source"); exist {
+		deleteSource = true
+		fullCommand += " --delete-source"
+	}
+	if name, exist := query["name"]; exist {
+		backupName = utils.CleanBackupNameRE.ReplaceAllString(name[0], "")
+	}
+	fullCommand = fmt.Sprintf("%s %s", fullCommand, backupName)
+
+	callback, err := parseCallback(query)
+	if err != nil {
+		log.Error().Err(err).Send()
+		api.writeError(w, http.StatusBadRequest, "create_remote", err)
+		return
+	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "create_remote", err)
+		return
+	}
+
+	ackStatus, ackOperationId, err := api.startAsync("create_remote", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
+		err := api.metrics.ExecuteWithSubCommandsMetrics("create_remote", func(step func(string, func() error) error) error {
+			b := backup.NewBackuper(cfg)
+			return b.CreateToRemote(backupName, deleteSource, diffFrom, diffFromRemote, since, tablePattern, partitionsToBackup, schemaOnly, createRBAC, rbacOnly, createConfigs, configsOnly, createKeeper, createNamedCollections, skipCheckPartsColumns, resume, storageNames, api.clickhouseBackupVersion, commandId, step)
+		})
+		go func() {
+			if metricsErr := api.UpdateBackupMetrics(context.Background(), false); metricsErr != nil {
+				log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+			}
+		}()
+		status.Current.Stop(commandId, err)
+		if err != nil {
+			log.Error().Msgf("API /backup/create_remote error: %v", err)
+			api.errorCallback(context.Background(), err, operationId.String(), callback)
+			return
+		}
+		api.successCallback(context.Background(), operationId.String(), callback)
+	})
+	if err != nil {
+		api.writeQueueError(w, "create_remote", err)
+		return
+	}
+	api.sendJSONEachRow(w, http.StatusCreated, struct {
+		Status      string `json:"status"`
+		Operation   string `json:"operation"`
+		BackupName  string `json:"backup_name"`
+		BackupFrom  string `json:"backup_from,omitempty"`
+		Diff        bool   `json:"diff"`
+		OperationId string `json:"operation_id"`
+	}{
+		Status:      ackStatus,
+		Operation:   "create_remote",
+		BackupName:  backupName,
+		BackupFrom:  diffFrom,
+		Diff:        diffFrom != "" || diffFromRemote != "",
+		OperationId: ackOperationId,
+	})
+}
+
+// httpRestoreRemoteHandler - download a backup from remote storage and restore it, the same as `restore_remote` command
+func (api *APIServer) httpRestoreRemoteHandler(w http.ResponseWriter, r *http.Request) {
+	if api.isLocked() {
+		log.Warn().Err(ErrAPILocked).Send()
+		api.writeError(w, http.StatusLocked, "restore_remote", ErrAPILocked)
+		return
+	}
+	cfg, err := api.ReloadConfig(w, "restore_remote")
+	if err != nil {
+		return
+	}
+	vars := mux.Vars(r)
+	operationId, _ := uuid.NewUUID()
+	query := r.URL.Query()
+	params, fullCommand, err := api.parseRestoreParams(query, "restore_remote")
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, "restore_remote", err)
+		return
+	}
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "restore_remote", cfg, query); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
+
+	name := utils.CleanBackupNameRE.ReplaceAllString(vars["name"], "")
+	fullCommand += fmt.Sprintf(" %s", name)
+
+	callback, err := parseCallback(query)
+	if err != nil {
+		log.Error().Err(err).Send()
+		api.writeError(w, http.StatusBadRequest, "restore_remote", err)
+		return
+	}
+	priority, err := api.getPriority(query)
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "restore_remote", err)
+		return
+	}
+
+	ackStatus, ackOperationId, err := api.startAsync("restore_remote", fullCommand, priority, operationId.String(), query["callback"], func(commandId int) {
+		err := api.metrics.ExecuteWithSubCommandsMetrics("restore_remote", func(step func(string, func() error) error) error {
+			b := backup.NewBackuper(cfg)
+			return b.RestoreFromRemote(name, params.tablePattern, params.databaseMapping, params.tableMapping, params.partitions, params.schemaOnly, params.dataOnly, params.dropExists, params.ignoreDependencies, params.restoreRBAC, params.rbacOnly, params.restoreConfigs, params.configsOnly, params.restoreKeeper, params.restoreNamedCollections, params.resume, api.cliApp.Version, commandId, step)
+		})
+		go func() {
+			if metricsErr := api.UpdateBackupMetrics(context.Background(), true); metricsErr != nil {
+				log.Error().Msgf("UpdateBackupMetrics return error: %v", metricsErr)
+			}
+		}()
+		status.Current.Stop(commandId, err)
+		if err != nil {
+			log.Error().Msgf("API /backup/restore_remote error: %v", err)
+			api.errorCallback(context.Background(), err, operationId.String(), callback)
+			return
+		}
+		api.successCallback(context.Background(), operationId.String(), callback)
+	})
+	if err != nil {
+		api.writeQueueError(w, "restore_remote", err)
+		return
+	}
+	api.sendJSONEachRow(w, http.StatusOK, struct {
+		Status      string `json:"status"`
+		Operation   string `json:"operation"`
+		BackupName  string `json:"backup_name"`
+		OperationId string `json:"operation_id"`
+	}{
+		Status:      ackStatus,
+		Operation:   "restore_remote",
+		BackupName:  name,
+		OperationId: ackOperationId,
 	})
 }
 
 // httpDeleteHandler - delete a backup from local or remote storage
 func (api *APIServer) httpDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	if !api.config.API.AllowParallel && status.Current.InProgress() {
+	if api.isLocked() {
 		log.Warn().Err(ErrAPILocked).Send()
 		api.writeError(w, http.StatusLocked, "delete", ErrAPILocked)
 		return
@@ -1484,6 +1939,24 @@ func (api *APIServer) httpDeleteHandler(w http.ResponseWriter, r *http.Request)
 	}
 	vars := mux.Vars(r)
 	fullCommand := fmt.Sprintf("delete %s %s", vars["where"], vars["name"])
+	var storageNames []string
+	if cfg, storageNames, err = api.getStorageConfig(w, "delete", cfg, r.URL.Query()); err != nil {
+		return
+	}
+	if len(storageNames) > 0 {
+		fullCommand += " --storage=" + strings.Join(storageNames, ",")
+	}
+	priority, err := api.getPriority(r.URL.Query())
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, "delete", err)
+		return
+	}
+	release, err := api.waitQueue(r.Context(), "delete", fullCommand, priority)
+	if err != nil {
+		api.writeQueueError(w, "delete", err)
+		return
+	}
+	defer release()
 	commandId, ctx := status.Current.Start(fullCommand)
 	b := backup.NewBackuper(cfg)
 	switch vars["where"] {
@@ -1542,7 +2015,7 @@ func (api *APIServer) UpdateBackupMetrics(ctx context.Context, onlyLocal bool) e
 	if len(localBackups) > 0 {
 		numberBackupsLocal = len(localBackups)
 		lastBackup := localBackups[numberBackupsLocal-1]
-		lastSizeLocal = lastBackup.DataSize + lastBackup.MetadataSize + lastBackup.ConfigSize + lastBackup.RBACSize
+		lastSizeLocal = lastBackup.DataSize + lastBackup.MetadataSize + lastBackup.ConfigSize + lastBackup.RBACSize + lastBackup.KeeperSize
 		lastBackupCreateLocal = &lastBackup.CreationDate
 		api.metrics.LastBackupSizeLocal.Set(float64(lastSizeLocal))
 		api.metrics.NumberBackupsLocal.Set(float64(numberBackupsLocal))
@@ -1726,6 +2199,29 @@ func (api *APIServer) ReloadConfig(w http.ResponseWriter, command string) (*conf
 	return cfg, nil
 }
 
+// getStorageConfig - `storage` query parameter selects named storage from `storages` config section, only create_remote and watch allow comma separated list
+func (api *APIServer) getStorageConfig(w http.ResponseWriter, command string, cfg *config.Config, query url.Values) (*config.Config, []string, error) {
+	storage, exists := api.getQueryParameter(query, "storage")
+	if !exists {
+		return cfg, nil, nil
+	}
+	storageNames := config.ParseStorageNames(storage)
+	if len(storageNames) == 0 {
+		return cfg, nil, nil
+	}
+	if len(storageNames) > 1 && command != "create_remote" && command != "watch" {
+		err := fmt.Errorf("storage=%s, several storages supported only by create_remote and watch", storage)
+		api.writeError(w, http.StatusBadRequest, command, err)
+		return nil, nil, err
+	}
+	storageCfg, err := cfg.WithStorage(storageNames[0])
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, command, err)
+		return nil, nil, err
+	}
+	return storageCfg, storageNames, nil
+}
+
 func (api *APIServer) ResumeOperationsAfterRestart() error {
 	ch := clickhouse.ClickHouse{
 		Config: &api.config.ClickHouse,
@@ -1768,14 +2264,23 @@ func (api *APIServer) ResumeOperationsAfterRestart() error {
 			stateFiles = append(stateFiles, embeddedStateFiles...)
 			for _, stateFile := range stateFiles {
 				command := strings.TrimSuffix(filepath.Base(stateFile), ".state2")
-				state := resumable.NewState(filepath.Dir(stateFile), backupName, command, nil)
+				// upload to named storage keeps state in upload.<storage>.state2, storage is restored from params
+				if strings.HasPrefix(command, "upload.") {
+					command = "upload"
+				}
+				// NewState with nil params cleanup already processed keys, state shall be opened only for read params
+				state, err := resumable.OpenStateReadOnly(stateFile)
+				if err != nil {
+					log.Warn().Str("operation", "ResumeOperationsAfterRestart").Msgf("skip %s: %v", stateFile, err)
+					continue
+				}
 				params := state.GetParams()
 				state.Close()
 				if !api.config.API.AllowParallel && status.Current.InProgress() {
 					return fmt.Errorf("another commands in progress")
 				}
 				switch command {
-				case "download":
+				case "download", "restore":
 				case "upload":
 					args := make([]string, 0)
 					args = append(args, command)
@@ -1794,13 +2299,17 @@ func (api *APIServer) ResumeOperationsAfterRestart() error {
 						args = append(args, "--schema=1")
 					}
 
-					if partitions, ok := params["partitions"]; ok && len(partitions.([]interface{})) > 0 {
-						partitionsStr := make([]string, len(partitions.([]interface{})))
-						for j, v := range partitions.([]interface{}) {
+					// nil partitions stored as JSON null
+					if partitions, ok := params["partitions"].([]interface{}); ok && len(partitions) > 0 {
+						partitionsStr := make([]string, len(partitions))
+						for j, v := range partitions {
 							partitionsStr[j] = fmt.Sprintf("--partitions=\"%s\"", v.(string))
 						}
 						args = append(args, partitionsStr...)
 					}
+					if storage, ok := params["storage"]; ok && storage.(string) != "" {
+						args = append(args, fmt.Sprintf("--storage=%s", storage))
+					}
 					args = append(args, "--resumable=1", backupName)
 					fullCommand := strings.Join(args, " ")
 					log.Info().Str("operation", "ResumeOperationsAfterRestart").Send()
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+func TestParseRestoreParams(t *testing.T) {
+	r := require.New(t)
+	api := &APIServer{config: config.DefaultConfig()}
+	query := url.Values{
+		"table":                    {"db.*"},
+		"restore-database-mapping": {"db:db2,db3:db4"},
+		"partitions":               {"202401", "202402"},
+		"rm":                       {"1"},
+		"rbac":                     {"1"},
+		"resume":                   {"1"},
+	}
+	params, fullCommand, err := api.parseRestoreParams(query, "restore_remote")
+	r.NoError(err)
+	r.Equal("db.*", params.tablePattern)
+	r.Equal([]string{"db:db2", "db3:db4"}, params.databaseMapping)
+	r.Equal([]string{"202401", "202402"}, params.partitions)
+	r.True(params.dropExists)
+	r.True(params.restoreRBAC)
+	r.True(params.resume)
+	r.False(params.schemaOnly)
+	r.Equal(`restore_remote --tables="db.*" --restore-database-mapping="db:db2,db3:db4" --partitions="202401" --partitions="202402" --rm --rbac --resume`, fullCommand)
+
+	_, _, err = api.parseRestoreParams(url.Values{"restore_table_mapping": {"wrong"}}, "restore")
+	r.Error(err)
+}
+
+func TestGetStorageConfig(t *testing.T) {
+	r := require.New(t)
+	cfg := config.DefaultConfig()
+	cfg.General.RemoteStorage = "s3"
+	cfg.S3.Bucket = "primary"
+	r.NoError(yaml.Unmarshal([]byte(`
+storages:
+  offsite:
+    remote_storage: sftp
+    sftp:
+      address: backup.example.com
+      path: /backups/
+  archive:
+    s3:
+      bucket: archive
+`), cfg))
+	api := &APIServer{config: cfg}
+
+	storageCfg, storageNames, err := api.getStorageConfig(httptest.NewRecorder(), "upload", cfg, url.Values{})
+	r.NoError(err)
+	r.Nil(storageNames)
+	r.Equal("primary", storageCfg.S3.Bucket)
+
+	storageCfg, storageNames, err = api.getStorageConfig(httptest.NewRecorder(), "upload", cfg, url.Values{"storage": {"offsite"}})
+	r.NoError(err)
+	r.Equal([]string{"offsite"}, storageNames)
+	r.Equal("sftp", storageCfg.General.RemoteStorage)
+	r.Equal("/backups", storageCfg.SFTP.Path)
+	r.Equal("offsite", storageCfg.StorageName)
+
+	storageCfg, storageNames, err = api.getStorageConfig(httptest.NewRecorder(), "create_remote", cfg, url.Values{"storage": {"archive, offsite"}})
+	r.NoError(err)
+	r.Equal([]string{"archive", "offsite"}, storageNames)
+	r.Equal("s3", storageCfg.General.RemoteStorage)
+	r.Equal("archive", storageCfg.S3.Bucket)
+	r.Equal("primary", cfg.S3.Bucket)
+
+	// other named storage overrides main config instead of current storage
+	storageCfg, err = storageCfg.WithStorage("offsite")
+	r.NoError(err)
+	r.Equal("primary", storageCfg.S3.Bucket)
+
+	storageCfg, storageNames, err = api.getStorageConfig(httptest.NewRecorder(), "watch", cfg, url.Values{"storage": {"offsite,archive"}})
+	r.NoError(err)
+	r.Equal([]string{"offsite", "archive"}, storageNames)
+	r.Equal("sftp", storageCfg.General.RemoteStorage)
+
+	w := httptest.NewRecorder()
+	_, _, err = api.getStorageConfig(w, "upload", cfg, url.Values{"storage": {"archive,offsite"}})
+	r.Error(err)
+	r.Equal(http.StatusBadRequest, w.Code)
+
+	_, _, err = api.getStorageConfig(httptest.NewRecorder(), "list", cfg, url.Values{"storage": {"unknown"}})
+	r.Error(err)
+}
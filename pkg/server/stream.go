@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+// streamHeartbeatInterval - send SSE comment to keep connection through proxies with idle timeouts
+const streamHeartbeatInterval = 15 * time.Second
+
+// writeSSE - write one Server-Sent Event, data shall be single line JSON
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, event string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// writeLogLineSSE - send `log` event, and additional `progress` event when log line contains `progress` field
+func writeLogLineSSE(w http.ResponseWriter, flusher http.Flusher, line []byte) error {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	if err := writeSSE(w, flusher, "log", line); err != nil {
+		return err
+	}
+	logFields := map[string]interface{}{}
+	if err := json.Unmarshal(line, &logFields); err != nil {
+		return nil
+	}
+	if progress, exists := logFields["progress"]; exists {
+		progressEvent, err := json.Marshal(map[string]interface{}{
+			"progress":  progress,
+			"operation": logFields["operation"],
+			"table":     logFields["table"],
+			"time":      logFields["time"],
+		})
+		if err != nil {
+			return err
+		}
+		return writeSSE(w, flusher, "progress", progressEvent)
+	}
+	return nil
+}
+
+// httpActionStreamHandler - stream log lines and progress of command as Server-Sent Events until command finished,
+// `id` is `id` field from GET /backup/actions or `operation_id` returned by async handlers
+func (api *APIServer) httpActionStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.writeError(w, http.StatusInternalServerError, "stream", fmt.Errorf("streaming unsupported"))
+		return
+	}
+	command, err := status.Current.FindCommand(mux.Vars(r)["id"])
+	if err != nil {
+		api.writeError(w, http.StatusNotFound, "stream", err)
+		return
+	}
+	history, lines, unsubscribe, err := status.Current.SubscribeLogs(command.Id)
+	if err != nil {
+		api.writeError(w, http.StatusNotFound, "stream", err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sendStatus := func(event string) error {
+		current, statusErr := status.Current.GetCommandStatus(command.Id)
+		if statusErr != nil {
+			return statusErr
+		}
+		statusJSON, statusErr := json.Marshal(current)
+		if statusErr != nil {
+			return statusErr
+		}
+		return writeSSE(w, flusher, event, statusJSON)
+	}
+	if err = sendStatus("status"); err != nil {
+		log.Warn().Msgf("httpActionStreamHandler send status error: %v", err)
+		return
+	}
+	for _, line := range history {
+		if err = writeLogLineSSE(w, flusher, line); err != nil {
+			log.Warn().Msgf("httpActionStreamHandler send log error: %v", err)
+			return
+		}
+	}
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case line, isOpen := <-lines:
+			if !isOpen {
+				if err = sendStatus("end"); err != nil {
+					log.Warn().Msgf("httpActionStreamHandler send end error: %v", err)
+				}
+				return
+			}
+			if err = writeLogLineSSE(w, flusher, line); err != nil {
+				log.Warn().Msgf("httpActionStreamHandler send log error: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+	"github.com/Altinity/clickhouse-backup/v2/pkg/status"
+)
+
+func TestActionStreamHandler(t *testing.T) {
+	logger := zerolog.New(status.Current.LogWriter())
+	api := &APIServer{
+		cliApp: cli.NewApp(),
+		config: config.DefaultConfig(),
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/backup/actions/{id}/stream", api.httpActionStreamHandler).Methods("GET")
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	commandId, _ := status.Current.Start("upload test_stream")
+	status.Current.SetOperationId(commandId, "test-operation-id")
+	// parallel command lines and lines without command_id shall not be attached when several commands in progress
+	otherCommandId, _ := status.Current.Start("download test_stream")
+	defer status.Current.Stop(otherCommandId, nil)
+	commandLogger := logger.With().Int(status.CommandIdLogField, commandId).Logger()
+	otherCommandLogger := logger.With().Int(status.CommandIdLogField, otherCommandId).Logger()
+	commandLogger.Info().Msg("before subscribe")
+	otherCommandLogger.Info().Msg("other command")
+	logger.Info().Msg("without command_id")
+
+	resp, err := http.Get(srv.URL + "/backup/actions/test-operation-id/stream")
+	if err != nil {
+		t.Fatalf("GET stream error: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response status=%d content-type=%s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		otherCommandLogger.Info().Str("operation", "download_data").Str("progress", "1/2").Msg("other command")
+		commandLogger.Info().Str("operation", "upload_data").Str("table", "default.test").Str("progress", "1/1").Msg("done")
+		status.Current.Stop(commandId, nil)
+	}()
+
+	events := make([]string, 0)
+	data := make([]string, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		}
+		if strings.HasPrefix(line, "data: ") {
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	expectedEvents := []string{"status", "log", "log", "progress", "end"}
+	if strings.Join(events, ",") != strings.Join(expectedEvents, ",") {
+		t.Fatalf("unexpected events %v, expected %v", events, expectedEvents)
+	}
+	if !strings.Contains(data[1], "before subscribe") {
+		t.Fatalf("history log line not streamed: %s", data[1])
+	}
+	if !strings.Contains(data[3], `"progress":"1/1"`) || !strings.Contains(data[3], `"table":"default.test"`) {
+		t.Fatalf("unexpected progress event: %s", data[3])
+	}
+	if !strings.Contains(data[4], `"status":"success"`) {
+		t.Fatalf("unexpected end event: %s", data[4])
+	}
+
+	notFound := httptest.NewRecorder()
+	router.ServeHTTP(notFound, httptest.NewRequest(http.MethodGet, "/backup/actions/unknown-id/stream", nil))
+	if notFound.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status for unknown id %d", notFound.Code)
+	}
+}
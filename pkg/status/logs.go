@@ -0,0 +1,171 @@
+package status
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// maxLogLinesPerCommand - how many last log lines keep for each command to replay for new subscribers
+	maxLogLinesPerCommand = 1000
+	// maxFinishedCommandLogs - how many finished commands keep their log lines
+	maxFinishedCommandLogs = 32
+)
+
+type commandLog struct {
+	lines       [][]byte
+	finished    bool
+	subscribers map[chan []byte]struct{}
+}
+
+// logHub - zerolog output writer which keep JSON log lines for each in progress command.
+// Lines with `command_id` field are attached only to this command, lines without it are attached only when single command is in progress,
+// so when `allow_parallel: true` lines which not logged via log.Ctx(ctx) of command context are skipped instead of mixed between concurrent commands.
+// logHub has own mutex and never log itself, AsyncStatus call it under own lock
+type logHub struct {
+	commands map[int]*commandLog
+	active   []int
+	finished []int
+	sync.Mutex
+}
+
+func newLogHub() *logHub {
+	return &logHub{
+		commands: map[int]*commandLog{},
+	}
+}
+
+// register - allow subscribe to command which not started yet, for example waits in operation queue
+func (h *logHub) register(commandId int) {
+	h.Lock()
+	defer h.Unlock()
+	h.registerUnlocked(commandId)
+}
+
+func (h *logHub) registerUnlocked(commandId int) {
+	if _, exists := h.commands[commandId]; exists {
+		return
+	}
+	h.commands[commandId] = &commandLog{
+		lines:       make([][]byte, 0),
+		subscribers: map[chan []byte]struct{}{},
+	}
+}
+
+func (h *logHub) start(commandId int) {
+	h.Lock()
+	defer h.Unlock()
+	h.registerUnlocked(commandId)
+	h.active = append(h.active, commandId)
+}
+
+func (h *logHub) stop(commandId int) {
+	h.Lock()
+	defer h.Unlock()
+	cmdLog, exists := h.commands[commandId]
+	if !exists || cmdLog.finished {
+		return
+	}
+	cmdLog.finished = true
+	for ch := range cmdLog.subscribers {
+		close(ch)
+		delete(cmdLog.subscribers, ch)
+	}
+	for i, id := range h.active {
+		if id == commandId {
+			h.active = append(h.active[:i], h.active[i+1:]...)
+			break
+		}
+	}
+	h.finished = append(h.finished, commandId)
+	if len(h.finished) > maxFinishedCommandLogs {
+		delete(h.commands, h.finished[0])
+		h.finished = h.finished[1:]
+	}
+}
+
+// Write - implements io.Writer, zerolog call it once for each event with a JSON object line
+func (h *logHub) Write(p []byte) (int, error) {
+	commandId, hasCommandId := parseLogCommandId(p)
+	h.Lock()
+	defer h.Unlock()
+	if len(h.active) == 0 {
+		return len(p), nil
+	}
+	if !hasCommandId {
+		if len(h.active) != 1 {
+			return len(p), nil
+		}
+		commandId = h.active[0]
+	}
+	cmdLog, exists := h.commands[commandId]
+	if !exists || cmdLog.finished {
+		return len(p), nil
+	}
+	line := make([]byte, len(p))
+	copy(line, p)
+	cmdLog.lines = append(cmdLog.lines, line)
+	if len(cmdLog.lines) > maxLogLinesPerCommand {
+		cmdLog.lines = cmdLog.lines[len(cmdLog.lines)-maxLogLinesPerCommand:]
+	}
+	for ch := range cmdLog.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// slow subscriber, drop line instead of blocking logging for all go-routines
+		}
+	}
+	return len(p), nil
+}
+
+var commandIdLogFieldPrefix = []byte(`"` + CommandIdLogField + `":`)
+
+// parseLogCommandId - extract `command_id` field from zerolog JSON line without full decode, zerolog writes integer fields without quotes and spaces,
+// field name inside string value is escaped as \"command_id\" and doesn't match
+func parseLogCommandId(p []byte) (int, bool) {
+	idx := bytes.Index(p, commandIdLogFieldPrefix)
+	if idx < 0 {
+		return 0, false
+	}
+	commandId, digits := 0, 0
+	for _, c := range p[idx+len(commandIdLogFieldPrefix):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		commandId = commandId*10 + int(c-'0')
+		digits += 1
+	}
+	return commandId, digits > 0
+}
+
+// subscribe - return already written lines and channel for new lines, channel closed when command finished
+func (h *logHub) subscribe(commandId int) ([][]byte, chan []byte, bool) {
+	h.Lock()
+	defer h.Unlock()
+	cmdLog, exists := h.commands[commandId]
+	if !exists {
+		return nil, nil, false
+	}
+	history := make([][]byte, len(cmdLog.lines))
+	copy(history, cmdLog.lines)
+	ch := make(chan []byte, 256)
+	if cmdLog.finished {
+		close(ch)
+	} else {
+		cmdLog.subscribers[ch] = struct{}{}
+	}
+	return history, ch, true
+}
+
+func (h *logHub) unsubscribe(commandId int, ch chan []byte) {
+	h.Lock()
+	defer h.Unlock()
+	cmdLog, exists := h.commands[commandId]
+	if !exists {
+		return
+	}
+	if _, subscribed := cmdLog.subscribers[ch]; subscribed {
+		delete(cmdLog.subscribers, ch)
+		close(ch)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,23 +18,33 @@ const (
 	SuccessStatus    = "success"
 	CancelStatus     = "cancel"
 	ErrorStatus      = "error"
+	// QueuedStatus - command acknowledged by API and waits in operation queue, Run switch it to InProgressStatus
+	QueuedStatus = "queued"
 )
 
-var Current = &AsyncStatus{}
+var Current = &AsyncStatus{logs: newLogHub()}
 
 const NotFromAPI = int(-1)
 
+// CommandIdLogField - log field which contains commandId for log.Ctx(ctx) of commands started via Start or Run
+const CommandIdLogField = "command_id"
+
 type AsyncStatus struct {
 	commands []ActionRow
+	logs     *logHub
 	sync.RWMutex
 }
 
 type ActionRowStatus struct {
-	Command string `json:"command"`
-	Status  string `json:"status"`
-	Start   string `json:"start,omitempty"`
-	Finish  string `json:"finish,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Id          int    `json:"id"`
+	Command     string `json:"command"`
+	Status      string `json:"status"`
+	Start       string `json:"start,omitempty"`
+	Finish      string `json:"finish,omitempty"`
+	Error       string `json:"error,omitempty"`
+	OperationId string `json:"operation_id,omitempty"`
+	// Step - current sub-step of complex commands like create_remote and restore_remote, failed step when error happens
+	Step string `json:"step,omitempty"`
 }
 
 type ActionRow struct {
@@ -44,9 +56,10 @@ type ActionRow struct {
 func (status *AsyncStatus) Start(command string) (int, context.Context) {
 	status.Lock()
 	defer status.Unlock()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := newCommandContext(len(status.commands))
 	status.commands = append(status.commands, ActionRow{
 		ActionRowStatus: ActionRowStatus{
+			Id:      len(status.commands),
 			Command: command,
 			Start:   time.Now().Format(common.TimeFormat),
 			Status:  InProgressStatus,
@@ -55,10 +68,49 @@ func (status *AsyncStatus) Start(command string) (int, context.Context) {
 		Cancel: cancel,
 	})
 	lastCommandId := len(status.commands) - 1
+	status.logs.start(lastCommandId)
 	log.Debug().Msgf("api.status.Start -> status.commands[%d] == %+v", lastCommandId, status.commands[lastCommandId])
 	return lastCommandId, ctx
 }
 
+// newCommandContext - log.Ctx(ctx) lines contain command_id, logHub use it to attach lines only to this command
+func newCommandContext(commandId int) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return log.With().Int(CommandIdLogField, commandId).Logger().WithContext(ctx), cancel
+}
+
+// Enqueue - add command which waits in API operation queue, it visible in /backup/actions and logs could be subscribed before start,
+// Run shall be called when command starts, Stop when command removed from queue without start
+func (status *AsyncStatus) Enqueue(command string) int {
+	status.Lock()
+	defer status.Unlock()
+	commandId := len(status.commands)
+	status.commands = append(status.commands, ActionRow{
+		ActionRowStatus: ActionRowStatus{
+			Id:      commandId,
+			Command: command,
+			Status:  QueuedStatus,
+		},
+	})
+	status.logs.register(commandId)
+	log.Debug().Msgf("api.status.Enqueue -> status.commands[%d] == %+v", commandId, status.commands[commandId])
+	return commandId
+}
+
+// Run - switch queued command to InProgressStatus, return context of command
+func (status *AsyncStatus) Run(commandId int) context.Context {
+	status.Lock()
+	defer status.Unlock()
+	ctx, cancel := newCommandContext(commandId)
+	status.commands[commandId].Ctx = ctx
+	status.commands[commandId].Cancel = cancel
+	status.commands[commandId].Status = InProgressStatus
+	status.commands[commandId].Start = time.Now().Format(common.TimeFormat)
+	status.logs.start(commandId)
+	log.Debug().Msgf("api.status.Run -> status.commands[%d] == %+v", commandId, status.commands[commandId])
+	return ctx
+}
+
 func (status *AsyncStatus) CheckCommandInProgress(command string) bool {
 	status.RLock()
 	defer status.RUnlock()
@@ -90,7 +142,8 @@ func (status *AsyncStatus) GetContextWithCancel(commandId int) (context.Context,
 	defer status.RUnlock()
 	if commandId == NotFromAPI {
 		ctx, cancel := context.WithCancel(context.Background())
-		return ctx, cancel, nil
+		// progress lines use log.Ctx(ctx), which is disabled for context without logger
+		return log.Logger.WithContext(ctx), cancel, nil
 	}
 	if commandId >= len(status.commands) {
 		return nil, nil, fmt.Errorf("commandId=%d not exists in current running commands", commandId)
@@ -104,10 +157,12 @@ func (status *AsyncStatus) GetContextWithCancel(commandId int) (context.Context,
 func (status *AsyncStatus) Stop(commandId int, err error) {
 	status.Lock()
 	defer status.Unlock()
-	if status.commands[commandId].Status != InProgressStatus {
+	if status.commands[commandId].Status != InProgressStatus && status.commands[commandId].Status != QueuedStatus {
 		return
 	}
-	status.commands[commandId].Cancel()
+	if status.commands[commandId].Cancel != nil {
+		status.commands[commandId].Cancel()
+	}
 	s := SuccessStatus
 	if err != nil {
 		s = ErrorStatus
@@ -117,6 +172,7 @@ func (status *AsyncStatus) Stop(commandId int, err error) {
 	status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
 	status.commands[commandId].Ctx = nil
 	status.commands[commandId].Cancel = nil
+	status.logs.stop(commandId)
 	log.Debug().Msgf("api.status.stop -> status.commands[%d] == %+v", commandId, status.commands[commandId])
 }
 
@@ -160,6 +216,7 @@ func (status *AsyncStatus) Cancel(command string, err error) error {
 	status.commands[commandId].Error = err.Error()
 	status.commands[commandId].Status = CancelStatus
 	status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
+	status.logs.stop(commandId)
 	log.Debug().Msgf("api.status.cancel -> status.commands[%d] == %+v", commandId, status.commands[commandId])
 	return nil
 }
@@ -176,6 +233,7 @@ func (status *AsyncStatus) CancelAll(cancelMsg string) {
 		status.commands[commandId].Status = CancelStatus
 		status.commands[commandId].Error = cancelMsg
 		status.commands[commandId].Finish = time.Now().Format(common.TimeFormat)
+		status.logs.stop(commandId)
 		log.Debug().Msgf("api.status.cancel -> status.commands[%d] == %+v", commandId, status.commands[commandId])
 	}
 }
@@ -195,13 +253,7 @@ func (status *AsyncStatus) GetStatus(current bool, filter string, last int) []Ac
 	for _, command := range status.commands {
 		if filter == "" || (strings.Contains(command.Command, filter) || strings.Contains(command.Status, filter) || strings.Contains(command.Error, filter)) {
 			// copy without context and cancel
-			filteredCommands = append(filteredCommands, ActionRowStatus{
-				Command: command.Command,
-				Status:  command.Status,
-				Start:   command.Start,
-				Finish:  command.Finish,
-				Error:   command.Error,
-			})
+			filteredCommands = append(filteredCommands, command.ActionRowStatus)
 		}
 	}
 	if len(filteredCommands) == 0 {
@@ -219,3 +271,63 @@ func (status *AsyncStatus) GetStatus(current bool, filter string, last int) []Ac
 	}
 	return filteredCommands[begin:end]
 }
+
+// SetOperationId - link operation_id returned from API to command, allow to find command by operation_id
+func (status *AsyncStatus) SetOperationId(commandId int, operationId string) {
+	status.Lock()
+	defer status.Unlock()
+	if commandId < 0 || commandId >= len(status.commands) {
+		return
+	}
+	status.commands[commandId].OperationId = operationId
+}
+
+// SetStep - save current sub-step of command, visible in /backup/status and /backup/actions
+func (status *AsyncStatus) SetStep(commandId int, step string) {
+	status.Lock()
+	defer status.Unlock()
+	if commandId < 0 || commandId >= len(status.commands) {
+		return
+	}
+	status.commands[commandId].Step = step
+	log.Info().Str("command", status.commands[commandId].Command).Str("step", step).Msg("step started")
+}
+
+// FindCommand - find command by numeric id or by operation_id
+func (status *AsyncStatus) FindCommand(id string) (ActionRowStatus, error) {
+	status.RLock()
+	defer status.RUnlock()
+	for _, cmd := range status.commands {
+		if strconv.Itoa(cmd.Id) == id || (cmd.OperationId != "" && cmd.OperationId == id) {
+			return cmd.ActionRowStatus, nil
+		}
+	}
+	return ActionRowStatus{}, fmt.Errorf("command `%s` not found", id)
+}
+
+// GetCommandStatus - return current status for commandId
+func (status *AsyncStatus) GetCommandStatus(commandId int) (ActionRowStatus, error) {
+	status.RLock()
+	defer status.RUnlock()
+	if commandId < 0 || commandId >= len(status.commands) {
+		return ActionRowStatus{}, fmt.Errorf("commandId=%d not exists", commandId)
+	}
+	return status.commands[commandId].ActionRowStatus, nil
+}
+
+// LogWriter - io.Writer which shall be added to zerolog output to allow SubscribeLogs
+func (status *AsyncStatus) LogWriter() io.Writer {
+	return status.logs
+}
+
+// SubscribeLogs - return JSON log lines which already written during command execution and channel for next lines,
+// channel will close when command finished, call returned unsubscribe function when stop reading
+func (status *AsyncStatus) SubscribeLogs(commandId int) ([][]byte, <-chan []byte, func(), error) {
+	history, ch, exists := status.logs.subscribe(commandId)
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("logs for commandId=%d not found", commandId)
+	}
+	return history, ch, func() {
+		status.logs.unsubscribe(commandId, ch)
+	}, nil
+}
@@ -387,3 +387,50 @@ func isContainerAlreadyExists(err error) bool {
 	}
 	return false
 }
+
+// AbortStaleUploads - discard uncommitted blocks of blobs under path and object_disk_path which never committed and modified earlier than olderThan,
+// blob with only uncommitted blocks can't be deleted, so empty block list commits before delete, non-empty backupName limits discard to blobs of this backup
+func (a *AzureBlob) AbortStaleUploads(ctx context.Context, backupName string, olderThan time.Duration) (int, error) {
+	deadline := time.Now().Add(-olderThan)
+	aborted := 0
+	for _, prefix := range staleUploadsPrefixes(a.Config.Path, a.Config.ObjectDiskPath, backupName) {
+		opt := azblob.ListBlobsSegmentOptions{
+			Details: azblob.BlobListingDetails{UncommittedBlobs: true},
+		}
+		if prefix != "" {
+			opt.Prefix = prefix
+		}
+		for mrk := (azblob.Marker{}); mrk.NotDone(); {
+			r, err := a.Container.ListBlobsFlatSegment(ctx, mrk, opt)
+			if err != nil {
+				return aborted, err
+			}
+			for _, blob := range r.Segment.BlobItems {
+				if blob.Properties.ContentLength != nil && *blob.Properties.ContentLength > 0 {
+					continue
+				}
+				if blob.Properties.LastModified.IsZero() || blob.Properties.LastModified.After(deadline) {
+					continue
+				}
+				blockBlob := a.Container.NewBlockBlobURL(blob.Name)
+				blockList, err := blockBlob.GetBlockList(ctx, azblob.BlockListAll, azblob.LeaseAccessConditions{})
+				if err != nil {
+					return aborted, fmt.Errorf("AZBLOB->GetBlockList %s return error: %v", blob.Name, err)
+				}
+				if len(blockList.CommittedBlocks) > 0 || len(blockList.UncommittedBlocks) == 0 {
+					continue
+				}
+				if _, err = blockBlob.CommitBlockList(ctx, []string{}, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, a.CPK, azblob.ImmutabilityPolicyOptions{}); err != nil {
+					return aborted, fmt.Errorf("AZBLOB->CommitBlockList %s return error: %v", blob.Name, err)
+				}
+				if _, err = blockBlob.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{}); err != nil {
+					return aborted, fmt.Errorf("AZBLOB->Delete %s return error: %v", blob.Name, err)
+				}
+				log.Info().Str("blob", blob.Name).Int("uncommitted_blocks", len(blockList.UncommittedBlocks)).Msg("AZBLOB->AbortStaleUploads discarded uncommitted blocks")
+				aborted += 1
+			}
+			mrk = r.NextMarker
+		}
+	}
+	return aborted, nil
+}
@@ -29,6 +29,8 @@ import (
 const (
 	// BufferSize - size of ring buffer between stream handlers
 	BufferSize = 128 * 1024
+	// SharedPartsDir - remote root directory for content addressed data parts, it is not a backup
+	SharedPartsDir = ".shared_parts"
 )
 
 type readerWrapperForContext func(p []byte) (n int, err error)
@@ -52,24 +54,29 @@ type BackupDestination struct {
 var metadataCacheLock sync.RWMutex
 
 func (bd *BackupDestination) RemoveBackupRemote(ctx context.Context, backup Backup, cfg *config.Config) error {
+	return bd.RemoveRemotePath(ctx, backup.BackupName, cfg)
+}
+
+// RemoveRemotePath - recursive delete all objects under remotePath
+func (bd *BackupDestination) RemoveRemotePath(ctx context.Context, remotePath string, cfg *config.Config) error {
 	retry := retrier.New(retrier.ConstantBackoff(cfg.General.RetriesOnFailure, cfg.General.RetriesDuration), nil)
 	if bd.Kind() == "SFTP" || bd.Kind() == "FTP" {
 		return retry.RunCtx(ctx, func(ctx context.Context) error {
-			return bd.DeleteFile(ctx, backup.BackupName)
+			return bd.DeleteFile(ctx, remotePath)
 		})
 	}
-	return bd.Walk(ctx, backup.BackupName+"/", true, func(ctx context.Context, f RemoteFile) error {
+	return bd.Walk(ctx, remotePath+"/", true, func(ctx context.Context, f RemoteFile) error {
 		if bd.Kind() == "azblob" {
 			if f.Size() > 0 || !f.LastModified().IsZero() {
 				return retry.RunCtx(ctx, func(ctx context.Context) error {
-					return bd.DeleteFile(ctx, path.Join(backup.BackupName, f.Name()))
+					return bd.DeleteFile(ctx, path.Join(remotePath, f.Name()))
 				})
 			} else {
 				return nil
 			}
 		}
 		return retry.RunCtx(ctx, func(ctx context.Context) error {
-			return bd.DeleteFile(ctx, path.Join(backup.BackupName, f.Name()))
+			return bd.DeleteFile(ctx, path.Join(remotePath, f.Name()))
 		})
 	})
 }
@@ -175,6 +182,9 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 	cacheMiss := false
 	err = bd.Walk(ctx, "/", false, func(ctx context.Context, o RemoteFile) error {
 		backupName := strings.Trim(o.Name(), "/")
+		if backupName == SharedPartsDir {
+			return nil
+		}
 		if !parseMetadata || (parseMetadataOnly != "" && parseMetadataOnly != backupName) {
 			if cachedMetadata, isCached := listCache[backupName]; isCached {
 				result = append(result, cachedMetadata)
@@ -267,6 +277,11 @@ func (bd *BackupDestination) BackupList(ctx context.Context, parseMetadata bool,
 }
 
 func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remotePath string, localPath string, maxSpeed uint64) error {
+	return bd.DownloadCompressedStreamWithFormat(ctx, remotePath, localPath, maxSpeed, bd.compressionFormat)
+}
+
+// DownloadCompressedStreamWithFormat - archive could be compressed by another format than compression_format, look `tables->compression_format`
+func (bd *BackupDestination) DownloadCompressedStreamWithFormat(ctx context.Context, remotePath string, localPath string, maxSpeed uint64, compressionFormat string) error {
 	if err := os.MkdirAll(localPath, 0750); err != nil {
 		return err
 	}
@@ -295,7 +310,6 @@ func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remot
 
 	buf := buffer.New(BufferSize)
 	bufReader := nio.NewReader(reader, buf)
-	compressionFormat := bd.compressionFormat
 	if !checkArchiveExtension(path.Ext(remotePath), compressionFormat) {
 		log.Warn().Msgf("remote file backup extension %s not equal with %s", remotePath, compressionFormat)
 		compressionFormat = strings.Replace(path.Ext(remotePath), ".", "", -1)
@@ -348,6 +362,11 @@ func (bd *BackupDestination) DownloadCompressedStream(ctx context.Context, remot
 }
 
 func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLocalPath string, files []string, remotePath string, maxSpeed uint64) error {
+	return bd.UploadCompressedStreamWithFormat(ctx, baseLocalPath, files, remotePath, maxSpeed, bd.compressionFormat, bd.compressionLevel)
+}
+
+// UploadCompressedStreamWithFormat - compress by format and level which override compression_format, look `tables->compression_format`
+func (bd *BackupDestination) UploadCompressedStreamWithFormat(ctx context.Context, baseLocalPath string, files []string, remotePath string, maxSpeed uint64, compressionFormat string, compressionLevel int) error {
 	var totalBytes int64
 	for _, filename := range files {
 		fInfo, err := os.Stat(path.Join(baseLocalPath, filename))
@@ -375,7 +394,7 @@ func (bd *BackupDestination) UploadCompressedStream(ctx context.Context, baseLoc
 				}
 			}
 		}()
-		z, err := getArchiveWriter(bd.compressionFormat, bd.compressionLevel)
+		z, err := getArchiveWriter(compressionFormat, compressionLevel)
 		if err != nil {
 			return err
 		}
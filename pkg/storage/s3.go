@@ -763,3 +763,51 @@ func (f *s3File) LastModified() time.Time {
 func (f *s3File) StorageClass() string {
 	return f.storageClass
 }
+
+// AbortStaleUploads - abort multipart uploads under path and object_disk_path initiated earlier than olderThan, killed upload leaves them billed forever,
+// non-empty backupName limits abort to uploads of this backup
+func (s *S3) AbortStaleUploads(ctx context.Context, backupName string, olderThan time.Duration) (int, error) {
+	deadline := time.Now().Add(-olderThan)
+	aborted := 0
+	for _, prefix := range staleUploadsPrefixes(s.Config.Path, s.Config.ObjectDiskPath, backupName) {
+		params := &s3.ListMultipartUploadsInput{
+			Bucket: aws.String(s.Config.Bucket),
+		}
+		if prefix != "" {
+			params.Prefix = aws.String(prefix)
+		}
+		if s.Config.RequestPayer != "" {
+			params.RequestPayer = s3types.RequestPayer(s.Config.RequestPayer)
+		}
+		for {
+			uploads, err := s.client.ListMultipartUploads(ctx, params)
+			if err != nil {
+				return aborted, fmt.Errorf("S3->ListMultipartUploads %s/%s return error: %v", s.Config.Bucket, prefix, err)
+			}
+			for _, upload := range uploads.Uploads {
+				if upload.Initiated == nil || upload.Initiated.After(deadline) {
+					continue
+				}
+				abortParams := &s3.AbortMultipartUploadInput{
+					Bucket:   aws.String(s.Config.Bucket),
+					Key:      upload.Key,
+					UploadId: upload.UploadId,
+				}
+				if s.Config.RequestPayer != "" {
+					abortParams.RequestPayer = s3types.RequestPayer(s.Config.RequestPayer)
+				}
+				if _, err = s.client.AbortMultipartUpload(ctx, abortParams); err != nil {
+					return aborted, fmt.Errorf("S3->AbortMultipartUpload %s/%s return error: %v", s.Config.Bucket, *upload.Key, err)
+				}
+				log.Info().Str("key", *upload.Key).Time("initiated", *upload.Initiated).Msg("S3->AbortStaleUploads aborted multipart upload")
+				aborted += 1
+			}
+			if uploads.IsTruncated == nil || !*uploads.IsTruncated {
+				break
+			}
+			params.KeyMarker = uploads.NextKeyMarker
+			params.UploadIdMarker = uploads.NextUploadIdMarker
+		}
+	}
+	return aborted, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
 	"io"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -16,6 +17,8 @@ import (
 	libSFTP "github.com/pkg/sftp"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // SFTP Implement RemoteStorage
@@ -36,33 +39,29 @@ func (sftp *SFTP) Kind() string {
 }
 
 func (sftp *SFTP) Connect(ctx context.Context) error {
-	authMethods := make([]ssh.AuthMethod, 0)
-
-	if sftp.Config.Key == "" && sftp.Config.Password == "" {
-		return errors.New("please specify sftp.key or sftp.password for authentication")
+	if sftp.Config.Key == "" && sftp.Config.Password == "" && !sftp.Config.UseAgent {
+		return errors.New("please specify sftp.key, sftp.password or sftp.use_agent for authentication")
 	}
-
-	if sftp.Config.Key != "" {
-		fSftpKey, err := os.ReadFile(sftp.Config.Key)
-		if err != nil {
-			return err
-		}
-		sftpKey, err := ssh.ParsePrivateKey(fSftpKey)
-		if err != nil {
-			return err
-		}
-
-		authMethods = append(authMethods, ssh.PublicKeys(sftpKey))
+	authMethods, agentConnection, err := sftp.getAuthMethods()
+	if err != nil {
+		return err
 	}
-
-	if sftp.Config.Password != "" {
-		authMethods = append(authMethods, ssh.Password(sftp.Config.Password))
+	// agent signers are required only during handshake
+	if agentConnection != nil {
+		defer func() {
+			if closeErr := agentConnection.Close(); closeErr != nil {
+				log.Warn().Msgf("can't close ssh-agent connection: %v", closeErr)
+			}
+		}()
+	}
+	hostKeyCallback, err := sftp.getHostKeyCallback()
+	if err != nil {
+		return err
 	}
-
 	sftpConfig := &ssh.ClientConfig{
 		User:            sftp.Config.Username,
 		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 	}
 	addr := fmt.Sprintf("%s:%d", sftp.Config.Address, sftp.Config.Port)
 	sftp.Debug("[SFTP_DEBUG] try connect to tcp://%s", addr)
@@ -89,6 +88,118 @@ func (sftp *SFTP) Connect(ctx context.Context) error {
 	return nil
 }
 
+// getAuthMethods - private key with optional passphrase and certificate, ssh-agent and password, returned agent connection shall be closed after Dial
+func (sftp *SFTP) getAuthMethods() ([]ssh.AuthMethod, io.Closer, error) {
+	authMethods := make([]ssh.AuthMethod, 0)
+	if sftp.Config.Key != "" {
+		signer, err := loadSFTPKey(sftp.Config.Key, sftp.Config.KeyPassphrase, sftp.Config.Certificate)
+		if err != nil {
+			return nil, nil, err
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	var agentConnection net.Conn
+	if sftp.Config.UseAgent {
+		agentSocket := os.Getenv("SSH_AUTH_SOCK")
+		if agentSocket == "" {
+			return nil, nil, errors.New("sftp.use_agent: true, but SSH_AUTH_SOCK is empty")
+		}
+		var err error
+		if agentConnection, err = net.Dial("unix", agentSocket); err != nil {
+			return nil, nil, fmt.Errorf("can't connect to ssh-agent %s: %v", agentSocket, err)
+		}
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agent.NewClient(agentConnection).Signers))
+	}
+	if sftp.Config.Password != "" {
+		authMethods = append(authMethods, ssh.Password(sftp.Config.Password))
+	}
+	if agentConnection == nil {
+		return authMethods, nil, nil
+	}
+	return authMethods, agentConnection, nil
+}
+
+// loadSFTPKey - parse private key, encrypted key requires passphrase, certificate signed by trusted CA replaces public key during auth
+func loadSFTPKey(keyFile, passphrase, certificateFile string) (ssh.Signer, error) {
+	keyBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyBytes)
+	}
+	if err != nil {
+		var passphraseMissingErr *ssh.PassphraseMissingError
+		if errors.As(err, &passphraseMissingErr) {
+			return nil, fmt.Errorf("%s is encrypted, please specify sftp.key_passphrase", keyFile)
+		}
+		return nil, fmt.Errorf("can't parse %s: %v", keyFile, err)
+	}
+	if certificateFile == "" {
+		return signer, nil
+	}
+	certBytes, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", certificateFile, err)
+	}
+	certificate, isCertificate := publicKey.(*ssh.Certificate)
+	if !isCertificate {
+		return nil, fmt.Errorf("%s is not ssh certificate", certificateFile)
+	}
+	return ssh.NewCertSigner(certificate, signer)
+}
+
+// getHostKeyCallback - verify host key with known_hosts file and pinned fingerprints, both shall pass when both defined
+func (sftp *SFTP) getHostKeyCallback() (ssh.HostKeyCallback, error) {
+	if sftp.Config.KnownHosts == "" && sftp.Config.HostKeyFingerprint == "" {
+		if !sftp.Config.InsecureIgnoreHostKey {
+			return nil, fmt.Errorf("sftp.known_hosts and sftp.host_key_fingerprint are empty, can't verify host key of %s, set one of them or `sftp.insecure_ignore_host_key: true`", sftp.Config.Address)
+		}
+		log.Warn().Msgf("sftp.insecure_ignore_host_key: true, host key of %s will not be verified", sftp.Config.Address)
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	var knownHostsCallback ssh.HostKeyCallback
+	if sftp.Config.KnownHosts != "" {
+		var err error
+		if knownHostsCallback, err = knownhosts.New(sftp.Config.KnownHosts); err != nil {
+			return nil, fmt.Errorf("can't read sftp.known_hosts: %v", err)
+		}
+	}
+	fingerprints := make([]string, 0)
+	for _, fingerprint := range strings.Split(sftp.Config.HostKeyFingerprint, ",") {
+		if fingerprint = strings.TrimSpace(fingerprint); fingerprint != "" {
+			if !strings.HasPrefix(fingerprint, "SHA256:") {
+				fingerprint = "SHA256:" + fingerprint
+			}
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if knownHostsCallback != nil {
+			if err := knownHostsCallback(hostname, remote, key); err != nil {
+				return err
+			}
+		}
+		if len(fingerprints) == 0 {
+			return nil
+		}
+		hostFingerprint := ssh.FingerprintSHA256(key)
+		for _, fingerprint := range fingerprints {
+			if fingerprint == hostFingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("host key fingerprint %s for %s doesn't match sftp.host_key_fingerprint", hostFingerprint, hostname)
+	}, nil
+}
+
 func (sftp *SFTP) Close(ctx context.Context) error {
 	if err := sftp.sftpClient.Close(); err != nil {
 		return fmt.Errorf("sftpClient.Close() error: , %v", err)
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	libSFTP "github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/Altinity/clickhouse-backup/v2/pkg/config"
+)
+
+// sftpTestServer - local sshd with sftp subsystem, accepts authorizedKey and certificates signed by userCA
+type sftpTestServer struct {
+	host    string
+	port    uint
+	hostKey ssh.Signer
+}
+
+func generateSFTPTestKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	return privateKey, signer
+}
+
+func startSFTPTestServer(t *testing.T, authorizedKey, userCA ssh.PublicKey) sftpTestServer {
+	_, hostKey := generateSFTPTestKey(t)
+	certChecker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return userCA != nil && bytes.Equal(auth.Marshal(), userCA.Marshal())
+		},
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorizedKey != nil && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	serverConfig := &ssh.ServerConfig{PublicKeyCallback: certChecker.Authenticate}
+	serverConfig.AddHostKey(hostKey)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTPTestConnection(conn, serverConfig)
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return sftpTestServer{host: "127.0.0.1", port: uint(addr.Port), hostKey: hostKey}
+}
+
+func serveSFTPTestConnection(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range channelRequests {
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(isSFTP, nil)
+				if isSFTP {
+					if server, err := libSFTP.NewServer(channel); err == nil {
+						_ = server.Serve()
+					}
+					_ = channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s sftpTestServer) config(t *testing.T) *config.SFTPConfig {
+	return &config.SFTPConfig{Address: s.host, Port: s.port, Username: "test", Path: t.TempDir()}
+}
+
+func (s sftpTestServer) writeKnownHosts(t *testing.T, hostKey ssh.PublicKey) string {
+	knownHostsFile := path.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(s.host, strconv.Itoa(int(s.port))))}, hostKey)
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600))
+	return knownHostsFile
+}
+
+func connectSFTPTest(t *testing.T, cfg *config.SFTPConfig) error {
+	sftp := &SFTP{Config: cfg}
+	if err := sftp.Connect(context.Background()); err != nil {
+		return err
+	}
+	defer func() {
+		require.NoError(t, sftp.Close(context.Background()))
+	}()
+	require.NoError(t, sftp.PutFile(context.Background(), "test.txt", io.NopCloser(bytes.NewReader([]byte("test")))))
+	f, err := sftp.StatFile(context.Background(), "test.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), f.Size())
+	return nil
+}
+
+func TestSFTPEncryptedKeyAndHostKeyVerification(t *testing.T) {
+	clientKey, clientSigner := generateSFTPTestKey(t)
+	server := startSFTPTestServer(t, clientSigner.PublicKey(), nil)
+
+	pemBlock, err := ssh.MarshalPrivateKeyWithPassphrase(clientKey, "", []byte("secret"))
+	require.NoError(t, err)
+	keyFile := path.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(pemBlock), 0600))
+
+	cfg := server.config(t)
+	cfg.Key = keyFile
+	err = connectSFTPTest(t, cfg)
+	require.ErrorContains(t, err, "sftp.key_passphrase")
+
+	cfg.KeyPassphrase = "secret"
+	// host key is not verified only with explicit opt-in
+	require.ErrorContains(t, connectSFTPTest(t, cfg), "sftp.insecure_ignore_host_key")
+	cfg.InsecureIgnoreHostKey = true
+	require.NoError(t, connectSFTPTest(t, cfg))
+	cfg.InsecureIgnoreHostKey = false
+
+	cfg.KnownHosts = server.writeKnownHosts(t, server.hostKey.PublicKey())
+	require.NoError(t, connectSFTPTest(t, cfg))
+
+	_, otherHostKey := generateSFTPTestKey(t)
+	cfg.KnownHosts = server.writeKnownHosts(t, otherHostKey.PublicKey())
+	require.Error(t, connectSFTPTest(t, cfg))
+
+	cfg.KnownHosts = ""
+	cfg.HostKeyFingerprint = ssh.FingerprintSHA256(otherHostKey.PublicKey())
+	require.ErrorContains(t, connectSFTPTest(t, cfg), "doesn't match sftp.host_key_fingerprint")
+
+	cfg.HostKeyFingerprint = ssh.FingerprintSHA256(otherHostKey.PublicKey()) + ", " + ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	require.NoError(t, connectSFTPTest(t, cfg))
+}
+
+func TestSFTPCertificate(t *testing.T) {
+	_, caSigner := generateSFTPTestKey(t)
+	clientKey, clientSigner := generateSFTPTestKey(t)
+	server := startSFTPTestServer(t, nil, caSigner.PublicKey())
+
+	pemBlock, err := ssh.MarshalPrivateKey(clientKey, "")
+	require.NoError(t, err)
+	keyFile := path.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(pemBlock), 0600))
+
+	cfg := server.config(t)
+	cfg.Key = keyFile
+	cfg.HostKeyFingerprint = ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	require.Error(t, connectSFTPTest(t, cfg))
+
+	cert := &ssh.Certificate{
+		Key:             clientSigner.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"test"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, caSigner))
+	cfg.Certificate = keyFile + "-cert.pub"
+	require.NoError(t, os.WriteFile(cfg.Certificate, ssh.MarshalAuthorizedKey(cert), 0600))
+	require.NoError(t, connectSFTPTest(t, cfg))
+}
+
+func TestSFTPAgent(t *testing.T) {
+	clientKey, clientSigner := generateSFTPTestKey(t)
+	server := startSFTPTestServer(t, clientSigner.PublicKey(), nil)
+
+	keyring := agent.NewKeyring()
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: clientKey}))
+	agentSocket := path.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", agentSocket)
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	cfg := server.config(t)
+	cfg.UseAgent = true
+	cfg.HostKeyFingerprint = ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	t.Setenv("SSH_AUTH_SOCK", "")
+	require.ErrorContains(t, connectSFTPTest(t, cfg), "SSH_AUTH_SOCK")
+	t.Setenv("SSH_AUTH_SOCK", agentSocket)
+	require.NoError(t, connectSFTPTest(t, cfg))
+}
@@ -36,3 +36,9 @@ type RemoteStorage interface {
 	PutFileAbsolute(ctx context.Context, key string, r io.ReadCloser) error
 	CopyObject(ctx context.Context, srcSize int64, srcBucket, srcKey, dstKey string) (int64, error)
 }
+
+// StaleUploadsAborter - remote storage which keeps billed multipart uploads or uncommitted blocks after killed upload,
+// non-empty backupName limits abort to path/backupName and object_disk_path/backupName
+type StaleUploadsAborter interface {
+	AbortStaleUploads(ctx context.Context, backupName string, olderThan time.Duration) (int, error)
+}
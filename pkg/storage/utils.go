@@ -5,6 +5,7 @@ import (
 	"github.com/klauspost/compress/zstd"
 	"github.com/mholt/archiver/v4"
 	"github.com/rs/zerolog/log"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -128,3 +129,24 @@ func checkArchiveExtension(ext, format string) bool {
 	}
 	return false
 }
+
+// staleUploadsPrefixes - list prefixes for AbortStaleUploads, empty prefix means whole bucket or container,
+// non-empty backupName narrows path and object_disk_path to path/backupName/ and object_disk_path/backupName/
+func staleUploadsPrefixes(storagePath, objectDiskPath, backupName string) []string {
+	paths := []string{storagePath}
+	if objectDiskPath != "" && objectDiskPath != storagePath {
+		paths = append(paths, objectDiskPath)
+	}
+	prefixes := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if backupName != "" {
+			p = path.Join(p, backupName)
+		}
+		if p == "" || p == "/" {
+			prefixes = append(prefixes, "")
+			continue
+		}
+		prefixes = append(prefixes, strings.TrimSuffix(p, "/")+"/")
+	}
+	return prefixes
+}
@@ -125,3 +125,10 @@ func TestGetBackupsToDeleteWithRecursiveRequiredBackups(t *testing.T) {
 	}
 	assert.Equal(t, expectedData, GetBackupsToDeleteRemote(testData, 6))
 }
+
+func TestStaleUploadsPrefixes(t *testing.T) {
+	assert.Equal(t, []string{"backup/shard1/", "object_disks/shard1/"}, staleUploadsPrefixes("backup/shard1", "object_disks/shard1", ""))
+	assert.Equal(t, []string{"backup/shard1/test_backup/", "object_disks/shard1/test_backup/"}, staleUploadsPrefixes("backup/shard1", "object_disks/shard1", "test_backup"))
+	assert.Equal(t, []string{""}, staleUploadsPrefixes("/", "", ""))
+	assert.Equal(t, []string{"test_backup/"}, staleUploadsPrefixes("", "", "test_backup"))
+}